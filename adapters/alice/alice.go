@@ -0,0 +1,30 @@
+// Package stackalice converts between stack chains and justinas/alice
+// chains, so that an application can move from one to the other a few
+// middleware at a time.
+//
+// Alice middleware have no access to the stack Context as an argument, but
+// can reach it with stack.FromRequest when run inside a stack chain.
+package stackalice
+
+import (
+	"net/http"
+
+	"github.com/alexedwards/stack"
+	"github.com/justinas/alice"
+)
+
+// FromAlice returns a stack chain which runs the middleware in ac. Further
+// middleware can be added with Append as usual:
+//
+//	st := stackalice.FromAlice(alice.New(timeoutHandler, nosurf.NewPure)).Append(tokenMiddleware)
+func FromAlice(ac alice.Chain) stack.Chain {
+	return stack.New(stack.Adapt(func(next http.Handler) http.Handler {
+		return ac.Then(next)
+	}))
+}
+
+// ToAlice returns an alice chain which runs the middleware in c, using a
+// new stack Context for each request.
+func ToAlice(c stack.Chain) alice.Chain {
+	return alice.New(c.Std())
+}
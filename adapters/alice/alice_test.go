@@ -0,0 +1,63 @@
+package stackalice
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alexedwards/stack"
+	"github.com/justinas/alice"
+)
+
+func assertEquals(t *testing.T, e interface{}, o interface{}) {
+	if e != o {
+		t.Errorf("\n...expected = %v\n...obtained = %v", e, o)
+	}
+}
+
+func serve(h http.Handler) string {
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec.Body.String()
+}
+
+func wobbleMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "wobble>")
+		next.ServeHTTP(w, r)
+	})
+}
+
+func wibbleMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "wibble>")
+		next.ServeHTTP(w, r)
+	})
+}
+
+func bishMiddleware(ctx *stack.Context, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx.Put("bish", "bash")
+		fmt.Fprint(w, "bish>")
+		next.ServeHTTP(w, r)
+	})
+}
+
+func handler(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "handler [bish=%v]", ctx.Get("bish"))
+}
+
+func TestFromAlice(t *testing.T) {
+	st := FromAlice(alice.New(wobbleMiddleware, wibbleMiddleware)).Append(bishMiddleware).Then(handler)
+	assertEquals(t, "wobble>wibble>bish>handler [bish=bash]", serve(st))
+}
+
+func TestToAlice(t *testing.T) {
+	ac := alice.New(wobbleMiddleware).Extend(ToAlice(stack.New(bishMiddleware)))
+	h := ac.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "handler [bish=%v]", stack.FromRequest(r).Get("bish"))
+	})
+	assertEquals(t, "wobble>bish>handler [bish=bash]", serve(h))
+}
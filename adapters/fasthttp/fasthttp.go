@@ -0,0 +1,32 @@
+// Package stackfasthttp serves stack chains from a valyala/fasthttp server.
+//
+//	fasthttp.ListenAndServe(":4000", stackfasthttp.Handler(stack.New(token).Then(show)))
+//
+// Each request is translated into a net/http request and served by the
+// chain as usual, with the response copied back into the fasthttp
+// RequestCtx. The translation is done by fasthttp's own fasthttpadaptor
+// package, which means:
+//
+//   - The request body is read in full by fasthttp before the chain runs,
+//     so it is limited by the server's MaxRequestBodySize rather than by
+//     middleware such as Decompress.
+//   - The response is buffered in full and sent once the chain returns.
+//     Flushing has no effect, so streaming responses (including Server-Sent
+//     Events) aren't supported.
+//   - Hijacking the connection isn't supported, so WebSocket upgrades must
+//     be handled by fasthttp directly.
+//
+// The translation costs an allocation or two per request, so it only pays
+// off where the rest of the service benefits from fasthttp.
+package stackfasthttp
+
+import (
+	"github.com/alexedwards/stack"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// Handler adapts hc into a fasthttp.RequestHandler.
+func Handler(hc stack.HandlerChain) fasthttp.RequestHandler {
+	return fasthttpadaptor.NewFastHTTPHandler(hc)
+}
@@ -0,0 +1,45 @@
+package stackfasthttp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/alexedwards/stack"
+	"github.com/valyala/fasthttp"
+)
+
+func assertEquals(t *testing.T, e interface{}, o interface{}) {
+	if e != o {
+		t.Errorf("\n...expected = %v\n...obtained = %v", e, o)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	bishMiddleware := func(ctx *stack.Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx.Put("bish", r.Header.Get("X-Bish"))
+			next.ServeHTTP(w, r)
+		})
+	}
+	hc := stack.New(bishMiddleware).Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Flip", "flop")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "%s %s [bish=%v] %s", r.Method, r.URL.Path, ctx.Get("bish"), body)
+	})
+
+	var req fasthttp.Request
+	req.Header.SetMethod("POST")
+	req.SetRequestURI("/users?bish=bash")
+	req.Header.Set("X-Bish", "bash")
+	req.SetBodyString("body")
+	var fctx fasthttp.RequestCtx
+	fctx.Init(&req, nil, nil)
+
+	Handler(hc)(&fctx)
+	assertEquals(t, http.StatusCreated, fctx.Response.StatusCode())
+	assertEquals(t, "flop", string(fctx.Response.Header.Peek("X-Flip")))
+	assertEquals(t, "POST /users [bish=bash] body", string(fctx.Response.Body()))
+}
@@ -0,0 +1,76 @@
+// Package stackmux serves stack chains from a gorilla/mux Router.
+//
+//	router := mux.NewRouter()
+//	stackmux.Handle(router, "/users/{id}", stack.New(auth).Then(showUser)).Methods("GET").Name("user")
+//
+// Route variables are copied into the chain's Context, where they can be
+// read with stack.Param, and the name of the matched route can be read with
+// RouteName, so handlers don't need to import gorilla/mux themselves.
+package stackmux
+
+import (
+	"net/http"
+
+	"github.com/alexedwards/stack"
+	"github.com/gorilla/mux"
+)
+
+const routeNameKey = "stack.mux.routeName"
+
+// Handle registers hc on router for path, and returns the mux.Route so
+// that matchers and a name can be added as usual.
+func Handle(router *mux.Router, path string, hc stack.HandlerChain) *mux.Route {
+	return router.Handle(path, Handler(hc))
+}
+
+// Handler adapts hc into an http.Handler which copies the route variables
+// and route name for each request into the chain's Context.
+func Handler(hc stack.HandlerChain) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hc := stack.InjectParams(hc, vars(r))
+		if name := routeName(r); name != "" {
+			hc = stack.Inject(hc, routeNameKey, name)
+		}
+		hc.ServeHTTP(w, r)
+	})
+}
+
+// Vars returns middleware which copies the route variables and route name
+// into the Context. It is for chains which are registered on a mux.Router
+// without using Handle or Handler, such as with ThenHandler.
+func Vars() func(*stack.Context, http.Handler) http.Handler {
+	return func(ctx *stack.Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			stack.SetParams(ctx, vars(r))
+			if name := routeName(r); name != "" {
+				ctx.Put(routeNameKey, name)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RouteName returns the name of the mux.Route which matched the current
+// request, or an empty string if the route has no name.
+func RouteName(ctx *stack.Context) string {
+	name, _ := ctx.Get(routeNameKey).(string)
+	return name
+}
+
+// vars returns a copy of the request's route variables, so that changes
+// made through the Context don't affect mux.Vars.
+func vars(r *http.Request) map[string]string {
+	v := mux.Vars(r)
+	params := make(map[string]string, len(v))
+	for key, val := range v {
+		params[key] = val
+	}
+	return params
+}
+
+func routeName(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		return route.GetName()
+	}
+	return ""
+}
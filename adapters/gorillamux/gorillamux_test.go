@@ -0,0 +1,53 @@
+package stackmux
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alexedwards/stack"
+	"github.com/gorilla/mux"
+)
+
+func assertEquals(t *testing.T, e interface{}, o interface{}) {
+	if e != o {
+		t.Errorf("\n...expected = %v\n...obtained = %v", e, o)
+	}
+}
+
+func showHandler(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "[id=%s][route=%s]", stack.Param(ctx, "id"), RouteName(ctx))
+}
+
+func TestHandler(t *testing.T) {
+	h := Handler(stack.New().Then(showHandler))
+	r, _ := http.NewRequest("GET", "/users/42", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, mux.SetURLVars(r, map[string]string{"id": "42"}))
+	assertEquals(t, "[id=42][route=]", rec.Body.String())
+}
+
+func TestVars(t *testing.T) {
+	h := stack.New(Vars()).Then(showHandler)
+	r, _ := http.NewRequest("GET", "/users/42", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, mux.SetURLVars(r, map[string]string{"id": "42"}))
+	assertEquals(t, "[id=42][route=]", rec.Body.String())
+}
+
+func TestHandleRouteName(t *testing.T) {
+	router := mux.NewRouter()
+	Handle(router, "/users", stack.New().Then(showHandler)).Methods("GET").Name("user.list")
+	router.Handle("/other", stack.New(Vars()).Then(showHandler)).Name("other")
+
+	r, _ := http.NewRequest("GET", "/users", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, r)
+	assertEquals(t, "[id=][route=user.list]", rec.Body.String())
+
+	r, _ = http.NewRequest("GET", "/other", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, r)
+	assertEquals(t, "[id=][route=other]", rec.Body.String())
+}
@@ -0,0 +1,32 @@
+// Package stackhttprouter serves stack chains from julienschmidt/httprouter.
+//
+//	router := httprouter.New()
+//	router.GET("/users/:id", stackhttprouter.Handle(stack.New(auth).Then(showUser)))
+//
+// Route parameters are copied into the chain's Context, where they can be
+// read with stack.Param (or Param in this package).
+package stackhttprouter
+
+import (
+	"net/http"
+
+	"github.com/alexedwards/stack"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Handle adapts hc into an httprouter.Handle.
+func Handle(hc stack.HandlerChain) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		params := make(map[string]string, len(ps))
+		for _, p := range ps {
+			params[p.Key] = p.Value
+		}
+		stack.InjectParams(hc, params).ServeHTTP(w, r)
+	}
+}
+
+// Param returns the value of the named route parameter, or an empty string
+// if there isn't one. It is equivalent to stack.Param.
+func Param(ctx *stack.Context, name string) string {
+	return stack.Param(ctx, name)
+}
@@ -0,0 +1,34 @@
+package stackhttprouter
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alexedwards/stack"
+	"github.com/julienschmidt/httprouter"
+)
+
+func assertEquals(t *testing.T, e interface{}, o interface{}) {
+	if e != o {
+		t.Errorf("\n...expected = %v\n...obtained = %v", e, o)
+	}
+}
+
+func TestHandle(t *testing.T) {
+	hc := stack.New().Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "[id=%s][post=%s][missing=%s]", Param(ctx, "id"), stack.Param(ctx, "post"), Param(ctx, "missing"))
+	})
+	h := Handle(hc)
+
+	r, _ := http.NewRequest("GET", "/users/42/posts/7", nil)
+	rec := httptest.NewRecorder()
+	h(rec, r, httprouter.Params{{Key: "id", Value: "42"}, {Key: "post", Value: "7"}})
+	assertEquals(t, "[id=42][post=7][missing=]", rec.Body.String())
+
+	// Parameters from one request must not leak into the next.
+	rec = httptest.NewRecorder()
+	h(rec, r, httprouter.Params{{Key: "id", Value: "43"}})
+	assertEquals(t, "[id=43][post=][missing=]", rec.Body.String())
+}
@@ -0,0 +1,241 @@
+package stack
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// AssetOption configures an Assets.
+type AssetOption func(*Assets)
+
+// AssetPrefix sets the URL path the assets are served under, which
+// AssetURL prepends to file names. The default is "/assets/".
+func AssetPrefix(prefix string) AssetOption {
+	return func(a *Assets) {
+		a.prefix = prefix
+	}
+}
+
+// AssetManifest makes NewAssets read the mapping from file names to
+// fingerprinted names from the named JSON file in the asset root, as
+// written by most front-end build tools:
+//
+//	{"app.css": "app.3f2a9c1e.css", "js/app.js": "js/app.8d1b2c0f.js"}
+//
+// The fingerprinted files are served as they are. Without a manifest,
+// NewAssets fingerprints every file itself.
+func AssetManifest(name string) AssetOption {
+	return func(a *Assets) {
+		a.manifest = name
+	}
+}
+
+// Assets serves static files under fingerprinted names, which include a
+// hash of their content, so that they can be cached by clients forever
+// and still be updated by a deploy. Create one with NewAssets, serve it
+// with ThenAssets and make it available to handlers and templates with
+// UseAssets.
+type Assets struct {
+	root     http.FileSystem
+	prefix   string
+	manifest string
+	// urls maps file names to fingerprinted names, and files maps
+	// fingerprinted names back to the files in root.
+	urls  map[string]string
+	files map[string]string
+	etags map[string]string
+}
+
+// NewAssets returns an Assets for the files in root. Unless a manifest is
+// configured, every file is read and given a fingerprinted name (so
+// "css/app.css" becomes something like "css/app.3f2a9c1e5b7d.css");
+// files whose names already look fingerprinted are left as they are.
+// Reading the files happens once, so that mistakes are caught at startup.
+func NewAssets(root http.FileSystem, opts ...AssetOption) (*Assets, error) {
+	a := &Assets{
+		root:   root,
+		prefix: "/assets/",
+		urls:   make(map[string]string),
+		files:  make(map[string]string),
+		etags:  make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if !strings.HasPrefix(a.prefix, "/") {
+		a.prefix = "/" + a.prefix
+	}
+	if !strings.HasSuffix(a.prefix, "/") {
+		a.prefix += "/"
+	}
+
+	if a.manifest != "" {
+		if err := a.readManifest(); err != nil {
+			return nil, err
+		}
+		return a, nil
+	}
+	if err := a.walk(""); err != nil {
+		return nil, fmt.Errorf("stack: fingerprinting assets: %w", err)
+	}
+	return a, nil
+}
+
+func (a *Assets) readManifest() error {
+	f, err := a.root.Open("/" + strings.TrimPrefix(a.manifest, "/"))
+	if err != nil {
+		return fmt.Errorf("stack: reading asset manifest: %w", err)
+	}
+	defer f.Close()
+	var m map[string]string
+	if err := json.NewDecoder(f).Decode(&m); err != nil {
+		return fmt.Errorf("stack: reading asset manifest %s: %w", a.manifest, err)
+	}
+	for name, fingerprinted := range m {
+		name = strings.TrimPrefix(name, "/")
+		fingerprinted = strings.TrimPrefix(fingerprinted, "/")
+		a.urls[name] = fingerprinted
+		a.files[fingerprinted] = fingerprinted
+	}
+	return nil
+}
+
+// walk fingerprints the files in the directory dir, and its
+// subdirectories.
+func (a *Assets) walk(dir string) error {
+	d, err := a.root.Open("/" + dir)
+	if err != nil {
+		return err
+	}
+	fis, err := d.Readdir(-1)
+	d.Close()
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		name := path.Join(dir, fi.Name())
+		if fi.IsDir() {
+			if err := a.walk(name); err != nil {
+				return err
+			}
+			continue
+		}
+		if defaultFingerprint.MatchString(name) {
+			a.urls[name] = name
+			a.files[name] = name
+			continue
+		}
+		sum, err := a.hash(name)
+		if err != nil {
+			return err
+		}
+		ext := path.Ext(name)
+		fingerprinted := strings.TrimSuffix(name, ext) + "." + sum + ext
+		a.urls[name] = fingerprinted
+		a.files[fingerprinted] = name
+		a.etags[fingerprinted] = `"` + sum + `"`
+	}
+	return nil
+}
+
+func (a *Assets) hash(name string) (string, error) {
+	f, err := a.root.Open("/" + name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12], nil
+}
+
+// URL returns the URL path of the fingerprinted copy of the named file
+// (such as "/assets/app.3f2a9c1e5b7d.css" for "app.css"). Names which
+// aren't known are returned under the prefix unchanged, and are served
+// without long-lived caching.
+func (a *Assets) URL(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if fingerprinted, ok := a.urls[name]; ok {
+		name = fingerprinted
+	}
+	return a.prefix + name
+}
+
+// UseAssets makes a available to AssetURL, and to templates rendered by the
+// render package, for requests handled by the chain.
+func (c Chain) UseAssets(a *Assets) Chain {
+	c.assets = a
+	return c
+}
+
+// AssetURL returns the URL of the fingerprinted copy of the named asset,
+// using the Assets set on the chain with UseAssets (or ThenAssets). Without
+// one, name is returned unchanged.
+func AssetURL(ctx *Context, name string) string {
+	if ctx.assets == nil {
+		return name
+	}
+	return ctx.assets.URL(name)
+}
+
+// ThenAssets finishes the chain with a handler serving a, and should be
+// mounted at the Assets' prefix:
+//
+//	mux.Handle(stack.Mount("/assets", stack.New().ThenAssets(assets)))
+//
+// Fingerprinted names are served with a one year, immutable Cache-Control
+// header and an ETag derived from the content, and the ETag middleware is
+// skipped for them. The original names are served too, for references
+// which can't be rewritten (such as url() in a stylesheet), but must be
+// revalidated by clients.
+func (c Chain) ThenAssets(a *Assets) HandlerChain {
+	cfg := &fileConfig{root: a.root}
+	c.assets = a
+	c.h = func(ctx *Context) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			a.serve(ctx, cfg, w, r)
+		})
+	}
+	return newHandlerChain(c)
+}
+
+func (a *Assets) serve(ctx *Context, cfg *fileConfig, w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		Error(ctx, w, r, NewHTTPError(http.StatusMethodNotAllowed, nil))
+		return
+	}
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if file, ok := a.files[name]; ok {
+		SkipETag(ctx)
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		if etag, ok := a.etags[name]; ok {
+			w.Header().Set("ETag", etag)
+		}
+		name = file
+	}
+
+	f, fi, err := cfg.open("/" + name)
+	if err == nil && fi.IsDir() {
+		f.Close()
+		err = os.ErrNotExist
+	}
+	if err != nil {
+		// Don't let an error page be cached as the asset.
+		w.Header().Del("Cache-Control")
+		w.Header().Del("ETag")
+		Error(ctx, w, r, fileError(err))
+		return
+	}
+	defer f.Close()
+	cfg.serveFile(w, r, f, fi)
+}
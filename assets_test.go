@@ -0,0 +1,81 @@
+package stack
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAssets(t *testing.T) {
+	a, err := NewAssets(http.Dir("testdata/static"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, "/assets/css/app.3f2a9c1e.css", a.URL("css/app.3f2a9c1e.css"))
+	assertEquals(t, "/assets/missing.js", a.URL("/missing.js"))
+	u := a.URL("css/site.css")
+	assertEquals(t, true, u != "/assets/css/site.css")
+	assertEquals(t, true, defaultFingerprint.MatchString(u))
+
+	st := New().ThenAssets(a)
+	rec := serveFile(st, strings.TrimPrefix(u, "/assets"))
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "body{}\n", rec.Body.String())
+	assertEquals(t, "public, max-age=31536000, immutable", rec.Header().Get("Cache-Control"))
+	assertEquals(t, `"`+u[len("/assets/css/site."):len(u)-len(".css")]+`"`, rec.Header().Get("ETag"))
+	assertEquals(t, "text/css; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	rec = serveFile(st, "/css/site.css")
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "no-cache", rec.Header().Get("Cache-Control"))
+
+	rec = serveFile(st, "/css/site.0123456789ab.css")
+	assertEquals(t, 404, rec.Code)
+	assertEquals(t, "", rec.Header().Get("Cache-Control"))
+
+	rec = serveFile(st, "/css")
+	assertEquals(t, 404, rec.Code)
+}
+
+func TestAssetsManifest(t *testing.T) {
+	fsys := fstest.MapFS{
+		"manifest.json":         {Data: []byte(`{"app.js": "/js/app.8d1b2c0f.js"}`)},
+		"js/app.8d1b2c0f.js":    {Data: []byte("bish()")},
+		"js/unfingerprinted.js": {Data: []byte("bash()")},
+	}
+	a, err := NewAssets(http.FS(fsys), AssetManifest("manifest.json"), AssetPrefix("static"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, "/static/js/app.8d1b2c0f.js", a.URL("app.js"))
+
+	st := New().ThenAssets(a)
+	rec := serveFile(st, "/js/app.8d1b2c0f.js")
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "bish()", rec.Body.String())
+	assertEquals(t, "public, max-age=31536000, immutable", rec.Header().Get("Cache-Control"))
+
+	rec = serveFile(st, "/js/unfingerprinted.js")
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "no-cache", rec.Header().Get("Cache-Control"))
+
+	_, err = NewAssets(http.FS(fsys), AssetManifest("missing.json"))
+	assertEquals(t, true, err != nil)
+}
+
+func TestAssetURL(t *testing.T) {
+	a, err := NewAssets(http.Dir("testdata/static"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var with, without string
+	recordGet(New().UseAssets(a).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		with = AssetURL(ctx, "css/site.css")
+	}))
+	recordGet(New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		without = AssetURL(ctx, "css/site.css")
+	}))
+	assertEquals(t, a.URL("css/site.css"), with)
+	assertEquals(t, "css/site.css", without)
+}
@@ -0,0 +1,142 @@
+package stack
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrForbidden is passed to the chain's error handler when a policy
+// denies a request. It is wrapped in an HTTPError with status 401 if the
+// request hasn't been authenticated, or 403 if it has.
+var ErrForbidden = errors.New("stack: access denied by policy")
+
+// Policy decides whether a request may proceed. It can inspect the
+// authenticated principal (see Principal) and anything else stored in the
+// Context by earlier middleware, as well as the request itself.
+type Policy interface {
+	Allow(ctx *Context, r *http.Request) (bool, error)
+}
+
+// PolicyFunc adapts an ordinary function into a Policy.
+type PolicyFunc func(ctx *Context, r *http.Request) (bool, error)
+
+// Allow calls fn(ctx, r).
+func (fn PolicyFunc) Allow(ctx *Context, r *http.Request) (bool, error) {
+	return fn(ctx, r)
+}
+
+// Authorize returns middleware which only lets requests allowed by policy
+// continue down the chain. Denied requests are passed to the chain's error
+// handler with ErrForbidden, and errors returned by the policy are passed
+// on as they are.
+func Authorize(policy Policy) chainMiddleware {
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, err := policy.Allow(ctx, r)
+			if err != nil {
+				Error(ctx, w, r, err)
+				return
+			}
+			if !ok {
+				status := http.StatusForbidden
+				if Principal(ctx) == nil {
+					status = http.StatusUnauthorized
+				}
+				Error(ctx, w, r, NewHTTPError(status, ErrForbidden))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Authenticated is a Policy which allows any request with a principal.
+var Authenticated Policy = PolicyFunc(func(ctx *Context, r *http.Request) (bool, error) {
+	return Principal(ctx) != nil, nil
+})
+
+// AllOf returns a Policy which allows a request only if every one of
+// policies does. Policies are evaluated in order, stopping at the first
+// denial or error.
+func AllOf(policies ...Policy) Policy {
+	return PolicyFunc(func(ctx *Context, r *http.Request) (bool, error) {
+		for _, p := range policies {
+			if ok, err := p.Allow(ctx, r); err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	})
+}
+
+// AnyOf returns a Policy which allows a request if at least one of
+// policies does. Policies are evaluated in order, stopping at the first
+// which allows the request or returns an error.
+func AnyOf(policies ...Policy) Policy {
+	return PolicyFunc(func(ctx *Context, r *http.Request) (bool, error) {
+		for _, p := range policies {
+			if ok, err := p.Allow(ctx, r); err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	})
+}
+
+// Not returns a Policy which allows a request only if policy denies it.
+func Not(policy Policy) Policy {
+	return PolicyFunc(func(ctx *Context, r *http.Request) (bool, error) {
+		ok, err := policy.Allow(ctx, r)
+		if err != nil {
+			return false, err
+		}
+		return !ok, nil
+	})
+}
+
+// FromNetworks returns a Policy which allows requests from clients whose
+// IP address is in one of networks, given in CIDR notation ("10.0.0.0/8")
+// or as single addresses ("::1"). The address is taken from the request's
+// RemoteAddr, so behind a proxy it is the proxy's address unless earlier
+// middleware rewrite RemoteAddr. FromNetworks panics if a network is
+// invalid.
+func FromNetworks(networks ...string) Policy {
+	nets := make([]*net.IPNet, len(networks))
+	for i, network := range networks {
+		if !strings.Contains(network, "/") {
+			ip := net.ParseIP(network)
+			if ip == nil {
+				panic("stack: invalid network: " + network)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets[i] = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(network)
+		if err != nil {
+			panic("stack: invalid network: " + network)
+		}
+		nets[i] = ipnet
+	}
+	return PolicyFunc(func(ctx *Context, r *http.Request) (bool, error) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return false, nil
+		}
+		for _, ipnet := range nets {
+			if ipnet.Contains(ip) {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+}
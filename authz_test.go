@@ -0,0 +1,80 @@
+package stack
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func principalMiddleware(principal interface{}) chainMiddleware {
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if principal != nil {
+				SetPrincipal(ctx, principal)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+var (
+	allow   = PolicyFunc(func(*Context, *http.Request) (bool, error) { return true, nil })
+	deny    = PolicyFunc(func(*Context, *http.Request) (bool, error) { return false, nil })
+	failing = PolicyFunc(func(*Context, *http.Request) (bool, error) { return false, errors.New("bish") })
+	isAdmin = PolicyFunc(func(ctx *Context, r *http.Request) (bool, error) {
+		return Principal(ctx) == "admin", nil
+	})
+)
+
+func authorizeStatus(principal interface{}, policy Policy) int {
+	st := New(principalMiddleware(principal), Authorize(policy)).Then(bishHandler)
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	return rec.Code
+}
+
+func TestAuthorize(t *testing.T) {
+	assertEquals(t, 200, authorizeStatus("admin", isAdmin))
+	assertEquals(t, 403, authorizeStatus("flip", isAdmin))
+	assertEquals(t, 401, authorizeStatus(nil, isAdmin))
+	assertEquals(t, 500, authorizeStatus("admin", failing))
+	assertEquals(t, 200, authorizeStatus("flip", Authenticated))
+	assertEquals(t, 401, authorizeStatus(nil, Authenticated))
+}
+
+func TestPolicyCombinators(t *testing.T) {
+	assertEquals(t, 200, authorizeStatus("flip", AllOf(allow, allow)))
+	assertEquals(t, 403, authorizeStatus("flip", AllOf(allow, deny)))
+	assertEquals(t, 200, authorizeStatus("flip", AnyOf(deny, allow)))
+	assertEquals(t, 403, authorizeStatus("flip", AnyOf(deny, deny)))
+	assertEquals(t, 500, authorizeStatus("flip", AnyOf(deny, failing, allow)))
+	assertEquals(t, 200, authorizeStatus("flip", Not(deny)))
+	assertEquals(t, 403, authorizeStatus("flip", Not(allow)))
+	assertEquals(t, 200, authorizeStatus("admin", AllOf(Authenticated, AnyOf(isAdmin, deny))))
+}
+
+func TestFromNetworks(t *testing.T) {
+	policy := FromNetworks("10.0.0.0/8", "192.0.2.1", "2001:db8::/32")
+	for addr, want := range map[string]bool{
+		"10.1.2.3:80":      true,
+		"192.0.2.1:80":     true,
+		"192.0.2.2:80":     false,
+		"[2001:db8::1]:80": true,
+		"[2001:db9::1]:80": false,
+		"10.1.2.3":         true,
+		"not an address":   false,
+	} {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.RemoteAddr = addr
+		ok, err := policy.Allow(nil, r)
+		assertEquals(t, nil, err)
+		assertEquals(t, want, ok)
+	}
+
+	defer func() {
+		assertEquals(t, "stack: invalid network: 10.0.0.0/33", recover())
+	}()
+	FromNetworks("10.0.0.0/33")
+}
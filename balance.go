@@ -0,0 +1,291 @@
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const backendKey = "stack.backend"
+
+// ErrNoBackends is passed to the chain's error handler (wrapped in an
+// HTTPError with status 503) when a Balancer has no backends.
+var ErrNoBackends = errors.New("stack: no backends available")
+
+// Backend is an upstream server for a Balancer. Weight is only used by
+// BalanceWeighted, and defaults to 1.
+type Backend struct {
+	URL    string
+	Weight int
+}
+
+// BackendStats describes the state of one of a Balancer's backends.
+type BackendStats struct {
+	URL      string
+	Healthy  bool
+	Inflight int
+	Requests int64
+	Failures int64
+}
+
+// BalancerOption configures a Balancer.
+type BalancerOption func(*Balancer)
+
+// BalanceLeastInflight sends each request to the backend with the fewest
+// requests in flight, rather than taking turns.
+func BalanceLeastInflight() BalancerOption {
+	return func(b *Balancer) {
+		b.pick = (*Balancer).leastInflight
+	}
+}
+
+// BalanceWeighted shares requests between backends in proportion to their
+// weights, interleaving them smoothly rather than in bursts.
+func BalanceWeighted() BalancerOption {
+	return func(b *Balancer) {
+		b.pick = (*Balancer).weighted
+	}
+}
+
+// BalanceHealth sets how many consecutive failures (errors reaching a
+// backend, or 5xx responses) mark a backend unhealthy, and for how long it
+// is then skipped. The defaults are 3 failures and 10 seconds.
+func BalanceHealth(maxFails int, cooldown time.Duration) BalancerOption {
+	return func(b *Balancer) {
+		b.maxFails, b.cooldown = maxFails, cooldown
+	}
+}
+
+type backend struct {
+	url      *url.URL
+	weight   int
+	current  int
+	inflight int
+	fails    int
+	down     time.Time
+	requests int64
+	failures int64
+}
+
+// Balancer shares requests between a set of backends. It is used with
+// ThenBalance.
+type Balancer struct {
+	mu       sync.Mutex
+	backends []*backend
+	next     int
+	pick     func(*Balancer, []*backend) *backend
+	maxFails int
+	cooldown time.Duration
+}
+
+// NewBalancer returns a Balancer over backends, which by default takes
+// turns between them. It panics if a backend's URL is invalid.
+func NewBalancer(backends []Backend, opts ...BalancerOption) *Balancer {
+	b := &Balancer{pick: (*Balancer).roundRobin, maxFails: 3, cooldown: 10 * time.Second}
+	for _, be := range backends {
+		u, err := url.Parse(be.URL)
+		if err != nil || u.Host == "" {
+			panic(fmt.Sprintf("stack: invalid backend URL %q", be.URL))
+		}
+		weight := be.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		b.backends = append(b.backends, &backend{url: u, weight: weight})
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// choose picks a backend for a request at now. Backends marked unhealthy
+// are skipped, unless they all are, in which case every backend is tried
+// rather than failing every request.
+func (b *Balancer) choose(now time.Time) *backend {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	healthy := make([]*backend, 0, len(b.backends))
+	for _, be := range b.backends {
+		if !now.Before(be.down) {
+			healthy = append(healthy, be)
+		}
+	}
+	if len(healthy) == 0 {
+		healthy = b.backends
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	be := b.pick(b, healthy)
+	be.inflight++
+	be.requests++
+	return be
+}
+
+func (b *Balancer) roundRobin(backends []*backend) *backend {
+	be := backends[b.next%len(backends)]
+	b.next++
+	return be
+}
+
+func (b *Balancer) leastInflight(backends []*backend) *backend {
+	best := backends[0]
+	for _, be := range backends[1:] {
+		if be.inflight < best.inflight {
+			best = be
+		}
+	}
+	return best
+}
+
+// weighted implements nginx's smooth weighted round-robin.
+func (b *Balancer) weighted(backends []*backend) *backend {
+	var best *backend
+	total := 0
+	for _, be := range backends {
+		be.current += be.weight
+		total += be.weight
+		if best == nil || be.current > best.current {
+			best = be
+		}
+	}
+	best.current -= total
+	return best
+}
+
+// done records the end of a request to be, and whether it failed.
+func (b *Balancer) done(be *backend, failed bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	be.inflight--
+	if !failed {
+		be.fails = 0
+		return
+	}
+	be.failures++
+	be.fails++
+	if be.fails >= b.maxFails {
+		be.down = now.Add(b.cooldown)
+		be.fails = 0
+	}
+}
+
+// Stats returns the current state of each backend, in the order they were
+// given to NewBalancer.
+func (b *Balancer) Stats() []BackendStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	stats := make([]BackendStats, len(b.backends))
+	for i, be := range b.backends {
+		stats[i] = BackendStats{
+			URL:      be.url.String(),
+			Healthy:  !now.Before(be.down),
+			Inflight: be.inflight,
+			Requests: be.requests,
+			Failures: be.failures,
+		}
+	}
+	return stats
+}
+
+// ThenBalance finishes the chain with a reverse proxy, as ThenProxy does,
+// which shares requests between b's backends. Backends are watched
+// passively: a run of failed requests marks a backend unhealthy (see
+// BalanceHealth) and it gets no more requests until it has cooled down.
+// The URL of the backend used for a request is available through
+// BackendURL.
+func (c Chain) ThenBalance(b *Balancer, opts ...ProxyOption) HandlerChain {
+	opts = append(opts, func(p *httputil.ReverseProxy) {
+		rt := p.Transport
+		if rt == nil {
+			rt = http.DefaultTransport
+		}
+		p.Transport = balancedTransport{b, rt}
+	})
+	return c.ThenProxy(func(ctx *Context, r *http.Request) (*url.URL, error) {
+		be := b.choose(Now(ctx))
+		if be == nil {
+			return nil, NewHTTPError(http.StatusServiceUnavailable, ErrNoBackends)
+		}
+		br := &balancedRequest{b: b, be: be}
+		ctx.Put(backendKey, br)
+		// The proxy may reject the request before it reaches the
+		// transport, so make sure it stops counting as in flight.
+		ctx.Defer(func() { br.done(false, Now(ctx)) })
+		return be.url, nil
+	}, opts...)
+}
+
+// balancedRequest reports the outcome of a request to the Balancer which
+// chose its backend, once.
+type balancedRequest struct {
+	b    *Balancer
+	be   *backend
+	once sync.Once
+}
+
+func (br *balancedRequest) done(failed bool, now time.Time) {
+	br.once.Do(func() { br.b.done(br.be, failed, now) })
+}
+
+// balancedTransport reports the outcome of each request to the Balancer
+// which chose its backend.
+type balancedTransport struct {
+	b  *Balancer
+	rt http.RoundTripper
+}
+
+func (bt balancedTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx := FromRequest(r)
+	br := ctx.Get(backendKey).(*balancedRequest)
+	res, err := bt.rt.RoundTrip(r)
+	if err != nil {
+		br.done(true, Now(ctx))
+		return nil, err
+	}
+	// The request is in flight until its body has been copied to the
+	// client (or, after a protocol switch, until the connection closes).
+	rb := &releaseBody{ReadCloser: res.Body, release: func() {
+		br.done(res.StatusCode >= 500, Now(ctx))
+	}}
+	res.Body = rb
+	// ReverseProxy needs to write to the body of a 101 Switching
+	// Protocols response.
+	if rwc, ok := rb.ReadCloser.(io.ReadWriteCloser); ok {
+		res.Body = releaseReadWriteBody{rb, rwc}
+	}
+	return res, nil
+}
+
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (rb *releaseBody) Close() error {
+	err := rb.ReadCloser.Close()
+	rb.release()
+	return err
+}
+
+type releaseReadWriteBody struct {
+	*releaseBody
+	io.Writer
+}
+
+// BackendURL returns the URL of the backend ThenBalance sent the request
+// to, or "" if it wasn't sent to one.
+func BackendURL(ctx *Context) string {
+	br, ok := ctx.Get(backendKey).(*balancedRequest)
+	if !ok {
+		return ""
+	}
+	return br.be.url.String()
+}
@@ -0,0 +1,157 @@
+package stack
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func backendServers(names ...string) ([]Backend, func()) {
+	var backends []Backend
+	var servers []*httptest.Server
+	for _, name := range names {
+		name := name
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(name, "broken") {
+				w.WriteHeader(http.StatusBadGateway)
+			}
+			io.WriteString(w, name)
+		}))
+		servers = append(servers, ts)
+		backends = append(backends, Backend{URL: ts.URL})
+	}
+	return backends, func() {
+		for _, ts := range servers {
+			ts.Close()
+		}
+	}
+}
+
+func balancedBodies(hc HandlerChain, n int) string {
+	var bodies []string
+	for i := 0; i < n; i++ {
+		rec := httptest.NewRecorder()
+		hc.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		bodies = append(bodies, rec.Body.String())
+	}
+	return strings.Join(bodies, " ")
+}
+
+func TestThenBalance(t *testing.T) {
+	backends, closeAll := backendServers("bish", "bash", "bosh")
+	defer closeAll()
+
+	var used string
+	st := New(func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			used = BackendURL(ctx)
+		})
+	}).ThenBalance(NewBalancer(backends))
+	assertEquals(t, "bish bash bosh bish", balancedBodies(st, 4))
+	assertEquals(t, backends[0].URL, used)
+
+	backends[0].Weight = 2
+	st = New().ThenBalance(NewBalancer(backends[:2], BalanceWeighted()))
+	assertEquals(t, "bish bash bish bish bash bish", balancedBodies(st, 6))
+
+	st = New().ThenBalance(NewBalancer(nil))
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	assertEquals(t, 503, rec.Code)
+}
+
+func TestBalanceLeastInflight(t *testing.T) {
+	b := NewBalancer([]Backend{{URL: "http://a"}, {URL: "http://b"}}, BalanceLeastInflight())
+	now := time.Now()
+	first := b.choose(now)
+	second := b.choose(now)
+	assertEquals(t, "http://a", first.url.String())
+	assertEquals(t, "http://b", second.url.String())
+	b.done(first, false, now)
+	assertEquals(t, "http://a", b.choose(now).url.String())
+}
+
+func TestBalanceHealth(t *testing.T) {
+	backends, closeAll := backendServers("broken", "bash")
+	defer closeAll()
+	clock := &settableClock{t: time.Now()}
+	b := NewBalancer(backends, BalanceHealth(2, time.Minute))
+	st := New().UseClock(clock).ThenBalance(b)
+
+	// After two failures the broken backend is skipped.
+	assertEquals(t, "broken bash broken bash bash bash", balancedBodies(st, 6))
+	stats := b.Stats()
+	assertEquals(t, false, stats[0].Healthy)
+	assertEquals(t, int64(2), stats[0].Failures)
+	assertEquals(t, int64(4), stats[1].Requests)
+	assertEquals(t, 0, stats[1].Inflight)
+
+	clock.t = clock.t.Add(time.Minute)
+	assertEquals(t, "broken bash", balancedBodies(st, 2))
+}
+
+func TestBalanceUpgrade(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: bish\r\n\r\n")
+		brw.Flush()
+		line, _ := brw.ReadString('\n')
+		brw.WriteString("echo " + line)
+		brw.Flush()
+	}))
+	defer backend.Close()
+	b := NewBalancer([]Backend{{URL: backend.URL}})
+	front := httptest.NewServer(New().ThenBalance(b))
+	defer front.Close()
+
+	conn, err := net.Dial("tcp", front.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: bish\r\nConnection: Upgrade\r\nUpgrade: bish\r\n\r\n")
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 101, res.StatusCode)
+	io.WriteString(conn, "bash\n")
+	line, _ := br.ReadString('\n')
+	assertEquals(t, "echo bash\n", line)
+}
+
+func TestBalanceReleasesRejectedRequests(t *testing.T) {
+	backends, closeAll := backendServers("bish")
+	defer closeAll()
+	b := NewBalancer(backends)
+	st := New().ThenBalance(b)
+
+	// ReverseProxy refuses to switch to an unprintable protocol before
+	// the request reaches the transport.
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "bish\x7f")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 502, rec.Code)
+	assertEquals(t, 0, b.Stats()[0].Inflight)
+}
+
+func TestNewBalancerInvalidURL(t *testing.T) {
+	defer func() {
+		assertEquals(t, `stack: invalid backend URL "bish"`, recover())
+	}()
+	NewBalancer([]Backend{{URL: "bish"}})
+}
@@ -0,0 +1,140 @@
+package stack
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// BindOption configures the body binding middleware, such as BindJSON.
+type BindOption func(*bindConfig)
+
+// BindMaxBytes sets the maximum size of the request body in bytes. Larger
+// bodies are rejected with a 413 Request Entity Too Large status. The
+// default is 1MB.
+func BindMaxBytes(n int64) BindOption {
+	return func(c *bindConfig) {
+		c.maxBytes = n
+	}
+}
+
+// BindStrict makes the middleware reject bodies with fields which don't
+// exist in the target type, rather than ignoring them.
+func BindStrict() BindOption {
+	return func(c *bindConfig) {
+		c.strict = true
+	}
+}
+
+type bindConfig struct {
+	maxBytes int64
+	strict   bool
+}
+
+func newBindConfig(opts []BindOption) *bindConfig {
+	cfg := &bindConfig{maxBytes: 1 << 20}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// BindJSON returns middleware which decodes the JSON request body into a
+// T, and stores it in the Context for the handler to retrieve with Body:
+//
+//	chain := stack.New(stack.BindJSON[createUser](stack.BindStrict()))
+//	...
+//	input := stack.Body[createUser](ctx)
+//
+// Requests whose Content-Type isn't JSON are passed to the chain's error
+// handler with a 415 Unsupported Media Type status, bodies over the size
+// limit with 413, and bodies which can't be decoded into a T (including an
+// empty body, or one with trailing data) with 400 Bad Request. Requests
+// without a Content-Type are assumed to be JSON.
+func BindJSON[T any](opts ...BindOption) chainMiddleware {
+	cfg := newBindConfig(opts)
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var v T
+			if err := cfg.decodeJSON(w, r, &v); err != nil {
+				Error(ctx, w, r, err)
+				return
+			}
+			ctx.Put(bodyKey[T](), v)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (cfg *bindConfig) decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	if ct := r.Header.Get("Content-Type"); ct != "" && !isJSON(ct) {
+		return NewHTTPError(http.StatusUnsupportedMediaType, fmt.Errorf("stack: expected a JSON body, not %s", ct))
+	}
+	if r.Body == nil {
+		return NewHTTPError(http.StatusBadRequest, errors.New("stack: request has no body"))
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, cfg.maxBytes))
+	if cfg.strict {
+		dec.DisallowUnknownFields()
+	}
+	err := dec.Decode(v)
+	if err == nil {
+		// Anything but whitespace after the value is an error.
+		if _, err = dec.Token(); err == io.EOF {
+			err = nil
+		} else if err == nil {
+			err = errors.New("unexpected data after JSON value")
+		}
+	}
+	if err == nil {
+		return nil
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return NewHTTPError(http.StatusRequestEntityTooLarge, err)
+	}
+	if err == io.EOF {
+		err = errors.New("empty body")
+	}
+	return NewHTTPError(http.StatusBadRequest, fmt.Errorf("stack: invalid JSON body: %w", err))
+}
+
+func isJSON(contentType string) bool {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+// Body returns the request body bound by BindJSON (or another binding
+// middleware) as a T. It panics if the chain has no binding middleware
+// for T, as that is a mistake in assembling the chain.
+func Body[T any](ctx *Context) T {
+	v, ok := ctx.Get(bodyKey[T]()).(T)
+	if !ok {
+		panic(fmt.Sprintf("stack: no %s body bound for the request", configType[T]()))
+	}
+	return v
+}
+
+// UpdateInput calls fn with a pointer to each T bound for the request by
+// BindJSON and BindQuery, and stores the values it leaves behind. It is for
+// middleware which cleans up input before Validate sees it, such as that in
+// the normalize package.
+func UpdateInput[T any](ctx *Context, fn func(v *T)) {
+	for _, key := range []string{bodyKey[T](), queryKey[T]()} {
+		v, ok := ctx.Get(key).(T)
+		if !ok {
+			continue
+		}
+		fn(&v)
+		ctx.Put(key, v)
+	}
+}
+
+func bodyKey[T any]() string {
+	t := configType[T]()
+	return "stack.body." + t.PkgPath() + "." + t.String()
+}
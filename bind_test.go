@@ -0,0 +1,86 @@
+package stack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type bindInput struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func postJSON(hc HandlerChain, contentType string, body string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	hc.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestBindJSON(t *testing.T) {
+	var got bindInput
+	handler := func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		got = Body[bindInput](ctx)
+	}
+	st := New(BindJSON[bindInput](BindMaxBytes(64))).Then(handler)
+
+	rec := postJSON(st, "application/json; charset=utf-8", `{"name": "bish", "count": 2, "other": true}`+"\n")
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, bindInput{"bish", 2}, got)
+
+	tests := []struct {
+		contentType string
+		body        string
+		status      int
+	}{
+		{"", `{"name": "bash"}`, 200},
+		{"application/problem+json", `{}`, 200},
+		{"text/plain", `{"name": "bash"}`, 415},
+		{"application/json", ``, 400},
+		{"application/json", `{"name": 1}`, 400},
+		{"application/json", `{"name": "bash"} {}`, 400},
+		{"application/json", `{"name": "` + strings.Repeat("b", 64) + `"}`, 413},
+	}
+	for _, test := range tests {
+		rec := postJSON(st, test.contentType, test.body)
+		assertEquals(t, test.status, rec.Code)
+	}
+
+	strict := New(BindJSON[bindInput](BindStrict())).Then(handler)
+	assertEquals(t, 400, postJSON(strict, "application/json", `{"other": true}`).Code)
+	assertEquals(t, 200, postJSON(strict, "application/json", `{"count": 3}`).Code)
+}
+
+func TestBodyWithoutBinding(t *testing.T) {
+	defer func() {
+		assertEquals(t, "stack: no stack.bindInput body bound for the request", recover())
+	}()
+	Body[bindInput](NewContext())
+}
+
+func TestUpdateInput(t *testing.T) {
+	var got bindInput
+	double := func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			UpdateInput(ctx, func(v *bindInput) { v.Count *= 2 })
+			next.ServeHTTP(w, r)
+		})
+	}
+	st := New(BindJSON[bindInput](), double).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		got = Body[bindInput](ctx)
+	})
+	postJSON(st, "", `{"name": "bish", "count": 2}`)
+	assertEquals(t, bindInput{"bish", 4}, got)
+
+	// Without a binding, fn isn't called.
+	called := false
+	recordGet(New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		UpdateInput(ctx, func(v *bindInput) { called = true })
+	}))
+	assertEquals(t, false, called)
+}
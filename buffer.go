@@ -0,0 +1,141 @@
+package stack
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+const bufferedBodyKey = "stack.bufferedBody"
+
+// ErrBodyNotBuffered is returned by BodyBytes when the chain doesn't use
+// the BufferBody middleware.
+var ErrBodyNotBuffered = errors.New("stack: request body not buffered")
+
+// BufferOption configures the BufferBody middleware.
+type BufferOption func(*bufferConfig)
+
+// BufferMemory sets how many bytes of the body are held in memory. The
+// rest is written to a temporary file. The default is 1MB.
+func BufferMemory(n int64) BufferOption {
+	return func(c *bufferConfig) {
+		c.memory = n
+	}
+}
+
+type bufferConfig struct {
+	limit  int64
+	memory int64
+}
+
+type bufferedBody struct {
+	mem  []byte
+	file *os.File
+	size int64
+}
+
+// reader returns a new reader over the whole body.
+func (bb *bufferedBody) reader() io.Reader {
+	r := io.Reader(bytes.NewReader(bb.mem))
+	if bb.file != nil {
+		n := int64(len(bb.mem))
+		r = io.MultiReader(r, io.NewSectionReader(bb.file, 0, bb.size-n))
+	}
+	return r
+}
+
+// BufferBody returns middleware which reads the request body, up to limit
+// bytes, before the rest of the chain runs, so that it can be read more
+// than once: by middleware verifying a signature, retrying the request or
+// recording it for auditing, say, as well as by the handler. Each reader
+// should use BodyReader or BodyBytes, and r.Body is replaced with a reader
+// over the buffered body. The request's GetBody is set too, so that the
+// request can be resent with an http.Client.
+//
+// Bodies larger than limit are passed to the chain's error handler with a
+// 413 Request Entity Too Large status. Anything over the memory threshold
+// (see BufferMemory) is spilled to a file in the request's TempDir.
+func BufferBody(limit int64, opts ...BufferOption) chainMiddleware {
+	cfg := &bufferConfig{limit: limit, memory: 1 << 20}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return LintAs(func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bb := &bufferedBody{}
+			if r.Body != nil && r.Body != http.NoBody {
+				if err := cfg.read(ctx, r.Body, bb); err != nil {
+					Error(ctx, w, r, err)
+					return
+				}
+				r.Body.Close()
+			}
+			ctx.Put(bufferedBodyKey, bb)
+			r.Body = io.NopCloser(bb.reader())
+			r.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bb.reader()), nil
+			}
+			r.ContentLength = bb.size
+			next.ServeHTTP(w, r)
+		})
+	}, BuffersBody)
+}
+
+func (cfg *bufferConfig) read(ctx *Context, body io.Reader, bb *bufferedBody) error {
+	src := io.LimitReader(body, cfg.limit+1)
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(src, cfg.memory))
+	if err != nil {
+		return NewHTTPError(http.StatusBadRequest, err)
+	}
+	bb.mem, bb.size = buf.Bytes(), n
+
+	if n == cfg.memory && n <= cfg.limit {
+		dir, err := TempDir(ctx)
+		if err != nil {
+			return err
+		}
+		f, err := os.CreateTemp(dir, "body-")
+		if err != nil {
+			return err
+		}
+		ctx.Defer(func() { f.Close() })
+		n, err = io.Copy(f, src)
+		if err != nil {
+			return NewHTTPError(http.StatusBadRequest, err)
+		}
+		bb.file = f
+		bb.size += n
+	}
+	if bb.size > cfg.limit {
+		return NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Errorf("stack: request body over %d bytes", cfg.limit))
+	}
+	return nil
+}
+
+// BodyReader returns a new reader over the request body buffered by
+// BufferBody, or nil if the body hasn't been buffered.
+func BodyReader(ctx *Context) io.Reader {
+	bb, ok := ctx.Get(bufferedBodyKey).(*bufferedBody)
+	if !ok {
+		return nil
+	}
+	return bb.reader()
+}
+
+// BodyBytes returns the request body buffered by BufferBody, reading it
+// back from disk if it was too large to keep in memory.
+func BodyBytes(ctx *Context) ([]byte, error) {
+	bb, ok := ctx.Get(bufferedBodyKey).(*bufferedBody)
+	if !ok {
+		return nil, ErrBodyNotBuffered
+	}
+	if bb.file == nil {
+		return bb.mem, nil
+	}
+	return io.ReadAll(bb.reader())
+}
@@ -0,0 +1,61 @@
+package stack
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBufferBody(t *testing.T) {
+	var seen []string
+	var ctx *Context
+	peek := func(c *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, err := BodyBytes(c)
+			assertEquals(t, nil, err)
+			seen = append(seen, string(b))
+			next.ServeHTTP(w, r)
+		})
+	}
+	handler := func(c *Context, w http.ResponseWriter, r *http.Request) {
+		ctx = c
+		b, _ := io.ReadAll(r.Body)
+		seen = append(seen, string(b))
+		body, _ := r.GetBody()
+		b, _ = io.ReadAll(body)
+		seen = append(seen, string(b))
+		b, _ = io.ReadAll(BodyReader(c))
+		seen = append(seen, string(b))
+		assertEquals(t, int64(len(b)), r.ContentLength)
+	}
+
+	for _, memory := range []int64{1 << 20, 4} {
+		seen = nil
+		st := New(BufferBody(16, BufferMemory(memory)), peek).Then(handler)
+		r, _ := http.NewRequest("POST", "/", strings.NewReader("bish bash bosh"))
+		rec := httptest.NewRecorder()
+		st.ServeHTTP(rec, r)
+		assertEquals(t, 200, rec.Code)
+		assertEquals(t, "bish bash bosh|bish bash bosh|bish bash bosh|bish bash bosh", strings.Join(seen, "|"))
+		bb := ctx.Get(bufferedBodyKey).(*bufferedBody)
+		assertEquals(t, memory == 4, bb.file != nil)
+		if bb.file != nil {
+			_, err := os.Stat(bb.file.Name())
+			assertEquals(t, true, os.IsNotExist(err))
+		}
+
+		r, _ = http.NewRequest("POST", "/", strings.NewReader(strings.Repeat("b", 17)))
+		rec = httptest.NewRecorder()
+		st.ServeHTTP(rec, r)
+		assertEquals(t, 413, rec.Code)
+	}
+}
+
+func TestBodyBytesNotBuffered(t *testing.T) {
+	_, err := BodyBytes(NewContext())
+	assertEquals(t, ErrBodyNotBuffered, err)
+	assertEquals(t, nil, BodyReader(NewContext()))
+}
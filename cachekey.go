@@ -0,0 +1,102 @@
+package stack
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const cacheKeyKey = "stack.cacheKey"
+
+// defaultTrackingParams are the query parameters which CanonicalQuery
+// drops by default. They identify where a visitor came from, and never
+// change the response.
+var defaultTrackingParams = []string{"utm_*", "gclid", "fbclid", "msclkid", "mc_cid", "mc_eid", "_ga"}
+
+// CanonicalOption configures the CanonicalQuery middleware.
+type CanonicalOption func(*canonicalConfig)
+
+// CanonicalDrop replaces the list of query parameters which are dropped
+// from the canonical query. Entries ending in "*" (such as "utm_*") match
+// any parameter with that prefix.
+func CanonicalDrop(params ...string) CanonicalOption {
+	return func(c *canonicalConfig) {
+		c.drop = params
+	}
+}
+
+// CanonicalRewrite makes the middleware pass on a copy of the request with
+// the canonical query string, so that handlers don't see the dropped
+// parameters either. Earlier middleware still see the original.
+func CanonicalRewrite() CanonicalOption {
+	return func(c *canonicalConfig) {
+		c.rewrite = true
+	}
+}
+
+type canonicalConfig struct {
+	drop    []string
+	rewrite bool
+}
+
+func (cfg *canonicalConfig) dropped(param string) bool {
+	for _, d := range cfg.drop {
+		if d == param || strings.HasSuffix(d, "*") && strings.HasPrefix(param, d[:len(d)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanonicalQuery returns middleware which computes a canonical key for GET
+// and HEAD requests, so that requests for the same thing can be recognised.
+// The key is the request's host and path, followed by its query parameters
+// sorted by name, without tracking parameters such as utm_source and gclid
+// (see CanonicalDrop). It is available through CacheKey, and RenderCache
+// caches pages by it.
+func CanonicalQuery(opts ...CanonicalOption) chainMiddleware {
+	cfg := &canonicalConfig{drop: defaultTrackingParams}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "GET" && r.Method != "HEAD" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			query := r.URL.Query()
+			for param := range query {
+				if cfg.dropped(param) {
+					delete(query, param)
+				}
+			}
+			// Encode sorts by name, keeping the order of repeated values,
+			// which may be significant.
+			canonical := query.Encode()
+			if cfg.rewrite {
+				r2 := new(http.Request)
+				*r2 = *r
+				r2.URL = new(url.URL)
+				*r2.URL = *r.URL
+				r2.URL.RawQuery = canonical
+				r = r2
+			}
+
+			key := strings.ToLower(r.Host) + r.URL.EscapedPath()
+			if canonical != "" {
+				key += "?" + canonical
+			}
+			ctx.Put(cacheKeyKey, key)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CacheKey returns the canonical key computed by CanonicalQuery, or "" if
+// the request has none, such as because it isn't a GET or HEAD request.
+func CacheKey(ctx *Context) string {
+	key, _ := ctx.Get(cacheKeyKey).(string)
+	return key
+}
@@ -0,0 +1,54 @@
+package stack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCanonicalQuery(t *testing.T) {
+	var key, query string
+	handler := func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		key, query = CacheKey(ctx), r.URL.RawQuery
+	}
+	get := func(hc HandlerChain, method, target string) {
+		key, query = "", ""
+		r := httptest.NewRequest(method, target, nil)
+		hc.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	st := New(CanonicalQuery()).Then(handler)
+	tests := []struct {
+		target string
+		key    string
+	}{
+		{"/users", "example.com/users"},
+		{"/users?b=2&a=1&a=0", "example.com/users?a=1&a=0&b=2"},
+		{"/users?utm_source=x&page=2&gclid=y&utm_medium=z", "example.com/users?page=2"},
+		{"/users?utm_source=x", "example.com/users"},
+		{"http://EXAMPLE.com/a%2Fb?q=bish+bash", "example.com/a%2Fb?q=bish+bash"},
+	}
+	for _, test := range tests {
+		get(st, "GET", test.target)
+		assertEquals(t, test.key, key)
+	}
+	// Without CanonicalRewrite the handler sees the original query.
+	get(st, "GET", "/users?utm_source=x")
+	assertEquals(t, "utm_source=x", query)
+
+	get(st, "POST", "/users?a=1")
+	assertEquals(t, "", key)
+
+	var outer string
+	st = New(func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			outer = r.URL.RawQuery
+		})
+	}, CanonicalQuery(CanonicalDrop("session"), CanonicalRewrite())).Then(handler)
+	get(st, "HEAD", "/users?utm_source=x&session=1&b=2")
+	assertEquals(t, "example.com/users?b=2&utm_source=x", key)
+	assertEquals(t, "b=2&utm_source=x", query)
+	// Earlier middleware still see the query the client sent.
+	assertEquals(t, "utm_source=x&session=1&b=2", outer)
+}
@@ -0,0 +1,119 @@
+package stack
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// Capability is something which middleware can require the chain to
+// provide. See Requires.
+type Capability string
+
+// Capabilities which chains can be configured to provide.
+const (
+	// NeedsResponses requires a ResponseRecorder (see RecordResponses),
+	// as used by Response, BeforeWrite and OnFinish.
+	NeedsResponses Capability = "a response recorder (call RecordResponses)"
+	// NeedsCookieCodec requires a CookieCodec (see UseCookieCodec), as
+	// used by SetSignedCookie and ReadSignedCookie.
+	NeedsCookieCodec Capability = "a cookie codec (call UseCookieCodec)"
+	// NeedsGoroutineWait requires the chain to wait for goroutines started
+	// with Go before finishing each request (see WaitForGoroutines).
+	NeedsGoroutineWait Capability = "waiting for goroutines (call WaitForGoroutines)"
+)
+
+// Requires declares that mw needs the chain to provide caps, so that
+// closing a chain which doesn't with Then (or one of its variants) panics
+// straight away, rather than mw misbehaving at request time:
+//
+//	func Audit() func(*stack.Context, http.Handler) http.Handler {
+//		return stack.Requires(audit, stack.NeedsResponses)
+//	}
+//
+// Declarations are found through Named, Optional and LintAs, but not
+// through other wrappers.
+func Requires(mw chainMiddleware, caps ...Capability) chainMiddleware {
+	wrapped := func(ctx *Context, next http.Handler) http.Handler {
+		return mw(ctx, next)
+	}
+	declare(wrapped, func(toggles map[string]bool) ([]Capability, []Role) {
+		inner, roles := declared(mw, toggles)
+		return append(caps[:len(caps):len(caps)], inner...), roles
+	})
+	return wrapped
+}
+
+// A declaration returns the capabilities a middleware needs and the roles
+// it plays, given the chain's toggles.
+type declaration func(toggles map[string]bool) ([]Capability, []Role)
+
+// declarations maps the middleware returned by Requires, LintAs, Named and
+// Optional to their declarations.
+var declarations sync.Map
+
+// declare records d for mw, which must be a closure made for this call so
+// that it isn't shared with other middleware.
+func declare(mw chainMiddleware, d declaration) {
+	declarations.Store(middlewareID(mw), d)
+}
+
+// lookupDeclaration returns the declaration recorded for mw, if any.
+func lookupDeclaration(mw chainMiddleware) (declaration, bool) {
+	d, ok := declarations.Load(middlewareID(mw))
+	if !ok {
+		return nil, false
+	}
+	return d.(declaration), true
+}
+
+// declared returns the capabilities declared for mw and the roles it
+// plays.
+func declared(mw chainMiddleware, toggles map[string]bool) ([]Capability, []Role) {
+	if d, ok := lookupDeclaration(mw); ok {
+		return d(toggles)
+	}
+	return nil, nil
+}
+
+// middlewareID identifies a middleware value by the closure it points to.
+// Unlike the code pointer given by reflect, this tells apart closures made
+// by the same function.
+func middlewareID(mw chainMiddleware) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&mw))
+}
+
+// provides reports whether the chain provides cap.
+func (c Chain) provides(cap Capability) bool {
+	switch cap {
+	case NeedsResponses:
+		return c.record
+	case NeedsCookieCodec:
+		return c.cc != nil
+	case NeedsGoroutineWait:
+		return c.waitGo > 0
+	}
+	return false
+}
+
+// checkCapabilities panics if the chain's middleware require capabilities
+// which it doesn't provide. Middleware turned off with Toggle are ignored.
+func (c Chain) checkCapabilities() {
+	var caps []Capability
+	for _, mw := range c.mws {
+		needs, _ := declared(mw, c.toggles)
+		caps = append(caps, needs...)
+	}
+	var missing []string
+	seen := make(map[Capability]bool)
+	for _, cap := range caps {
+		if !seen[cap] && !c.provides(cap) {
+			missing = append(missing, string(cap))
+		}
+		seen[cap] = true
+	}
+	if len(missing) > 0 {
+		panic("stack: chain middleware require " + strings.Join(missing, " and "))
+	}
+}
@@ -0,0 +1,51 @@
+package stack
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRequires(t *testing.T) {
+	st := New(Requires(bishMiddleware, NeedsResponses), flipMiddleware).RecordResponses().Then(bishHandler)
+	assertEquals(t, "bishMiddleware>flipMiddleware>bishHandler [bish=bash]", serveAndRequest(st))
+
+	New(Named("bish", Requires(bishMiddleware, NeedsGoroutineWait))).WaitForGoroutines(time.Second).Then(bishHandler)
+
+	// Named middleware is only built when the chain serves a request.
+	built := 0
+	counting := func(ctx *Context, next http.Handler) http.Handler {
+		built++
+		return next
+	}
+	st = New(Named("counting", counting), Named("outer", Named("inner", Requires(counting, NeedsResponses)))).RecordResponses().Then(bishHandler)
+	assertEquals(t, 0, built)
+	recordGet(st)
+	assertEquals(t, 2, built)
+
+	defer func() {
+		assertEquals(t, "stack: chain middleware require a response recorder (call RecordResponses) and waiting for goroutines (call WaitForGoroutines)", recover())
+	}()
+	New(Requires(bishMiddleware, NeedsResponses), Named("flip", Requires(flipMiddleware, NeedsResponses, NeedsGoroutineWait))).Then(bishHandler)
+}
+
+func TestRequiresToggled(t *testing.T) {
+	mw := Optional("bish", Requires(bishMiddleware, NeedsResponses))
+	New(mw).Then(bishHandler)
+
+	defer func() {
+		assertEquals(t, "stack: chain middleware require a response recorder (call RecordResponses)", recover())
+	}()
+	New(mw).Toggle(map[string]bool{"bish": true}).Then(bishHandler)
+}
+
+func TestRequiresRouterUse(t *testing.T) {
+	rt := NewRouter()
+	rt.Use("bish", Requires(bishMiddleware, NeedsResponses))
+	rt.Get("/recorded", New().RecordResponses().Then(bishHandler))
+
+	defer func() {
+		assertEquals(t, "stack: chain middleware require a response recorder (call RecordResponses)", recover())
+	}()
+	rt.Get("/", New().Then(bishHandler))
+}
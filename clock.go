@@ -0,0 +1,50 @@
+package stack
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Clock tells the time. Chains use the system clock unless another is set
+// with UseClock, which lets tests control the timestamps and expiry times
+// produced by the package.
+type Clock interface {
+	Now() time.Time
+}
+
+// UseClock sets the Clock used by the chain's ResponseRecorder, signed
+// cookie expiry and the RememberMe refresher, and returned by Now.
+func (c Chain) UseClock(clock Clock) Chain {
+	c.clock = clock
+	return c
+}
+
+// UseIDGenerator sets the function NewID uses to generate identifiers for
+// requests handled by the chain. A generator returning predictable values
+// (such as a counter) makes a chain's output reproducible in tests.
+func (c Chain) UseIDGenerator(fn func() string) Chain {
+	c.newID = fn
+	return c
+}
+
+// Now returns the current time according to the chain's Clock.
+func Now(ctx *Context) time.Time {
+	if ctx.clock != nil {
+		return ctx.clock.Now()
+	}
+	return time.Now()
+}
+
+// NewID returns a new identifier, such as for a request ID, from the
+// chain's ID generator. By default identifiers are 32 random hex digits.
+func NewID(ctx *Context) string {
+	if ctx.newID != nil {
+		return ctx.newID()
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("stack: unable to read random bytes: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,84 @@
+package stack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// stepClock advances by a second each time it is read.
+type stepClock struct {
+	t time.Time
+}
+
+func (c *stepClock) Now() time.Time {
+	c.t = c.t.Add(time.Second)
+	return c.t
+}
+
+func TestUseClock(t *testing.T) {
+	clock := &stepClock{t: time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)}
+	var info ResponseInfo
+	var now time.Time
+	st := New(func(ctx *Context, next http.Handler) http.Handler {
+		OnFinish(ctx, func(i ResponseInfo) { info = i })
+		now = Now(ctx)
+		return next
+	}).UseClock(clock).RecordResponses().Then(bishHandler)
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	st.ServeHTTP(httptest.NewRecorder(), r)
+
+	// The recorder's start time, then the middleware's read, the first
+	// byte, the write and the finish.
+	assertEquals(t, time.Date(2006, 1, 2, 15, 4, 7, 0, time.UTC), now)
+	assertEquals(t, 4*time.Second, info.Duration)
+}
+
+func TestUseClockCookies(t *testing.T) {
+	cc, _ := NewCookieCodec([][]byte{testSigningKey}, nil)
+	clock := &stepClock{t: time.Now().Add(-time.Hour)}
+	var err error
+	st := New().UseCookieCodec(cc).UseClock(clock).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			err = SetSignedCookie(ctx, w, &http.Cookie{Name: "bish", Value: "bash", MaxAge: 60})
+			return
+		}
+		_, err = ReadSignedCookie(ctx, r, "bish")
+	})
+
+	r, _ := http.NewRequest("POST", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, nil, err)
+
+	// The cookie expires a minute after the clock's time, so has already
+	// expired by the system clock but not by the chain's.
+	r, _ = http.NewRequest("GET", "/", nil)
+	r.Header.Set("Cookie", rec.Header().Get("Set-Cookie"))
+	st.ServeHTTP(httptest.NewRecorder(), r)
+	assertEquals(t, nil, err)
+	_, err = cc.Decode("bish", rec.Result().Cookies()[0].Value)
+	assertEquals(t, ErrCookieExpired, err)
+}
+
+func TestNewID(t *testing.T) {
+	ctx := NewContext()
+	id := NewID(ctx)
+	assertEquals(t, 32, len(id))
+	assertEquals(t, false, id == NewID(ctx))
+
+	var ids []string
+	n := 0
+	st := New().UseIDGenerator(func() string {
+		n++
+		return string(rune('a' + n))
+	}).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, NewID(ctx))
+	})
+	r, _ := http.NewRequest("GET", "/", nil)
+	st.ServeHTTP(httptest.NewRecorder(), r)
+	st.ServeHTTP(httptest.NewRecorder(), r)
+	assertEquals(t, "b c", ids[0]+" "+ids[1])
+}
@@ -0,0 +1,374 @@
+package stack
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Encoder is a streaming compressor which can be reused by resetting it
+// onto a new destination writer. *gzip.Writer and *zlib.Writer satisfy it,
+// as do the writers from the popular Brotli and Zstandard packages.
+type Encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// EncoderFunc creates a new Encoder writing to w at the given compression
+// level.
+type EncoderFunc func(w io.Writer, level int) (Encoder, error)
+
+// CompressOption configures the Compress middleware.
+type CompressOption func(*compressConfig)
+
+// CompressLevel sets the compression level passed to every encoder. The
+// default is -1 (the encoder's own default).
+func CompressLevel(level int) CompressOption {
+	return func(c *compressConfig) {
+		c.level = level
+	}
+}
+
+// CompressMinSize sets the minimum response size in bytes before the body
+// is compressed. Smaller responses are sent as-is. The default is 1024.
+func CompressMinSize(n int) CompressOption {
+	return func(c *compressConfig) {
+		c.minSize = n
+	}
+}
+
+// CompressTypes replaces the list of compressible content types. Entries
+// ending in "/" (such as "text/") match any subtype.
+func CompressTypes(types ...string) CompressOption {
+	return func(c *compressConfig) {
+		c.types = types
+	}
+}
+
+// CompressEncoder registers an additional content-coding (such as "br" or
+// "zstd"). Encoders registered this way are preferred over the built-in
+// gzip and deflate encoders when the client rates them equally.
+func CompressEncoder(name string, fn EncoderFunc) CompressOption {
+	return func(c *compressConfig) {
+		c.encoders = append([]namedEncoder{{name: strings.ToLower(name), fn: fn}}, c.encoders...)
+	}
+}
+
+var defaultCompressTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/xhtml+xml",
+	"application/rss+xml",
+	"application/atom+xml",
+	"image/svg+xml",
+}
+
+type namedEncoder struct {
+	name string
+	fn   EncoderFunc
+	pool *sync.Pool
+}
+
+type compressConfig struct {
+	level    int
+	minSize  int
+	types    []string
+	encoders []namedEncoder
+}
+
+// Compress returns middleware which compresses response bodies using the
+// best content-coding accepted by the client. Responses are buffered until
+// they reach the minimum size, so small responses are never compressed,
+// and calling Flush sends any buffered data immediately. A strong ETag set
+// on a compressed response is made weak, as the bytes sent differ from
+// those it was computed for.
+//
+// Only gzip and deflate are built in, as the standard library has no
+// Brotli or Zstandard encoder; register them with CompressEncoder:
+//
+//	stack.Compress(stack.CompressEncoder("br", func(w io.Writer, level int) (stack.Encoder, error) {
+//		return brotli.NewWriterLevel(w, level), nil
+//	}))
+func Compress(opts ...CompressOption) chainMiddleware {
+	cfg := &compressConfig{
+		level:   -1,
+		minSize: 1024,
+		types:   defaultCompressTypes,
+		encoders: []namedEncoder{
+			{name: "gzip", fn: newGzipEncoder},
+			{name: "deflate", fn: newDeflateEncoder},
+		},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	for i := range cfg.encoders {
+		cfg.encoders[i].pool = &sync.Pool{}
+	}
+
+	return LintAs(func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if IsWebSocketUpgrade(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			addVary(w.Header(), "Accept-Encoding")
+			enc := cfg.negotiate(r.Header.Get("Accept-Encoding"))
+			if enc == nil || r.Method == "HEAD" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: w, cfg: cfg, encoder: enc}
+			defer func() {
+				if p := recover(); p != nil {
+					cw.abandon()
+					panic(p)
+				}
+				cw.close()
+			}()
+			next.ServeHTTP(PreserveInterfaces(cw), r)
+		})
+	}, CompressesResponses)
+}
+
+func newGzipEncoder(w io.Writer, level int) (Encoder, error) {
+	return gzip.NewWriterLevel(w, level)
+}
+
+// The "deflate" content-coding is zlib-wrapped deflate data (RFC 7230
+// section 4.2.2), not a raw deflate stream.
+func newDeflateEncoder(w io.Writer, level int) (Encoder, error) {
+	return zlib.NewWriterLevel(w, level)
+}
+
+// negotiate picks the most preferred encoder acceptable to the client, or
+// nil if the response should not be encoded.
+func (c *compressConfig) negotiate(acceptEncoding string) *namedEncoder {
+	if acceptEncoding == "" {
+		return nil
+	}
+	accepted := parseQualityList(acceptEncoding)
+	quality := func(name string) float64 {
+		wildcard := -1.0
+		for _, qv := range accepted {
+			switch qv.value {
+			case name:
+				return qv.quality
+			case "*":
+				wildcard = qv.quality
+			}
+		}
+		if wildcard < 0 {
+			return 0
+		}
+		return wildcard
+	}
+
+	var best *namedEncoder
+	var bestQ float64
+	for i := range c.encoders {
+		q := quality(c.encoders[i].name)
+		if q > bestQ {
+			best, bestQ = &c.encoders[i], q
+		}
+	}
+	return best
+}
+
+// Event streams are never compressed, even though they match "text/", as
+// some proxies and clients buffer compressed streams.
+func (c *compressConfig) compressible(contentType string) bool {
+	return !isEventStream(contentType) && mediaTypeIn(contentType, c.types)
+}
+
+// compressWriter buffers the start of the response until it knows whether
+// the body should be compressed, then either streams it through a pooled
+// encoder or passes it straight to the underlying ResponseWriter.
+type compressWriter struct {
+	http.ResponseWriter
+	cfg     *compressConfig
+	encoder *namedEncoder
+	enc     Encoder
+	buf     []byte
+	status  int
+	decided bool
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	// Informational responses (such as 103 Early Hints) may precede the
+	// real one, so pass them straight through.
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if cw.decided || cw.status != 0 {
+		return
+	}
+	cw.status = code
+	// Bodiless responses don't need to wait for a body.
+	if code == http.StatusSwitchingProtocols || code == http.StatusNoContent || code == http.StatusNotModified {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.cfg.minSize {
+			return len(p), nil
+		}
+		if err := cw.decide(false); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// ReadFrom lets io.Copy use the underlying ResponseWriter's ReadFrom (and
+// so sendfile) once the writer knows that the response is being sent
+// uncompressed.
+func (cw *compressWriter) ReadFrom(src io.Reader) (int64, error) {
+	n, err := copyUntil(cw, src, func() bool { return cw.decided })
+	if err != nil || !cw.decided {
+		return n, err
+	}
+	var m int64
+	if rf, ok := cw.ResponseWriter.(io.ReaderFrom); ok && cw.enc == nil {
+		m, err = rf.ReadFrom(src)
+	} else {
+		m, err = io.Copy(writerOnly{cw}, src)
+	}
+	return n + m, err
+}
+
+// Flush sends any buffered data to the client. A response which is flushed
+// before reaching the minimum size is still compressed if its content type
+// allows, since streamed responses have no known final size.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		cw.decide(true)
+	}
+	if cw.enc != nil {
+		cw.enc.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// decide chooses whether to compress, writes the header and any buffered
+// body, and switches the writer into pass-through or encoding mode.
+func (cw *compressWriter) decide(streaming bool) error {
+	cw.decided = true
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	compress := cw.status >= 200 &&
+		cw.status != http.StatusNoContent &&
+		cw.status != http.StatusPartialContent &&
+		cw.status != http.StatusNotModified &&
+		h.Get("Content-Encoding") == "" &&
+		(streaming || len(cw.buf) >= cw.cfg.minSize) &&
+		cw.cfg.compressible(h.Get("Content-Type"))
+
+	if compress {
+		// If the encoder can't be created, fall back to sending the body
+		// uncompressed rather than failing the response.
+		if enc, err := cw.getEncoder(); err == nil {
+			cw.enc = enc
+			h.Del("Content-Length")
+			// Byte ranges of the encoded body aren't supported.
+			h.Del("Accept-Ranges")
+			h.Set("Content-Encoding", cw.encoder.name)
+			if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				h.Set("ETag", "W/"+etag)
+			}
+		}
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// Hijack stops the writer from sending anything itself once the handler
+// has taken over the connection.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	cw.decided = true
+	cw.buf = nil
+	return hijack(cw.ResponseWriter)
+}
+
+// Unwrap returns the underlying ResponseWriter.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func (cw *compressWriter) getEncoder() (Encoder, error) {
+	if enc, ok := cw.encoder.pool.Get().(Encoder); ok {
+		enc.Reset(cw.ResponseWriter)
+		return enc, nil
+	}
+	return cw.encoder.fn(cw.ResponseWriter, cw.cfg.level)
+}
+
+// close flushes out anything still buffered and returns the encoder to
+// the pool.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if cw.status == 0 && len(cw.buf) == 0 {
+			// The handler never wrote anything; let net/http send its
+			// default response.
+			return
+		}
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		cw.decide(false)
+	}
+	if cw.enc != nil {
+		cw.enc.Close()
+		cw.encoder.pool.Put(cw.enc)
+		cw.enc = nil
+	}
+}
+
+// abandon drops the response when the handler panics, so that a partial
+// body isn't sent as if it were complete. Anything not yet written is
+// discarded, leaving the response to whatever recovers the panic.
+func (cw *compressWriter) abandon() {
+	cw.decided = true
+	cw.buf = nil
+	// The encoder's state belongs to the broken response, so it isn't
+	// closed (which would end the stream cleanly) or reused.
+	cw.enc = nil
+}
@@ -0,0 +1,168 @@
+package stack
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("bish bash bosh ", 100)
+	st := New(Compress()).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(body))
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "deflate;q=0.5, gzip")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+
+	assertEquals(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assertEquals(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	gr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(gr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, body, string(b))
+}
+
+func TestCompressWeakensETag(t *testing.T) {
+	st := New(Compress(CompressMinSize(1))).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("ETag", r.URL.Query().Get("etag"))
+		w.Write([]byte("bish"))
+	})
+
+	for etag, want := range map[string]string{`"v1"`: `W/"v1"`, `W/"v1"`: `W/"v1"`} {
+		r, _ := http.NewRequest("GET", "/?etag="+etag, nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		st.ServeHTTP(rec, r)
+		assertEquals(t, want, rec.Header().Get("ETag"))
+	}
+}
+
+func TestCompressPanic(t *testing.T) {
+	recoverer := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if recover() != nil {
+					http.Error(w, "oops", http.StatusInternalServerError)
+				}
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+	st := New(Adapt(recoverer), Compress()).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("half a resp"))
+		panic("bish")
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 500, rec.Code)
+	assertEquals(t, "oops\n", rec.Body.String())
+}
+
+func TestCompressMinSize(t *testing.T) {
+	st := New(Compress(CompressMinSize(100))).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("small"))
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+
+	assertEquals(t, "", rec.Header().Get("Content-Encoding"))
+	assertEquals(t, "small", rec.Body.String())
+}
+
+func TestCompressContentTypes(t *testing.T) {
+	st := New(Compress(CompressMinSize(1))).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("not really a png"))
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+
+	assertEquals(t, "", rec.Header().Get("Content-Encoding"))
+	assertEquals(t, "not really a png", rec.Body.String())
+}
+
+func TestCompressNotAccepted(t *testing.T) {
+	st := New(Compress(CompressMinSize(1))).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("plain text"))
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip;q=0, identity")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+
+	assertEquals(t, "", rec.Header().Get("Content-Encoding"))
+	assertEquals(t, "plain text", rec.Body.String())
+}
+
+func TestCompressFlush(t *testing.T) {
+	st := New(Compress()).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("bish\n"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte("bash\n"))
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+
+	assertEquals(t, true, rec.Flushed)
+	assertEquals(t, "gzip", rec.Header().Get("Content-Encoding"))
+	gr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(gr)
+	assertEquals(t, "bish\nbash\n", string(b))
+}
+
+func TestCompressSkipsEventStreams(t *testing.T) {
+	st := New(Compress()).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: bish\n\n"))
+		w.(http.Flusher).Flush()
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+
+	assertEquals(t, true, rec.Flushed)
+	assertEquals(t, "", rec.Header().Get("Content-Encoding"))
+	assertEquals(t, "data: bish\n\n", rec.Body.String())
+}
+
+func TestParseQualityList(t *testing.T) {
+	qvs := parseQualityList("deflate;q=0.5, gzip, br;q=0.9, *;q=0")
+	assertEquals(t, 4, len(qvs))
+	assertEquals(t, "gzip", qvs[0].value)
+	assertEquals(t, "br", qvs[1].value)
+	assertEquals(t, "deflate", qvs[2].value)
+	assertEquals(t, 0.0, qvs[3].quality)
+}
@@ -0,0 +1,61 @@
+package stack
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Validator is implemented by configuration types which can check
+// themselves. See InjectConfig.
+type Validator interface {
+	Validate() error
+}
+
+// InjectConfig returns a copy of hc with cfg available to the chain's
+// middleware and handler through Config. It is stored under a key derived
+// from its type, so each chain holds at most one config of each type:
+//
+//	type uploadConfig struct {
+//		MaxSize int64
+//		Dir     string
+//	}
+//
+//	hc = stack.InjectConfig(hc, uploadConfig{MaxSize: 10 << 20, Dir: "/tmp"})
+//
+// If cfg, or a pointer to it, implements Validator then InjectConfig calls
+// Validate and panics if it fails, so that invalid configuration is caught
+// at startup. T should be a struct (or other value) type; as Config
+// returns a copy, handlers can't change the configuration seen by other
+// requests, unless it contains pointers, maps or slices.
+func InjectConfig[T any](hc HandlerChain, cfg T) HandlerChain {
+	v, ok := any(&cfg).(Validator)
+	if !ok {
+		v, ok = any(cfg).(Validator)
+	}
+	if ok {
+		if err := v.Validate(); err != nil {
+			panic(fmt.Sprintf("stack: invalid %s: %v", configType[T](), err))
+		}
+	}
+	return Inject(hc, configKey[T](), cfg)
+}
+
+// Config returns the configuration of type T injected into the chain with
+// InjectConfig. It panics if there isn't one, as that is a mistake in
+// assembling the chain rather than something to handle at request time.
+func Config[T any](ctx *Context) T {
+	cfg, ok := ctx.Get(configKey[T]()).(T)
+	if !ok {
+		panic(fmt.Sprintf("stack: no %s injected into the chain", configType[T]()))
+	}
+	return cfg
+}
+
+func configType[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func configKey[T any]() string {
+	t := configType[T]()
+	return "stack.config." + t.PkgPath() + "." + t.String()
+}
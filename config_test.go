@@ -0,0 +1,54 @@
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+type bishConfig struct {
+	Greeting string
+	Limit    int
+}
+
+func (c *bishConfig) Validate() error {
+	if c.Limit <= 0 {
+		return errors.New("limit must be positive")
+	}
+	return nil
+}
+
+type flipConfig struct {
+	Greeting string
+}
+
+func configHandler(ctx *Context, w http.ResponseWriter, r *http.Request) {
+	bc := Config[bishConfig](ctx)
+	fmt.Fprintf(w, "%s %d %s", bc.Greeting, bc.Limit, Config[flipConfig](ctx).Greeting)
+	// Changes to the copy don't affect other requests.
+	bc.Limit = 0
+}
+
+func TestInjectConfig(t *testing.T) {
+	st := New().Then(configHandler)
+	st = InjectConfig(st, bishConfig{"bash", 3})
+	st = InjectConfig(st, flipConfig{"flop"})
+
+	assertEquals(t, "bash 3 flop", serveAndRequest(st))
+	assertEquals(t, "bash 3 flop", serveAndRequest(st))
+}
+
+func TestInjectConfigValidates(t *testing.T) {
+	defer func() {
+		assertEquals(t, "stack: invalid stack.bishConfig: limit must be positive", recover())
+	}()
+	InjectConfig(New().Then(configHandler), bishConfig{"bash", 0})
+}
+
+func TestConfigMissing(t *testing.T) {
+	defer func() {
+		assertEquals(t, "stack: no stack.flipConfig injected into the chain", recover())
+	}()
+	Config[flipConfig](NewContext())
+}
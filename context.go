@@ -1,12 +1,67 @@
 package stack
 
 import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
 	"sync"
+	"sync/atomic"
 )
 
+// Values is the part of Context used to store request-scoped data. Code
+// which only needs to get and put values can accept a Values rather than a
+// *Context, so that tests can pass something simpler (such as
+// stacktest.MapValues).
+//
+// *Context doesn't implement Values itself, since its Put and Delete
+// methods return the Context so that calls can be chained, and changing
+// that would break existing code. Wrap it with ContextValues instead.
+type Values interface {
+	Get(key string) interface{}
+	Put(key string, val interface{})
+	Delete(key string)
+	Exists(key string) bool
+}
+
+// ContextValues returns ctx as a Values. It is needed because Context.Put
+// and Context.Delete return the Context, so that calls can be chained.
+func ContextValues(ctx *Context) Values {
+	return contextValues{ctx}
+}
+
+type contextValues struct {
+	*Context
+}
+
+func (cv contextValues) Put(key string, val interface{}) {
+	cv.Context.Put(key, val)
+}
+
+func (cv contextValues) Delete(key string) {
+	cv.Context.Delete(key)
+}
+
 type Context struct {
-	mu sync.RWMutex
-	m  map[string]interface{}
+	mu           sync.RWMutex
+	m            map[string]interface{}
+	errorHandler ErrorHandlerFunc
+	cookieCodec  *CookieCodec
+	response     *ResponseRecorder
+	err          error
+	clock        Clock
+	newID        func() string
+	deferred     []func()
+	reporter     func(*Context, error)
+	goroutines   *sync.WaitGroup
+	toggles      map[string]bool
+	reads        map[string]*int32
+	renderer     Renderer
+	assets       *Assets
+	views        map[reflect.Type]string
+	// request is the request the chain was called with, for helpers which
+	// need to call Error but aren't passed the request.
+	request *http.Request
 }
 
 func NewContext() *Context {
@@ -15,6 +70,9 @@ func NewContext() *Context {
 }
 
 func (c *Context) Get(key string) interface{} {
+	if read, ok := c.reads[key]; ok {
+		atomic.StoreInt32(read, 1)
+	}
 	if !c.Exists(key) {
 		return nil
 	}
@@ -44,6 +102,90 @@ func (c *Context) Exists(key string) bool {
 	return ok
 }
 
+// Keys returns the keys stored in the Context, in sorted order.
+func (c *Context) Keys() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	keys := make([]string, 0, len(c.m))
+	for k := range c.m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GetOrCompute returns the value for key if it exists. Otherwise it calls
+// fn, stores the value it returns (unless fn fails) and returns that. fn
+// is called without the Context's lock held, so it may use the Context
+// itself.
+func (c *Context) GetOrCompute(key string, fn func() (interface{}, error)) (interface{}, error) {
+	c.mu.RLock()
+	val, ok := c.m[key]
+	c.mu.RUnlock()
+	if ok {
+		return val, nil
+	}
+
+	val, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Another goroutine may have got there first, in which case keep its
+	// value so that every caller sees the same one.
+	if existing, ok := c.m[key]; ok {
+		return existing, nil
+	}
+	c.m[key] = val
+	return val, nil
+}
+
+// Defer registers fn to be called once the chain has finished handling the
+// request, after the response has been written and any OnFinish callbacks
+// have run. Deferred functions are called in reverse order of
+// registration, even if the handler panics, so middleware can use Defer to
+// release resources (such as temporary files or pooled buffers) which the
+// rest of the chain might use. If the handler panics, its panic continues
+// once they have run, even if one of them panics too.
+func (c *Context) Defer(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deferred = append(c.deferred, fn)
+}
+
+// runDeferred calls the functions registered with Defer. A panic in one of
+// them doesn't stop the rest being called, and is raised again once they
+// have all run. p is the value recovered from a panic already under way,
+// if any, which is raised instead, as it's the one recovery and logging
+// middleware need to see.
+func (c *Context) runDeferred(p interface{}) {
+	first := p
+	for {
+		c.mu.Lock()
+		n := len(c.deferred)
+		if n == 0 {
+			c.mu.Unlock()
+			break
+		}
+		fn := c.deferred[n-1]
+		c.deferred = c.deferred[:n-1]
+		c.mu.Unlock()
+
+		func() {
+			defer func() {
+				if p := recover(); p != nil && first == nil {
+					first = p
+				}
+			}()
+			fn()
+		}()
+	}
+	if first != nil {
+		panic(first)
+	}
+}
+
 func (c *Context) copy() *Context {
 	nc := NewContext()
 	c.mu.RLock()
@@ -53,3 +195,38 @@ func (c *Context) copy() *Context {
 	}
 	return nc
 }
+
+// inherit sets the settings which come from the chain, rather than from
+// the values put in it, to those of from.
+func (c *Context) inherit(from *Context) {
+	c.errorHandler = from.errorHandler
+	c.cookieCodec = from.cookieCodec
+	c.clock = from.clock
+	c.newID = from.newID
+	c.reporter = from.reporter
+	c.toggles = from.toggles
+	c.reads = from.reads
+	c.renderer = from.renderer
+	c.assets = from.assets
+	c.views = from.views
+}
+
+type requestContextKey struct{}
+
+// FromRequest returns the stack Context for a request, or nil if there
+// isn't one. It gives middleware adapted with Adapt and handlers added with
+// ThenHandler or ThenHandlerFunc, which don't receive the Context as an
+// argument, access to it through the request's context.Context.
+func FromRequest(r *http.Request) *Context {
+	ctx, _ := r.Context().Value(requestContextKey{}).(*Context)
+	return ctx
+}
+
+// withContext returns a shallow copy of r carrying ctx, unless r already
+// carries it.
+func withContext(r *http.Request, ctx *Context) *http.Request {
+	if FromRequest(r) == ctx {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), requestContextKey{}, ctx))
+}
@@ -1,6 +1,15 @@
 package stack
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var errTest = errors.New("test error")
 
 func TestGet(t *testing.T) {
 	ctx := NewContext()
@@ -17,8 +26,9 @@ func TestGet(t *testing.T) {
 func TestPut(t *testing.T) {
 	ctx := NewContext()
 
-	ctx.Put("bish", "bash")
+	ctx.Put("bish", "bash").Put("flip", "flop")
 	assertEquals(t, "bash", ctx.m["bish"])
+	assertEquals(t, "flop", ctx.m["flip"])
 }
 
 func TestDelete(t *testing.T) {
@@ -29,6 +39,16 @@ func TestDelete(t *testing.T) {
 	assertEquals(t, nil, ctx.m["flip"])
 }
 
+func TestContextValues(t *testing.T) {
+	ctx := NewContext()
+	v := ContextValues(ctx)
+
+	v.Put("bish", "bash")
+	assertEquals(t, "bash", ctx.Get("bish"))
+	v.Delete("bish")
+	assertEquals(t, false, v.Exists("bish"))
+}
+
 func TestCopy(t *testing.T) {
 	ctx := NewContext()
 	ctx.m["flip"] = "flop"
@@ -46,3 +66,91 @@ func TestExists(t *testing.T) {
 	assertEquals(t, true, ctx.Exists("flip"))
 	assertEquals(t, false, ctx.Exists("bash"))
 }
+
+func TestKeys(t *testing.T) {
+	ctx := NewContext()
+	ctx.m["flip"] = "flop"
+	ctx.m["bish"] = "bash"
+
+	assertEquals(t, "[bish flip]", fmt.Sprint(ctx.Keys()))
+}
+
+func TestGetOrCompute(t *testing.T) {
+	ctx := NewContext()
+	calls := 0
+	fn := func() (interface{}, error) {
+		calls++
+		return "bash", nil
+	}
+
+	val, err := ctx.GetOrCompute("bish", fn)
+	assertEquals(t, nil, err)
+	assertEquals(t, "bash", val)
+	val, _ = ctx.GetOrCompute("bish", fn)
+	assertEquals(t, "bash", val)
+	assertEquals(t, 1, calls)
+
+	_, err = ctx.GetOrCompute("flip", func() (interface{}, error) {
+		return nil, errTest
+	})
+	assertEquals(t, errTest, err)
+	assertEquals(t, false, ctx.Exists("flip"))
+}
+
+func TestFromRequest(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/", nil)
+	if FromRequest(r) != nil {
+		t.Error("expected nil Context for a plain request")
+	}
+	ctx := NewContext()
+	r2 := withContext(r, ctx)
+	assertEquals(t, ctx, FromRequest(r2))
+	assertEquals(t, r2, withContext(r2, ctx))
+}
+
+func TestDefer(t *testing.T) {
+	var calls []string
+	mw := func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx.Defer(func() { calls = append(calls, "first") })
+			ctx.Defer(func() { calls = append(calls, "second") })
+			OnFinish(ctx, func(ResponseInfo) { calls = append(calls, "finish") })
+			next.ServeHTTP(w, r)
+		})
+	}
+	st := New(mw).RecordResponses().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	})
+
+	serveAndRequest(st)
+	assertEquals(t, "handler finish second first", strings.Join(calls, " "))
+}
+
+func TestDeferPanics(t *testing.T) {
+	var calls []string
+	st := New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		ctx.Defer(func() { calls = append(calls, "first") })
+		ctx.Defer(func() { panic("bish") })
+		ctx.Defer(func() { calls = append(calls, "third") })
+		panic("bash")
+	})
+
+	defer func() {
+		assertEquals(t, "bash", recover())
+		assertEquals(t, "third first", strings.Join(calls, " "))
+	}()
+	r, _ := http.NewRequest("GET", "/", nil)
+	st.ServeHTTP(httptest.NewRecorder(), r)
+}
+
+func TestDeferPanicsWithoutHandlerPanic(t *testing.T) {
+	st := New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		ctx.Defer(func() { panic("bish") })
+	})
+
+	defer func() {
+		assertEquals(t, "bish", recover())
+	}()
+	r, _ := http.NewRequest("GET", "/", nil)
+	st.ServeHTTP(httptest.NewRecorder(), r)
+}
@@ -0,0 +1,178 @@
+package stack
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Errors returned when reading signed cookies.
+var (
+	ErrNoCookieCodec = errors.New("stack: no cookie codec configured for chain")
+	ErrInvalidCookie = errors.New("stack: invalid cookie")
+	ErrCookieExpired = errors.New("stack: cookie has expired")
+)
+
+// CookieCodec signs, and optionally encrypts, cookie values. It supports
+// key rotation: new values are always protected with the first key of each
+// kind, while values protected with any of the keys are accepted.
+type CookieCodec struct {
+	signingKeys [][]byte
+	aeads       []cipher.AEAD
+}
+
+// NewCookieCodec returns a CookieCodec which signs values with HMAC-SHA256
+// using signingKeys and, if any encryptionKeys are given, encrypts them
+// with AES-GCM. Signing keys should be at least 32 random bytes, and
+// encryption keys must be 16, 24 or 32 bytes long (for AES-128, AES-192
+// or AES-256).
+func NewCookieCodec(signingKeys [][]byte, encryptionKeys [][]byte) (*CookieCodec, error) {
+	if len(signingKeys) == 0 {
+		return nil, errors.New("stack: at least one cookie signing key is required")
+	}
+	cc := &CookieCodec{signingKeys: signingKeys}
+	for _, key := range encryptionKeys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("stack: invalid cookie encryption key: %v", err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		cc.aeads = append(cc.aeads, aead)
+	}
+	return cc, nil
+}
+
+// Encode protects value for storage in the cookie called name. A non-zero
+// expiry is embedded in the signed payload, so that the value is rejected
+// after that time even if the client keeps the cookie.
+func (cc *CookieCodec) Encode(name, value string, expiry time.Time) (string, error) {
+	payload := make([]byte, 8+len(value))
+	if !expiry.IsZero() {
+		binary.BigEndian.PutUint64(payload, uint64(expiry.Unix()))
+	}
+	copy(payload[8:], value)
+
+	if len(cc.aeads) > 0 {
+		aead := cc.aeads[0]
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return "", err
+		}
+		payload = aead.Seal(nonce, nonce, payload, []byte(name))
+	}
+	data := base64.RawURLEncoding.EncodeToString(payload)
+	return data + "." + cc.sign(cc.signingKeys[0], name, data), nil
+}
+
+// Decode verifies (and decrypts) a value produced by Encode for the cookie
+// called name.
+func (cc *CookieCodec) Decode(name, encoded string) (string, error) {
+	return cc.decode(name, encoded, time.Now())
+}
+
+func (cc *CookieCodec) decode(name, encoded string, now time.Time) (string, error) {
+	i := strings.LastIndexByte(encoded, '.')
+	if i < 0 {
+		return "", ErrInvalidCookie
+	}
+	data, sig := encoded[:i], encoded[i+1:]
+	valid := false
+	for _, key := range cc.signingKeys {
+		if hmac.Equal([]byte(sig), []byte(cc.sign(key, name, data))) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return "", ErrInvalidCookie
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+
+	if len(cc.aeads) > 0 {
+		var plain []byte
+		for _, aead := range cc.aeads {
+			ns := aead.NonceSize()
+			if len(payload) < ns {
+				continue
+			}
+			if plain, err = aead.Open(nil, payload[:ns], payload[ns:], []byte(name)); err == nil {
+				break
+			}
+		}
+		if plain == nil {
+			return "", ErrInvalidCookie
+		}
+		payload = plain
+	}
+	if len(payload) < 8 {
+		return "", ErrInvalidCookie
+	}
+	if exp := binary.BigEndian.Uint64(payload); exp != 0 && now.Unix() > int64(exp) {
+		return "", ErrCookieExpired
+	}
+	return string(payload[8:]), nil
+}
+
+func (cc *CookieCodec) sign(key []byte, name, data string) string {
+	mac := hmac.New(sha256.New, key)
+	// Bind the signature to the cookie name so that a value can't be
+	// replayed under a different cookie.
+	mac.Write([]byte(name))
+	mac.Write([]byte{0})
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SetSignedCookie protects cookie.Value with the chain's CookieCodec and
+// sets the cookie on w. If the cookie has an Expires or MaxAge attribute
+// the corresponding expiry is also enforced when the cookie is read back.
+func SetSignedCookie(ctx *Context, w http.ResponseWriter, cookie *http.Cookie) error {
+	if ctx.cookieCodec == nil {
+		return ErrNoCookieCodec
+	}
+	var expiry time.Time
+	switch {
+	case cookie.MaxAge > 0:
+		expiry = Now(ctx).Add(time.Duration(cookie.MaxAge) * time.Second)
+	case !cookie.Expires.IsZero():
+		expiry = cookie.Expires
+	}
+	value, err := ctx.cookieCodec.Encode(cookie.Name, cookie.Value, expiry)
+	if err != nil {
+		return err
+	}
+	c := *cookie
+	c.Value = value
+	http.SetCookie(w, &c)
+	return nil
+}
+
+// ReadSignedCookie returns the verified value of the named cookie set by
+// SetSignedCookie. It returns http.ErrNoCookie if the cookie isn't present
+// and ErrInvalidCookie if it has been tampered with.
+func ReadSignedCookie(ctx *Context, r *http.Request, name string) (string, error) {
+	if ctx.cookieCodec == nil {
+		return "", ErrNoCookieCodec
+	}
+	c, err := r.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return ctx.cookieCodec.decode(name, c.Value, Now(ctx))
+}
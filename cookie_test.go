@@ -0,0 +1,90 @@
+package stack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testSigningKey    = []byte("0123456789abcdef0123456789abcdef")
+	testEncryptionKey = []byte("fedcba9876543210")
+)
+
+func TestCookieCodec(t *testing.T) {
+	cc, err := NewCookieCodec([][]byte{testSigningKey}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, _ := cc.Encode("bish", "bash", time.Time{})
+	val, err := cc.Decode("bish", enc)
+	assertEquals(t, nil, err)
+	assertEquals(t, "bash", val)
+
+	_, err = cc.Decode("flip", enc)
+	assertEquals(t, ErrInvalidCookie, err)
+
+	_, err = cc.Decode("bish", "x"+enc)
+	assertEquals(t, ErrInvalidCookie, err)
+
+	enc, _ = cc.Encode("bish", "bash", time.Now().Add(-time.Second))
+	_, err = cc.Decode("bish", enc)
+	assertEquals(t, ErrCookieExpired, err)
+}
+
+func TestCookieCodecEncryption(t *testing.T) {
+	cc, err := NewCookieCodec([][]byte{testSigningKey}, [][]byte{testEncryptionKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, _ := cc.Encode("bish", "secret value", time.Time{})
+	assertEquals(t, false, strings.Contains(enc, "c2VjcmV0"))
+	val, err := cc.Decode("bish", enc)
+	assertEquals(t, nil, err)
+	assertEquals(t, "secret value", val)
+
+	_, err = NewCookieCodec([][]byte{testSigningKey}, [][]byte{[]byte("short")})
+	assertEquals(t, true, err != nil)
+}
+
+func TestCookieCodecRotation(t *testing.T) {
+	oldKey := []byte("an old signing key which was replaced")
+	oldEnc := []byte("0123456789abcdef")
+	old, _ := NewCookieCodec([][]byte{oldKey}, [][]byte{oldEnc})
+	enc, _ := old.Encode("bish", "bash", time.Time{})
+
+	cc, _ := NewCookieCodec([][]byte{testSigningKey, oldKey}, [][]byte{testEncryptionKey, oldEnc})
+	val, err := cc.Decode("bish", enc)
+	assertEquals(t, nil, err)
+	assertEquals(t, "bash", val)
+}
+
+func TestSignedCookieHelpers(t *testing.T) {
+	cc, _ := NewCookieCodec([][]byte{testSigningKey}, nil)
+	st := New().UseCookieCodec(cc).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		if val, err := ReadSignedCookie(ctx, r, "bish"); err == nil {
+			w.Write([]byte(val))
+			return
+		}
+		SetSignedCookie(ctx, w, &http.Cookie{Name: "bish", Value: "bash", MaxAge: 60})
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	cookie := (&http.Response{Header: rec.Header()}).Cookies()[0]
+	assertEquals(t, 60, cookie.MaxAge)
+
+	r.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, "bash", rec.Body.String())
+}
+
+func TestSignedCookieWithoutCodec(t *testing.T) {
+	ctx := NewContext()
+	err := SetSignedCookie(ctx, httptest.NewRecorder(), &http.Cookie{Name: "bish"})
+	assertEquals(t, ErrNoCookieCodec, err)
+}
@@ -0,0 +1,179 @@
+package stack
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// DebugOption configures the chain returned by Debug.
+type DebugOption func(*debugConfig)
+
+// DebugGuard sets the middleware which requests must pass before reaching
+// the debug endpoints, replacing the default guard, which only allows
+// requests from loopback addresses. For example, to allow the internal
+// network with a password:
+//
+//	stack.Debug(stack.DebugGuard(
+//		stack.Authorize(stack.FromNetworks("10.0.0.0/8")),
+//		basicauth.New("debug", basicauth.Users(users)),
+//	))
+func DebugGuard(mws ...chainMiddleware) DebugOption {
+	return func(dc *debugConfig) {
+		dc.guard = mws
+	}
+}
+
+// DebugRouter makes the routes registered with rt available from the
+// /routes endpoint.
+func DebugRouter(rt *Router) DebugOption {
+	return func(dc *debugConfig) {
+		dc.router = rt
+	}
+}
+
+type debugConfig struct {
+	guard  []chainMiddleware
+	router *Router
+}
+
+// Debug returns a chain serving runtime debugging endpoints, for requests
+// whose paths end in:
+//
+//	/pprof/            an index of the available profiles
+//	/pprof/{profile}   a profile, such as heap or goroutine, in the format
+//	                   read by go tool pprof (or as text with ?debug=1)
+//	/pprof/profile     a CPU profile, over ?seconds=N (default 30)
+//	/pprof/trace       an execution trace, over ?seconds=N (default 1)
+//	/pprof/cmdline     the program's command line
+//	/goroutines        a stack trace of every goroutine
+//	/routes            the routes of the Router given with DebugRouter
+//
+// Other paths get a 404. Mount it wherever suits, behind a guard:
+//
+//	mux.Handle(stack.Mount("/debug", stack.Debug()))
+//
+// The default guard only checks that the connection comes from a loopback
+// address. Behind a reverse proxy on the same host, such as nginx on
+// localhost, every request does, so the endpoints (which reveal the
+// program's memory and goroutines) are open to anyone who can reach the
+// proxy. In that case either put TrustProxies in front of Debug, so that
+// the guard sees the client's address, or pass a guard of your own with
+// DebugGuard.
+//
+// The endpoints are served with runtime/pprof rather than net/http/pprof,
+// so nothing is registered on http.DefaultServeMux.
+func Debug(opts ...DebugOption) HandlerChain {
+	cfg := &debugConfig{
+		guard: []chainMiddleware{Authorize(FromNetworks("127.0.0.0/8", "::1"))},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return New(cfg.guard...).Then(cfg.serve)
+}
+
+func (cfg *debugConfig) serve(ctx *Context, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	path := r.URL.Path
+	if i := strings.LastIndex(path, "/pprof/"); i >= 0 {
+		cfg.pprof(ctx, w, r, path[i+len("/pprof/"):])
+		return
+	}
+	switch {
+	case strings.HasSuffix(path, "/goroutines"):
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		pprof.Lookup("goroutine").WriteTo(w, 2)
+	case strings.HasSuffix(path, "/routes") && cfg.router != nil:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, ri := range cfg.router.Routes() {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", ri.Method, ri.Pattern, ri.Name, strings.Join(ri.Middleware, ", "))
+		}
+		tw.Flush()
+	default:
+		Error(ctx, w, r, NewHTTPError(http.StatusNotFound, nil))
+	}
+}
+
+func (cfg *debugConfig) pprof(ctx *Context, w http.ResponseWriter, r *http.Request, name string) {
+	switch name {
+	case "":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html><head><title>profiles</title></head><body><ul>\n")
+		for _, p := range pprof.Profiles() {
+			fmt.Fprintf(w, "<li><a href=\"%s?debug=1\">%s</a> (%d)</li>\n", p.Name(), p.Name(), p.Count())
+		}
+		fmt.Fprint(w, "<li><a href=\"profile\">profile</a></li>\n<li><a href=\"trace\">trace</a></li>\n</ul></body></html>\n")
+	case "cmdline":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, strings.Join(os.Args, "\x00"))
+	case "profile":
+		SkipETag(ctx)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="profile"`)
+		if err := pprof.StartCPUProfile(w); err != nil {
+			w.Header().Del("Content-Disposition")
+			Error(ctx, w, r, err)
+			return
+		}
+		waitOrDone(r, secondsParam(r, 30))
+		pprof.StopCPUProfile()
+	case "trace":
+		SkipETag(ctx)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="trace"`)
+		if err := trace.Start(w); err != nil {
+			w.Header().Del("Content-Disposition")
+			Error(ctx, w, r, err)
+			return
+		}
+		waitOrDone(r, secondsParam(r, 1))
+		trace.Stop()
+	default:
+		p := pprof.Lookup(name)
+		if p == nil {
+			Error(ctx, w, r, NewHTTPError(http.StatusNotFound, nil))
+			return
+		}
+		debug, _ := strconv.Atoi(r.URL.Query().Get("debug"))
+		if debug > 0 {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		}
+		if name == "heap" && r.URL.Query().Get("gc") != "" {
+			runtime.GC()
+		}
+		p.WriteTo(w, debug)
+	}
+}
+
+// secondsParam returns the duration given by the request's seconds query
+// parameter, or def seconds if it is missing or invalid.
+func secondsParam(r *http.Request, def int) time.Duration {
+	n, err := strconv.Atoi(r.URL.Query().Get("seconds"))
+	if err != nil || n <= 0 {
+		n = def
+	}
+	return time.Duration(n) * time.Second
+}
+
+// waitOrDone waits for d, or until the client goes away.
+func waitOrDone(r *http.Request, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-r.Context().Done():
+	}
+}
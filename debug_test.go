@@ -0,0 +1,53 @@
+package stack
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func debugRequest(hc HandlerChain, path, remoteAddr string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", path, nil)
+	r.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	hc.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestDebug(t *testing.T) {
+	rt := NewRouter()
+	rt.Use("auth", bishMiddleware)
+	rt.Get("/users/{id}", New().Then(bishHandler)).Name("user.show")
+	hc := Debug(DebugRouter(rt))
+
+	rec := debugRequest(hc, "/debug/goroutines", "192.0.2.1:1234")
+	assertEquals(t, 401, rec.Code)
+
+	rec = debugRequest(hc, "/debug/goroutines", "127.0.0.1:1234")
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, true, strings.Contains(rec.Body.String(), "goroutine "))
+
+	rec = debugRequest(hc, "/debug/pprof/", "[::1]:1234")
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, true, strings.Contains(rec.Body.String(), `href="heap?debug=1"`))
+
+	rec = debugRequest(hc, "/debug/pprof/heap?debug=1", "127.0.0.1:1234")
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	rec = debugRequest(hc, "/debug/pprof/bish", "127.0.0.1:1234")
+	assertEquals(t, 404, rec.Code)
+
+	rec = debugRequest(hc, "/debug/routes", "127.0.0.1:1234")
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "GET  /users/{id}  user.show  auth\n", rec.Body.String())
+
+	rec = debugRequest(hc, "/debug/bash", "127.0.0.1:1234")
+	assertEquals(t, 404, rec.Code)
+}
+
+func TestDebugGuard(t *testing.T) {
+	hc := Debug(DebugGuard(Authorize(FromNetworks("192.0.2.0/24"))))
+	assertEquals(t, 200, debugRequest(hc, "/pprof/cmdline", "192.0.2.1:1234").Code)
+	assertEquals(t, 401, debugRequest(hc, "/pprof/cmdline", "127.0.0.1:1234").Code)
+}
@@ -0,0 +1,122 @@
+package stack
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DecoderFunc creates a reader which decompresses data read from r.
+type DecoderFunc func(r io.Reader) (io.ReadCloser, error)
+
+// DecompressOption configures the Decompress middleware.
+type DecompressOption func(*decompressConfig)
+
+// DecompressMaxSize sets the maximum number of bytes a decompressed request
+// body may contain. Reading past the limit returns an error and the
+// connection is closed once the handler returns. The default is 10MB.
+func DecompressMaxSize(n int64) DecompressOption {
+	return func(c *decompressConfig) {
+		c.maxSize = n
+	}
+}
+
+// DecompressDecoder registers a decoder for an additional content-coding.
+// Only gzip and deflate are built in, as the standard library has no
+// Zstandard or Brotli decoder; register them with DecompressDecoder:
+//
+//	stack.Decompress(stack.DecompressDecoder("zstd", func(r io.Reader) (io.ReadCloser, error) {
+//		d, err := zstd.NewReader(r)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return d.IOReadCloser(), nil
+//	}))
+func DecompressDecoder(name string, fn DecoderFunc) DecompressOption {
+	return func(c *decompressConfig) {
+		c.decoders[strings.ToLower(name)] = fn
+	}
+}
+
+type decompressConfig struct {
+	maxSize  int64
+	decoders map[string]DecoderFunc
+}
+
+// Decompress returns middleware which transparently decompresses request
+// bodies sent with a Content-Encoding header, so that later middleware
+// and handlers always read plain bytes from r.Body. Requests using an
+// unsupported encoding are passed to the chain's error handler with a 415
+// Unsupported Media Type status, and malformed compressed data with 400 Bad
+// Request.
+func Decompress(opts ...DecompressOption) chainMiddleware {
+	cfg := &decompressConfig{
+		maxSize: 10 << 20,
+		decoders: map[string]DecoderFunc{
+			"gzip":    newGzipDecoder,
+			"x-gzip":  newGzipDecoder,
+			"deflate": newDeflateDecoder,
+		},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ce := r.Header.Get("Content-Encoding")
+			if ce == "" || r.Body == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Codings are listed in the order they were applied, so undo
+			// them in reverse.
+			codings := strings.Split(ce, ",")
+			body := r.Body
+			// net/http closes the request body, but not the decoders
+			// reading it.
+			var decoders []io.ReadCloser
+			defer func() {
+				for _, dr := range decoders {
+					dr.Close()
+				}
+			}()
+			for i := len(codings) - 1; i >= 0; i-- {
+				coding := strings.ToLower(strings.TrimSpace(codings[i]))
+				if coding == "identity" || coding == "" {
+					continue
+				}
+				fn, ok := cfg.decoders[coding]
+				if !ok {
+					Error(ctx, w, r, NewHTTPError(415, fmt.Errorf("stack: unsupported content-coding %q", coding)))
+					return
+				}
+				dr, err := fn(body)
+				if err != nil {
+					Error(ctx, w, r, NewHTTPError(400, err))
+					return
+				}
+				decoders = append(decoders, dr)
+				body = dr
+			}
+
+			r.Body = http.MaxBytesReader(w, body, cfg.maxSize)
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			r.ContentLength = -1
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func newGzipDecoder(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func newDeflateDecoder(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
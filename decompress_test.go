@@ -0,0 +1,94 @@
+package stack
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func gzipBytes(s string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write([]byte(s))
+	gw.Close()
+	return buf.Bytes()
+}
+
+func echoBodyHandler(w http.ResponseWriter, r *http.Request) {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), 413)
+		return
+	}
+	fmt.Fprintf(w, "%s [ce=%q]", b, r.Header.Get("Content-Encoding"))
+}
+
+func TestDecompress(t *testing.T) {
+	st := New(Decompress()).ThenHandlerFunc(echoBodyHandler)
+
+	r, _ := http.NewRequest("POST", "/", bytes.NewReader(gzipBytes(`{"bish":"bash"}`)))
+	r.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, `{"bish":"bash"} [ce=""]`, rec.Body.String())
+}
+
+func TestDecompressUnsupported(t *testing.T) {
+	st := New(Decompress()).ThenHandlerFunc(echoBodyHandler)
+
+	r, _ := http.NewRequest("POST", "/", strings.NewReader("bish"))
+	r.Header.Set("Content-Encoding", "compress")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 415, rec.Code)
+}
+
+func TestDecompressMalformed(t *testing.T) {
+	st := New(Decompress()).ThenHandlerFunc(echoBodyHandler)
+
+	r, _ := http.NewRequest("POST", "/", strings.NewReader("not gzip"))
+	r.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 400, rec.Code)
+}
+
+func TestDecompressMaxSize(t *testing.T) {
+	st := New(Decompress(DecompressMaxSize(10))).ThenHandlerFunc(echoBodyHandler)
+
+	r, _ := http.NewRequest("POST", "/", bytes.NewReader(gzipBytes(strings.Repeat("a", 1000))))
+	r.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 413, rec.Code)
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed *bool
+}
+
+func (cr closeRecorder) Close() error {
+	*cr.closed = true
+	return nil
+}
+
+func TestDecompressClosesDecoders(t *testing.T) {
+	var closed bool
+	st := New(Decompress(DecompressDecoder("bish", func(r io.Reader) (io.ReadCloser, error) {
+		return closeRecorder{r, &closed}, nil
+	}))).ThenHandlerFunc(echoBodyHandler)
+
+	r, _ := http.NewRequest("POST", "/", strings.NewReader("bash"))
+	r.Header.Set("Content-Encoding", "bish")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, `bash [ce=""]`, rec.Body.String())
+	assertEquals(t, true, closed)
+}
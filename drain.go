@@ -0,0 +1,50 @@
+package stack
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrDraining is passed to the chain's error handler (wrapped in an
+// HTTPError with status 503) for requests rejected by Drain.
+var ErrDraining = errors.New("stack: server is shutting down")
+
+// Drain returns middleware for zero-downtime deploys, which changes how
+// requests are handled while a server started with Serve is draining (see
+// ServeShutdownDelay). Responses get a "Connection: close" header, so
+// that clients reconnect to another instance for their next request.
+// Requests with methods which aren't idempotent, such as POST and PATCH,
+// are rejected with a 503 and a Retry-After header, as a client can't
+// safely retry them if the connection is closed before they complete.
+// Requests which had started before the server began draining are
+// unaffected.
+func Drain(retryAfter time.Duration) chainMiddleware {
+	seconds := strconv.Itoa(int((retryAfter + time.Second - 1) / time.Second))
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !Draining(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Connection", "close")
+			if !idempotent(r.Method) {
+				w.Header().Set("Retry-After", seconds)
+				Error(ctx, w, r, NewHTTPError(http.StatusServiceUnavailable, ErrDraining))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// idempotent reports whether method is idempotent, as defined by RFC 9110
+// section 9.2.2.
+func idempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package stack
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	st := New(Drain(1500 * time.Millisecond)).Then(bishHandler)
+	state := &serverState{}
+	serve := func(method string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, "/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), serverStateKey{}, state))
+		rec := httptest.NewRecorder()
+		st.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := serve("POST")
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "", rec.Header().Get("Connection"))
+
+	atomic.StoreInt32(&state.draining, 1)
+
+	rec = serve("GET")
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "close", rec.Header().Get("Connection"))
+
+	rec = serve("POST")
+	assertEquals(t, 503, rec.Code)
+	assertEquals(t, "close", rec.Header().Get("Connection"))
+	assertEquals(t, "2", rec.Header().Get("Retry-After"))
+}
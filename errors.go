@@ -0,0 +1,65 @@
+package stack
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrorHandlerFunc handles errors raised by middleware and handlers in a
+// chain, typically by writing an error response.
+type ErrorHandlerFunc func(ctx *Context, w http.ResponseWriter, r *http.Request, err error)
+
+// HTTPError is an error carrying the HTTP status code which should be sent
+// to the client.
+type HTTPError struct {
+	Status int
+	Err    error
+}
+
+// NewHTTPError returns an HTTPError with the given status code, wrapping
+// err (which may be nil).
+func NewHTTPError(status int, err error) *HTTPError {
+	return &HTTPError{Status: status, Err: err}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return http.StatusText(e.Status)
+}
+
+// Unwrap returns the underlying error, so that errors.Is and errors.As
+// see through an HTTPError.
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode returns the status code associated with err: the Status of
+// the first *HTTPError in its chain, or 500 for any other error.
+func StatusCode(err error) int {
+	var he *HTTPError
+	if errors.As(err, &he) {
+		return he.Status
+	}
+	return http.StatusInternalServerError
+}
+
+// defaultErrorHandler sends the status text for the error's status code,
+// so that internal error messages are never leaked to clients.
+func defaultErrorHandler(ctx *Context, w http.ResponseWriter, r *http.Request, err error) {
+	status := StatusCode(err)
+	http.Error(w, http.StatusText(status), status)
+}
+
+// Error passes err to the error handler for the chain which ctx belongs
+// to. Middleware should call it, and then return without calling the next
+// handler, whenever they need to abort the request.
+func Error(ctx *Context, w http.ResponseWriter, r *http.Request, err error) {
+	ctx.err = err
+	if ctx.errorHandler != nil {
+		ctx.errorHandler(ctx, w, r, err)
+		return
+	}
+	defaultErrorHandler(ctx, w, r, err)
+}
@@ -0,0 +1,46 @@
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func failingMiddleware(ctx *Context, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Error(ctx, w, r, NewHTTPError(418, errors.New("bish")))
+	})
+}
+
+func TestDefaultErrorHandler(t *testing.T) {
+	st := New(failingMiddleware).Then(bishHandler)
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 418, rec.Code)
+	assertEquals(t, "I'm a teapot\n", rec.Body.String())
+}
+
+func TestOnError(t *testing.T) {
+	st := New(failingMiddleware).OnError(func(ctx *Context, w http.ResponseWriter, r *http.Request, err error) {
+		w.WriteHeader(StatusCode(err))
+		fmt.Fprintf(w, "custom: %v", err)
+	}).Then(bishHandler)
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 418, rec.Code)
+	assertEquals(t, "custom: bish", rec.Body.String())
+}
+
+func TestStatusCode(t *testing.T) {
+	assertEquals(t, 404, StatusCode(NewHTTPError(404, nil)))
+	assertEquals(t, 500, StatusCode(errors.New("bish")))
+	assertEquals(t, "Not Found", NewHTTPError(404, nil).Error())
+	assertEquals(t, 406, StatusCode(fmt.Errorf("rendering: %w", NewHTTPError(406, ErrNotAcceptable))))
+	assertEquals(t, true, errors.Is(NewHTTPError(406, ErrNotAcceptable), ErrNotAcceptable))
+}
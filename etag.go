@@ -0,0 +1,251 @@
+package stack
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const skipETagKey = "stack.skipETag"
+
+// ETagOption configures the ETag middleware.
+type ETagOption func(*etagConfig)
+
+// ETagWeak makes the middleware generate weak (W/"...") ETags.
+func ETagWeak() ETagOption {
+	return func(c *etagConfig) {
+		c.weak = true
+	}
+}
+
+// ETagMaxBytes sets the largest body the middleware buffers to hash. Larger
+// responses are streamed through without an ETag. The default is 1MB.
+func ETagMaxBytes(n int64) ETagOption {
+	return func(c *etagConfig) {
+		c.maxBytes = n
+	}
+}
+
+type etagConfig struct {
+	weak     bool
+	maxBytes int64
+}
+
+// SkipETag opts the current request out of the ETag middleware. It should
+// be called by a handler before it writes the response, and is intended
+// for dynamic endpoints where buffering and hashing the body is pointless.
+func SkipETag(ctx *Context) {
+	ctx.Put(skipETagKey, true)
+}
+
+// ETag returns middleware which buffers successful GET and HEAD responses,
+// sets an ETag header derived from a hash of the body and answers
+// conditional requests carrying If-None-Match or If-Modified-Since with
+// 304 Not Modified and an empty body. If the handler sets an ETag itself,
+// the body isn't buffered, and only conditional requests are answered.
+//
+// Responses are streamed through unchanged if the handler calls SkipETag,
+// writes a non-200 status, flushes the response or writes more than the
+// limit set with ETagMaxBytes.
+func ETag(opts ...ETagOption) chainMiddleware {
+	cfg := &etagConfig{maxBytes: 1 << 20}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return LintAs(func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "GET" && r.Method != "HEAD" || IsWebSocketUpgrade(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ew := &etagWriter{ResponseWriter: w, ctx: ctx, r: r, cfg: cfg}
+			next.ServeHTTP(PreserveInterfaces(ew), r)
+			ew.finish()
+		})
+	}, ComputesETags)
+}
+
+type etagWriter struct {
+	http.ResponseWriter
+	ctx         *Context
+	r           *http.Request
+	cfg         *etagConfig
+	buf         bytes.Buffer
+	status      int
+	passthrough bool
+	// discard is set once a 304 has been sent for a handler's own ETag.
+	discard bool
+}
+
+func (ew *etagWriter) WriteHeader(code int) {
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		ew.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if ew.status != 0 {
+		return
+	}
+	ew.status = code
+	if code != http.StatusOK || ew.ctx.Exists(skipETagKey) {
+		ew.passthrough = true
+		ew.ResponseWriter.WriteHeader(code)
+		return
+	}
+	// There's no need to hash the body if the handler has an ETag of its
+	// own, such as one derived from a version number.
+	h := ew.Header()
+	if etag := h.Get("ETag"); etag != "" {
+		ew.passthrough = true
+		if notModified(ew.r, etag, h.Get("Last-Modified")) {
+			ew.discard = true
+			h.Del("Content-Type")
+			h.Del("Content-Length")
+			code = http.StatusNotModified
+		}
+		ew.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (ew *etagWriter) Write(p []byte) (int, error) {
+	if ew.status == 0 {
+		ew.WriteHeader(http.StatusOK)
+	}
+	if !ew.passthrough && int64(ew.buf.Len()+len(p)) > ew.cfg.maxBytes {
+		ew.stream()
+	}
+	switch {
+	case ew.discard:
+		return len(p), nil
+	case ew.passthrough:
+		return ew.ResponseWriter.Write(p)
+	}
+	return ew.buf.Write(p)
+}
+
+// ReadFrom buffers src, or hands it to the underlying ResponseWriter's
+// ReadFrom once the response is being streamed through unchanged.
+func (ew *etagWriter) ReadFrom(src io.Reader) (int64, error) {
+	if ew.status == 0 {
+		ew.WriteHeader(http.StatusOK)
+	}
+	if !ew.passthrough || ew.discard {
+		// Go through Write, so that the buffer limit applies.
+		return io.Copy(writerOnly{ew}, src)
+	}
+	if rf, ok := ew.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+	return io.Copy(writerOnly{ew.ResponseWriter}, src)
+}
+
+// Flush gives up on generating an ETag and streams the response from this
+// point on.
+func (ew *etagWriter) Flush() {
+	if ew.status == 0 {
+		ew.WriteHeader(http.StatusOK)
+	}
+	if !ew.passthrough {
+		ew.stream()
+	}
+	if f, ok := ew.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// stream gives up on generating an ETag, writing out what has been
+// buffered so far.
+func (ew *etagWriter) stream() {
+	ew.passthrough = true
+	ew.ResponseWriter.WriteHeader(ew.status)
+	ew.ResponseWriter.Write(ew.buf.Bytes())
+	ew.buf.Reset()
+}
+
+func (ew *etagWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	ew.passthrough = true
+	return hijack(ew.ResponseWriter)
+}
+
+// Unwrap returns the underlying ResponseWriter.
+func (ew *etagWriter) Unwrap() http.ResponseWriter {
+	return ew.ResponseWriter
+}
+
+func (ew *etagWriter) finish() {
+	if ew.passthrough || ew.status == 0 {
+		return
+	}
+	h := ew.Header()
+	etag := fmt.Sprintf(`"%x"`, sha1.Sum(ew.buf.Bytes()))
+	if ew.cfg.weak {
+		etag = "W/" + etag
+	}
+	h.Set("ETag", etag)
+
+	if notModified(ew.r, etag, h.Get("Last-Modified")) {
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		ew.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+	ew.ResponseWriter.WriteHeader(ew.status)
+	ew.ResponseWriter.Write(ew.buf.Bytes())
+}
+
+// notModified reports whether a GET or HEAD request's conditional headers
+// are satisfied by the current representation. If-None-Match takes
+// precedence over If-Modified-Since, as required by RFC 7232.
+func notModified(r *http.Request, etag string, lastModified string) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagListMatch(inm, etag, false)
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || lastModified == "" {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	lm, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !lm.Truncate(time.Second).After(t)
+}
+
+// etagListMatch reports whether etag matches any entry in a comma-separated
+// If-Match or If-None-Match header value, or the header is "*". Strong
+// comparison, as If-Match requires, also requires that neither tag is
+// weak, so "*" only matches a strong etag.
+func etagListMatch(header string, etag string, strong bool) bool {
+	if etag == "" {
+		return false
+	}
+	if strong && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strong {
+			if candidate == etag {
+				return true
+			}
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package stack
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestETag(t *testing.T) {
+	st := New(ETag()).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "bish bash bosh")
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "bish bash bosh", rec.Body.String())
+	etag := rec.Header().Get("ETag")
+	assertEquals(t, 42, len(etag))
+
+	r.Header.Set("If-None-Match", `"other", `+etag)
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 304, rec.Code)
+	assertEquals(t, "", rec.Body.String())
+	assertEquals(t, etag, rec.Header().Get("ETag"))
+}
+
+func TestETagWeak(t *testing.T) {
+	st := New(ETag(ETagWeak())).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "bish bash bosh")
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	etag := rec.Header().Get("ETag")
+	assertEquals(t, "W/", etag[:2])
+
+	r.Header.Set("If-None-Match", etag[2:])
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 304, rec.Code)
+}
+
+func TestETagIfModifiedSince(t *testing.T) {
+	st := New(ETag()).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+		fmt.Fprint(w, "bish bash bosh")
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("If-Modified-Since", "Wed, 21 Oct 2015 07:28:00 GMT")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 304, rec.Code)
+
+	r.Header.Set("If-Modified-Since", "Tue, 20 Oct 2015 07:28:00 GMT")
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 200, rec.Code)
+}
+
+func TestSkipETag(t *testing.T) {
+	st := New(ETag()).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		SkipETag(ctx)
+		fmt.Fprint(w, "bish bash bosh")
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, "", rec.Header().Get("ETag"))
+	assertEquals(t, "bish bash bosh", rec.Body.String())
+}
+
+func TestETagNonOK(t *testing.T) {
+	st := New(ETag()).ThenHandler(http.NotFoundHandler())
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("If-None-Match", "*")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 404, rec.Code)
+	assertEquals(t, "", rec.Header().Get("ETag"))
+}
+
+func TestETagFromHandler(t *testing.T) {
+	st := New(ETag()).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, "bish")
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, `"v1"`, rec.Header().Get("ETag"))
+	assertEquals(t, "bish", rec.Body.String())
+
+	r.Header.Set("If-None-Match", `"v1"`)
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 304, rec.Code)
+	assertEquals(t, "", rec.Body.String())
+}
+
+func TestETagMaxBytes(t *testing.T) {
+	st := New(ETag(ETagMaxBytes(8))).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "bish ")
+		fmt.Fprint(w, r.URL.Query().Get("more"))
+	})
+
+	r, _ := http.NewRequest("GET", "/?more=bash", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "", rec.Header().Get("ETag"))
+	assertEquals(t, "bish bash", rec.Body.String())
+
+	r, _ = http.NewRequest("GET", "/?more=ba", nil)
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 42, len(rec.Header().Get("ETag")))
+	assertEquals(t, "bish ba", rec.Body.String())
+}
@@ -0,0 +1,77 @@
+package stack
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// CSV streams a CSV response, for exports too large to build in memory. It
+// calls next for each record until next returns io.EOF, writing header
+// (unless it is nil) before the first record. The response is written with
+// Stream, so it is flushed periodically (through the Compress middleware,
+// if the chain uses it) and writes fail once the client has gone away.
+//
+// The header isn't written until next has returned its first record, so an
+// error from the first call (such as a failed query) is passed to the
+// chain's error handler rather than truncating the response. Later errors
+// are reported as described for Stream. CSV returns the first error other
+// than io.EOF.
+func CSV(ctx *Context, w http.ResponseWriter, header []string, next func() ([]string, error)) error {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	return Stream(ctx, w, func(w io.Writer) error {
+		cw := csv.NewWriter(w)
+		write := func(record []string) error {
+			// Flush each record so that Stream sees it, rather than
+			// waiting for csv.Writer's buffer to fill.
+			cw.Write(record)
+			cw.Flush()
+			return cw.Error()
+		}
+		for {
+			record, err := next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if header != nil {
+				if err := write(header); err != nil {
+					return err
+				}
+				header = nil
+			}
+			if err := write(record); err != nil {
+				return err
+			}
+		}
+		if header != nil {
+			return write(header)
+		}
+		return nil
+	})
+}
+
+// NDJSON streams a newline-delimited JSON response, with one value per
+// line. It calls next for each value until next returns io.EOF. Errors are
+// handled as for CSV.
+func NDJSON(ctx *Context, w http.ResponseWriter, next func() (interface{}, error)) error {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	return Stream(ctx, w, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		for {
+			v, err := next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := enc.Encode(v); err != nil {
+				return err
+			}
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package stack
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// rowsOf returns a func which yields rows and then io.EOF.
+func rowsOf(rows ...[]string) func() ([]string, error) {
+	return func() ([]string, error) {
+		if len(rows) == 0 {
+			return nil, io.EOF
+		}
+		row := rows[0]
+		rows = rows[1:]
+		return row, nil
+	}
+}
+
+func TestCSV(t *testing.T) {
+	var next func() ([]string, error)
+	st := New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		CSV(ctx, w, []string{"name", "note"}, next)
+	})
+
+	next = rowsOf([]string{"bish", "a, b"}, []string{"bash", `"c"`})
+	rec := serveFile(st, "/")
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	assertEquals(t, "name,note\nbish,\"a, b\"\nbash,\"\"\"c\"\"\"\n", rec.Body.String())
+
+	next = rowsOf()
+	rec = serveFile(st, "/")
+	assertEquals(t, "name,note\n", rec.Body.String())
+
+	next = func() ([]string, error) { return nil, errors.New("query failed") }
+	rec = serveFile(st, "/")
+	assertEquals(t, 500, rec.Code)
+}
+
+func TestCSVCompressed(t *testing.T) {
+	st := New(Compress()).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		CSV(ctx, w, nil, rowsOf([]string{"bish"}, []string{"bash"}))
+	})
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, "gzip", rec.Header().Get("Content-Encoding"))
+	gr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(gr)
+	assertEquals(t, "bish\nbash\n", string(body))
+}
+
+func TestNDJSON(t *testing.T) {
+	values := []interface{}{map[string]int{"bish": 1}, "bash", nil}
+	st := New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		i := 0
+		NDJSON(ctx, w, func() (interface{}, error) {
+			if i == len(values) {
+				return nil, io.EOF
+			}
+			i++
+			return values[i-1], nil
+		})
+	})
+	rec := serveFile(st, "/")
+	assertEquals(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	assertEquals(t, "{\"bish\":1}\n\"bash\"\nnull\n", rec.Body.String())
+
+	st = New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		NDJSON(ctx, w, func() (interface{}, error) { return make(chan int), nil })
+	})
+	rec = serveFile(st, "/")
+	assertEquals(t, 500, rec.Code)
+}
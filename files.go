@@ -0,0 +1,245 @@
+package stack
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileOption configures the static file handler created by ThenFiles.
+type FileOption func(*fileConfig)
+
+// FileIndex sets the names of the files served for a directory request,
+// in order of preference. The default is "index.html".
+func FileIndex(names ...string) FileOption {
+	return func(c *fileConfig) {
+		c.index = names
+	}
+}
+
+// FileListing enables or disables HTML listings for directories which
+// don't contain an index file. Listings are disabled by default.
+func FileListing(enabled bool) FileOption {
+	return func(c *fileConfig) {
+		c.listing = enabled
+	}
+}
+
+// FileMaxAge sets the max-age used in the Cache-Control header for files
+// which aren't fingerprinted. The default is zero, which sends
+// "Cache-Control: no-cache" so that clients always revalidate.
+func FileMaxAge(d time.Duration) FileOption {
+	return func(c *fileConfig) {
+		c.maxAge = d
+	}
+}
+
+// FileFingerprint sets the pattern used to recognise fingerprinted file
+// names (such as "app.3f2a9c1e.css"). Matching files are served with a
+// one year, immutable Cache-Control header. Passing nil disables this.
+func FileFingerprint(re *regexp.Regexp) FileOption {
+	return func(c *fileConfig) {
+		c.fingerprint = re
+	}
+}
+
+// FileSPA makes requests for missing files fall back to serving the named
+// file (usually "/index.html"), as needed by single-page applications
+// which do their own client-side routing.
+func FileSPA(fallback string) FileOption {
+	return func(c *fileConfig) {
+		c.fallback = path.Clean("/" + fallback)
+	}
+}
+
+var defaultFingerprint = regexp.MustCompile(`[.-][0-9a-fA-F]{8,}\.[^/.]+$`)
+
+type fileConfig struct {
+	root        http.FileSystem
+	index       []string
+	listing     bool
+	maxAge      time.Duration
+	fingerprint *regexp.Regexp
+	fallback    string
+}
+
+// ThenFiles finishes the chain with a handler serving files from root. The
+// request path is cleaned before use, directories are served using their
+// index file, and missing files are passed to the chain's error handler
+// with a 404 status (unless an SPA fallback is configured).
+//
+// Files are served with http.ServeContent, so Range and If-Range requests
+// are supported. When root is an http.Dir the body is copied with the
+// operating system's sendfile, provided any ResponseWriter wrappers in the
+// chain implement io.ReaderFrom (as all of the wrappers in this package
+// do).
+func (c Chain) ThenFiles(root http.FileSystem, opts ...FileOption) HandlerChain {
+	cfg := &fileConfig{
+		root:        root,
+		index:       []string{"index.html"},
+		fingerprint: defaultFingerprint,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	c.h = func(ctx *Context) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg.serve(ctx, w, r)
+		})
+	}
+	return newHandlerChain(c)
+}
+
+func (cfg *fileConfig) serve(ctx *Context, w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		Error(ctx, w, r, NewHTTPError(http.StatusMethodNotAllowed, nil))
+		return
+	}
+	if strings.Contains(r.URL.Path, "\x00") {
+		Error(ctx, w, r, NewHTTPError(http.StatusBadRequest, nil))
+		return
+	}
+	// Files have validators of their own, and may be too big to buffer.
+	SkipETag(ctx)
+	name := path.Clean("/" + r.URL.Path)
+
+	f, fi, err := cfg.open(name)
+	if err != nil {
+		if os.IsNotExist(err) && cfg.fallback != "" {
+			cfg.serveFallback(ctx, w, r)
+			return
+		}
+		Error(ctx, w, r, fileError(err))
+		return
+	}
+	defer f.Close()
+
+	if fi.IsDir() {
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			redirectWithQuery(w, r, path.Base(name)+"/")
+			return
+		}
+		for _, index := range cfg.index {
+			ff, ffi, err := cfg.open(path.Join(name, index))
+			if err == nil && !ffi.IsDir() {
+				defer ff.Close()
+				cfg.serveFile(w, r, ff, ffi)
+				return
+			}
+			if err == nil {
+				ff.Close()
+			}
+		}
+		if !cfg.listing {
+			Error(ctx, w, r, NewHTTPError(http.StatusForbidden, nil))
+			return
+		}
+		cfg.serveListing(ctx, w, r, f)
+		return
+	}
+	cfg.serveFile(w, r, f, fi)
+}
+
+func (cfg *fileConfig) open(name string) (http.File, os.FileInfo, error) {
+	f, err := cfg.root.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, fi, nil
+}
+
+func (cfg *fileConfig) serveFile(w http.ResponseWriter, r *http.Request, f http.File, fi os.FileInfo) {
+	h := w.Header()
+	if h.Get("Cache-Control") == "" {
+		switch {
+		case cfg.fingerprint != nil && cfg.fingerprint.MatchString(fi.Name()):
+			h.Set("Cache-Control", "public, max-age=31536000, immutable")
+		case cfg.maxAge > 0:
+			h.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(cfg.maxAge.Seconds())))
+		default:
+			h.Set("Cache-Control", "no-cache")
+		}
+	}
+	// A validator derived from the modification time and size lets
+	// ServeContent answer If-None-Match and If-Range requests without
+	// reading the file.
+	if h.Get("ETag") == "" {
+		h.Set("ETag", fileETag(fi))
+	}
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+}
+
+func (cfg *fileConfig) serveFallback(ctx *Context, w http.ResponseWriter, r *http.Request) {
+	f, fi, err := cfg.open(cfg.fallback)
+	if err != nil || fi.IsDir() {
+		if err == nil {
+			f.Close()
+		}
+		Error(ctx, w, r, NewHTTPError(http.StatusNotFound, nil))
+		return
+	}
+	defer f.Close()
+	// The fallback document stands in for many URLs, so never let it be
+	// cached as if it were the real resource.
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+}
+
+func (cfg *fileConfig) serveListing(ctx *Context, w http.ResponseWriter, r *http.Request, dir http.File) {
+	fis, err := dir.Readdir(-1)
+	if err != nil {
+		Error(ctx, w, r, err)
+		return
+	}
+	sort.Sort(byName(fis))
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<pre>\n")
+	for _, fi := range fis {
+		name := fi.Name()
+		if fi.IsDir() {
+			name += "/"
+		}
+		u := url.URL{Path: name}
+		fmt.Fprintf(w, "<a href=\"%s\">%s</a>\n", html.EscapeString(u.String()), html.EscapeString(name))
+	}
+	fmt.Fprintf(w, "</pre>\n")
+}
+
+type byName []os.FileInfo
+
+func (b byName) Len() int           { return len(b) }
+func (b byName) Less(i, j int) bool { return b[i].Name() < b[j].Name() }
+func (b byName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+func fileError(err error) error {
+	switch {
+	case os.IsNotExist(err):
+		return NewHTTPError(http.StatusNotFound, err)
+	case os.IsPermission(err):
+		return NewHTTPError(http.StatusForbidden, err)
+	}
+	return err
+}
+
+// redirectWithQuery redirects to target (relative to the current path),
+// keeping the request's query string.
+func redirectWithQuery(w http.ResponseWriter, r *http.Request, target string) {
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusMovedPermanently)
+}
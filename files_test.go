@@ -0,0 +1,113 @@
+package stack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func serveFile(hc HandlerChain, path string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("GET", path, nil)
+	rec := httptest.NewRecorder()
+	hc.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestThenFiles(t *testing.T) {
+	st := New().ThenFiles(http.Dir("testdata/static"))
+
+	rec := serveFile(st, "/")
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "<h1>bish</h1>\n", rec.Body.String())
+	assertEquals(t, "no-cache", rec.Header().Get("Cache-Control"))
+
+	rec = serveFile(st, "/css/../css/site.css")
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "body{}\n", rec.Body.String())
+
+	rec = serveFile(st, "/../../files.go")
+	assertEquals(t, 404, rec.Code)
+
+	rec = serveFile(st, "/css?bish=bash")
+	assertEquals(t, 301, rec.Code)
+	assertEquals(t, "/css/?bish=bash", rec.Header().Get("Location"))
+
+	rec = serveFile(st, "/css/")
+	assertEquals(t, 403, rec.Code)
+}
+
+func TestThenFilesSkipsETag(t *testing.T) {
+	var skipped bool
+	check := func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			skipped = ctx.Exists(skipETagKey)
+		})
+	}
+	st := New(check, ETag()).ThenFiles(http.Dir("testdata/static"))
+
+	rec := serveFile(st, "/css/site.css")
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, true, skipped)
+	fi, _ := os.Stat("testdata/static/css/site.css")
+	assertEquals(t, fileETag(fi), rec.Header().Get("ETag"))
+}
+
+func TestThenFilesCacheHeaders(t *testing.T) {
+	st := New().ThenFiles(http.Dir("testdata/static"), FileMaxAge(time.Hour))
+
+	rec := serveFile(st, "/css/app.3f2a9c1e.css")
+	assertEquals(t, "public, max-age=31536000, immutable", rec.Header().Get("Cache-Control"))
+
+	rec = serveFile(st, "/css/site.css")
+	assertEquals(t, "public, max-age=3600", rec.Header().Get("Cache-Control"))
+}
+
+func TestThenFilesListing(t *testing.T) {
+	st := New().ThenFiles(http.Dir("testdata/static"), FileListing(true))
+
+	rec := serveFile(st, "/css/")
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "<pre>\n<a href=\"app.3f2a9c1e.css\">app.3f2a9c1e.css</a>\n<a href=\"site.css\">site.css</a>\n</pre>\n", rec.Body.String())
+}
+
+func TestThenFilesSPA(t *testing.T) {
+	st := New().ThenFiles(http.Dir("testdata/static"), FileSPA("index.html"))
+
+	rec := serveFile(st, "/users/42")
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "<h1>bish</h1>\n", rec.Body.String())
+}
+
+func TestThenFilesMiddleware(t *testing.T) {
+	st := New(flipMiddleware).ThenFiles(http.Dir("testdata/static"))
+
+	rec := serveFile(st, "/css/site.css")
+	assertEquals(t, "flipMiddleware>body{}\n", rec.Body.String())
+}
+
+func TestThenFilesRange(t *testing.T) {
+	st := New().ThenFiles(http.Dir("testdata/static"))
+
+	r, _ := http.NewRequest("GET", "/logo.png", nil)
+	r.Header.Set("Range", "bytes=0-2")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 206, rec.Code)
+	assertEquals(t, "PNG", rec.Body.String())
+	assertEquals(t, "bytes", rec.Header().Get("Accept-Ranges"))
+	etag := rec.Header().Get("ETag")
+
+	r.Header.Set("If-Range", etag)
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 206, rec.Code)
+
+	r.Header.Set("If-Range", `"stale"`)
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "PNG-ish binary data 0123456789", rec.Body.String())
+}
@@ -0,0 +1,63 @@
+package stack
+
+import "net/http"
+
+const flagsKey = "stack.flags"
+
+// FlagProvider decides which feature flags are set for a request. It can
+// inspect the request and anything stored in the Context by earlier
+// middleware, such as the principal, to roll features out to some users.
+type FlagProvider interface {
+	Flags(ctx *Context, r *http.Request) (map[string]bool, error)
+}
+
+// FlagProviderFunc adapts an ordinary function into a FlagProvider.
+type FlagProviderFunc func(ctx *Context, r *http.Request) (map[string]bool, error)
+
+// Flags calls fn(ctx, r).
+func (fn FlagProviderFunc) Flags(ctx *Context, r *http.Request) (map[string]bool, error) {
+	return fn(ctx, r)
+}
+
+// StaticFlags is a FlagProvider which gives every request the same flags.
+type StaticFlags map[string]bool
+
+// Flags returns the map itself.
+func (sf StaticFlags) Flags(ctx *Context, r *http.Request) (map[string]bool, error) {
+	return sf, nil
+}
+
+// FeatureFlags returns middleware which resolves the feature flags for
+// each request with provider and stores them in the Context, where they
+// can be read with Flag. If the chain contains more than one FeatureFlags
+// middleware, flags from later ones take precedence. Errors returned by
+// the provider are passed to the chain's error handler.
+func FeatureFlags(provider FlagProvider) chainMiddleware {
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			flags, err := provider.Flags(ctx, r)
+			if err != nil {
+				Error(ctx, w, r, err)
+				return
+			}
+			resolved := make(map[string]bool, len(flags))
+			if prev, ok := ctx.Get(flagsKey).(map[string]bool); ok {
+				for name, on := range prev {
+					resolved[name] = on
+				}
+			}
+			for name, on := range flags {
+				resolved[name] = on
+			}
+			ctx.Put(flagsKey, resolved)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Flag reports whether the named feature flag is set for the current
+// request. Flags which no provider set are treated as unset.
+func Flag(ctx *Context, name string) bool {
+	flags, _ := ctx.Get(flagsKey).(map[string]bool)
+	return flags[name]
+}
@@ -0,0 +1,44 @@
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func flagHandler(ctx *Context, w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "bish=%v flip=%v wobble=%v", Flag(ctx, "bish"), Flag(ctx, "flip"), Flag(ctx, "wobble"))
+}
+
+func TestFeatureFlags(t *testing.T) {
+	byPath := FlagProviderFunc(func(ctx *Context, r *http.Request) (map[string]bool, error) {
+		return map[string]bool{"flip": r.URL.Path == "/beta", "bish": false}, nil
+	})
+	st := New(FeatureFlags(StaticFlags{"bish": true, "wobble": true}), FeatureFlags(byPath)).Then(flagHandler)
+
+	r, _ := http.NewRequest("GET", "/beta", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, "bish=false flip=true wobble=true", rec.Body.String())
+
+	r, _ = http.NewRequest("GET", "/", nil)
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, "bish=false flip=false wobble=true", rec.Body.String())
+
+	assertEquals(t, false, Flag(NewContext(), "bish"))
+}
+
+func TestFeatureFlagsError(t *testing.T) {
+	failing := FlagProviderFunc(func(ctx *Context, r *http.Request) (map[string]bool, error) {
+		return nil, errors.New("flag service unavailable")
+	})
+	st := New(FeatureFlags(failing)).Then(flagHandler)
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 500, rec.Code)
+}
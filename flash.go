@@ -0,0 +1,91 @@
+package stack
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+const (
+	flashesKey      = "stack.flashes"
+	flashQueueKey   = "stack.flashQueue"
+	flashCookieName = "flash"
+)
+
+// Flash is a one-time message for the user, such as "Your changes have
+// been saved".
+type Flash struct {
+	Level   string `json:"l"`
+	Message string `json:"m"`
+}
+
+type flashQueue struct {
+	mu      sync.Mutex
+	flashes []Flash
+}
+
+// FlashMessages returns middleware which supports flash messages: messages
+// queued with AddFlash are stored in a signed cookie and made available to
+// the next request through Flashes, after which they are cleared. The
+// chain must have a CookieCodec (see Chain.UseCookieCodec) and record
+// responses (see Chain.RecordResponses), and Then panics if it doesn't.
+func FlashMessages() chainMiddleware {
+	return Requires(flashMessages, NeedsCookieCodec, NeedsResponses)
+}
+
+func flashMessages(ctx *Context, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ctx.cookieCodec == nil {
+			Error(ctx, w, r, ErrNoCookieCodec)
+			return
+		}
+
+		var current []Flash
+		if val, err := ReadSignedCookie(ctx, r, flashCookieName); err == nil {
+			json.Unmarshal([]byte(val), &current)
+		}
+		_, cookieErr := r.Cookie(flashCookieName)
+		hadCookie := cookieErr == nil
+		ctx.Put(flashesKey, current)
+
+		queue := &flashQueue{}
+		ctx.Put(flashQueueKey, queue)
+
+		BeforeWrite(ctx, func(w http.ResponseWriter) {
+			queue.mu.Lock()
+			defer queue.mu.Unlock()
+			cookie := &http.Cookie{Name: flashCookieName, Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode}
+			if len(queue.flashes) > 0 {
+				b, _ := json.Marshal(queue.flashes)
+				cookie.Value = string(b)
+				SetSignedCookie(ctx, w, cookie)
+				return
+			}
+			if hadCookie {
+				cookie.MaxAge = -1
+				http.SetCookie(w, cookie)
+			}
+		})
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AddFlash queues a message to be shown on the next request. It has no
+// effect unless the FlashMessages middleware is in the chain, and messages
+// added after the response headers have been written are lost.
+func AddFlash(ctx *Context, level string, message string) {
+	queue, ok := ctx.Get(flashQueueKey).(*flashQueue)
+	if !ok {
+		return
+	}
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	queue.flashes = append(queue.flashes, Flash{Level: level, Message: message})
+}
+
+// Flashes returns the messages queued by the previous request, for
+// rendering in the current response.
+func Flashes(ctx *Context) []Flash {
+	flashes, _ := ctx.Get(flashesKey).([]Flash)
+	return flashes
+}
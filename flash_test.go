@@ -0,0 +1,48 @@
+package stack
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFlashMessages(t *testing.T) {
+	cc, _ := NewCookieCodec([][]byte{testSigningKey}, nil)
+	st := New(FlashMessages()).RecordResponses().UseCookieCodec(cc).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			AddFlash(ctx, "info", "Saved")
+			AddFlash(ctx, "warning", "Check your email")
+			http.Redirect(w, r, "/", 303)
+			return
+		}
+		fmt.Fprintf(w, "%v", Flashes(ctx))
+	})
+
+	r, _ := http.NewRequest("POST", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 303, rec.Code)
+	cookie := (&http.Response{Header: rec.Header()}).Cookies()[0]
+
+	r, _ = http.NewRequest("GET", "/", nil)
+	r.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, "[{info Saved} {warning Check your email}]", rec.Body.String())
+	cleared := (&http.Response{Header: rec.Header()}).Cookies()[0]
+	assertEquals(t, -1, cleared.MaxAge)
+
+	r, _ = http.NewRequest("GET", "/", nil)
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, "[]", rec.Body.String())
+	assertEquals(t, "", rec.Header().Get("Set-Cookie"))
+}
+
+func TestFlashMessagesWithoutCodec(t *testing.T) {
+	defer func() {
+		assertEquals(t, "stack: chain middleware require a cookie codec (call UseCookieCodec)", recover())
+	}()
+	New(FlashMessages()).RecordResponses().Then(bishHandler)
+}
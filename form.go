@@ -0,0 +1,109 @@
+package stack
+
+import (
+	"errors"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+)
+
+const (
+	formKey      = "stack.form"
+	formFilesKey = "stack.formFiles"
+)
+
+// FormOption configures the ParseForm middleware.
+type FormOption func(*formConfig)
+
+// FormMaxBytes sets the maximum size of the request body in bytes,
+// including any uploaded files. Larger bodies are rejected with a 413
+// Request Entity Too Large status. The default is 10MB.
+func FormMaxBytes(n int64) FormOption {
+	return func(c *formConfig) {
+		c.maxBytes = n
+	}
+}
+
+// FormMaxMemory sets how many bytes of a multipart form are held in
+// memory. The rest of the uploaded files are stored in temporary files on
+// disk. The default is 1MB.
+func FormMaxMemory(n int64) FormOption {
+	return func(c *formConfig) {
+		c.maxMemory = n
+	}
+}
+
+type formConfig struct {
+	maxBytes  int64
+	maxMemory int64
+}
+
+// ParseForm returns middleware which parses URL-encoded and multipart form
+// bodies, making the values available through Form and the uploaded files
+// through FormFiles. Requests with other content types pass through
+// untouched. Bodies over the size limit are passed to the chain's error
+// handler with a 413 status, and malformed bodies with 400 Bad Request.
+//
+// Temporary files created for uploads are removed once the chain has
+// finished with the request (see Context.Defer).
+func ParseForm(opts ...FormOption) chainMiddleware {
+	cfg := &formConfig{maxBytes: 10 << 20, maxMemory: 1 << 20}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if r.Body == nil || mt != "application/x-www-form-urlencoded" && mt != "multipart/form-data" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, cfg.maxBytes)
+			var err error
+			if mt == "multipart/form-data" {
+				err = r.ParseMultipartForm(cfg.maxMemory)
+				if form := r.MultipartForm; form != nil {
+					ctx.Defer(func() { form.RemoveAll() })
+				}
+			} else {
+				err = r.ParseForm()
+			}
+			if err != nil {
+				Error(ctx, w, r, formError(err))
+				return
+			}
+
+			ctx.Put(formKey, r.PostForm)
+			if r.MultipartForm != nil {
+				ctx.Put(formFilesKey, r.MultipartForm.File)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func formError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return NewHTTPError(http.StatusRequestEntityTooLarge, err)
+	}
+	return NewHTTPError(http.StatusBadRequest, err)
+}
+
+// Form returns the values from the request body parsed by ParseForm, not
+// including the query string. It returns nil if the body wasn't a form.
+func Form(ctx *Context) url.Values {
+	form, _ := ctx.Get(formKey).(url.Values)
+	return form
+}
+
+// FormFiles returns the files uploaded in the named field of a multipart
+// form parsed by ParseForm. Each FileHeader holds the file's name, size
+// and headers, and can be opened to read its contents.
+func FormFiles(ctx *Context, field string) []*multipart.FileHeader {
+	files, _ := ctx.Get(formFilesKey).(map[string][]*multipart.FileHeader)
+	return files[field]
+}
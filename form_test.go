@@ -0,0 +1,92 @@
+package stack
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func multipartBody(t *testing.T, fields map[string]string, files map[string]string) (string, *bytes.Buffer) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	for name, content := range files {
+		fw, err := mw.CreateFormFile("upload", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(fw, content)
+	}
+	mw.Close()
+	return mw.FormDataContentType(), &buf
+}
+
+func TestParseForm(t *testing.T) {
+	var ctx *Context
+	st := New(ParseForm(FormMaxBytes(64))).Then(func(c *Context, w http.ResponseWriter, r *http.Request) {
+		ctx = c
+	})
+
+	r, _ := http.NewRequest("POST", "/?q=1", strings.NewReader("bish=bash&bish=bosh"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "bash,bosh", strings.Join(Form(ctx)["bish"], ","))
+	assertEquals(t, "", Form(ctx).Get("q"))
+
+	r, _ = http.NewRequest("POST", "/", strings.NewReader("bish="+strings.Repeat("b", 64)))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 413, rec.Code)
+
+	r, _ = http.NewRequest("POST", "/", strings.NewReader(`{"bish": "bash"}`))
+	r.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, true, Form(ctx) == nil)
+}
+
+func TestParseFormMultipart(t *testing.T) {
+	var path string
+	st := New(ParseForm(FormMaxMemory(1))).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		assertEquals(t, "bash", Form(ctx).Get("bish"))
+		files := FormFiles(ctx, "upload")
+		assertEquals(t, 1, len(files))
+		assertEquals(t, "notes.txt", files[0].Filename)
+		assertEquals(t, int64(9), files[0].Size)
+		f, err := files[0].Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		// Above the memory limit, the file is stored on disk.
+		path = f.(*os.File).Name()
+		b, _ := io.ReadAll(f)
+		assertEquals(t, "bish bash", string(b))
+	})
+
+	contentType, body := multipartBody(t, map[string]string{"bish": "bash"}, map[string]string{"notes.txt": "bish bash"})
+	r, _ := http.NewRequest("POST", "/", body)
+	r.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 200, rec.Code)
+	_, err := os.Stat(path)
+	assertEquals(t, true, os.IsNotExist(err))
+
+	r, _ = http.NewRequest("POST", "/", strings.NewReader("bish"))
+	r.Header.Set("Content-Type", "multipart/form-data; boundary=bash")
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 400, rec.Code)
+}
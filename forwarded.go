@@ -0,0 +1,175 @@
+package stack
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type forwardedSchemeKey struct{}
+
+// ProxyHeaders selects the headers TrustProxies reads. Only one set is
+// ever used, since a client can send either and a proxy which sets one
+// usually passes the other through untouched.
+type ProxyHeaders int
+
+const (
+	// XForwardedFor reads only X-Forwarded-For. Proxies which set it often
+	// pass X-Forwarded-Host and X-Forwarded-Proto through from the client
+	// untouched, so they aren't trusted.
+	XForwardedFor ProxyHeaders = iota
+	// XForwardedHeaders reads X-Forwarded-Host and X-Forwarded-Proto as
+	// well as X-Forwarded-For. Only use it if the proxy in front of the
+	// application sets (rather than passes on) all three, or clients can
+	// choose the host that AbsoluteURL and Redirect use.
+	XForwardedHeaders
+	// ForwardedHeader reads the standard Forwarded header (RFC 7239).
+	ForwardedHeader
+)
+
+// TrustProxies returns middleware which, for requests from reverse proxies
+// in networks (see FromNetworks), replaces the request's RemoteAddr and
+// Host with the client address and host reported by the proxies, and
+// records the scheme the client used, so that AbsoluteURL and Redirect
+// build URLs the client can follow. headers selects which headers the
+// proxies set.
+//
+// Proxies append to the client address list, so it is read from the
+// right, skipping addresses in networks, and the first address which
+// isn't trusted is taken to be the client's. Anything to the left of it
+// was sent by the client and is ignored. With the Forwarded header the
+// host and protocol come from the same element as the client address;
+// with XForwardedHeaders the right-most X-Forwarded-Host and
+// X-Forwarded-Proto values are used.
+//
+// Requests from other addresses are left alone, as anyone can send the
+// headers.
+func TrustProxies(headers ProxyHeaders, networks ...string) chainMiddleware {
+	trusted := FromNetworks(networks...)
+	isTrusted := func(ctx *Context, addr string) bool {
+		ok, _ := trusted.Allow(ctx, &http.Request{RemoteAddr: addr})
+		return ok
+	}
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isTrusted(ctx, r.RemoteAddr) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			var client, host, proto string
+			if headers == ForwardedHeader {
+				client, host, proto = forwardedValues(r.Header, func(addr string) bool { return isTrusted(ctx, addr) })
+			} else {
+				client, host, proto = xForwardedValues(r.Header, func(addr string) bool { return isTrusted(ctx, addr) })
+				if headers != XForwardedHeaders {
+					host, proto = "", ""
+				}
+			}
+			if client == "" && host == "" && proto == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			r = r.Clone(r.Context())
+			if ip := net.ParseIP(client); ip != nil {
+				r.RemoteAddr = net.JoinHostPort(ip.String(), "0")
+			}
+			if host != "" {
+				r.Host = host
+			}
+			if proto == "http" || proto == "https" {
+				r = r.WithContext(context.WithValue(r.Context(), forwardedSchemeKey{}, proto))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedValues returns the client address, host and protocol from the
+// right-most element of the Forwarded header whose address isn't
+// trusted. An element without a usable address (such as "for=unknown")
+// stops the search, and its host and protocol are used with no client
+// address.
+func forwardedValues(h http.Header, trusted func(addr string) bool) (client, host, proto string) {
+	var elems []string
+	for _, v := range h.Values("Forwarded") {
+		elems = append(elems, strings.Split(v, ",")...)
+	}
+	for i := len(elems) - 1; i >= 0; i-- {
+		client, host, proto = "", "", ""
+		for _, pair := range strings.Split(elems[i], ";") {
+			key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok {
+				continue
+			}
+			val = strings.Trim(val, `"`)
+			switch strings.ToLower(key) {
+			case "for":
+				client = forwardedAddr(val)
+			case "host":
+				host = val
+			case "proto":
+				proto = strings.ToLower(val)
+			}
+		}
+		if client == "" || !trusted(client) {
+			break
+		}
+	}
+	return client, host, proto
+}
+
+// xForwardedValues returns the right-most untrusted address in
+// X-Forwarded-For, with the right-most X-Forwarded-Host and
+// X-Forwarded-Proto values.
+func xForwardedValues(h http.Header, trusted func(addr string) bool) (client, host, proto string) {
+	values := func(name string) []string {
+		var list []string
+		for _, v := range h.Values(name) {
+			for _, s := range strings.Split(v, ",") {
+				list = append(list, strings.TrimSpace(s))
+			}
+		}
+		return list
+	}
+	addrs := values("X-Forwarded-For")
+	for i := len(addrs) - 1; i >= 0; i-- {
+		client = forwardedAddr(addrs[i])
+		if client == "" || !trusted(client) {
+			break
+		}
+	}
+	if hosts := values("X-Forwarded-Host"); len(hosts) > 0 {
+		host = hosts[len(hosts)-1]
+	}
+	if protos := values("X-Forwarded-Proto"); len(protos) > 0 {
+		proto = strings.ToLower(protos[len(protos)-1])
+	}
+	return client, host, proto
+}
+
+// forwardedAddr returns the IP address in a forwarded address, which may
+// carry a port and, for IPv6, brackets ("[2001:db8::1]:4711"), or an
+// empty string if it isn't an IP address.
+func forwardedAddr(val string) string {
+	if h, _, err := net.SplitHostPort(val); err == nil {
+		val = h
+	}
+	ip := net.ParseIP(strings.Trim(val, "[]"))
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// requestScheme returns the scheme the client used for r, as reported by
+// a trusted proxy or otherwise from the connection.
+func requestScheme(r *http.Request) string {
+	if scheme, ok := r.Context().Value(forwardedSchemeKey{}).(string); ok {
+		return scheme
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
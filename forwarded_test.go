@@ -0,0 +1,80 @@
+package stack
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func forwardedRequest(headers ProxyHeaders, remoteAddr string, header http.Header) string {
+	st := New(TrustProxies(headers, "10.0.0.0/8")).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s %s", r.RemoteAddr, requestScheme(r), r.Host)
+	})
+	r := httptest.NewRequest("GET", "http://example.com/", nil)
+	r.RemoteAddr = remoteAddr
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	return rec.Body.String()
+}
+
+func TestTrustProxies(t *testing.T) {
+	xff := http.Header{
+		"X-Forwarded-For":   {"192.0.2.7, 10.0.0.2"},
+		"X-Forwarded-Host":  {"bish.example"},
+		"X-Forwarded-Proto": {"https"},
+	}
+	assertEquals(t, "192.0.2.7:0 https bish.example", forwardedRequest(XForwardedHeaders, "10.0.0.1:1234", xff))
+	assertEquals(t, "192.0.2.1:1234 http example.com", forwardedRequest(XForwardedHeaders, "192.0.2.1:1234", xff))
+	// Without opting in, X-Forwarded-Host and X-Forwarded-Proto may have
+	// come from the client.
+	assertEquals(t, "192.0.2.7:0 http example.com", forwardedRequest(XForwardedFor, "10.0.0.1:1234", xff))
+
+	fwd := http.Header{
+		"Forwarded":       {`for="[2001:db8::1]:4711";proto=https;host=bash.example, for=10.0.0.2`},
+		"X-Forwarded-For": {"192.0.2.7"},
+	}
+	assertEquals(t, "[2001:db8::1]:0 https bash.example", forwardedRequest(ForwardedHeader, "10.0.0.1:1234", fwd))
+
+	assertEquals(t, "10.0.0.1:1234 http example.com", forwardedRequest(XForwardedHeaders, "10.0.0.1:1234", nil))
+	assertEquals(t, "10.0.0.1:1234 http example.com", forwardedRequest(ForwardedHeader, "10.0.0.1:1234", nil))
+}
+
+func TestTrustProxiesRemoteAddr(t *testing.T) {
+	tests := []struct {
+		headers ProxyHeaders
+		header  http.Header
+		addr    string
+	}{
+		// A proxy which overwrites X-Forwarded-For, passing through a
+		// Forwarded header sent by the client.
+		{XForwardedHeaders, http.Header{"X-Forwarded-For": {"203.0.113.9"}, "Forwarded": {"for=127.0.0.1"}}, "203.0.113.9:0"},
+		{ForwardedHeader, http.Header{"X-Forwarded-For": {"127.0.0.1"}, "Forwarded": {"for=203.0.113.9"}}, "203.0.113.9:0"},
+		// Proxies which append to an address list sent by the client.
+		{XForwardedHeaders, http.Header{"X-Forwarded-For": {"127.0.0.1, 203.0.113.9"}}, "203.0.113.9:0"},
+		{XForwardedHeaders, http.Header{"X-Forwarded-For": {"127.0.0.1", "203.0.113.9, 10.0.0.3"}}, "203.0.113.9:0"},
+		{ForwardedHeader, http.Header{"Forwarded": {"for=127.0.0.1, for=203.0.113.9", "for=10.0.0.3"}}, "203.0.113.9:0"},
+		// Every address is trusted, so the client is the left-most.
+		{XForwardedHeaders, http.Header{"X-Forwarded-For": {"10.0.0.4, 10.0.0.3"}}, "10.0.0.4:0"},
+		// An address which can't be parsed stops the search.
+		{ForwardedHeader, http.Header{"Forwarded": {"for=127.0.0.1, for=unknown"}}, "10.0.0.1:1234"},
+		{XForwardedHeaders, http.Header{"X-Forwarded-For": {"127.0.0.1, bish"}}, "10.0.0.1:1234"},
+	}
+	for _, test := range tests {
+		got := forwardedRequest(test.headers, "10.0.0.1:1234", test.header)
+		assertEquals(t, test.addr+" http example.com", got)
+	}
+
+	// A client behind an appending proxy can't pass a network check by
+	// claiming another address.
+	st := New(TrustProxies(XForwardedHeaders, "10.0.0.0/8"), Authorize(FromNetworks("127.0.0.0/8"))).Then(bishHandler)
+	r := httptest.NewRequest("GET", "http://example.com/", nil)
+	r.RemoteAddr = "10.0.0.5:1234"
+	r.Header.Set("X-Forwarded-For", "127.0.0.1, 203.0.113.9")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 401, rec.Code)
+}
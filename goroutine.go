@@ -0,0 +1,91 @@
+package stack
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// PanicError is passed to the chain's reporter when a goroutine started
+// with Go panics.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// UseReporter sets the function which reports errors that can't be sent to
+// the client, such as panics in goroutines started with Go. By default
+// they are written to the standard logger.
+func (c Chain) UseReporter(fn func(ctx *Context, err error)) Chain {
+	c.reporter = fn
+	return c
+}
+
+// WaitForGoroutines makes the chain wait, for at most d, for goroutines
+// started with Go to finish before running its OnFinish callbacks and
+// returning. By default the chain doesn't wait.
+func (c Chain) WaitForGoroutines(d time.Duration) Chain {
+	c.waitGo = d
+	return c
+}
+
+// Go runs fn in a new goroutine with a detached copy of ctx, which holds
+// the same values but can outlive the request. A panic in fn is recovered
+// and passed to the chain's reporter as a *PanicError, rather than
+// crashing the program, and functions registered with detached.Defer are
+// called when fn returns.
+func Go(ctx *Context, fn func(detached *Context)) {
+	detached := ctx.copy()
+	detached.inherit(ctx)
+	wg := ctx.goroutines
+	if wg != nil {
+		wg.Add(1)
+	}
+	go func() {
+		if wg != nil {
+			defer wg.Done()
+		}
+		defer func() {
+			if p := recover(); p != nil {
+				report(detached, &PanicError{Value: p, Stack: debug.Stack()})
+			}
+		}()
+		defer func() {
+			detached.runDeferred(recover())
+		}()
+		fn(detached)
+	}()
+}
+
+func report(ctx *Context, err error) {
+	if ctx.reporter != nil {
+		ctx.reporter(ctx, err)
+		return
+	}
+	if pe, ok := err.(*PanicError); ok {
+		log.Printf("stack: %v\n%s", pe, pe.Stack)
+		return
+	}
+	log.Printf("stack: %v", err)
+}
+
+// waitGoroutines waits for wg, giving up after d.
+func waitGoroutines(wg *sync.WaitGroup, d time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-done:
+	case <-t.C:
+	}
+}
@@ -0,0 +1,84 @@
+package stack
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGo(t *testing.T) {
+	reported := make(chan error, 1)
+	done := make(chan string, 1)
+	st := New(bishMiddleware).UseReporter(func(ctx *Context, err error) {
+		reported <- err
+	}).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		Go(ctx, func(detached *Context) {
+			detached.Defer(func() { done <- detached.Get("bish").(string) })
+			detached.Put("bish", "bosh")
+			panic("flip")
+		})
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	ctx := st.ServeWithContext(httptest.NewRecorder(), r)
+	assertEquals(t, "bosh", <-done)
+	err := <-reported
+	pe, ok := err.(*PanicError)
+	if !ok {
+		t.Fatalf("expected a *PanicError, got %T", err)
+	}
+	assertEquals(t, "flip", pe.Value)
+	assertEquals(t, "panic: flip", pe.Error())
+	assertEquals(t, true, strings.Contains(string(pe.Stack), "goroutine"))
+	// The request's own Context is unaffected.
+	assertEquals(t, "bash", ctx.Get("bish"))
+}
+
+func TestGoInheritsSettings(t *testing.T) {
+	a, err := NewAssets(http.Dir("testdata/static"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan string, 1)
+	st := New().UseAssets(a).Toggle(map[string]bool{"bish": false}).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		Go(ctx, func(detached *Context) {
+			// The toggled off middleware isn't run.
+			Named("bish", bishMiddleware)(detached, http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), r)
+			done <- AssetURL(detached, "css/site.css") + " " + fmt.Sprint(detached.Get("bish"))
+		})
+	})
+	recordGet(st)
+	assertEquals(t, a.URL("css/site.css")+" <nil>", <-done)
+}
+
+func TestWaitForGoroutines(t *testing.T) {
+	var info ResponseInfo
+	finished := false
+	record := func(ctx *Context, next http.Handler) http.Handler {
+		OnFinish(ctx, func(i ResponseInfo) { info = i })
+		return next
+	}
+	st := New(record).RecordResponses().WaitForGoroutines(time.Second).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		Go(ctx, func(*Context) {
+			time.Sleep(20 * time.Millisecond)
+			finished = true
+		})
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	st.ServeHTTP(httptest.NewRecorder(), r)
+	assertEquals(t, true, finished)
+	assertEquals(t, true, info.Duration >= 20*time.Millisecond)
+
+	block := make(chan struct{})
+	defer close(block)
+	st = New().WaitForGoroutines(10 * time.Millisecond).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		Go(ctx, func(*Context) { <-block })
+	})
+	start := time.Now()
+	st.ServeHTTP(httptest.NewRecorder(), r)
+	assertEquals(t, true, time.Since(start) < time.Second)
+}
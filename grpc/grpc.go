@@ -0,0 +1,238 @@
+// Package stackgrpc runs stack-style middleware as gRPC server
+// interceptors, so that a service exposing both HTTP and gRPC endpoints
+// can share one set of authentication, logging and tracing middleware.
+//
+// Middleware are written once against Call, which describes either kind
+// of request, and read and write values in the same *stack.Context used by
+// HTTP chains:
+//
+//	shared := stackgrpc.New().Use("auth", authenticate).Use("log", logCall)
+//
+//	server := grpc.NewServer(
+//		grpc.UnaryInterceptor(shared.UnaryInterceptor()),
+//		grpc.StreamInterceptor(shared.StreamInterceptor()),
+//	)
+//	api := stack.New(shared.HTTP()).Then(apiHandler)
+//
+// gRPC handlers can retrieve the Context with FromContext.
+package stackgrpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/alexedwards/stack"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Call describes the request a Middleware is running for.
+type Call struct {
+	// Method is the HTTP method and path (e.g. "GET /users/42") or the
+	// full gRPC method name (e.g. "/users.Users/Get").
+	Method string
+	// Header holds the HTTP request headers or the gRPC request metadata,
+	// with keys in canonical form.
+	Header http.Header
+	// GRPC reports whether the call is a gRPC call.
+	GRPC bool
+}
+
+// Middleware runs code around a call. It should call next to continue
+// with the rest of the chain, or return an error to abort the call. Errors
+// created with stack.NewHTTPError are converted into the equivalent gRPC
+// status for gRPC calls, and passed to the chain's error handler (via
+// stack.Error) for HTTP calls.
+type Middleware func(ctx *stack.Context, call *Call, next func() error) error
+
+type namedMiddleware struct {
+	name string
+	mw   Middleware
+}
+
+// Chain is an immutable list of Middleware.
+type Chain struct {
+	mws []namedMiddleware
+}
+
+// New returns a Chain containing mws.
+func New(mws ...Middleware) Chain {
+	return Chain{}.Append(mws...)
+}
+
+// Append returns a new Chain with mws added to the end.
+func (c Chain) Append(mws ...Middleware) Chain {
+	for _, mw := range mws {
+		c = c.add(namedMiddleware{mw: mw})
+	}
+	return c
+}
+
+// Use returns a new Chain with mw added to the end under name, so that it
+// can later be removed with Skip.
+func (c Chain) Use(name string, mw Middleware) Chain {
+	return c.add(namedMiddleware{name, mw})
+}
+
+// Skip returns a new Chain without the named middleware. It panics if the
+// chain has no middleware with one of the names.
+func (c Chain) Skip(names ...string) Chain {
+	skip := make(map[string]bool, len(names))
+	for _, name := range names {
+		skip[name] = true
+	}
+	mws := make([]namedMiddleware, 0, len(c.mws))
+	for _, nm := range c.mws {
+		if nm.name != "" && skip[nm.name] {
+			delete(skip, nm.name)
+			continue
+		}
+		mws = append(mws, nm)
+	}
+	for name := range skip {
+		panic("stackgrpc: no middleware named " + name)
+	}
+	return Chain{mws}
+}
+
+// Names returns the names of the middleware in the chain, in order, with
+// an empty string for middleware added without a name.
+func (c Chain) Names() []string {
+	names := make([]string, len(c.mws))
+	for i, nm := range c.mws {
+		names[i] = nm.name
+	}
+	return names
+}
+
+func (c Chain) add(nm namedMiddleware) Chain {
+	mws := make([]namedMiddleware, len(c.mws), len(c.mws)+1)
+	copy(mws, c.mws)
+	return Chain{append(mws, nm)}
+}
+
+func (c Chain) run(ctx *stack.Context, call *Call, final func() error) error {
+	next := final
+	for i := len(c.mws) - 1; i >= 0; i-- {
+		mw, n := c.mws[i].mw, next
+		next = func() error {
+			return mw(ctx, call, n)
+		}
+	}
+	return next()
+}
+
+type contextKey struct{}
+
+// FromContext returns the stack Context for a gRPC call made through one
+// of the chain's interceptors, or nil if there isn't one.
+func FromContext(ctx context.Context) *stack.Context {
+	sctx, _ := ctx.Value(contextKey{}).(*stack.Context)
+	return sctx
+}
+
+// UnaryInterceptor returns a unary server interceptor which runs the chain
+// before each call, with a new Context.
+func (c Chain) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(gctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx := stack.NewContext()
+		gctx = context.WithValue(gctx, contextKey{}, ctx)
+		var resp interface{}
+		err := c.run(ctx, newCall(gctx, info.FullMethod), func() error {
+			var err error
+			resp, err = handler(gctx, req)
+			return err
+		})
+		return resp, statusError(err)
+	}
+}
+
+// StreamInterceptor returns a stream server interceptor which runs the
+// chain before each stream, with a new Context.
+func (c Chain) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := stack.NewContext()
+		ws := &serverStream{ServerStream: ss, ctx: context.WithValue(ss.Context(), contextKey{}, ctx)}
+		err := c.run(ctx, newCall(ws.ctx, info.FullMethod), func() error {
+			return handler(srv, ws)
+		})
+		return statusError(err)
+	}
+}
+
+// HTTP returns the chain as a single stack middleware. If a Middleware
+// returns an error without calling next, the error is passed to
+// stack.Error; errors returned after the response has been written are
+// discarded.
+func (c Chain) HTTP() func(*stack.Context, http.Handler) http.Handler {
+	return func(ctx *stack.Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			call := &Call{Method: r.Method + " " + r.URL.Path, Header: r.Header}
+			called := false
+			err := c.run(ctx, call, func() error {
+				called = true
+				next.ServeHTTP(w, r)
+				return nil
+			})
+			if err != nil && !called {
+				stack.Error(ctx, w, r, err)
+			}
+		})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ss *serverStream) Context() context.Context {
+	return ss.ctx
+}
+
+func newCall(ctx context.Context, method string) *Call {
+	call := &Call{Method: method, Header: make(http.Header), GRPC: true}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, vals := range md {
+		if strings.HasPrefix(key, ":") {
+			continue
+		}
+		key = http.CanonicalHeaderKey(key)
+		call.Header[key] = append(call.Header[key], vals...)
+	}
+	return call
+}
+
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.AlreadyExists,
+	http.StatusPreconditionFailed:  codes.FailedPrecondition,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	http.StatusNotImplemented:      codes.Unimplemented,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+	http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+	http.StatusInternalServerError: codes.Internal,
+}
+
+// statusError converts a *stack.HTTPError into a gRPC status error, with
+// the status text as its message so that the underlying error isn't sent
+// to the client. Other errors are returned unchanged, for gRPC to handle
+// as usual.
+func statusError(err error) error {
+	var he *stack.HTTPError
+	if !errors.As(err, &he) {
+		return err
+	}
+	code, ok := grpcCodes[he.Status]
+	if !ok {
+		code = codes.Unknown
+	}
+	return status.Error(code, http.StatusText(he.Status))
+}
@@ -0,0 +1,135 @@
+package stackgrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/stack"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func assertEquals(t *testing.T, e interface{}, o interface{}) {
+	if e != o {
+		t.Errorf("\n...expected = %v\n...obtained = %v", e, o)
+	}
+}
+
+// authenticate is shared by the HTTP and gRPC tests.
+func authenticate(ctx *stack.Context, call *Call, next func() error) error {
+	if call.Header.Get("Authorization") != "Bearer bish" {
+		return stack.NewHTTPError(http.StatusUnauthorized, nil)
+	}
+	stack.SetPrincipal(ctx, "bish")
+	return next()
+}
+
+func trace(log *[]string) Middleware {
+	return func(ctx *stack.Context, call *Call, next func() error) error {
+		*log = append(*log, "before "+call.Method)
+		err := next()
+		*log = append(*log, fmt.Sprintf("after %v", err))
+		return err
+	}
+}
+
+func TestUnaryInterceptor(t *testing.T) {
+	var log []string
+	interceptor := New(trace(&log)).Use("auth", authenticate).UnaryInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/users.Users/Get"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return fmt.Sprintf("%v for %v", req, stack.Principal(FromContext(ctx))), nil
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer bish"))
+	resp, err := interceptor(ctx, "user 42", info, handler)
+	assertEquals(t, nil, err)
+	assertEquals(t, "user 42 for bish", resp)
+
+	resp, err = interceptor(context.Background(), "user 42", info, handler)
+	assertEquals(t, nil, resp)
+	assertEquals(t, codes.Unauthenticated, status.Code(err))
+	assertEquals(t, "before /users.Users/Get|after <nil>|before /users.Users/Get|after Unauthorized", strings.Join(log, "|"))
+}
+
+func TestUnaryInterceptorHandlerErrors(t *testing.T) {
+	interceptor := New().UnaryInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/users.Users/Get"}
+	errPlain := errors.New("bish")
+
+	_, err := interceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		return nil, errPlain
+	})
+	assertEquals(t, errPlain, err)
+
+	_, err = interceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		return nil, stack.NewHTTPError(http.StatusNotFound, errPlain)
+	})
+	assertEquals(t, codes.NotFound, status.Code(err))
+	st, _ := status.FromError(err)
+	assertEquals(t, "Not Found", st.Message())
+}
+
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ts *testStream) Context() context.Context { return ts.ctx }
+
+func TestStreamInterceptor(t *testing.T) {
+	interceptor := New().Use("auth", authenticate).StreamInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/users.Users/List", IsServerStream: true}
+	var principal interface{}
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		principal = stack.Principal(FromContext(ss.Context()))
+		return nil
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer bish"))
+	assertEquals(t, nil, interceptor(nil, &testStream{ctx: ctx}, info, handler))
+	assertEquals(t, "bish", principal)
+
+	err := interceptor(nil, &testStream{ctx: context.Background()}, info, handler)
+	assertEquals(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestHTTP(t *testing.T) {
+	var log []string
+	shared := New(trace(&log)).Use("auth", authenticate)
+	h := stack.New(shared.HTTP()).Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "hello %v", stack.Principal(ctx))
+	})
+
+	r, _ := http.NewRequest("GET", "/users/42", nil)
+	r.Header.Set("Authorization", "Bearer bish")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	assertEquals(t, "hello bish", rec.Body.String())
+
+	r.Header.Del("Authorization")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	assertEquals(t, 401, rec.Code)
+	assertEquals(t, "before GET /users/42|after <nil>|before GET /users/42|after Unauthorized", strings.Join(log, "|"))
+}
+
+func TestSkipAndNames(t *testing.T) {
+	var log []string
+	c := New(trace(&log)).Use("auth", authenticate).Use("trace", trace(&log))
+	assertEquals(t, "|auth|trace", strings.Join(c.Names(), "|"))
+	assertEquals(t, "|trace", strings.Join(c.Skip("auth").Names(), "|"))
+	assertEquals(t, "|auth|trace", strings.Join(c.Names(), "|"))
+
+	defer func() {
+		assertEquals(t, "stackgrpc: no middleware named bash", recover())
+	}()
+	c.Skip("bash")
+}
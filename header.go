@@ -0,0 +1,79 @@
+package stack
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// qualityValue is a single entry from a header such as Accept or
+// Accept-Encoding, along with its q-value.
+type qualityValue struct {
+	value   string
+	quality float64
+}
+
+// parseQualityList parses a comma-separated header value with optional
+// q-values (e.g. "gzip;q=1.0, identity; q=0.5, *;q=0") and returns the
+// entries sorted by descending quality. Entries with equal quality keep
+// the order in which they appeared in the header.
+func parseQualityList(header string) []qualityValue {
+	var qvs []qualityValue
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		qv := qualityValue{quality: 1}
+		fields := strings.Split(part, ";")
+		qv.value = strings.ToLower(strings.TrimSpace(fields[0]))
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if len(param) < 2 || (param[0] != 'q' && param[0] != 'Q') || param[1] != '=' {
+				continue
+			}
+			q, err := strconv.ParseFloat(param[2:], 64)
+			if err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+			qv.quality = q
+		}
+		qvs = append(qvs, qv)
+	}
+	sort.Stable(byQuality(qvs))
+	return qvs
+}
+
+type byQuality []qualityValue
+
+func (b byQuality) Len() int           { return len(b) }
+func (b byQuality) Less(i, j int) bool { return b[i].quality > b[j].quality }
+func (b byQuality) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+// AddVary appends value to the Vary header in h unless it is already
+// present, for middleware outside this package whose responses depend on
+// a request header.
+func AddVary(h http.Header, value string) {
+	addVary(h, value)
+}
+
+// addVary appends value to the Vary header unless it is already present.
+func addVary(h http.Header, value string) {
+	if !headerHasToken(h, "Vary", value) {
+		h.Add("Vary", value)
+	}
+}
+
+// headerHasToken reports whether the comma-separated header key contains
+// token, ignoring case.
+func headerHasToken(h http.Header, key string, token string) bool {
+	for _, line := range h[http.CanonicalHeaderKey(key)] {
+		for _, v := range strings.Split(line, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
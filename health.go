@@ -0,0 +1,171 @@
+package stack
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Checker checks whether a dependency of the service, such as a database
+// or a session store, is available.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function into a Checker.
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls fn.
+func (fn CheckerFunc) Check(ctx context.Context) error {
+	return fn(ctx)
+}
+
+// HealthOption configures the chain returned by Health.
+type HealthOption func(*healthConfig)
+
+// HealthCheck adds a named readiness check. For example, to check a
+// database connection:
+//
+//	stack.HealthCheck("db", stack.CheckerFunc(db.PingContext))
+func HealthCheck(name string, c Checker) HealthOption {
+	return func(hc *healthConfig) {
+		hc.checks[name] = c
+	}
+}
+
+// HealthCacheFor sets how long check results are reused for, so that
+// frequent probes don't overload the dependencies. The default is one
+// second.
+func HealthCacheFor(d time.Duration) HealthOption {
+	return func(hc *healthConfig) {
+		hc.cacheFor = d
+	}
+}
+
+// HealthTimeout sets how long each check may take before it is treated as
+// failed. The default is five seconds.
+func HealthTimeout(d time.Duration) HealthOption {
+	return func(hc *healthConfig) {
+		hc.timeout = d
+	}
+}
+
+type healthConfig struct {
+	checks   map[string]Checker
+	cacheFor time.Duration
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	checked time.Time
+	results map[string]error
+}
+
+// Health returns a chain serving liveness and readiness probes, for
+// requests whose paths end in /livez and /readyz respectively. Other paths
+// get a 404. Mount it wherever the probes are expected:
+//
+//	mux.Handle("/livez", health)
+//	mux.Handle("/readyz", health)
+//
+// The liveness probe always succeeds while the process can serve
+// requests. The readiness probe runs the checks added with HealthCheck
+// concurrently and responds 503 Service Unavailable if any fail, or if a
+// server started with Serve is draining. Both respond with a plain text
+// report listing each check.
+func Health(opts ...HealthOption) HandlerChain {
+	cfg := &healthConfig{
+		checks:   make(map[string]Checker),
+		cacheFor: time.Second,
+		timeout:  5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return New().Then(cfg.serve)
+}
+
+func (cfg *healthConfig) serve(ctx *Context, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/livez"):
+		fmt.Fprint(w, "livez check passed\n")
+	case strings.HasSuffix(r.URL.Path, "/readyz"):
+		cfg.ready(w, r)
+	default:
+		Error(ctx, w, r, NewHTTPError(http.StatusNotFound, nil))
+	}
+}
+
+func (cfg *healthConfig) ready(w http.ResponseWriter, r *http.Request) {
+	results := cfg.run()
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var report strings.Builder
+	ok := true
+	if Draining(r) {
+		ok = false
+		report.WriteString("[-]draining\n")
+	}
+	for _, name := range names {
+		if err := results[name]; err != nil {
+			ok = false
+			fmt.Fprintf(&report, "[-]%s failed: %v\n", name, err)
+		} else {
+			fmt.Fprintf(&report, "[+]%s ok\n", name)
+		}
+	}
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		report.WriteString("readyz check failed\n")
+	} else {
+		report.WriteString("readyz check passed\n")
+	}
+	fmt.Fprint(w, report.String())
+}
+
+// run returns the results of the checks, running them if the cached
+// results are too old. The checks aren't tied to the probe's request, so
+// a client giving up doesn't leave failures in the cache.
+func (cfg *healthConfig) run() map[string]error {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	if cfg.results != nil && time.Since(cfg.checked) < cfg.cacheFor {
+		return cfg.results
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.timeout)
+	defer cancel()
+	results := make(map[string]error, len(cfg.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, c := range cfg.checks {
+		wg.Add(1)
+		go func(name string, c Checker) {
+			defer wg.Done()
+			// Don't wait for checks which ignore the context.
+			done := make(chan error, 1)
+			go func() { done <- c.Check(ctx) }()
+			var err error
+			select {
+			case err = <-done:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+			mu.Lock()
+			results[name] = err
+			mu.Unlock()
+		}(name, c)
+	}
+	wg.Wait()
+	cfg.results, cfg.checked = results, time.Now()
+	return results
+}
@@ -0,0 +1,84 @@
+package stack
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func probe(hc HandlerChain, path string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("GET", path, nil)
+	rec := httptest.NewRecorder()
+	hc.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestHealth(t *testing.T) {
+	var calls int32
+	dbErr := errors.New("connection refused")
+	db := CheckerFunc(func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return dbErr
+	})
+	cache := CheckerFunc(func(ctx context.Context) error { return nil })
+	hc := Health(HealthCheck("db", db), HealthCheck("cache", cache), HealthCacheFor(time.Hour))
+
+	rec := probe(hc, "/livez")
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "livez check passed\n", rec.Body.String())
+
+	rec = probe(hc, "/readyz")
+	assertEquals(t, 503, rec.Code)
+	assertEquals(t, "[+]cache ok\n[-]db failed: connection refused\nreadyz check failed\n", rec.Body.String())
+
+	// Results are cached.
+	dbErr = nil
+	rec = probe(hc, "/health/readyz")
+	assertEquals(t, 503, rec.Code)
+	assertEquals(t, int32(1), atomic.LoadInt32(&calls))
+
+	assertEquals(t, 404, probe(hc, "/healthz").Code)
+}
+
+func TestHealthPasses(t *testing.T) {
+	hc := Health(HealthCheck("db", CheckerFunc(func(ctx context.Context) error { return nil })))
+
+	rec := probe(hc, "/readyz")
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "[+]db ok\nreadyz check passed\n", rec.Body.String())
+}
+
+func TestHealthTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	hc := Health(HealthCheck("slow", CheckerFunc(func(ctx context.Context) error {
+		<-block
+		return nil
+	})), HealthTimeout(10*time.Millisecond))
+
+	rec := probe(hc, "/readyz")
+	assertEquals(t, 503, rec.Code)
+	assertEquals(t, "[-]slow failed: context deadline exceeded\nreadyz check failed\n", rec.Body.String())
+}
+
+func TestHealthDraining(t *testing.T) {
+	hc := Health()
+	assertEquals(t, 200, probe(hc, "/readyz").Code)
+
+	state := &serverState{draining: 1}
+	draining := func(path string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", path, nil)
+		r = r.WithContext(context.WithValue(r.Context(), serverStateKey{}, state))
+		rec := httptest.NewRecorder()
+		hc.ServeHTTP(rec, r)
+		return rec
+	}
+	rec := draining("/readyz")
+	assertEquals(t, 503, rec.Code)
+	assertEquals(t, "[-]draining\nreadyz check failed\n", rec.Body.String())
+	assertEquals(t, 200, draining("/livez").Code)
+}
@@ -0,0 +1,203 @@
+package stack
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// BeforeWrite registers fn to be called exactly once, immediately before
+// the response headers for the current request are written, whether that
+// is triggered by WriteHeader, Write or Flush, or by the chain returning
+// without writing anything. It lets middleware set cookies or headers at
+// the last possible moment, after the handler has had its say, without
+// wrapping the ResponseWriter themselves. Callbacks run in the order they
+// were registered, and are passed the ResponseWriter the headers are about
+// to be written to.
+//
+// BeforeWrite relies on the chain's ResponseRecorder, and panics if the
+// chain doesn't call RecordResponses. If the headers have already been
+// written, fn is never called.
+func BeforeWrite(ctx *Context, fn func(w http.ResponseWriter)) {
+	rr := ctx.response
+	if rr == nil {
+		panic("stack: BeforeWrite requires a chain with RecordResponses")
+	}
+	if rr.Written() || rr.hijacked {
+		return
+	}
+	rr.before = append(rr.before, fn)
+}
+
+func (rr *ResponseRecorder) runBefore() {
+	// Callbacks may register further callbacks, so don't range over a
+	// copy of the slice.
+	for i := 0; i < len(rr.before); i++ {
+		rr.before[i](rr.ResponseWriter)
+	}
+	rr.before = nil
+}
+
+// ResponseInfo describes a completed response, for OnFinish callbacks.
+type ResponseInfo struct {
+	// Status is the status code sent to the client. If the handler didn't
+	// write anything it is 200, which net/http sends by default, unless
+	// the handler panicked or hijacked the connection, in which case it is
+	// zero.
+	Status int
+	// Size is the number of body bytes written.
+	Size int64
+	// Duration is the time from the start of the chain until the handler
+	// returned.
+	Duration time.Duration
+	// Err is the last error passed to Error for the request, if any.
+	Err error
+	// Panic is the value the handler panicked with, if any. The panic
+	// continues once the callbacks have run.
+	Panic interface{}
+	// Hijacked reports whether the handler hijacked the connection.
+	Hijacked bool
+	// Empty reports whether the handler sent no body on purpose, with
+	// NoContent or Status.
+	Empty bool
+}
+
+// PendingResponse describes the response about to be written, for
+// BeforeWrite callbacks which act on its outcome. Status is the status
+// code about to be sent (or 200, net/http's default, if the response
+// hasn't been started), and Err the last error passed to Error. Size and
+// Duration cover the time up to the call.
+//
+// PendingResponse relies on the chain's ResponseRecorder, and panics if
+// the chain doesn't call RecordResponses.
+func PendingResponse(ctx *Context) ResponseInfo {
+	rr := ctx.response
+	if rr == nil {
+		panic("stack: PendingResponse requires a chain with RecordResponses")
+	}
+	info := ResponseInfo{
+		Status:   rr.status,
+		Size:     rr.size,
+		Duration: rr.now().Sub(rr.start),
+		Err:      ctx.err,
+		Hijacked: rr.hijacked,
+		Empty:    rr.empty,
+	}
+	if rr.pending != 0 {
+		info.Status = rr.pending
+	}
+	if info.Status == 0 && !rr.hijacked {
+		info.Status = http.StatusOK
+	}
+	return info
+}
+
+// OnFinish registers fn to be called once the chain has finished handling
+// the current request, including when a handler panics. Callbacks run in
+// the reverse order to that in which they were registered, like deferred
+// calls, and are intended for logging, metrics, auditing and similar work
+// which needs the final status of the response.
+//
+// OnFinish relies on the chain's ResponseRecorder, and panics if the chain
+// doesn't call RecordResponses.
+func OnFinish(ctx *Context, fn func(ResponseInfo)) {
+	rr := ctx.response
+	if rr == nil {
+		panic("stack: OnFinish requires a chain with RecordResponses")
+	}
+	rr.after = append(rr.after, fn)
+}
+
+// finish runs the OnFinish callbacks. It must be deferred directly, so
+// that it can recover a panic and report it to the callbacks.
+func (rr *ResponseRecorder) finish(ctx *Context) {
+	if len(rr.after) == 0 {
+		return
+	}
+	p := recover()
+	info := ResponseInfo{
+		Status:   rr.status,
+		Size:     rr.size,
+		Duration: rr.now().Sub(rr.start),
+		Err:      ctx.err,
+		Panic:    p,
+		Hijacked: rr.hijacked,
+		Empty:    rr.empty,
+	}
+	if info.Status == 0 && p == nil && !rr.hijacked {
+		info.Status = http.StatusOK
+	}
+	for i := len(rr.after) - 1; i >= 0; i-- {
+		rr.after[i](info)
+	}
+	if p != nil {
+		panic(p)
+	}
+}
+
+// Around returns middleware which calls before, then the rest of the chain,
+// then after. The call to after is deferred, so it runs even if a later
+// handler panics (the panic then continues as normal). If before returns
+// an error it is passed to Error, and neither the rest of the chain nor
+// after are run. Either function may be nil:
+//
+//	timer := stack.Around(
+//		func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) error {
+//			ctx.Put("start", time.Now())
+//			return nil
+//		},
+//		func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+//			log.Printf("%s took %s", r.URL.Path, time.Since(ctx.Get("start").(time.Time)))
+//		},
+//	)
+func Around(before func(ctx *Context, w http.ResponseWriter, r *http.Request) error, after func(ctx *Context, w http.ResponseWriter, r *http.Request)) chainMiddleware {
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if before != nil {
+				if err := before(ctx, w, r); err != nil {
+					Error(ctx, w, r, err)
+					return
+				}
+			}
+			if after != nil {
+				defer after(ctx, w, r)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// HeadersFromContext makes the chain copy Context values into response
+// headers immediately before the headers are written, according to
+// mapping, which maps Context keys to header names:
+//
+//	stack.New(requestID, cache).HeadersFromContext(map[string]string{
+//		"requestID": "X-Request-ID",
+//		"cache":     "X-Cache",
+//	})
+//
+// Values are formatted with fmt.Sprint. Keys which aren't in the Context,
+// and headers which the handler has already set, are left alone. Calling
+// HeadersFromContext turns on RecordResponses.
+func (c Chain) HeadersFromContext(mapping map[string]string) Chain {
+	headers := make(map[string]string, len(c.ctxHeaders)+len(mapping))
+	for key, header := range c.ctxHeaders {
+		headers[key] = header
+	}
+	for key, header := range mapping {
+		headers[key] = header
+	}
+	c.ctxHeaders = headers
+	c.record = true
+	return c
+}
+
+func setContextHeaders(ctx *Context, h http.Header, mapping map[string]string) {
+	for key, header := range mapping {
+		val := ctx.Get(key)
+		if val == nil || h.Get(header) != "" {
+			continue
+		}
+		h.Set(header, fmt.Sprint(val))
+	}
+}
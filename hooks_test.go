@@ -0,0 +1,245 @@
+package stack
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func securityHeaders(ctx *Context, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		BeforeWrite(ctx, func(w http.ResponseWriter) {
+			if w.Header().Get("Content-Type") == "text/html" {
+				w.Header().Set("X-Frame-Options", "DENY")
+			}
+			BeforeWrite(ctx, func(w http.ResponseWriter) {
+				w.Header().Add("X-Order", "second")
+			})
+		})
+		BeforeWrite(ctx, func(w http.ResponseWriter) {
+			w.Header().Add("X-Order", "first")
+		})
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestBeforeWrite(t *testing.T) {
+	handlers := map[string]func(http.ResponseWriter, *http.Request){
+		"WriteHeader": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusEarlyHints)
+			w.WriteHeader(http.StatusCreated)
+			w.WriteHeader(http.StatusAccepted)
+		},
+		"Write": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "bish")
+		},
+		"ReadFrom": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			io.Copy(w, strings.NewReader("bish"))
+		},
+		"Flush": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.(http.Flusher).Flush()
+		},
+		"Nothing": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+		},
+	}
+	for name, fn := range handlers {
+		st := New(securityHeaders).RecordResponses().ThenHandlerFunc(fn)
+		r, _ := http.NewRequest("GET", "/", nil)
+		rec := httptest.NewRecorder()
+		st.ServeHTTP(rec, r)
+		if rec.Header().Get("X-Frame-Options") != "DENY" {
+			t.Errorf("%s: X-Frame-Options not set", name)
+		}
+		if order := strings.Join(rec.Header()["X-Order"], ","); order != "first,second" {
+			t.Errorf("%s: hooks ran in order %q", name, order)
+		}
+	}
+}
+
+func TestPendingResponse(t *testing.T) {
+	var pending []int
+	st := New(func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pending = append(pending, PendingResponse(ctx).Status)
+			BeforeWrite(ctx, func(w http.ResponseWriter) {
+				pending = append(pending, PendingResponse(ctx).Status)
+				Response(ctx).SetPendingStatus(http.StatusInternalServerError)
+			})
+			next.ServeHTTP(w, r)
+		})
+	}).RecordResponses().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		Response(ctx).SetPendingStatus(http.StatusTeapot)
+	})
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	ctx := st.ServeWithContext(rec, r)
+	assertEquals(t, 500, rec.Code)
+	assertEquals(t, 500, Response(ctx).Status())
+	assertEquals(t, "[200 201]", fmt.Sprint(pending))
+}
+
+func TestBeforeWriteAfterHeaders(t *testing.T) {
+	st := New().RecordResponses().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "bish")
+		BeforeWrite(ctx, func(w http.ResponseWriter) {
+			t.Error("hook called after headers were written")
+		})
+		fmt.Fprint(w, "bash")
+	})
+	assertEquals(t, "bishbash", serveAndRequest(st))
+}
+
+func TestBeforeWriteWithoutRecorder(t *testing.T) {
+	defer func() {
+		assertEquals(t, "stack: BeforeWrite requires a chain with RecordResponses", recover())
+	}()
+	BeforeWrite(NewContext(), func(http.ResponseWriter) {})
+}
+
+func finishLogger(log *[]string) chainMiddleware {
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			OnFinish(ctx, func(info ResponseInfo) {
+				*log = append(*log, fmt.Sprintf("status=%d size=%d err=%v panic=%v hijacked=%v", info.Status, info.Size, info.Err, info.Panic, info.Hijacked))
+			})
+			OnFinish(ctx, func(info ResponseInfo) {
+				if info.Duration <= 0 {
+					*log = append(*log, "bad duration")
+				}
+			})
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestOnFinish(t *testing.T) {
+	var log []string
+	serve := func(fn func(ctx *Context, w http.ResponseWriter, r *http.Request)) {
+		st := New(finishLogger(&log)).RecordResponses().Then(fn)
+		r, _ := http.NewRequest("GET", "/", nil)
+		st.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	serve(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "bish")
+	})
+	serve(func(ctx *Context, w http.ResponseWriter, r *http.Request) {})
+	serve(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		Error(ctx, w, r, NewHTTPError(http.StatusTeapot, errTest))
+	})
+	assertEquals(t, "status=200 size=4 err=<nil> panic=<nil> hijacked=false|"+
+		"status=200 size=0 err=<nil> panic=<nil> hijacked=false|"+
+		"status=418 size=13 err=test error panic=<nil> hijacked=false", strings.Join(log, "|"))
+
+	log = nil
+	func() {
+		defer func() {
+			assertEquals(t, "bish", recover())
+		}()
+		serve(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+			panic("bish")
+		})
+	}()
+	assertEquals(t, "status=0 size=0 err=<nil> panic=bish hijacked=false", strings.Join(log, "|"))
+}
+
+func TestOnFinishWithoutRecorder(t *testing.T) {
+	defer func() {
+		assertEquals(t, "stack: OnFinish requires a chain with RecordResponses", recover())
+	}()
+	OnFinish(NewContext(), func(ResponseInfo) {})
+}
+
+func TestAround(t *testing.T) {
+	var log []string
+	mw := Around(
+		func(ctx *Context, w http.ResponseWriter, r *http.Request) error {
+			log = append(log, "before")
+			if r.URL.Path == "/denied" {
+				return NewHTTPError(http.StatusForbidden, nil)
+			}
+			ctx.Put("bish", "bash")
+			return nil
+		},
+		func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+			log = append(log, fmt.Sprintf("after [bish=%v]", ctx.Get("bish")))
+		},
+	)
+	st := New(mw).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("bosh")
+		}
+		log = append(log, "handler")
+	})
+
+	for _, path := range []string{"/", "/denied", "/panic"} {
+		func() {
+			defer func() { recover() }()
+			r, _ := http.NewRequest("GET", path, nil)
+			rec := httptest.NewRecorder()
+			st.ServeHTTP(rec, r)
+			if path == "/denied" {
+				assertEquals(t, 403, rec.Code)
+			}
+		}()
+	}
+	assertEquals(t, "before|handler|after [bish=bash]|before|before|after [bish=bash]", strings.Join(log, "|"))
+
+	log = nil
+	res := serveAndRequest(New(Around(nil, nil), flipMiddleware).Then(bishHandler))
+	assertEquals(t, "flipMiddleware>bishHandler [bish=<nil>]", res)
+}
+
+func TestHeadersFromContext(t *testing.T) {
+	requestID := func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx.Put("requestID", 42)
+			next.ServeHTTP(w, r)
+		})
+	}
+	st := New(requestID).HeadersFromContext(map[string]string{
+		"requestID": "X-Request-ID",
+	}).HeadersFromContext(map[string]string{
+		"cache":  "X-Cache",
+		"absent": "X-Absent",
+		"custom": "X-Custom",
+	}).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Custom", "handler")
+		ctx.Put("custom", "context")
+		// Values are copied when the headers are written, so later
+		// changes have no effect.
+		ctx.Put("cache", "HIT")
+		fmt.Fprint(w, "bish")
+		ctx.Put("cache", "MISS")
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, "42", rec.Header().Get("X-Request-ID"))
+	assertEquals(t, "HIT", rec.Header().Get("X-Cache"))
+	assertEquals(t, "handler", rec.Header().Get("X-Custom"))
+	_, ok := rec.Header()["X-Absent"]
+	assertEquals(t, false, ok)
+}
+
+func TestHeadersFromContextWithoutBody(t *testing.T) {
+	st := New().HeadersFromContext(map[string]string{
+		"requestID": "X-Request-ID",
+	}).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		ctx.Put("requestID", 42)
+	})
+
+	rec := recordGet(st)
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "42", rec.Header().Get("X-Request-ID"))
+}
@@ -0,0 +1,72 @@
+package stack
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+const prettyJSONKey = "stack.prettyJSON"
+
+// responseBuffers holds buffers for helpers which render a whole response
+// before writing it.
+var responseBuffers = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// PrettyJSON sets whether JSON indents the responses it writes for the
+// current request, such as for a ?pretty query parameter or requests from
+// a browser.
+func PrettyJSON(ctx *Context, pretty bool) {
+	ctx.Put(prettyJSONKey, pretty)
+}
+
+// JSON writes v as a JSON response with the given status code. The value
+// is encoded into a buffer first, so that if encoding fails nothing has
+// been written and the error is passed to the chain's error handler
+// instead. It sets the Content-Type and Content-Length headers, and
+// returns any error from encoding or writing the response.
+func JSON(ctx *Context, w http.ResponseWriter, status int, v interface{}) error {
+	buf := responseBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer responseBuffers.Put(buf)
+
+	enc := json.NewEncoder(buf)
+	if pretty, _ := ctx.Get(prettyJSONKey).(bool); pretty {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(v); err != nil {
+		Error(ctx, w, ctx.request, err)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// JSONError is an ErrorHandlerFunc for JSON APIs (see Chain.OnError). It
+// writes the status code for err, and a body in the form:
+//
+//	{"error":{"status":404,"message":"Not Found"}}
+//
+// Like the default error handler, it only sends the status text, so that
+// internal error messages are never leaked to clients.
+func JSONError(ctx *Context, w http.ResponseWriter, r *http.Request, err error) {
+	status := StatusCode(err)
+	body := jsonErrorBody{}
+	body.Error.Status = status
+	body.Error.Message = http.StatusText(status)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	JSON(ctx, w, status, body)
+}
+
+type jsonErrorBody struct {
+	Error struct {
+		Status  int    `json:"status"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
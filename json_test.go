@@ -0,0 +1,59 @@
+package stack
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func recordGet(hc HandlerChain) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	hc.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestJSON(t *testing.T) {
+	st := New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		JSON(ctx, w, http.StatusCreated, map[string]string{"bish": "bash"})
+	})
+	rec := recordGet(st)
+	assertEquals(t, 201, rec.Code)
+	assertEquals(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+	assertEquals(t, "16", rec.Header().Get("Content-Length"))
+	assertEquals(t, "{\"bish\":\"bash\"}\n", rec.Body.String())
+
+	st = New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		PrettyJSON(ctx, true)
+		JSON(ctx, w, http.StatusOK, map[string]string{"bish": "bash"})
+	})
+	assertEquals(t, "{\n  \"bish\": \"bash\"\n}\n", recordGet(st).Body.String())
+}
+
+func TestJSONEncodeError(t *testing.T) {
+	var handled error
+	var returned error
+	st := New().OnError(func(ctx *Context, w http.ResponseWriter, r *http.Request, err error) {
+		handled = err
+		assertEquals(t, "/", r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		returned = JSON(ctx, w, http.StatusOK, func() {})
+	})
+	rec := recordGet(st)
+	assertEquals(t, 500, rec.Code)
+	assertEquals(t, "", rec.Body.String())
+	assertEquals(t, returned, handled)
+	assertEquals(t, true, returned != nil)
+}
+
+func TestJSONError(t *testing.T) {
+	st := New().OnError(JSONError).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		Error(ctx, w, r, NewHTTPError(http.StatusNotFound, errors.New("no such user")))
+	})
+	rec := recordGet(st)
+	assertEquals(t, 404, rec.Code)
+	assertEquals(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+	assertEquals(t, "{\"error\":{\"status\":404,\"message\":\"Not Found\"}}\n", rec.Body.String())
+}
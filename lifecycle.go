@@ -0,0 +1,58 @@
+package stack
+
+import "context"
+
+// Lifecycle is implemented by components, such as stores and metrics
+// exporters used by middleware, which need to be started before a chain
+// serves requests and stopped once it has finished.
+type Lifecycle interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// OnStart registers fn to be called by HandlerChain.Start, and so by
+// Serve before it starts accepting connections. Functions are called in
+// the order they were registered.
+func (c Chain) OnStart(fn func(ctx context.Context) error) Chain {
+	c.onStart = append(c.onStart[:len(c.onStart):len(c.onStart)], fn)
+	return c
+}
+
+// OnStop registers fn to be called by HandlerChain.Stop, and so by Serve
+// once it has shut down. Functions are called in the reverse of the order
+// they were registered.
+func (c Chain) OnStop(fn func(ctx context.Context) error) Chain {
+	c.onStop = append(c.onStop[:len(c.onStop):len(c.onStop)], fn)
+	return c
+}
+
+// Manage registers l's Start and Stop methods with OnStart and OnStop:
+//
+//	store := redisstore.New(pool)
+//	app := stack.New(session.New(store)).RecordResponses().Manage(store).Then(handler)
+func (c Chain) Manage(l Lifecycle) Chain {
+	return c.OnStart(l.Start).OnStop(l.Stop)
+}
+
+// Start calls the functions registered with OnStart, stopping at and
+// returning the first error.
+func (hc HandlerChain) Start(ctx context.Context) error {
+	for _, fn := range hc.onStart {
+		if err := fn(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop calls the functions registered with OnStop, in reverse order. They
+// are all called even if some fail, and the first error is returned.
+func (hc HandlerChain) Stop(ctx context.Context) error {
+	var first error
+	for i := len(hc.onStop) - 1; i >= 0; i-- {
+		if err := hc.onStop[i](ctx); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,56 @@
+package stack
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type logComponent struct {
+	name string
+	log  *[]string
+}
+
+func (c logComponent) Start(ctx context.Context) error {
+	*c.log = append(*c.log, "start "+c.name)
+	return nil
+}
+
+func (c logComponent) Stop(ctx context.Context) error {
+	*c.log = append(*c.log, "stop "+c.name)
+	return nil
+}
+
+func TestLifecycle(t *testing.T) {
+	var log []string
+	hook := func(event string, err error) func(context.Context) error {
+		return func(context.Context) error {
+			log = append(log, event)
+			return err
+		}
+	}
+	errStop := errors.New("stop failed")
+	base := New().OnStart(hook("start bish", nil)).OnStop(hook("stop bish", errStop))
+	hc := base.Manage(logComponent{"flip", &log}).Then(bishHandler)
+	other := base.OnStart(hook("start other", nil)).Then(bishHandler)
+
+	assertEquals(t, nil, hc.Start(context.Background()))
+	assertEquals(t, errStop, hc.Stop(context.Background()))
+	assertEquals(t, "start bish, start flip, stop flip, stop bish", strings.Join(log, ", "))
+
+	log = nil
+	other.Start(context.Background())
+	assertEquals(t, "start bish, start other", strings.Join(log, ", "))
+}
+
+func TestLifecycleStartError(t *testing.T) {
+	var log []string
+	errStart := errors.New("start failed")
+	hc := New().OnStart(func(context.Context) error {
+		return errStart
+	}).Manage(logComponent{"flip", &log}).Then(bishHandler)
+
+	assertEquals(t, errStart, hc.Start(context.Background()))
+	assertEquals(t, 0, len(log))
+}
@@ -0,0 +1,170 @@
+package stack
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Problem is a likely misconfiguration of a chain, found by Lint.
+type Problem struct {
+	// Rule identifies the check which found the problem, such as
+	// "recover-outermost".
+	Rule    string
+	Message string
+}
+
+func (p Problem) String() string {
+	return p.Rule + ": " + p.Message
+}
+
+// Role is something a middleware does which Lint checks the position of.
+// See LintAs.
+type Role string
+
+// Roles which Lint knows about.
+const (
+	// RecoversPanics is played by middleware which recover panics, and so
+	// should be first in the chain.
+	RecoversPanics Role = "recovers panics"
+	// RetriesRequests is played by middleware which retry requests, and so
+	// need the request body to have been buffered.
+	RetriesRequests Role = "retries requests"
+	// BuffersBody is played by middleware which buffer the request body,
+	// such as BufferBody.
+	BuffersBody Role = "buffers the request body"
+	// CompressesResponses is played by Compress.
+	CompressesResponses Role = "compresses responses"
+	// ComputesETags is played by ETag.
+	ComputesETags Role = "computes ETags"
+)
+
+// LintAs declares that mw plays roles, so that Lint can check where it is
+// in the chain:
+//
+//	func Recover() func(*stack.Context, http.Handler) http.Handler {
+//		return stack.LintAs(recoverPanics, stack.RecoversPanics)
+//	}
+//
+// Like those made with Requires, declarations are found through Named and
+// Optional, but not through other wrappers.
+func LintAs(mw chainMiddleware, roles ...Role) chainMiddleware {
+	wrapped := func(ctx *Context, next http.Handler) http.Handler {
+		return mw(ctx, next)
+	}
+	declare(wrapped, func(toggles map[string]bool) ([]Capability, []Role) {
+		caps, inner := declared(mw, toggles)
+		return caps, append(roles[:len(roles):len(roles)], inner...)
+	})
+	return wrapped
+}
+
+// Lint checks hc for common misconfigurations:
+//
+//   - recover-outermost: middleware which recovers panics isn't the first
+//     in the chain, so panics in the middleware before it aren't caught.
+//   - compress-etag: Compress runs before ETag, so the ETag is computed
+//     from the uncompressed body and sent unchanged with compressed ones.
+//   - retry-buffer: middleware which retries requests isn't preceded by
+//     middleware which buffers the request body, so retries send an empty
+//     body.
+//   - unread-inject: a value injected with Inject (or one of its variants)
+//     hasn't been read with Context.Get.
+//
+// The first three checks go by the roles middleware declare with LintAs,
+// which this package's middleware do. Middleware turned off with Toggle
+// are skipped. The unread-inject check only runs for chains returned by
+// TrackReads, and relies on the chain having served requests, so is most
+// useful at the end of a test which exercises the chain; run before any
+// requests it reports every injected key.
+func Lint(hc HandlerChain) []Problem {
+	var problems []Problem
+	buffered := false
+	compress := -1
+	for i, mw := range hc.mws {
+		_, roles := declared(mw, hc.toggles)
+		for _, role := range roles {
+			switch role {
+			case RecoversPanics:
+				if i > 0 {
+					problems = append(problems, Problem{"recover-outermost", fmt.Sprintf("middleware %d of %d recovers panics, but isn't the first", i+1, len(hc.mws))})
+				}
+			case CompressesResponses:
+				compress = i
+			case ComputesETags:
+				if compress >= 0 {
+					problems = append(problems, Problem{"compress-etag", fmt.Sprintf("middleware %d compresses responses before middleware %d computes ETags", compress+1, i+1)})
+				}
+			case RetriesRequests:
+				if !buffered {
+					problems = append(problems, Problem{"retry-buffer", fmt.Sprintf("middleware %d retries requests, but no middleware before it buffers the request body", i+1)})
+				}
+			case BuffersBody:
+				buffered = true
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(hc.reads))
+	for key, read := range hc.reads {
+		if atomic.LoadInt32(read) == 0 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		problems = append(problems, Problem{"unread-inject", fmt.Sprintf("injected key %q has not been read", key)})
+	}
+	return problems
+}
+
+// TrackReads returns a copy of hc which records which of the values
+// injected with Inject (or one of its variants) are read, for Lint's
+// unread-inject check. Tracking adds a little to every Context.Get, so it
+// is intended for tests:
+//
+//	hc := stack.TrackReads(app)
+//	// ... serve requests with hc ...
+//	for _, p := range stack.Lint(hc) {
+//		t.Error(p)
+//	}
+func TrackReads(hc HandlerChain) HandlerChain {
+	hc.reads = make(map[string]*int32, len(hc.injected))
+	for _, key := range hc.injected {
+		hc.reads[key] = new(int32)
+	}
+	return hc
+}
+
+// MustLint panics if Lint finds any problems with hc, and otherwise
+// returns hc. It is intended for strict checking at startup.
+func MustLint(hc HandlerChain) HandlerChain {
+	problems := Lint(hc)
+	if len(problems) == 0 {
+		return hc
+	}
+	msgs := make([]string, len(problems))
+	for i, p := range problems {
+		msgs[i] = p.String()
+	}
+	panic("stack: chain has problems:\n\t" + strings.Join(msgs, "\n\t"))
+}
+
+// track records keys as injected, and if reads are being tracked as not
+// yet read.
+func (hc *HandlerChain) track(keys ...string) {
+	hc.injected = append(hc.injected[:len(hc.injected):len(hc.injected)], keys...)
+	if hc.reads == nil {
+		return
+	}
+	reads := make(map[string]*int32, len(hc.reads)+len(keys))
+	for key, read := range hc.reads {
+		reads[key] = read
+	}
+	for _, key := range keys {
+		reads[key] = new(int32)
+	}
+	hc.reads = reads
+}
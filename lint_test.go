@@ -0,0 +1,54 @@
+package stack
+
+import (
+	"strings"
+	"testing"
+)
+
+var (
+	recoverMiddleware = LintAs(flipMiddleware, RecoversPanics)
+	retryMiddleware   = LintAs(flipMiddleware, RetriesRequests)
+	bufferMiddleware  = LintAs(flipMiddleware, BuffersBody)
+)
+
+func lintRules(hc HandlerChain) string {
+	var rules []string
+	for _, p := range Lint(hc) {
+		rules = append(rules, p.Rule)
+	}
+	return strings.Join(rules, " ")
+}
+
+func TestLint(t *testing.T) {
+	assertEquals(t, "", lintRules(New(recoverMiddleware, ETag(), Compress(), bufferMiddleware, retryMiddleware).Then(bishHandler)))
+	assertEquals(t, "recover-outermost compress-etag retry-buffer", lintRules(New(bishMiddleware, recoverMiddleware, Compress(), ETag(), retryMiddleware).Then(bishHandler)))
+
+	problems := Lint(New(flipMiddleware, recoverMiddleware).Then(bishHandler))
+	assertEquals(t, "recover-outermost: middleware 2 of 2 recovers panics, but isn't the first", problems[0].String())
+
+	// Roles are found through Named, and middleware turned off are skipped.
+	assertEquals(t, "recover-outermost", lintRules(New(flipMiddleware, Named("recover", recoverMiddleware)).Then(bishHandler)))
+	assertEquals(t, "", lintRules(New(flipMiddleware, Optional("recover", recoverMiddleware)).Then(bishHandler)))
+	// A function name alone doesn't give a middleware a role.
+	assertEquals(t, "", lintRules(New(flipMiddleware, Named("recover", bishMiddleware)).Then(bishHandler)))
+}
+
+func TestLintUnreadInject(t *testing.T) {
+	st := InjectPairs(New().Then(flipHandler), "flip", "flop", "wobble", true)
+	// Reads are only tracked once asked for.
+	assertEquals(t, "", lintRules(st))
+	assertEquals(t, 0, len(st.reads))
+
+	st = TrackReads(st)
+	assertEquals(t, "unread-inject unread-inject", lintRules(st))
+
+	serveAndRequest(st)
+	problems := Lint(st)
+	assertEquals(t, 1, len(problems))
+	assertEquals(t, `unread-inject: injected key "wobble" has not been read`, problems[0].String())
+
+	defer func() {
+		assertEquals(t, true, strings.Contains(recover().(string), `"wobble"`))
+	}()
+	MustLint(st)
+}
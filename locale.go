@@ -0,0 +1,124 @@
+package stack
+
+import "net/http"
+
+const localeKey = "stack.locale"
+
+// LocaleSource identifies a place in the request that a locale can be
+// read from.
+type LocaleSource int
+
+const (
+	LocaleFromQuery LocaleSource = iota
+	LocaleFromCookie
+	LocaleFromHeader
+)
+
+// LocaleOption configures the DetectLocale middleware.
+type LocaleOption func(*localeConfig)
+
+// LocaleQuery sets the name of the query string parameter to read the
+// locale from. The default is "lang".
+func LocaleQuery(name string) LocaleOption {
+	return func(c *localeConfig) {
+		c.query = name
+	}
+}
+
+// LocaleCookie sets the name of the cookie to read the locale from. The
+// default is "lang".
+func LocaleCookie(name string) LocaleOption {
+	return func(c *localeConfig) {
+		c.cookie = name
+	}
+}
+
+// LocaleOrder sets the sources which are consulted, in order of
+// precedence. The default is query string, then cookie, then the
+// Accept-Language header.
+func LocaleOrder(sources ...LocaleSource) LocaleOption {
+	return func(c *localeConfig) {
+		c.order = sources
+	}
+}
+
+// LocaleContentLanguage makes the middleware set the Content-Language
+// response header to the detected locale.
+func LocaleContentLanguage() LocaleOption {
+	return func(c *localeConfig) {
+		c.contentLanguage = true
+	}
+}
+
+type localeConfig struct {
+	supported       []string
+	query           string
+	cookie          string
+	order           []LocaleSource
+	contentLanguage bool
+}
+
+// DetectLocale returns middleware which determines the locale for the
+// request and stores it in the Context, where it can be retrieved with
+// Locale. Candidate locales from each source are matched against the
+// supported BCP 47 language tags (so "en" matches "en-GB"), and the first
+// supported tag is used when no source yields a match.
+func DetectLocale(supported []string, opts ...LocaleOption) chainMiddleware {
+	cfg := &localeConfig{
+		supported: supported,
+		query:     "lang",
+		cookie:    "lang",
+		order:     []LocaleSource{LocaleFromQuery, LocaleFromCookie, LocaleFromHeader},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			locale := cfg.detect(w, r)
+			ctx.Put(localeKey, locale)
+			if cfg.contentLanguage && locale != "" {
+				w.Header().Set("Content-Language", locale)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (cfg *localeConfig) detect(w http.ResponseWriter, r *http.Request) string {
+	if len(cfg.supported) == 0 {
+		return ""
+	}
+	for _, source := range cfg.order {
+		var candidate string
+		switch source {
+		case LocaleFromQuery:
+			candidate = r.URL.Query().Get(cfg.query)
+		case LocaleFromCookie:
+			if c, err := r.Cookie(cfg.cookie); err == nil {
+				candidate = c.Value
+			}
+		case LocaleFromHeader:
+			addVary(w.Header(), "Accept-Language")
+			candidate = r.Header.Get("Accept-Language")
+		}
+		if candidate == "" {
+			continue
+		}
+		if locale, ok := negotiateLanguage(candidate, cfg.supported); ok {
+			return locale
+		}
+	}
+	return cfg.supported[0]
+}
+
+// Locale returns the BCP 47 language tag detected for the current request,
+// or an empty string if the DetectLocale middleware has not run. It is a
+// string, rather than a golang.org/x/text/language.Tag, to keep this
+// package free of that dependency; the locale package's Tag function
+// returns the parsed tag.
+func Locale(ctx *Context) string {
+	locale, _ := ctx.Get(localeKey).(string)
+	return locale
+}
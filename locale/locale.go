@@ -0,0 +1,28 @@
+// Package locale gives typed access to the locale detected by
+// stack.DetectLocale, as a golang.org/x/text/language.Tag:
+//
+//	func greet(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+//		p := message.NewPrinter(locale.Tag(ctx))
+//		p.Fprintf(w, "%d new messages", n)
+//	}
+//
+// It is a separate package so that programs which don't use it don't
+// depend on golang.org/x/text.
+package locale
+
+import (
+	"golang.org/x/text/language"
+
+	"github.com/alexedwards/stack"
+)
+
+// Tag returns the language tag detected for the current request by
+// stack.DetectLocale. It returns language.Und if the middleware has not
+// run, or the detected locale isn't a valid tag.
+func Tag(ctx *stack.Context) language.Tag {
+	tag, err := language.Parse(stack.Locale(ctx))
+	if err != nil {
+		return language.Und
+	}
+	return tag
+}
@@ -0,0 +1,32 @@
+package locale
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/text/language"
+
+	"github.com/alexedwards/stack"
+)
+
+func assertEquals(t *testing.T, e interface{}, o interface{}) {
+	if e != o {
+		t.Errorf("\n...expected = %v\n...obtained = %v", e, o)
+	}
+}
+
+func TestTag(t *testing.T) {
+	var tag language.Tag
+	handler := func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		tag = Tag(ctx)
+	}
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Language", "pt-BR, en;q=0.5")
+	stack.New(stack.DetectLocale([]string{"en", "pt-BR"})).Then(handler).ServeHTTP(httptest.NewRecorder(), r)
+	assertEquals(t, language.BrazilianPortuguese, tag)
+
+	stack.New().Then(handler).ServeHTTP(httptest.NewRecorder(), r)
+	assertEquals(t, language.Und, tag)
+}
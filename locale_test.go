@@ -0,0 +1,52 @@
+package stack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func localeHandler(ctx *Context, w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(Locale(ctx)))
+}
+
+func TestDetectLocale(t *testing.T) {
+	st := New(DetectLocale([]string{"en-GB", "fr", "de"})).Then(localeHandler)
+
+	tests := []struct {
+		url, cookie, header string
+		expected            string
+	}{
+		{"/", "", "", "en-GB"},
+		{"/", "", "de-AT, fr;q=0.5", "de"},
+		{"/", "fr", "de", "fr"},
+		{"/?lang=de", "fr", "en", "de"},
+		{"/?lang=es", "", "fr", "fr"},
+		{"/", "", "ja", "en-GB"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", test.url, nil)
+		if test.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: "lang", Value: test.cookie})
+		}
+		r.Header.Set("Accept-Language", test.header)
+		rec := httptest.NewRecorder()
+		st.ServeHTTP(rec, r)
+		assertEquals(t, test.expected, rec.Body.String())
+	}
+}
+
+func TestDetectLocaleOrder(t *testing.T) {
+	st := New(DetectLocale([]string{"en", "fr"},
+		LocaleOrder(LocaleFromHeader, LocaleFromQuery),
+		LocaleQuery("locale"),
+		LocaleContentLanguage(),
+	)).Then(localeHandler)
+
+	r, _ := http.NewRequest("GET", "/?locale=fr", nil)
+	r.Header.Set("Accept-Language", "en-US")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, "en", rec.Body.String())
+	assertEquals(t, "en", rec.Header().Get("Content-Language"))
+}
@@ -0,0 +1,46 @@
+package stack
+
+import (
+	"net/http"
+	"strings"
+)
+
+// MethodOverride returns middleware which lets clients that can only send
+// GET and POST requests (such as HTML forms) tunnel other methods through
+// POST. The method is taken from the X-HTTP-Method-Override header or, for
+// form submissions, the "_method" form field, and r.Method is rewritten
+// before the rest of the chain runs.
+//
+// Only the given methods may be used as override targets. If none are
+// given, PUT, PATCH and DELETE are allowed.
+func MethodOverride(methods ...string) chainMiddleware {
+	if len(methods) == 0 {
+		methods = []string{"PUT", "PATCH", "DELETE"}
+	}
+	allowed := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		allowed[strings.ToUpper(m)] = true
+	}
+
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "POST" {
+				m := r.Header.Get("X-HTTP-Method-Override")
+				if m == "" && isFormRequest(r) {
+					m = r.PostFormValue("_method")
+				}
+				if m = strings.ToUpper(strings.TrimSpace(m)); allowed[m] {
+					r.Method = m
+					r.Header.Del("X-HTTP-Method-Override")
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func isFormRequest(r *http.Request) bool {
+	ct := r.Header.Get("Content-Type")
+	return strings.HasPrefix(ct, "application/x-www-form-urlencoded") ||
+		strings.HasPrefix(ct, "multipart/form-data")
+}
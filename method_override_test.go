@@ -0,0 +1,50 @@
+package stack
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func methodHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, r.Method)
+}
+
+func TestMethodOverride(t *testing.T) {
+	st := New(MethodOverride()).ThenHandlerFunc(methodHandler)
+
+	tests := []struct {
+		method, header, form string
+		expected             string
+	}{
+		{"POST", "DELETE", "", "DELETE"},
+		{"POST", "", "_method=put", "PUT"},
+		{"POST", "CONNECT", "", "POST"},
+		{"GET", "DELETE", "", "GET"},
+		{"POST", "", "", "POST"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(test.method, "/", strings.NewReader(test.form))
+		if test.header != "" {
+			r.Header.Set("X-HTTP-Method-Override", test.header)
+		}
+		if test.form != "" {
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		rec := httptest.NewRecorder()
+		st.ServeHTTP(rec, r)
+		assertEquals(t, test.expected, rec.Body.String())
+	}
+}
+
+func TestMethodOverrideAllowlist(t *testing.T) {
+	st := New(MethodOverride("PATCH")).ThenHandlerFunc(methodHandler)
+
+	r, _ := http.NewRequest("POST", "/", nil)
+	r.Header.Set("X-HTTP-Method-Override", "DELETE")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, "POST", rec.Body.String())
+}
@@ -0,0 +1,73 @@
+// Package basicauth provides HTTP Basic authentication middleware for
+// stack chains.
+package basicauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/alexedwards/stack"
+)
+
+// ValidateFunc checks a username and password, returning the principal to
+// store in the Context and whether the credentials are valid.
+type ValidateFunc func(user, pass string) (principal interface{}, ok bool)
+
+// ErrUnauthorized is passed to the chain's error handler (wrapped in a
+// stack.HTTPError with status 401) when credentials are missing or invalid.
+var ErrUnauthorized = errors.New("basicauth: invalid credentials")
+
+// New returns middleware which requires HTTP Basic credentials accepted by
+// validate. On success the principal is stored with stack.SetPrincipal. On
+// failure a WWW-Authenticate challenge for realm is set and the request
+// is passed to the chain's error handler with a 401 status.
+func New(realm string, validate ValidateFunc) func(*stack.Context, http.Handler) http.Handler {
+	challenge := `Basic realm="` + quoteEscape(realm) + `", charset="UTF-8"`
+
+	return func(ctx *stack.Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, pass, ok := r.BasicAuth()
+			if ok {
+				var principal interface{}
+				if principal, ok = validate(user, pass); ok {
+					stack.SetPrincipal(ctx, principal)
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			w.Header().Set("WWW-Authenticate", challenge)
+			stack.Error(ctx, w, r, stack.NewHTTPError(http.StatusUnauthorized, ErrUnauthorized))
+		})
+	}
+}
+
+// Users returns a ValidateFunc which checks credentials against a map of
+// usernames to passwords, using constant-time comparisons. The username
+// is used as the principal.
+func Users(users map[string]string) ValidateFunc {
+	return func(user, pass string) (interface{}, bool) {
+		want, found := users[user]
+		// Compare even when the user is unknown, so that response timing
+		// doesn't reveal which usernames exist.
+		match := Compare(pass, want)
+		if !found || !match {
+			return nil, false
+		}
+		return user, true
+	}
+}
+
+// Compare reports whether a and b are equal in time which depends on
+// neither their contents nor their lengths.
+func Compare(a, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+func quoteEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,46 @@
+package basicauth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alexedwards/stack"
+)
+
+func assertEquals(t *testing.T, e interface{}, o interface{}) {
+	if e != o {
+		t.Errorf("\n...expected = %v\n...obtained = %v", e, o)
+	}
+}
+
+func TestNew(t *testing.T) {
+	st := stack.New(New(`Bish "Bash"`, Users(map[string]string{"flip": "flop"}))).Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "principal=%v", stack.Principal(ctx))
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.SetBasicAuth("flip", "flop")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "principal=flip", rec.Body.String())
+
+	r.SetBasicAuth("flip", "wrong")
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 401, rec.Code)
+	assertEquals(t, `Basic realm="Bish \"Bash\"", charset="UTF-8"`, rec.Header().Get("WWW-Authenticate"))
+
+	r, _ = http.NewRequest("GET", "/", nil)
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 401, rec.Code)
+}
+
+func TestCompare(t *testing.T) {
+	assertEquals(t, true, Compare("bish", "bish"))
+	assertEquals(t, false, Compare("bish", "bash"))
+	assertEquals(t, false, Compare("bish", "bish2"))
+}
@@ -0,0 +1,274 @@
+// Package introspect provides middleware which validates opaque OAuth 2.0
+// access tokens using a token introspection endpoint (RFC 7662).
+package introspect
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexedwards/stack"
+)
+
+const resultKey = "stack.introspect.result"
+
+// Result is the introspection response for an active token.
+type Result struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope"`
+	ClientID  string   `json:"client_id"`
+	Username  string   `json:"username"`
+	TokenType string   `json:"token_type"`
+	Exp       int64    `json:"exp"`
+	Subject   string   `json:"sub"`
+	Audience  Audience `json:"aud"`
+	Issuer    string   `json:"iss"`
+}
+
+// Audience is the "aud" member of an introspection response, which may be
+// either a single string or a list of strings.
+type Audience []string
+
+// UnmarshalJSON accepts either form of the "aud" member.
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = Audience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*a = Audience(list)
+	return nil
+}
+
+// Scopes returns the space-separated scope string as a slice.
+func (r *Result) Scopes() []string {
+	return strings.Fields(r.Scope)
+}
+
+// Errors passed to the chain's error handler. ErrMissingToken and
+// ErrInactiveToken are wrapped in a stack.HTTPError with status 401, and
+// ErrUnavailable with status 503.
+var (
+	ErrMissingToken  = errors.New("introspect: missing bearer token")
+	ErrInactiveToken = errors.New("introspect: token is not active")
+	ErrUnavailable   = errors.New("introspect: introspection endpoint unavailable")
+)
+
+// Option configures the middleware.
+type Option func(*config)
+
+// ClientCredentials sets the credentials used to authenticate to the
+// introspection endpoint with HTTP Basic authentication.
+func ClientCredentials(id, secret string) Option {
+	return func(c *config) {
+		c.clientID, c.clientSecret = id, secret
+	}
+}
+
+// CacheTTL sets how long introspection results are cached. Results are
+// never cached beyond the token's expiry. The default is one minute, and
+// zero disables caching.
+func CacheTTL(d time.Duration) Option {
+	return func(c *config) {
+		c.ttl = d
+	}
+}
+
+// CircuitBreaker stops calls to the introspection endpoint for cooldown
+// after threshold consecutive failures, failing requests immediately with
+// a 503 instead. The default is 5 failures and a 30 second cooldown.
+func CircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(c *config) {
+		c.threshold, c.cooldown = threshold, cooldown
+	}
+}
+
+// Client sets the HTTP client used to call the introspection endpoint.
+func Client(client *http.Client) Option {
+	return func(c *config) {
+		c.client = client
+	}
+}
+
+type config struct {
+	endpoint     string
+	clientID     string
+	clientSecret string
+	ttl          time.Duration
+	threshold    int
+	cooldown     time.Duration
+	client       *http.Client
+
+	mu        sync.Mutex
+	cache     map[[sha256.Size]byte]cacheEntry
+	failures  int
+	openUntil time.Time
+}
+
+type cacheEntry struct {
+	result  *Result
+	expires time.Time
+}
+
+// maxCacheEntries bounds the memory used by cached results.
+const maxCacheEntries = 10000
+
+// New returns middleware which introspects the Bearer token in the
+// Authorization header at endpoint. Active tokens have their introspection
+// result stored in the Context (see FromContext), their subject recorded
+// with stack.SetPrincipal and their scopes with stack.SetScopes. Tokens
+// lacking scopes declared with stack.RequireScopes are rejected with a 403.
+func New(endpoint string, opts ...Option) func(*stack.Context, http.Handler) http.Handler {
+	cfg := &config{
+		endpoint:  endpoint,
+		ttl:       time.Minute,
+		threshold: 5,
+		cooldown:  30 * time.Second,
+		client:    &http.Client{Timeout: 5 * time.Second},
+		cache:     make(map[[sha256.Size]byte]cacheEntry),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(ctx *stack.Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := bearerToken(r)
+			if token == "" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				stack.Error(ctx, w, r, stack.NewHTTPError(http.StatusUnauthorized, ErrMissingToken))
+				return
+			}
+			result, err := cfg.introspect(token)
+			if err != nil {
+				stack.Error(ctx, w, r, stack.NewHTTPError(http.StatusServiceUnavailable, err))
+				return
+			}
+			if !result.Active {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				stack.Error(ctx, w, r, stack.NewHTTPError(http.StatusUnauthorized, ErrInactiveToken))
+				return
+			}
+			ctx.Put(resultKey, result)
+			subject := result.Subject
+			if subject == "" {
+				subject = result.Username
+			}
+			stack.SetPrincipal(ctx, subject)
+			stack.SetScopes(ctx, result.Scopes())
+			if missing := stack.MissingScopes(ctx); len(missing) > 0 {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, strings.Join(missing, " ")))
+				stack.Error(ctx, w, r, stack.NewHTTPError(http.StatusForbidden, stack.ErrInsufficientScope))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// FromContext returns the introspection result for the current request's
+// token, or nil if there is none.
+func FromContext(ctx *stack.Context) *Result {
+	r, _ := ctx.Get(resultKey).(*Result)
+	return r
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(auth[7:])
+}
+
+func (cfg *config) introspect(token string) (*Result, error) {
+	// Cache by hash so that raw tokens aren't kept in memory.
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+
+	cfg.mu.Lock()
+	if e, ok := cfg.cache[key]; ok && now.Before(e.expires) {
+		cfg.mu.Unlock()
+		return e.result, nil
+	}
+	if now.Before(cfg.openUntil) {
+		cfg.mu.Unlock()
+		return nil, ErrUnavailable
+	}
+	cfg.mu.Unlock()
+
+	result, err := cfg.call(token)
+
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	if err != nil {
+		cfg.failures++
+		if cfg.threshold > 0 && cfg.failures >= cfg.threshold {
+			cfg.openUntil = now.Add(cfg.cooldown)
+			cfg.failures = 0
+		}
+		return nil, ErrUnavailable
+	}
+	cfg.failures = 0
+
+	if cfg.ttl > 0 {
+		expires := now.Add(cfg.ttl)
+		if result.Exp > 0 && time.Unix(result.Exp, 0).Before(expires) {
+			expires = time.Unix(result.Exp, 0)
+		}
+		if len(cfg.cache) >= maxCacheEntries {
+			cfg.evict(now)
+		}
+		cfg.cache[key] = cacheEntry{result: result, expires: expires}
+	}
+	return result, nil
+}
+
+// evict removes expired entries, or everything if none have expired.
+func (cfg *config) evict(now time.Time) {
+	for k, e := range cfg.cache {
+		if !now.Before(e.expires) {
+			delete(cfg.cache, k)
+		}
+	}
+	if len(cfg.cache) >= maxCacheEntries {
+		cfg.cache = make(map[[sha256.Size]byte]cacheEntry)
+	}
+}
+
+func (cfg *config) call(token string) (*Result, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequest("POST", cfg.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if cfg.clientID != "" {
+		req.SetBasicAuth(url.QueryEscape(cfg.clientID), url.QueryEscape(cfg.clientSecret))
+	}
+
+	res, err := cfg.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspect: endpoint returned %s", res.Status)
+	}
+	result := &Result{}
+	if err := json.NewDecoder(res.Body).Decode(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
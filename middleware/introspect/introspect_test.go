@@ -0,0 +1,89 @@
+package introspect
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/stack"
+)
+
+func assertEquals(t *testing.T, e interface{}, o interface{}) {
+	if e != o {
+		t.Errorf("\n...expected = %v\n...obtained = %v", e, o)
+	}
+}
+
+func resultHandler(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "principal=%v scopes=%v", stack.Principal(ctx), FromContext(ctx).Scopes())
+}
+
+func serveToken(hc stack.HandlerChain, token string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	hc.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestNew(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		user, pass, _ := r.BasicAuth()
+		if user != "client" || pass != "secret" {
+			w.WriteHeader(401)
+			return
+		}
+		active := r.PostFormValue("token") == "good"
+		json.NewEncoder(w).Encode(map[string]interface{}{"active": active, "sub": "flip", "scope": "orders:read orders:write", "aud": []string{"api"}})
+	}))
+	defer ts.Close()
+
+	st := stack.New(New(ts.URL, ClientCredentials("client", "secret"))).Then(resultHandler)
+
+	rec := serveToken(st, "good")
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "principal=flip scopes=[orders:read orders:write]", rec.Body.String())
+	serveToken(st, "good")
+	assertEquals(t, 1, calls)
+
+	rec = serveToken(st, "bad")
+	assertEquals(t, 401, rec.Code)
+	assertEquals(t, `Bearer error="invalid_token"`, rec.Header().Get("WWW-Authenticate"))
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(500)
+	}))
+	defer ts.Close()
+
+	st := stack.New(New(ts.URL, CircuitBreaker(2, time.Hour))).Then(resultHandler)
+
+	for i := 0; i < 4; i++ {
+		rec := serveToken(st, "good")
+		assertEquals(t, 503, rec.Code)
+	}
+	assertEquals(t, 2, calls)
+}
+
+func TestRequireScopes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"active": true, "sub": "flip", "scope": "orders:read"})
+	}))
+	defer ts.Close()
+
+	hc := stack.New(New(ts.URL)).Then(resultHandler)
+	rec := serveToken(stack.RequireScopes(hc, "orders:read"), "good")
+	assertEquals(t, 200, rec.Code)
+
+	rec = serveToken(stack.RequireScopes(hc, "orders:write"), "good")
+	assertEquals(t, 403, rec.Code)
+	assertEquals(t, `Bearer error="insufficient_scope", scope="orders:write"`, rec.Header().Get("WWW-Authenticate"))
+}
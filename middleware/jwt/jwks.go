@@ -0,0 +1,210 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKey is returned by a JWKS KeyFunc when the token's key ID is
+// not in the key set.
+var ErrUnknownKey = errors.New("jwt: unknown signing key")
+
+// minRefresh limits how often an unknown key ID can trigger a refetch of
+// the key set, so that junk tokens can't be used to hammer the JWKS URL.
+const minRefresh = time.Minute
+
+// failureBackoff is how long to wait after a failed fetch before trying
+// again, so that requests don't queue up behind an endpoint which is down.
+const failureBackoff = 10 * time.Second
+
+// JWKS returns a KeyFunc which looks up keys by ID in the JSON Web Key Set
+// published at url. The key set is cached for ttl, and refetched early
+// (at most once a minute) when a token refers to an unknown key ID, so
+// that key rotation is picked up promptly. Once the cached set has
+// expired, known keys go on being used while it is refetched in the
+// background, and if the endpoint is down it is retried at most every 10
+// seconds. RSA and EC (P-256, P-384, P-521) keys are supported.
+func JWKS(url string, ttl time.Duration) KeyFunc {
+	ks := &keySet{url: url, ttl: ttl, client: &http.Client{Timeout: 10 * time.Second}}
+	return ks.key
+}
+
+type keySet struct {
+	url     string
+	ttl     time.Duration
+	client  *http.Client
+	mu      sync.Mutex
+	keys    map[string]interface{}
+	fetched time.Time
+	// failed and err record the last failed fetch, and done is closed
+	// when the fetch in progress (if any) finishes.
+	failed time.Time
+	err    error
+	done   chan struct{}
+}
+
+func (ks *keySet) key(h Header) (interface{}, error) {
+	ks.mu.Lock()
+	key, ok := ks.keys[h.KeyID]
+	if ok && time.Since(ks.fetched) < ks.ttl {
+		ks.mu.Unlock()
+		return key, nil
+	}
+	if !ks.due() {
+		err := ks.err
+		ks.mu.Unlock()
+		return lookupResult(key, ok, err)
+	}
+	done := ks.refresh()
+	ks.mu.Unlock()
+	if ok {
+		// Keep using the stale key while the key set is refetched.
+		return key, nil
+	}
+
+	<-done
+	ks.mu.Lock()
+	key, ok = ks.keys[h.KeyID]
+	err := ks.err
+	ks.mu.Unlock()
+	return lookupResult(key, ok, err)
+}
+
+// due reports whether the key set should be fetched, for a lookup which
+// found a stale key or none. It must be called with ks.mu held.
+func (ks *keySet) due() bool {
+	if time.Since(ks.failed) < failureBackoff {
+		return false
+	}
+	age := time.Since(ks.fetched)
+	return ks.keys == nil || age >= ks.ttl || age >= minRefresh
+}
+
+// refresh starts fetching the key set, unless a fetch is already in
+// progress, and returns a channel which is closed when it finishes. It
+// must be called with ks.mu held.
+func (ks *keySet) refresh() chan struct{} {
+	if ks.done != nil {
+		return ks.done
+	}
+	done := make(chan struct{})
+	ks.done = done
+	go func() {
+		keys, err := ks.fetch()
+		ks.mu.Lock()
+		if err != nil {
+			ks.failed, ks.err = time.Now(), err
+		} else {
+			ks.keys, ks.fetched, ks.err = keys, time.Now(), nil
+		}
+		ks.done = nil
+		ks.mu.Unlock()
+		close(done)
+	}()
+	return done
+}
+
+// lookupResult returns key if it was found, and otherwise the error from
+// the last fetch or ErrUnknownKey.
+func lookupResult(key interface{}, ok bool, err error) (interface{}, error) {
+	switch {
+	case ok:
+		return key, nil
+	case err != nil:
+		return nil, err
+	}
+	return nil, ErrUnknownKey
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (ks *keySet) fetch() (map[string]interface{}, error) {
+	res, err := ks.client.Get(ks.url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwt: fetching %s: %s", ks.url, res.Status)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{})
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("jwt: unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("jwt: unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,368 @@
+// Package jwt provides middleware which authenticates requests carrying a
+// JSON Web Token in a Bearer Authorization header.
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/stack"
+)
+
+const claimsKey = "stack.jwt.claims"
+
+// Claims holds the decoded claims of a validated token.
+type Claims map[string]interface{}
+
+// Subject returns the "sub" claim.
+func (c Claims) Subject() string {
+	s, _ := c["sub"].(string)
+	return s
+}
+
+// Issuer returns the "iss" claim.
+func (c Claims) Issuer() string {
+	s, _ := c["iss"].(string)
+	return s
+}
+
+// Audience returns the "aud" claim, which may be a single string or a list
+// of strings in the token.
+func (c Claims) Audience() []string {
+	switch aud := c["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []interface{}:
+		var auds []string
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				auds = append(auds, s)
+			}
+		}
+		return auds
+	}
+	return nil
+}
+
+// Scopes returns the token's scopes, from either a space-separated "scope"
+// claim or a "scp" list claim.
+func (c Claims) Scopes() []string {
+	if s, ok := c["scope"].(string); ok {
+		return strings.Fields(s)
+	}
+	var scopes []string
+	if list, ok := c["scp"].([]interface{}); ok {
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				scopes = append(scopes, s)
+			}
+		}
+	}
+	return scopes
+}
+
+func (c Claims) time(name string) (time.Time, bool) {
+	f, ok := c[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+// Header holds the fields of a token's JOSE header used for verification.
+type Header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Type      string `json:"typ"`
+}
+
+// KeyFunc returns the key used to verify a token with the given header: a
+// []byte secret for HS256/384/512, an *rsa.PublicKey for RS256/384/512 or
+// an *ecdsa.PublicKey for ES256/384/512.
+type KeyFunc func(h Header) (interface{}, error)
+
+// StaticKey returns a KeyFunc which always returns key.
+func StaticKey(key interface{}) KeyFunc {
+	return func(Header) (interface{}, error) {
+		return key, nil
+	}
+}
+
+// Option configures the middleware.
+type Option func(*config)
+
+// Audience requires the token's "aud" claim to contain aud.
+func Audience(aud string) Option {
+	return func(c *config) {
+		c.audience = aud
+	}
+}
+
+// Issuer requires the token's "iss" claim to equal iss.
+func Issuer(iss string) Option {
+	return func(c *config) {
+		c.issuer = iss
+	}
+}
+
+// Leeway sets the clock skew allowed when checking the "exp" and "nbf"
+// claims. The default is one minute.
+func Leeway(d time.Duration) Option {
+	return func(c *config) {
+		c.leeway = d
+	}
+}
+
+// Realm sets the realm sent in WWW-Authenticate challenges.
+func Realm(realm string) Option {
+	return func(c *config) {
+		c.realm = realm
+	}
+}
+
+// Algorithms restricts the signing algorithms which are accepted. By
+// default any supported algorithm matching the type of the key is.
+func Algorithms(algs ...string) Option {
+	return func(c *config) {
+		c.algorithms = algs
+	}
+}
+
+type config struct {
+	keyFunc    KeyFunc
+	audience   string
+	issuer     string
+	leeway     time.Duration
+	realm      string
+	algorithms []string
+	now        func() time.Time
+}
+
+// Errors passed to the chain's error handler, wrapped in a stack.HTTPError
+// with status 401.
+var (
+	ErrMissingToken     = errors.New("jwt: missing bearer token")
+	ErrMalformedToken   = errors.New("jwt: malformed token")
+	ErrInvalidSignature = errors.New("jwt: invalid signature")
+	ErrExpired          = errors.New("jwt: token has expired")
+	ErrNotYetValid      = errors.New("jwt: token is not valid yet")
+	ErrInvalidAudience  = errors.New("jwt: invalid audience")
+	ErrInvalidIssuer    = errors.New("jwt: invalid issuer")
+)
+
+var knownErrors = map[error]bool{
+	ErrMalformedToken:   true,
+	ErrInvalidSignature: true,
+	ErrExpired:          true,
+	ErrNotYetValid:      true,
+	ErrInvalidAudience:  true,
+	ErrInvalidIssuer:    true,
+	ErrUnknownKey:       true,
+}
+
+// New returns middleware which validates the Bearer token in the
+// Authorization header. Valid tokens have their claims stored in the
+// Context (see FromContext), their subject recorded with
+// stack.SetPrincipal and their scopes with stack.SetScopes. Tokens lacking
+// scopes declared with stack.RequireScopes are rejected with a 403.
+// Requests with missing or invalid tokens get a WWW-Authenticate
+// challenge, with an RFC 6750 error code where appropriate, and are passed
+// to the chain's error handler with a 401 status.
+func New(keyFunc KeyFunc, opts ...Option) func(*stack.Context, http.Handler) http.Handler {
+	cfg := &config{keyFunc: keyFunc, leeway: time.Minute, now: time.Now}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(ctx *stack.Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := bearerToken(r)
+			if token == "" {
+				cfg.fail(ctx, w, r, ErrMissingToken)
+				return
+			}
+			claims, err := cfg.parse(token)
+			if err != nil {
+				cfg.fail(ctx, w, r, err)
+				return
+			}
+			ctx.Put(claimsKey, claims)
+			stack.SetPrincipal(ctx, claims.Subject())
+			stack.SetScopes(ctx, claims.Scopes())
+			if missing := stack.MissingScopes(ctx); len(missing) > 0 {
+				cfg.insufficientScope(ctx, w, r, missing)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// FromContext returns the claims of the token validated for the current
+// request, or nil if there are none.
+func FromContext(ctx *stack.Context) Claims {
+	c, _ := ctx.Get(claimsKey).(Claims)
+	return c
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(auth[7:])
+}
+
+func (cfg *config) challenge() string {
+	return `Bearer realm="` + strings.Replace(cfg.realm, `"`, `\"`, -1) + `"`
+}
+
+func (cfg *config) fail(ctx *stack.Context, w http.ResponseWriter, r *http.Request, err error) {
+	challenge := cfg.challenge()
+	if err != ErrMissingToken {
+		// Only describe errors from this package; others (such as a
+		// failure to fetch a key set) may contain internal details.
+		desc := "invalid token"
+		if knownErrors[err] {
+			desc = strings.TrimPrefix(err.Error(), "jwt: ")
+		}
+		challenge += fmt.Sprintf(`, error="invalid_token", error_description=%q`, desc)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	stack.Error(ctx, w, r, stack.NewHTTPError(http.StatusUnauthorized, err))
+}
+
+func (cfg *config) insufficientScope(ctx *stack.Context, w http.ResponseWriter, r *http.Request, missing []string) {
+	challenge := cfg.challenge() + fmt.Sprintf(`, error="insufficient_scope", scope=%q`, strings.Join(missing, " "))
+	w.Header().Set("WWW-Authenticate", challenge)
+	stack.Error(ctx, w, r, stack.NewHTTPError(http.StatusForbidden, stack.ErrInsufficientScope))
+}
+
+func (cfg *config) parse(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+	var h Header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, ErrMalformedToken
+	}
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrMalformedToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	if len(cfg.algorithms) > 0 && !contains(cfg.algorithms, h.Algorithm) {
+		return nil, ErrInvalidSignature
+	}
+	key, err := cfg.keyFunc(h)
+	if err != nil {
+		return nil, err
+	}
+	if err := verify(h.Algorithm, parts[0]+"."+parts[1], sig, key); err != nil {
+		return nil, err
+	}
+
+	now := cfg.now()
+	if exp, ok := claims.time("exp"); ok && now.After(exp.Add(cfg.leeway)) {
+		return nil, ErrExpired
+	}
+	if nbf, ok := claims.time("nbf"); ok && now.Add(cfg.leeway).Before(nbf) {
+		return nil, ErrNotYetValid
+	}
+	if cfg.issuer != "" && claims.Issuer() != cfg.issuer {
+		return nil, ErrInvalidIssuer
+	}
+	if cfg.audience != "" && !contains(claims.Audience(), cfg.audience) {
+		return nil, ErrInvalidAudience
+	}
+	return claims, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+var hashes = map[string]crypto.Hash{
+	"256": crypto.SHA256,
+	"384": crypto.SHA384,
+	"512": crypto.SHA512,
+}
+
+// verify checks sig against signed using key. The algorithm family must
+// match the type of the key, which prevents tokens signed with an HMAC
+// from being verified using a public key as the secret.
+func verify(alg, signed string, sig []byte, key interface{}) error {
+	if len(alg) != 5 {
+		return ErrInvalidSignature
+	}
+	hash, ok := hashes[alg[2:]]
+	if !ok {
+		return ErrInvalidSignature
+	}
+	hh := hash.New()
+	hh.Write([]byte(signed))
+	digest := hh.Sum(nil)
+
+	switch alg[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return ErrInvalidSignature
+		}
+		mac := hmac.New(hash.New, secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return ErrInvalidSignature
+		}
+	case "RS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPKCS1v15(pub, hash, digest, sig) != nil {
+			return ErrInvalidSignature
+		}
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return ErrInvalidSignature
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return ErrInvalidSignature
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return ErrInvalidSignature
+		}
+	default:
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/stack"
+)
+
+func assertEquals(t *testing.T, e interface{}, o interface{}) {
+	if e != o {
+		t.Errorf("\n...expected = %v\n...obtained = %v", e, o)
+	}
+}
+
+func encodeSegment(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func signToken(alg, kid string, claims map[string]interface{}, key interface{}) string {
+	signed := encodeSegment(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encodeSegment(claims)
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		sig, _ = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, _ := ecdsa.Sign(rand.Reader, k, digest[:])
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func claimsHandler(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "sub=%s principal=%v", FromContext(ctx).Subject(), stack.Principal(ctx))
+}
+
+func serveToken(hc stack.HandlerChain, token string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("GET", "/", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	hc.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestHS256(t *testing.T) {
+	secret := []byte("bish bash bosh")
+	st := stack.New(New(StaticKey(secret), Audience("api"), Issuer("auth"), Realm("example"))).Then(claimsHandler)
+
+	exp := float64(time.Now().Add(time.Hour).Unix())
+	token := signToken("HS256", "", map[string]interface{}{"sub": "flip", "aud": []string{"web", "api"}, "iss": "auth", "exp": exp}, secret)
+	rec := serveToken(st, token)
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "sub=flip principal=flip", rec.Body.String())
+
+	rec = serveToken(st, "")
+	assertEquals(t, 401, rec.Code)
+	assertEquals(t, `Bearer realm="example"`, rec.Header().Get("WWW-Authenticate"))
+
+	tests := []struct {
+		claims map[string]interface{}
+		key    []byte
+		desc   string
+	}{
+		{map[string]interface{}{"sub": "flip", "aud": "api", "iss": "auth", "exp": exp}, []byte("wrong"), "invalid signature"},
+		{map[string]interface{}{"sub": "flip", "aud": "api", "iss": "auth", "exp": float64(time.Now().Add(-time.Hour).Unix())}, secret, "token has expired"},
+		{map[string]interface{}{"sub": "flip", "aud": "other", "iss": "auth"}, secret, "invalid audience"},
+		{map[string]interface{}{"sub": "flip", "aud": "api", "iss": "other"}, secret, "invalid issuer"},
+	}
+	for _, test := range tests {
+		rec = serveToken(st, signToken("HS256", "", test.claims, test.key))
+		assertEquals(t, 401, rec.Code)
+		assertEquals(t, `Bearer realm="example", error="invalid_token", error_description="`+test.desc+`"`, rec.Header().Get("WWW-Authenticate"))
+	}
+
+	rec = serveToken(st, "bish.bash")
+	assertEquals(t, 401, rec.Code)
+}
+
+func TestES256(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	st := stack.New(New(StaticKey(&key.PublicKey))).Then(claimsHandler)
+
+	rec := serveToken(st, signToken("ES256", "", map[string]interface{}{"sub": "flip"}, key))
+	assertEquals(t, 200, rec.Code)
+}
+
+func TestAlgorithmConfusion(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	st := stack.New(New(StaticKey(&key.PublicKey))).Then(claimsHandler)
+
+	// A token "signed" with HS256 using the public key as the secret must
+	// not verify.
+	secret := key.PublicKey.N.Bytes()
+	rec := serveToken(st, signToken("HS256", "", map[string]interface{}{"sub": "flip"}, secret))
+	assertEquals(t, 401, rec.Code)
+
+	none := encodeSegment(map[string]string{"alg": "none"}) + "." + encodeSegment(map[string]string{"sub": "flip"}) + "."
+	rec = serveToken(st, none)
+	assertEquals(t, 401, rec.Code)
+}
+
+func TestJWKS(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	fetches := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "k1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   "AQAB",
+			}},
+		})
+	}))
+	defer ts.Close()
+
+	st := stack.New(New(JWKS(ts.URL, time.Hour))).Then(claimsHandler)
+
+	token := signToken("RS256", "k1", map[string]interface{}{"sub": "flip"}, key)
+	rec := serveToken(st, token)
+	assertEquals(t, 200, rec.Code)
+	rec = serveToken(st, token)
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, 1, fetches)
+
+	rec = serveToken(st, signToken("RS256", "k2", map[string]interface{}{"sub": "flip"}, key))
+	assertEquals(t, 401, rec.Code)
+	assertEquals(t, true, strings.Contains(rec.Header().Get("WWW-Authenticate"), "unknown signing key"))
+	assertEquals(t, 1, fetches)
+}
+
+func TestJWKSUnavailable(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	var fetches int32
+	var down int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		if atomic.LoadInt32(&down) == 1 {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "k1",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   "AQAB",
+			}},
+		})
+	}))
+	defer ts.Close()
+
+	ks := &keySet{url: ts.URL, ttl: time.Hour, client: ts.Client()}
+	_, err := ks.key(Header{KeyID: "k1"})
+	assertEquals(t, nil, err)
+
+	// Once the key set has expired, the known key is still returned while
+	// a refetch happens in the background.
+	atomic.StoreInt32(&down, 1)
+	ks.mu.Lock()
+	ks.fetched = ks.fetched.Add(-2 * time.Hour)
+	ks.mu.Unlock()
+	k, err := ks.key(Header{KeyID: "k1"})
+	assertEquals(t, nil, err)
+	assertEquals(t, true, k != nil)
+	ks.mu.Lock()
+	done := ks.done
+	ks.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+	assertEquals(t, int32(2), atomic.LoadInt32(&fetches))
+
+	// The failure is remembered, so lookups neither wait nor refetch.
+	_, err = ks.key(Header{KeyID: "k1"})
+	assertEquals(t, nil, err)
+	_, err = ks.key(Header{KeyID: "k2"})
+	assertEquals(t, true, err != nil)
+	assertEquals(t, int32(2), atomic.LoadInt32(&fetches))
+}
+
+func TestRequireScopes(t *testing.T) {
+	secret := []byte("bish bash bosh")
+	st := stack.RequireScopes(stack.New(New(StaticKey(secret))).Then(claimsHandler), "orders:write")
+
+	rec := serveToken(st, signToken("HS256", "", map[string]interface{}{"sub": "flip", "scope": "orders:read orders:write"}, secret))
+	assertEquals(t, 200, rec.Code)
+
+	rec = serveToken(st, signToken("HS256", "", map[string]interface{}{"sub": "flip", "scp": []string{"orders:read"}}, secret))
+	assertEquals(t, 403, rec.Code)
+	assertEquals(t, `Bearer realm="", error="insufficient_scope", scope="orders:write"`, rec.Header().Get("WWW-Authenticate"))
+}
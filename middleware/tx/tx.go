@@ -0,0 +1,149 @@
+// Package tx provides middleware which runs each request in a database
+// transaction, committing it if the request succeeds and rolling it back
+// if it fails.
+//
+// The middleware relies on the response hooks, so the chain must record
+// responses:
+//
+//	api := stack.New(tx.New(db)).RecordResponses().Then(createUser)
+//
+//	func createUser(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+//		_, err := tx.FromContext(ctx).Exec("INSERT INTO users ...")
+//		...
+//	}
+package tx
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/alexedwards/stack"
+)
+
+const txKey = "stack.tx"
+
+// Policy decides, once the response is complete, whether to commit the
+// transaction (true) or roll it back (false).
+type Policy func(info stack.ResponseInfo) bool
+
+// DefaultPolicy commits if the handler didn't panic, no error was passed
+// to stack.Error and the response status is 2xx or 3xx.
+func DefaultPolicy(info stack.ResponseInfo) bool {
+	return info.Panic == nil && info.Err == nil && info.Status >= 200 && info.Status < 400
+}
+
+// Option configures the transaction middleware.
+type Option func(*config)
+
+// CommitIf sets the policy used to decide whether to commit. The default
+// is DefaultPolicy.
+func CommitIf(policy Policy) Option {
+	return func(c *config) {
+		c.policy = policy
+	}
+}
+
+// TxOptions sets the options (such as the isolation level) used to begin
+// each transaction.
+func TxOptions(opts *sql.TxOptions) Option {
+	return func(c *config) {
+		c.txOptions = opts
+	}
+}
+
+// ErrorFunc sets the function called when a transaction can't be committed
+// or rolled back. By default these errors are ignored.
+func ErrorFunc(fn func(error)) Option {
+	return func(c *config) {
+		c.errorFunc = fn
+	}
+}
+
+type config struct {
+	policy    Policy
+	txOptions *sql.TxOptions
+	errorFunc func(error)
+}
+
+// New returns middleware which begins a transaction on db for each
+// request, stores it in the Context (see FromContext) and ends it
+// according to the policy. If the transaction can't be begun the error is
+// passed to the chain's error handler. The chain must record responses
+// (see stack.Chain.RecordResponses).
+//
+// The transaction is ended just before the response headers are written,
+// when the status is known, so that if it can't be committed the client
+// is sent a 500 Internal Server Error rather than the handler's status. If
+// the handler writes nothing, the transaction is ended when it returns,
+// and a failed commit is passed to the chain's error handler. Handlers may
+// also commit themselves, with FromContext(ctx).Commit(), in which case
+// the middleware's own commit or rollback is a no-op.
+//
+// The transaction is begun with the request's context, so database/sql
+// rolls it back if the client goes away; the commit then fails, and the
+// failure is reported to the ErrorFunc like any other.
+func New(db *sql.DB, opts ...Option) func(*stack.Context, http.Handler) http.Handler {
+	cfg := &config{policy: DefaultPolicy, errorFunc: func(error) {}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return stack.Requires(func(ctx *stack.Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var tx *sql.Tx
+			var ended bool
+			// end commits or rolls back the transaction, once, and returns
+			// the error from a failed commit.
+			end := func(info stack.ResponseInfo) error {
+				if tx == nil || ended {
+					return nil
+				}
+				ended = true
+				commit := cfg.policy(info)
+				var err error
+				if commit {
+					err = tx.Commit()
+				} else {
+					err = tx.Rollback()
+				}
+				if err == sql.ErrTxDone && r.Context().Err() == nil {
+					// The handler ended the transaction itself.
+					return nil
+				}
+				if err != nil {
+					cfg.errorFunc(err)
+				}
+				if !commit {
+					return nil
+				}
+				return err
+			}
+			// Register the callback first, so the transaction can't leak
+			// if the chain doesn't record responses and OnFinish panics.
+			// It ends the transaction if nothing else has, such as when
+			// the handler panics or hijacks the connection.
+			stack.OnFinish(ctx, func(info stack.ResponseInfo) {
+				end(info)
+			})
+			var err error
+			if tx, err = db.BeginTx(r.Context(), cfg.txOptions); err != nil {
+				stack.Error(ctx, w, r, err)
+				return
+			}
+			ctx.Put(txKey, tx)
+			stack.BeforeWrite(ctx, func(w http.ResponseWriter) {
+				if err := end(stack.PendingResponse(ctx)); err != nil {
+					stack.Response(ctx).SetPendingStatus(http.StatusInternalServerError)
+				}
+			})
+			next.ServeHTTP(w, r)
+		})
+	}, stack.NeedsResponses)
+}
+
+// FromContext returns the transaction for the current request, or nil if
+// the chain doesn't include the middleware.
+func FromContext(ctx *stack.Context) *sql.Tx {
+	tx, _ := ctx.Get(txKey).(*sql.Tx)
+	return tx
+}
@@ -0,0 +1,224 @@
+package tx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/stack"
+)
+
+func assertEquals(t *testing.T, e interface{}, o interface{}) {
+	if e != o {
+		t.Errorf("\n...expected = %v\n...obtained = %v", e, o)
+	}
+}
+
+// fakeDriver records the transactions begun on its connections.
+type fakeDriver struct {
+	mu        sync.Mutex
+	log       []string
+	beginErr  error
+	commitErr error
+}
+
+func (d *fakeDriver) record(event string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.log = append(d.log, event)
+}
+
+func (d *fakeDriver) logged(event string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range d.log {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *fakeDriver) events() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	events := strings.Join(d.log, " ")
+	d.log = nil
+	return events
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{d}, nil
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	if c.d.beginErr != nil {
+		return nil, c.d.beginErr
+	}
+	c.d.record("begin")
+	return fakeTx{c.d}, nil
+}
+
+type fakeTx struct {
+	d *fakeDriver
+}
+
+func (tx fakeTx) Commit() error {
+	tx.d.record("commit")
+	return tx.d.commitErr
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.d.record("rollback")
+	return nil
+}
+
+var testDriver = &fakeDriver{}
+
+func init() {
+	sql.Register("stacktx", testDriver)
+}
+
+func serve(hc stack.HandlerChain) {
+	r := httptest.NewRequest("GET", "/", nil)
+	func() {
+		defer func() { recover() }()
+		hc.ServeHTTP(httptest.NewRecorder(), r)
+	}()
+}
+
+func TestTx(t *testing.T) {
+	db, _ := sql.Open("stacktx", "")
+	defer db.Close()
+
+	tests := []struct {
+		handler func(ctx *stack.Context, w http.ResponseWriter, r *http.Request)
+		events  string
+	}{
+		{func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+			if FromContext(ctx) == nil {
+				t.Error("no transaction in the Context")
+			}
+		}, "begin commit"},
+		{func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/elsewhere", http.StatusSeeOther)
+		}, "begin commit"},
+		{func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusConflict)
+		}, "begin rollback"},
+		{func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+			stack.Error(ctx, w, r, errors.New("bish"))
+		}, "begin rollback"},
+		{func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+			panic("bash")
+		}, "begin rollback"},
+		{func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+			FromContext(ctx).Commit()
+			w.WriteHeader(http.StatusInternalServerError)
+		}, "begin commit"},
+	}
+	for i, test := range tests {
+		serve(stack.New(New(db)).RecordResponses().Then(test.handler))
+		if events := testDriver.events(); events != test.events {
+			t.Errorf("test %d: expected %q, got %q", i, test.events, events)
+		}
+	}
+}
+
+func TestTxPolicy(t *testing.T) {
+	db, _ := sql.Open("stacktx", "")
+	defer db.Close()
+
+	never := func(stack.ResponseInfo) bool { return false }
+	serve(stack.New(New(db, CommitIf(never))).RecordResponses().Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {}))
+	assertEquals(t, "begin rollback", testDriver.events())
+}
+
+func TestTxBeginError(t *testing.T) {
+	db, _ := sql.Open("stacktx", "")
+	defer db.Close()
+	testDriver.beginErr = errors.New("database down")
+	defer func() { testDriver.beginErr = nil }()
+
+	called := false
+	hc := stack.New(New(db)).RecordResponses().Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+	rec := httptest.NewRecorder()
+	hc.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	assertEquals(t, false, called)
+	assertEquals(t, 500, rec.Code)
+	assertEquals(t, "", testDriver.events())
+}
+
+func TestTxCommitError(t *testing.T) {
+	db, _ := sql.Open("stacktx", "")
+	defer db.Close()
+	testDriver.commitErr = errors.New("serialization failure")
+	defer func() { testDriver.commitErr = nil }()
+
+	var reported []error
+	mw := New(db, ErrorFunc(func(err error) { reported = append(reported, err) }))
+	handlers := []func(ctx *stack.Context, w http.ResponseWriter, r *http.Request){
+		func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("created"))
+		},
+		func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {},
+	}
+	for _, h := range handlers {
+		rec := httptest.NewRecorder()
+		stack.New(mw).RecordResponses().Then(h).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		assertEquals(t, 500, rec.Code)
+		assertEquals(t, "begin commit", testDriver.events())
+	}
+	assertEquals(t, 2, len(reported))
+}
+
+func TestTxClientGone(t *testing.T) {
+	db, _ := sql.Open("stacktx", "")
+	defer db.Close()
+
+	var reported error
+	reqCtx, cancel := context.WithCancel(context.Background())
+	hc := stack.New(New(db, ErrorFunc(func(err error) { reported = err }))).RecordResponses().Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		cancel()
+		// database/sql rolls the transaction back in the background.
+		for i := 0; i < 1000 && !testDriver.logged("rollback"); i++ {
+			time.Sleep(time.Millisecond)
+		}
+	})
+	r := httptest.NewRequest("GET", "/", nil).WithContext(reqCtx)
+	hc.ServeHTTP(httptest.NewRecorder(), r)
+	assertEquals(t, sql.ErrTxDone, reported)
+	assertEquals(t, "begin rollback", testDriver.events())
+}
+
+func TestTxRequiresResponses(t *testing.T) {
+	db, _ := sql.Open("stacktx", "")
+	defer db.Close()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected Then to panic")
+		}
+	}()
+	stack.New(New(db)).Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {})
+}
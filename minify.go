@@ -0,0 +1,68 @@
+package stack
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const skipMinifyKey = "stack.skipMinify"
+
+// Minifier minifies a body of the given media type (such as "text/html"),
+// reading it from src and writing the result to dst. The Minify method of
+// *minify.M from github.com/tdewolff/minify satisfies it.
+type Minifier interface {
+	Minify(mediaType string, dst io.Writer, src io.Reader) error
+}
+
+// MinifierFunc adapts a function into a Minifier.
+type MinifierFunc func(mediaType string, dst io.Writer, src io.Reader) error
+
+// Minify calls fn.
+func (fn MinifierFunc) Minify(mediaType string, dst io.Writer, src io.Reader) error {
+	return fn(mediaType, dst, src)
+}
+
+var defaultMinifyTypes = []string{
+	"text/html",
+	"text/css",
+	"text/javascript",
+	"application/javascript",
+}
+
+// SkipMinify opts the current request out of the Transformer returned by
+// MinifyTransformer, such as for a page whose whitespace matters. It must
+// be called before the response is written.
+func SkipMinify(ctx *Context) {
+	ctx.Put(skipMinifyKey, true)
+}
+
+// MinifyTransformer returns a Transformer, for use with Transform, which
+// passes responses with one of the given media types through m. The
+// default types are HTML, CSS and JavaScript. If m fails the original body
+// is sent unchanged.
+//
+//	stack.New(stack.Compress(), stack.Transform(stack.MinifyTransformer(minify.New())))
+func MinifyTransformer(m Minifier, types ...string) Transformer {
+	if len(types) == 0 {
+		types = defaultMinifyTypes
+	}
+	bt := BufferTransformer(func(ctx *Context, h http.Header, body []byte) ([]byte, error) {
+		mediaType := h.Get("Content-Type")
+		if i := strings.IndexByte(mediaType, ';'); i >= 0 {
+			mediaType = mediaType[:i]
+		}
+		var buf bytes.Buffer
+		if err := m.Minify(strings.ToLower(strings.TrimSpace(mediaType)), &buf, bytes.NewReader(body)); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}, types...)
+	return TransformerFunc(func(ctx *Context, h http.Header, status int, dst io.Writer) io.WriteCloser {
+		if ctx.Exists(skipMinifyKey) {
+			return nil
+		}
+		return bt.Transform(ctx, h, status, dst)
+	})
+}
@@ -0,0 +1,48 @@
+package stack
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+// collapseSpace is a Minifier which squeezes runs of whitespace.
+var collapseSpace = MinifierFunc(func(mediaType string, dst io.Writer, src io.Reader) error {
+	if mediaType == "text/css" {
+		return errors.New("unsupported")
+	}
+	b, _ := io.ReadAll(src)
+	_, err := dst.Write(regexp.MustCompile(`\s+`).ReplaceAll(b, []byte(" ")))
+	return err
+})
+
+func TestMinifyTransformer(t *testing.T) {
+	var contentType string
+	var skip bool
+	st := New(Transform(MinifyTransformer(collapseSpace))).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		if skip {
+			SkipMinify(ctx)
+		}
+		w.Header().Set("Content-Type", contentType)
+		io.WriteString(w, "<p>\n  bish\n</p>")
+	})
+
+	tests := []struct {
+		contentType string
+		skip        bool
+		body        string
+	}{
+		{"text/html; charset=utf-8", false, "<p> bish </p>"},
+		{"text/html", true, "<p>\n  bish\n</p>"},
+		{"text/plain", false, "<p>\n  bish\n</p>"},
+		// Minifier errors send the original body.
+		{"text/css", false, "<p>\n  bish\n</p>"},
+	}
+	for _, test := range tests {
+		contentType, skip = test.contentType, test.skip
+		rec := serveFile(st, "/")
+		assertEquals(t, test.body, rec.Body.String())
+	}
+}
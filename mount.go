@@ -0,0 +1,104 @@
+package stack
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const mountPrefixKey = "stack.mountPrefix"
+
+type mount struct {
+	prefix string
+	h      http.Handler
+}
+
+// Mount adapts hc to serve every path under prefix when registered on an
+// http.ServeMux:
+//
+//	mux.Handle(stack.Mount("/api/v1", api))
+//
+// The prefix is stripped from the request path before hc runs, so a
+// request for "/api/v1/users" reaches hc as "/users", and is recorded in
+// the Context where it can be retrieved with MountPrefix. The returned
+// pattern is prefix with a trailing slash.
+func Mount(prefix string, hc HandlerChain) (string, http.Handler) {
+	prefix = cleanPrefix(prefix)
+	return prefix + "/", mountHandler(prefix, hc)
+}
+
+// Mount serves every path under prefix with hc, after stripping the prefix
+// from the request path. Routes registered with Handle take priority over
+// mounts, and the longest matching prefix is used when mounts overlap.
+// The Router's base middleware run before the chain's own middleware.
+func (rt *Router) Mount(prefix string, hc HandlerChain) {
+	prefix = cleanPrefix(prefix)
+	for _, m := range rt.mounts {
+		if m.prefix == prefix {
+			panic("stack: prefix already mounted: " + prefix)
+		}
+	}
+	rt.mounts = append(rt.mounts, mount{prefix, mountHandler(prefix, prependMiddleware(hc, rt.baseMiddleware()))})
+	sort.SliceStable(rt.mounts, func(i, j int) bool {
+		return len(rt.mounts[i].prefix) > len(rt.mounts[j].prefix)
+	})
+}
+
+// MountPrefix returns the path prefix stripped from the current request by
+// Mount, including the prefixes of any enclosing mounts. It returns an
+// empty string if the chain isn't mounted.
+func MountPrefix(ctx *Context) string {
+	prefix, _ := ctx.Get(mountPrefixKey).(string)
+	return prefix
+}
+
+func cleanPrefix(prefix string) string {
+	if !strings.HasPrefix(prefix, "/") {
+		panic("stack: mount prefix must begin with '/': " + prefix)
+	}
+	return strings.TrimRight(prefix, "/")
+}
+
+func (rt *Router) matchMount(path string) http.Handler {
+	for _, m := range rt.mounts {
+		if path == m.prefix || strings.HasPrefix(path, m.prefix+"/") {
+			return m.h
+		}
+	}
+	return nil
+}
+
+func mountHandler(prefix string, hc HandlerChain) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, prefix)
+		if rest == "" {
+			rest = "/"
+		}
+		full := enclosingPrefix(r) + prefix
+
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = rest
+		if r.URL.RawPath != "" {
+			r2.URL.RawPath = ""
+			if raw := strings.TrimPrefix(r.URL.RawPath, prefix); raw != r.URL.RawPath {
+				r2.URL.RawPath = raw
+			}
+		}
+		hc.serve(w, r2, func(ctx *Context) {
+			ctx.Put(mountPrefixKey, full)
+		})
+	})
+}
+
+// enclosingPrefix returns the path prefix stripped by any mounts
+// enclosing the chain which is handling r.
+func enclosingPrefix(r *http.Request) string {
+	if ctx := FromRequest(r); ctx != nil {
+		return MountPrefix(ctx)
+	}
+	return ""
+}
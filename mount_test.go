@@ -0,0 +1,71 @@
+package stack
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func mountHandlerFunc(ctx *Context, w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "[prefix=%s][path=%s]", MountPrefix(ctx), r.URL.Path)
+}
+
+func TestMount(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle(Mount("/api/v1/", New(flipMiddleware).Then(mountHandlerFunc)))
+
+	assertEquals(t, "flipMiddleware>[prefix=/api/v1][path=/users/42]", routeTo(mux, "GET", "/api/v1/users/42").Body.String())
+	assertEquals(t, "flipMiddleware>[prefix=/api/v1][path=/]", routeTo(mux, "GET", "/api/v1/").Body.String())
+	assertEquals(t, 404, routeTo(mux, "GET", "/api/v2/users").Code)
+}
+
+func TestRouterMount(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/api/status", New().Then(bishHandler))
+	rt.Mount("/api", New().Then(mountHandlerFunc))
+	rt.Group(bishMiddleware).Mount("/api/admin", New().Then(mountHandlerFunc))
+
+	assertEquals(t, "bishHandler [bish=<nil>]", routeTo(rt, "GET", "/api/status").Body.String())
+	assertEquals(t, "[prefix=/api][path=/users]", routeTo(rt, "GET", "/api/users").Body.String())
+	assertEquals(t, "[prefix=/api][path=/]", routeTo(rt, "GET", "/api").Body.String())
+	assertEquals(t, "bishMiddleware>[prefix=/api/admin][path=/users]", routeTo(rt, "GET", "/api/admin/users").Body.String())
+	assertEquals(t, 404, routeTo(rt, "GET", "/apiary").Code)
+}
+
+func TestNestedMount(t *testing.T) {
+	inner := NewRouter()
+	inner.Mount("/admin", New().Then(mountHandlerFunc))
+	outer := NewRouter()
+	outer.Mount("/api", New().ThenHandler(inner))
+
+	r := httptest.NewRequest("GET", "/api/admin/users", nil)
+	rec := httptest.NewRecorder()
+	outer.ServeHTTP(rec, r)
+	assertEquals(t, "[prefix=/api/admin][path=/users]", rec.Body.String())
+}
+
+func TestMountEscapedPath(t *testing.T) {
+	rt := NewRouter()
+	rt.Mount("/files", New().ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.EscapedPath())
+	}))
+
+	r := httptest.NewRequest("GET", "/files/a%2Fb", nil)
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, r)
+	assertEquals(t, "/a%2Fb", rec.Body.String())
+}
+
+func TestMountPrefixAfterRewrite(t *testing.T) {
+	rt := NewRouter()
+	rt.Mount("/api", New().Then(mountHandlerFunc))
+	st := New(NormalizePath(PathRewrite())).ThenHandler(rt)
+
+	for _, path := range []string{"//api/users", "/a/../api/users"} {
+		r := httptest.NewRequest("GET", path, nil)
+		rec := httptest.NewRecorder()
+		st.ServeHTTP(rec, r)
+		assertEquals(t, "[prefix=/api][path=/users]", rec.Body.String())
+	}
+}
@@ -0,0 +1,240 @@
+package stack
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const negotiationKey = "stack.negotiation"
+
+// Negotiation records the representation chosen by the ContentNegotiation
+// middleware. Fields for which no offers were configured are empty.
+type Negotiation struct {
+	ContentType string
+	Language    string
+	Charset     string
+}
+
+// NegotiationOption configures the ContentNegotiation middleware.
+type NegotiationOption func(*negotiationConfig)
+
+// OfferContentTypes sets the media types the chain can produce, in order of
+// preference (e.g. "application/json", "text/html").
+func OfferContentTypes(types ...string) NegotiationOption {
+	return func(c *negotiationConfig) {
+		c.types = types
+	}
+}
+
+// OfferLanguages sets the language tags the chain can produce, in order of
+// preference (e.g. "en-GB", "fr").
+func OfferLanguages(langs ...string) NegotiationOption {
+	return func(c *negotiationConfig) {
+		c.languages = langs
+	}
+}
+
+// OfferCharsets sets the character sets the chain can produce, in order of
+// preference (e.g. "utf-8").
+func OfferCharsets(charsets ...string) NegotiationOption {
+	return func(c *negotiationConfig) {
+		c.charsets = charsets
+	}
+}
+
+type negotiationConfig struct {
+	types     []string
+	languages []string
+	charsets  []string
+}
+
+// ErrNotAcceptable is passed to the chain's error handler (wrapped in an
+// HTTPError with status 406) when none of the offers satisfy the client.
+var ErrNotAcceptable = errors.New("stack: no acceptable representation")
+
+// ContentNegotiation returns middleware which matches the request's Accept,
+// Accept-Language and Accept-Charset headers against the configured offers
+// and stores the result in the Context, where it can be retrieved with
+// Negotiated. If nothing acceptable can be offered the request is passed
+// to the chain's error handler with a 406 Not Acceptable status.
+func ContentNegotiation(opts ...NegotiationOption) chainMiddleware {
+	cfg := &negotiationConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var n Negotiation
+			var ok bool
+			if len(cfg.types) > 0 {
+				addVary(w.Header(), "Accept")
+				if n.ContentType, ok = negotiateMediaType(r.Header.Get("Accept"), cfg.types); !ok {
+					Error(ctx, w, r, NewHTTPError(http.StatusNotAcceptable, ErrNotAcceptable))
+					return
+				}
+			}
+			if len(cfg.languages) > 0 {
+				addVary(w.Header(), "Accept-Language")
+				if n.Language, ok = negotiateLanguage(r.Header.Get("Accept-Language"), cfg.languages); !ok {
+					Error(ctx, w, r, NewHTTPError(http.StatusNotAcceptable, ErrNotAcceptable))
+					return
+				}
+			}
+			if len(cfg.charsets) > 0 {
+				addVary(w.Header(), "Accept-Charset")
+				if n.Charset, ok = negotiateCharset(r.Header.Get("Accept-Charset"), cfg.charsets); !ok {
+					Error(ctx, w, r, NewHTTPError(http.StatusNotAcceptable, ErrNotAcceptable))
+					return
+				}
+			}
+			ctx.Put(negotiationKey, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Negotiated returns the result of content negotiation for the current
+// request. It returns the zero Negotiation if the ContentNegotiation
+// middleware has not run.
+func Negotiated(ctx *Context) Negotiation {
+	n, _ := ctx.Get(negotiationKey).(Negotiation)
+	return n
+}
+
+// negotiate returns the offer with the highest quality according to match,
+// which reports the quality of an offer against a single header entry and
+// a specificity used to pick between overlapping entries. An empty header
+// accepts the first offer.
+func negotiate(header string, offers []string, match func(accept, offer string) (int, bool)) (string, bool) {
+	if strings.TrimSpace(header) == "" {
+		return offers[0], true
+	}
+	accepted := parseQualityList(header)
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, specificity := 0.0, -1
+		for _, qv := range accepted {
+			if s, ok := match(qv.value, strings.ToLower(offer)); ok && s > specificity {
+				q, specificity = qv.quality, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+func negotiateMediaType(header string, offers []string) (string, bool) {
+	return negotiate(header, offers, func(accept, offer string) (int, bool) {
+		switch {
+		case accept == offer:
+			return 2, true
+		case accept == "*/*":
+			return 0, true
+		case strings.HasSuffix(accept, "/*") && strings.HasPrefix(offer, accept[:len(accept)-1]):
+			return 1, true
+		}
+		return 0, false
+	})
+}
+
+// negotiateLanguage uses basic filtering (RFC 4647) so that a range of "en"
+// matches an offer of "en-GB", and additionally lets a range of "en-GB"
+// fall back to an offer of "en".
+func negotiateLanguage(header string, offers []string) (string, bool) {
+	return negotiate(header, offers, func(accept, offer string) (int, bool) {
+		switch {
+		case accept == offer:
+			return 3, true
+		case strings.HasPrefix(offer, accept+"-"):
+			return 2, true
+		case strings.HasPrefix(accept, offer+"-"):
+			return 1, true
+		case accept == "*":
+			return 0, true
+		}
+		return 0, false
+	})
+}
+
+func negotiateCharset(header string, offers []string) (string, bool) {
+	return negotiate(header, offers, func(accept, offer string) (int, bool) {
+		switch {
+		case accept == offer:
+			return 1, true
+		case accept == "*":
+			return 0, true
+		}
+		return 0, false
+	})
+}
+
+// View registers the template which Negotiate renders, with the chain's
+// Renderer, when the client prefers HTML and the payload has the same
+// type as v:
+//
+//	chain = chain.View(User{}, "users/show.html")
+func (c Chain) View(v interface{}, name string) Chain {
+	views := make(map[reflect.Type]string, len(c.views)+1)
+	for t, n := range c.views {
+		views[t] = n
+	}
+	views[reflect.TypeOf(v)] = name
+	c.views = views
+	return c
+}
+
+// Negotiate writes payload with the given status code as JSON, XML or
+// HTML, according to the content type chosen by the ContentNegotiation
+// middleware. Without the middleware, the request's Accept header is
+// matched against the representations available for the payload. JSON
+// and XML are always available, and HTML if a template has been
+// registered for the payload's type with View. If the chosen content type
+// isn't one of these the request is passed to the chain's error handler
+// with a 406 Not Acceptable status and ErrNotAcceptable.
+func Negotiate(ctx *Context, w http.ResponseWriter, status int, payload interface{}) error {
+	view, hasView := ctx.views[reflect.TypeOf(payload)]
+	contentType := Negotiated(ctx).ContentType
+	if contentType == "" {
+		offers := []string{"application/json", "application/xml", "text/xml"}
+		if hasView {
+			offers = append(offers, "text/html")
+		}
+		accept := ""
+		if ctx.request != nil {
+			accept = ctx.request.Header.Get("Accept")
+		}
+		addVary(w.Header(), "Accept")
+		contentType, _ = negotiateMediaType(accept, offers)
+	}
+
+	switch {
+	case contentType == "application/json" || strings.HasSuffix(contentType, "+json"):
+		return JSON(ctx, w, status, payload)
+	case contentType == "application/xml" || contentType == "text/xml" || strings.HasSuffix(contentType, "+xml"):
+		var buf bytes.Buffer
+		buf.WriteString(xml.Header)
+		if err := xml.NewEncoder(&buf).Encode(payload); err != nil {
+			Error(ctx, w, ctx.request, err)
+			return err
+		}
+		w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		w.WriteHeader(status)
+		_, err := w.Write(buf.Bytes())
+		return err
+	case contentType == "text/html" && hasView:
+		return render(ctx, w, status, view, payload)
+	}
+	err := NewHTTPError(http.StatusNotAcceptable, ErrNotAcceptable)
+	Error(ctx, w, ctx.request, err)
+	return err
+}
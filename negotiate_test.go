@@ -0,0 +1,103 @@
+package stack
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func negotiatedHandler(ctx *Context, w http.ResponseWriter, r *http.Request) {
+	n := Negotiated(ctx)
+	fmt.Fprintf(w, "%s|%s|%s", n.ContentType, n.Language, n.Charset)
+}
+
+func TestContentNegotiation(t *testing.T) {
+	st := New(ContentNegotiation(
+		OfferContentTypes("application/json", "text/html"),
+		OfferLanguages("en-GB", "fr"),
+		OfferCharsets("utf-8"),
+	)).Then(negotiatedHandler)
+
+	tests := []struct {
+		accept, lang, charset string
+		expected              string
+	}{
+		{"", "", "", "application/json|en-GB|utf-8"},
+		{"text/html, application/json;q=0.9", "fr, en;q=0.8", "", "text/html|fr|utf-8"},
+		{"text/*", "en", "*", "text/html|en-GB|utf-8"},
+		{"*/*;q=0.5, application/json;q=0", "en-GB-oxendict", "UTF-8", "text/html|en-GB|utf-8"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", test.accept)
+		r.Header.Set("Accept-Language", test.lang)
+		r.Header.Set("Accept-Charset", test.charset)
+		rec := httptest.NewRecorder()
+		st.ServeHTTP(rec, r)
+		assertEquals(t, test.expected, rec.Body.String())
+	}
+}
+
+func TestContentNegotiationNotAcceptable(t *testing.T) {
+	st := New(ContentNegotiation(OfferContentTypes("application/json"))).Then(negotiatedHandler)
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "text/html")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 406, rec.Code)
+	assertEquals(t, "Accept", rec.Header().Get("Vary"))
+}
+
+func TestNegotiatedWithoutMiddleware(t *testing.T) {
+	assertEquals(t, Negotiation{}, Negotiated(NewContext()))
+}
+
+type bishPayload struct {
+	XMLName struct{} `json:"-" xml:"bish"`
+	Name    string   `json:"name" xml:"name"`
+}
+
+func negotiateWith(hc HandlerChain, accept string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("GET", "/", nil)
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	hc.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestNegotiate(t *testing.T) {
+	h := func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		Negotiate(ctx, w, http.StatusAccepted, bishPayload{Name: "bash"})
+	}
+	st := New().Then(h)
+
+	rec := negotiateWith(st, "")
+	assertEquals(t, 202, rec.Code)
+	assertEquals(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+	assertEquals(t, "{\"name\":\"bash\"}\n", rec.Body.String())
+	assertEquals(t, "Accept", rec.Header().Get("Vary"))
+
+	rec = negotiateWith(st, "text/xml")
+	assertEquals(t, 202, rec.Code)
+	assertEquals(t, "text/xml; charset=utf-8", rec.Header().Get("Content-Type"))
+	assertEquals(t, xml.Header+"<bish><name>bash</name></bish>", rec.Body.String())
+
+	rec = negotiateWith(st, "text/html")
+	assertEquals(t, 406, rec.Code)
+
+	withView := New().UseRenderer(greetRenderer{}).View(bishPayload{}, "greet").Then(h)
+	rec = negotiateWith(withView, "text/html,*/*;q=0.8")
+	assertEquals(t, 202, rec.Code)
+	assertEquals(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assertEquals(t, "<p>Hello {{} bash}, bish=<nil></p>", rec.Body.String())
+
+	// The middleware's decision takes precedence.
+	negotiated := New(ContentNegotiation(OfferContentTypes("application/xml", "application/json"))).Then(h)
+	rec = negotiateWith(negotiated, "")
+	assertEquals(t, "application/xml; charset=utf-8", rec.Header().Get("Content-Type"))
+}
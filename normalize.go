@@ -0,0 +1,99 @@
+package stack
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// PathOption configures the NormalizePath middleware.
+type PathOption func(*pathConfig)
+
+// PathAddTrailingSlash makes every canonical path end in a slash.
+func PathAddTrailingSlash() PathOption {
+	return func(c *pathConfig) {
+		c.trailing = 1
+	}
+}
+
+// PathStripTrailingSlash removes the trailing slash from every canonical
+// path other than "/".
+func PathStripTrailingSlash() PathOption {
+	return func(c *pathConfig) {
+		c.trailing = -1
+	}
+}
+
+// PathRewrite makes the middleware rewrite the request path in place
+// instead of redirecting the client to the canonical URL.
+func PathRewrite() PathOption {
+	return func(c *pathConfig) {
+		c.rewrite = true
+	}
+}
+
+type pathConfig struct {
+	trailing int
+	rewrite  bool
+}
+
+// NormalizePath returns middleware which canonicalizes the request path by
+// collapsing duplicate slashes, resolving "." and ".." segments and
+// (optionally) adding or stripping a trailing slash. Requests for a
+// non-canonical path are redirected to the canonical one with 301 Moved
+// Permanently (GET and HEAD) or 308 Permanent Redirect (other methods, so
+// that the method and body are preserved). It should be the first
+// middleware in the chain.
+func NormalizePath(opts ...PathOption) chainMiddleware {
+	cfg := &pathConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := cfg.canonical(r.URL.Path)
+			if p == r.URL.Path {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if cfg.rewrite {
+				r.URL.Path = p
+				r.URL.RawPath = ""
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			u := *r.URL
+			u.Path = p
+			u.RawPath = ""
+			code := http.StatusPermanentRedirect
+			if r.Method == "GET" || r.Method == "HEAD" {
+				code = http.StatusMovedPermanently
+			}
+			w.Header().Set("Location", u.RequestURI())
+			w.WriteHeader(code)
+		})
+	}
+}
+
+func (cfg *pathConfig) canonical(p string) string {
+	if p == "" {
+		return "/"
+	}
+	trailing := strings.HasSuffix(p, "/")
+	p = path.Clean("/" + p)
+	if p == "/" {
+		return p
+	}
+	switch {
+	case cfg.trailing > 0:
+		trailing = true
+	case cfg.trailing < 0:
+		trailing = false
+	}
+	if trailing {
+		p += "/"
+	}
+	return p
+}
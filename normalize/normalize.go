@@ -0,0 +1,182 @@
+// Package normalize provides middleware which normalizes text input
+// before it is validated, trimming space, applying Unicode normalization
+// and mapping case by the rules of the request's locale:
+//
+//	chain := stack.New(
+//		stack.DetectLocale([]string{"en", "tr"}),
+//		stack.BindJSON[signup](),
+//		normalize.Input[signup](),
+//		stack.Validate[signup](),
+//	)
+//
+// It is a separate package so that programs which don't use it don't
+// depend on golang.org/x/text.
+package normalize
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/alexedwards/stack"
+	"github.com/alexedwards/stack/locale"
+)
+
+// normalizeOp normalizes a single string, in the given locale.
+type normalizeOp func(s string, lang language.Tag) string
+
+var normalizeOps = map[string]normalizeOp{
+	"trim": func(s string, _ language.Tag) string {
+		return strings.TrimSpace(s)
+	},
+	"collapse": func(s string, _ language.Tag) string {
+		return strings.Join(strings.Fields(s), " ")
+	},
+	"nfc": func(s string, _ language.Tag) string {
+		return norm.NFC.String(s)
+	},
+	"lower": func(s string, lang language.Tag) string {
+		return cases.Lower(lang).String(s)
+	},
+	"upper": func(s string, lang language.Tag) string {
+		return cases.Upper(lang).String(s)
+	},
+	"fold": func(s string, _ language.Tag) string {
+		return cases.Fold().String(s)
+	},
+}
+
+// parseNormalizeRules parses a comma-separated list of rules, panicking
+// if any are unknown.
+func parseNormalizeRules(rules string) []normalizeOp {
+	var ops []normalizeOp
+	for _, rule := range strings.Split(rules, ",") {
+		op, ok := normalizeOps[strings.TrimSpace(rule)]
+		if !ok {
+			panic(fmt.Sprintf("normalize: unknown rule %q", rule))
+		}
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+func applyNormalizeOps(s string, ops []normalizeOp, lang language.Tag) string {
+	for _, op := range ops {
+		s = op(s, lang)
+	}
+	return s
+}
+
+type normalizeField struct {
+	index []int
+	ops   []normalizeOp
+}
+
+// Input returns middleware which normalizes the string fields of the T
+// bound earlier in the chain by stack.BindJSON or stack.BindQuery,
+// according to their normalize tags. It should come before stack.Validate,
+// so that values are checked in the form in which they'll be stored:
+//
+//	type signup struct {
+//		Email string   `json:"email" normalize:"trim,nfc,fold"`
+//		Name  string   `json:"name" normalize:"trim,collapse,nfc"`
+//		Tags  []string `json:"tags" normalize:"trim,lower"`
+//	}
+//
+// The rules, applied in the order given, are trim (remove leading and
+// trailing space), collapse (replace runs of space with a single space),
+// nfc (Unicode normalization form C, so that the same text always has the
+// same bytes), lower and upper (case mapping using the rules for the
+// request's stack.Locale, such as Turkish dotted and dotless i) and fold
+// (case folding, for case-insensitive matching). Tags may be applied to
+// string fields, pointers to strings and slices of strings. Input panics
+// if T isn't a struct or a tag has an unknown rule.
+func Input[T any]() func(*stack.Context, http.Handler) http.Handler {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("normalize: Input needs a struct type, not %s", t))
+	}
+	fields := normalizeFields(t, nil)
+
+	return func(ctx *stack.Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lang := locale.Tag(ctx)
+			stack.UpdateInput(ctx, func(v *T) {
+				rv := reflect.ValueOf(v).Elem()
+				for _, f := range fields {
+					normalizeValue(rv.FieldByIndex(f.index), f.ops, lang)
+				}
+			})
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func normalizeFields(t reflect.Type, index []int) []normalizeField {
+	var fields []normalizeField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		idx := append(append([]int(nil), index...), i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			fields = append(fields, normalizeFields(f.Type, idx)...)
+			continue
+		}
+		rules, ok := f.Tag.Lookup("normalize")
+		if !ok || !f.IsExported() {
+			continue
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr || ft.Kind() == reflect.Slice {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.String {
+			panic(fmt.Sprintf("normalize: tag on %s field %s", f.Type, f.Name))
+		}
+		fields = append(fields, normalizeField{idx, parseNormalizeRules(rules)})
+	}
+	return fields
+}
+
+func normalizeValue(v reflect.Value, ops []normalizeOp, lang language.Tag) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(applyNormalizeOps(v.String(), ops, lang))
+	case reflect.Ptr:
+		if !v.IsNil() {
+			normalizeValue(v.Elem(), ops, lang)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			normalizeValue(v.Index(i), ops, lang)
+		}
+	}
+}
+
+// Form is like Input, for forms parsed by stack.ParseForm. It takes a map
+// from field names to rules:
+//
+//	normalize.Form(map[string]string{"email": "trim,nfc,fold"})
+func Form(fields map[string]string) func(*stack.Context, http.Handler) http.Handler {
+	ops := make(map[string][]normalizeOp, len(fields))
+	for name, rules := range fields {
+		ops[name] = parseNormalizeRules(rules)
+	}
+
+	return func(ctx *stack.Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			form := stack.Form(ctx)
+			lang := locale.Tag(ctx)
+			for name, fieldOps := range ops {
+				for i, val := range form[name] {
+					form[name][i] = applyNormalizeOps(val, fieldOps, lang)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
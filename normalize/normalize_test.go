@@ -0,0 +1,90 @@
+package normalize
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/stack"
+)
+
+func assertEquals(t *testing.T, e interface{}, o interface{}) {
+	if e != o {
+		t.Errorf("\n...expected = %v\n...obtained = %v", e, o)
+	}
+}
+
+func postJSON(hc stack.HandlerChain, body string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	hc.ServeHTTP(rec, r)
+	return rec
+}
+
+type normalizeInput struct {
+	Email string   `json:"email" normalize:"trim,nfc,fold" validate:"required"`
+	Name  *string  `json:"name" normalize:"trim,collapse"`
+	Tags  []string `json:"tags" normalize:"upper"`
+	Raw   string   `json:"raw"`
+}
+
+func TestInput(t *testing.T) {
+	var got normalizeInput
+	chain := stack.New(stack.BindJSON[normalizeInput](), Input[normalizeInput](), stack.Validate[normalizeInput]())
+	st := chain.Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		got = stack.Body[normalizeInput](ctx)
+	})
+
+	// The email is sent with an e followed by a combining acute accent.
+	rec := postJSON(st, `{"email": " Rene\u0301@Example.COM ", "name": "  bish   bash ", "tags": ["istanbul"], "raw": " x "}`)
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "ren\u00e9@example.com", got.Email)
+	assertEquals(t, "bish bash", *got.Name)
+	assertEquals(t, "ISTANBUL", got.Tags[0])
+	assertEquals(t, " x ", got.Raw)
+
+	// Trimming happens before validation.
+	rec = postJSON(st, `{"email": "   "}`)
+	assertEquals(t, 422, rec.Code)
+
+	// Case mapping follows the request's locale.
+	st = stack.New(stack.DetectLocale([]string{"en", "tr"}), stack.BindJSON[normalizeInput](), Input[normalizeInput]()).Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		got = stack.Body[normalizeInput](ctx)
+	})
+	r, _ := http.NewRequest("POST", "/", strings.NewReader(`{"tags": ["istanbul"]}`))
+	r.Header.Set("Accept-Language", "tr")
+	st.ServeHTTP(httptest.NewRecorder(), r)
+	assertEquals(t, "İSTANBUL", got.Tags[0])
+}
+
+func TestInputBadTags(t *testing.T) {
+	type badRule struct {
+		Name string `normalize:"trim,shout"`
+	}
+	type badType struct {
+		Count int `normalize:"trim"`
+	}
+	for _, fn := range []func(){
+		func() { Input[badRule]() },
+		func() { Input[badType]() },
+	} {
+		func() {
+			defer func() {
+				assertEquals(t, true, recover() != nil)
+			}()
+			fn()
+		}()
+	}
+}
+
+func TestForm(t *testing.T) {
+	var email string
+	st := stack.New(stack.ParseForm(), Form(map[string]string{"email": "trim,lower"})).Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		email = stack.Form(ctx).Get("email")
+	})
+	r, _ := http.NewRequest("POST", "/", strings.NewReader("email=+Bish%40Example.com+"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	st.ServeHTTP(httptest.NewRecorder(), r)
+	assertEquals(t, "bish@example.com", email)
+}
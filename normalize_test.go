@@ -0,0 +1,47 @@
+package stack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func pathHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(r.URL.Path))
+}
+
+func TestNormalizePath(t *testing.T) {
+	tests := []struct {
+		opts     []PathOption
+		method   string
+		path     string
+		code     int
+		location string
+	}{
+		{nil, "GET", "/bish/bash", 200, ""},
+		{nil, "GET", "//bish///bash/", 301, "/bish/bash/"},
+		{nil, "GET", "/bish/./flip/../bash?bosh=1", 301, "/bish/bash?bosh=1"},
+		{nil, "POST", "/bish//bash", 308, "/bish/bash"},
+		{[]PathOption{PathAddTrailingSlash()}, "GET", "/bish", 301, "/bish/"},
+		{[]PathOption{PathStripTrailingSlash()}, "GET", "/bish/", 301, "/bish"},
+		{[]PathOption{PathStripTrailingSlash()}, "GET", "/", 200, ""},
+	}
+	for _, test := range tests {
+		st := New(NormalizePath(test.opts...)).ThenHandlerFunc(pathHandler)
+		r, _ := http.NewRequest(test.method, "http://example.com"+test.path, nil)
+		rec := httptest.NewRecorder()
+		st.ServeHTTP(rec, r)
+		assertEquals(t, test.code, rec.Code)
+		assertEquals(t, test.location, rec.Header().Get("Location"))
+	}
+}
+
+func TestNormalizePathRewrite(t *testing.T) {
+	st := New(NormalizePath(PathRewrite(), PathStripTrailingSlash())).ThenHandlerFunc(pathHandler)
+
+	r, _ := http.NewRequest("GET", "http://example.com//bish/../bash/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "/bash", rec.Body.String())
+}
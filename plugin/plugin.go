@@ -0,0 +1,162 @@
+// Package stackplugin builds stack middleware by name from a Registry of
+// factories, which can be extended at run time with factories loaded from
+// Go plugins. This lets operators add custom middleware to a packaged
+// binary by configuration, without rebuilding it.
+//
+// A plugin is a main package built with -buildmode=plugin which exports
+// two symbols: a StackPluginVersion variable, which must equal APIVersion,
+// and a StackMiddleware function returning the factories it provides:
+//
+//	package main
+//
+//	var StackPluginVersion = stackplugin.APIVersion
+//
+//	func StackMiddleware() map[string]stackplugin.Factory {
+//		return map[string]stackplugin.Factory{"audit": newAudit}
+//	}
+//
+// As with any Go plugin, it must be built with the same version of Go and
+// of every package it shares with the binary (including stack) as the
+// binary loading it, and plugins are only supported on some platforms.
+package stackplugin
+
+import (
+	"fmt"
+	"net/http"
+	goplugin "plugin"
+	"sort"
+	"sync"
+
+	"github.com/alexedwards/stack"
+)
+
+// APIVersion is the version of the plugin interface. It changes whenever
+// Factory or the symbol convention changes incompatibly.
+const APIVersion = 1
+
+// Factory creates a middleware from its configuration arguments.
+type Factory func(args map[string]string) (func(*stack.Context, http.Handler) http.Handler, error)
+
+// Spec names a middleware and gives its arguments, typically from a
+// configuration file.
+type Spec struct {
+	Name string            `json:"name"`
+	Args map[string]string `json:"args"`
+}
+
+// Registry holds middleware factories by name. It is safe for concurrent
+// use.
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[string]Factory
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]Factory)}
+}
+
+// Register adds a factory under name. It returns an error if the name is
+// already registered.
+func (reg *Registry) Register(name string, f Factory) error {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if _, exists := reg.factories[name]; exists {
+		return fmt.Errorf("stackplugin: middleware %q already registered", name)
+	}
+	reg.factories[name] = f
+	return nil
+}
+
+// Names returns the registered names, in sorted order.
+func (reg *Registry) Names() []string {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	names := make([]string, 0, len(reg.factories))
+	for name := range reg.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Build creates the named middleware with args. The middleware is named
+// with stack.Named, so it can be traced and toggled.
+func (reg *Registry) Build(name string, args map[string]string) (func(*stack.Context, http.Handler) http.Handler, error) {
+	reg.mu.RLock()
+	f, ok := reg.factories[name]
+	reg.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("stackplugin: no middleware registered as %q", name)
+	}
+	mw, err := f(args)
+	if err != nil {
+		return nil, fmt.Errorf("stackplugin: building %q: %w", name, err)
+	}
+	return stack.Named(name, mw), nil
+}
+
+// Chain builds a stack.Chain from specs, in order, stopping at the first
+// error.
+func (reg *Registry) Chain(specs ...Spec) (stack.Chain, error) {
+	c := stack.New()
+	for _, spec := range specs {
+		mw, err := reg.Build(spec.Name, spec.Args)
+		if err != nil {
+			return stack.Chain{}, err
+		}
+		c = c.Append(mw)
+	}
+	return c, nil
+}
+
+// symbolTable is the part of *plugin.Plugin used by Load.
+type symbolTable interface {
+	Lookup(name string) (goplugin.Symbol, error)
+}
+
+var open = func(path string) (symbolTable, error) {
+	return goplugin.Open(path)
+}
+
+// Load opens the Go plugin at path, checks that it was built for this
+// version of the plugin interface, and registers the factories it
+// provides. Nothing is registered if any of its names is already in use.
+func (reg *Registry) Load(path string) error {
+	p, err := open(path)
+	if err != nil {
+		return fmt.Errorf("stackplugin: %w", err)
+	}
+	sym, err := p.Lookup("StackPluginVersion")
+	if err != nil {
+		return fmt.Errorf("stackplugin: %s: %w", path, err)
+	}
+	version, ok := sym.(*int)
+	if !ok {
+		return fmt.Errorf("stackplugin: %s: StackPluginVersion is %T, not int", path, sym)
+	}
+	if *version != APIVersion {
+		return fmt.Errorf("stackplugin: %s: built for plugin API version %d, not %d", path, *version, APIVersion)
+	}
+	sym, err = p.Lookup("StackMiddleware")
+	if err != nil {
+		return fmt.Errorf("stackplugin: %s: %w", path, err)
+	}
+	fn, ok := sym.(func() map[string]Factory)
+	if !ok {
+		return fmt.Errorf("stackplugin: %s: StackMiddleware is %T, not func() map[string]Factory", path, sym)
+	}
+
+	factories := fn()
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	for name := range factories {
+		if _, exists := reg.factories[name]; exists {
+			return fmt.Errorf("stackplugin: %s: middleware %q already registered", path, name)
+		}
+	}
+	for name, f := range factories {
+		reg.factories[name] = f
+	}
+	return nil
+}
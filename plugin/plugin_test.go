@@ -0,0 +1,101 @@
+package stackplugin
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	goplugin "plugin"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/stack"
+)
+
+func assertEquals(t *testing.T, e interface{}, o interface{}) {
+	if e != o {
+		t.Errorf("\n...expected = %v\n...obtained = %v", e, o)
+	}
+}
+
+func greeter(args map[string]string) (func(*stack.Context, http.Handler) http.Handler, error) {
+	greeting, ok := args["greeting"]
+	if !ok {
+		return nil, errors.New("missing greeting")
+	}
+	return func(ctx *stack.Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "%s>", greeting)
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+func serve(c stack.Chain) string {
+	rec := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	c.ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "handler")
+	}).ServeHTTP(rec, r)
+	body, _ := ioutil.ReadAll(rec.Body)
+	return string(body)
+}
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry()
+	assertEquals(t, nil, reg.Register("greet", greeter))
+	assertEquals(t, `stackplugin: middleware "greet" already registered`, reg.Register("greet", greeter).Error())
+
+	c, err := reg.Chain(Spec{"greet", map[string]string{"greeting": "bish"}}, Spec{"greet", map[string]string{"greeting": "bash"}})
+	assertEquals(t, nil, err)
+	assertEquals(t, "bish>bash>handler", serve(c))
+
+	_, err = reg.Chain(Spec{Name: "greet"})
+	assertEquals(t, `stackplugin: building "greet": missing greeting`, err.Error())
+	_, err = reg.Chain(Spec{Name: "flip"})
+	assertEquals(t, `stackplugin: no middleware registered as "flip"`, err.Error())
+}
+
+type fakePlugin map[string]goplugin.Symbol
+
+func (fp fakePlugin) Lookup(name string) (goplugin.Symbol, error) {
+	sym, ok := fp[name]
+	if !ok {
+		return nil, fmt.Errorf("symbol %s not found", name)
+	}
+	return sym, nil
+}
+
+func TestLoad(t *testing.T) {
+	version := APIVersion
+	oldVersion := APIVersion - 1
+	middleware := func() map[string]Factory {
+		return map[string]Factory{"greet": greeter}
+	}
+	plugins := map[string]fakePlugin{
+		"good.so":    {"StackPluginVersion": &version, "StackMiddleware": middleware},
+		"old.so":     {"StackPluginVersion": &oldVersion, "StackMiddleware": middleware},
+		"missing.so": {"StackPluginVersion": &version},
+		"wrong.so":   {"StackPluginVersion": &version, "StackMiddleware": func() {}},
+	}
+	defer func(orig func(string) (symbolTable, error)) { open = orig }(open)
+	open = func(path string) (symbolTable, error) {
+		p, ok := plugins[path]
+		if !ok {
+			return nil, errors.New("no such file")
+		}
+		return p, nil
+	}
+
+	reg := NewRegistry()
+	assertEquals(t, "stackplugin: no such file", reg.Load("none.so").Error())
+	assertEquals(t, "stackplugin: old.so: built for plugin API version 0, not 1", reg.Load("old.so").Error())
+	assertEquals(t, "stackplugin: missing.so: symbol StackMiddleware not found", reg.Load("missing.so").Error())
+	assertEquals(t, true, strings.Contains(reg.Load("wrong.so").Error(), "StackMiddleware is func()"))
+	assertEquals(t, 0, len(reg.Names()))
+
+	assertEquals(t, nil, reg.Load("good.so"))
+	assertEquals(t, "greet", strings.Join(reg.Names(), ","))
+	assertEquals(t, `stackplugin: good.so: middleware "greet" already registered`, reg.Load("good.so").Error())
+}
@@ -0,0 +1,144 @@
+package stack
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+const preconditionsKey = "stack.preconditions"
+
+// ErrPreconditionRequired is passed to the chain's error handler (wrapped
+// in an HTTPError with status 428) by ConditionalRequests with
+// RequirePreconditions, when an unsafe request has no precondition.
+var ErrPreconditionRequired = errors.New("stack: request has no precondition")
+
+// Preconditions holds the conditional headers of a request. Dates which
+// are missing or can't be parsed are the zero Time, and are ignored, as
+// RFC 7232 requires.
+type Preconditions struct {
+	IfMatch           string
+	IfNoneMatch       string
+	IfModifiedSince   time.Time
+	IfUnmodifiedSince time.Time
+}
+
+// Conditional reports whether the request has any preconditions.
+func (p Preconditions) Conditional() bool {
+	return p.IfMatch != "" || p.IfNoneMatch != "" || !p.IfModifiedSince.IsZero() || !p.IfUnmodifiedSince.IsZero()
+}
+
+// ConditionalOption configures the ConditionalRequests middleware.
+type ConditionalOption func(*conditionalConfig)
+
+// RequirePreconditions makes the middleware reject PUT, PATCH and DELETE
+// requests without an If-Match or If-Unmodified-Since header, with a 428
+// Precondition Required status, so that clients can't overwrite changes
+// they haven't seen.
+func RequirePreconditions() ConditionalOption {
+	return func(c *conditionalConfig) {
+		c.require = true
+	}
+}
+
+type conditionalConfig struct {
+	require bool
+}
+
+// ConditionalRequests returns middleware which parses the request's
+// If-Match, If-None-Match, If-Modified-Since and If-Unmodified-Since
+// headers into the Context, where handlers check them against the current
+// state of the resource with CheckPreconditions.
+func ConditionalRequests(opts ...ConditionalOption) chainMiddleware {
+	cfg := &conditionalConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := parsePreconditions(r)
+			unsafe := r.Method == "PUT" || r.Method == "PATCH" || r.Method == "DELETE"
+			if cfg.require && unsafe && p.IfMatch == "" && p.IfUnmodifiedSince.IsZero() {
+				Error(ctx, w, r, NewHTTPError(http.StatusPreconditionRequired, ErrPreconditionRequired))
+				return
+			}
+			ctx.Put(preconditionsKey, p)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func parsePreconditions(r *http.Request) Preconditions {
+	p := Preconditions{
+		IfMatch:     r.Header.Get("If-Match"),
+		IfNoneMatch: r.Header.Get("If-None-Match"),
+	}
+	p.IfModifiedSince, _ = http.ParseTime(r.Header.Get("If-Modified-Since"))
+	p.IfUnmodifiedSince, _ = http.ParseTime(r.Header.Get("If-Unmodified-Since"))
+	return p
+}
+
+// RequestPreconditions returns the preconditions parsed by
+// ConditionalRequests, or parses them from the request if the middleware
+// hasn't run.
+func RequestPreconditions(ctx *Context) Preconditions {
+	if p, ok := ctx.Get(preconditionsKey).(Preconditions); ok {
+		return p
+	}
+	if ctx.request == nil {
+		return Preconditions{}
+	}
+	return parsePreconditions(ctx.request)
+}
+
+// CheckPreconditions evaluates the request's preconditions, in the order
+// given by RFC 7232, against the resource's current ETag and modification
+// time. Pass an empty etag if the resource doesn't exist (so that "*"
+// doesn't match it), and a zero modTime if it isn't known. It returns zero
+// if the request should go ahead, 304 Not Modified if a GET or HEAD request
+// can be answered from the client's cache, or 412 Precondition Failed
+// otherwise:
+//
+//	switch stack.CheckPreconditions(ctx, doc.ETag(), doc.Updated) {
+//	case http.StatusPreconditionFailed:
+//		stack.Error(ctx, w, r, stack.NewHTTPError(http.StatusPreconditionFailed, nil))
+//		return
+//	case http.StatusNotModified:
+//		w.WriteHeader(http.StatusNotModified)
+//		return
+//	}
+func CheckPreconditions(ctx *Context, etag string, modTime time.Time) int {
+	p := RequestPreconditions(ctx)
+	safe := true
+	if ctx.request != nil {
+		safe = ctx.request.Method == "GET" || ctx.request.Method == "HEAD"
+	}
+	modTime = modTime.Truncate(time.Second)
+
+	switch {
+	case p.IfMatch != "":
+		if !etagListMatch(p.IfMatch, etag, true) {
+			return http.StatusPreconditionFailed
+		}
+	case !p.IfUnmodifiedSince.IsZero() && !modTime.IsZero():
+		if modTime.After(p.IfUnmodifiedSince) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	switch {
+	case p.IfNoneMatch != "":
+		if etagListMatch(p.IfNoneMatch, etag, false) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	case safe && !p.IfModifiedSince.IsZero() && !modTime.IsZero():
+		if !modTime.After(p.IfModifiedSince) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
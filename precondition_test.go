@@ -0,0 +1,87 @@
+package stack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckPreconditions(t *testing.T) {
+	modTime := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	before := modTime.Add(-time.Hour).Format(http.TimeFormat)
+	after := modTime.Add(time.Hour).Format(http.TimeFormat)
+
+	tests := []struct {
+		method string
+		header string
+		value  string
+		etag   string
+		status int
+	}{
+		{"PUT", "", "", `"v1"`, 0},
+		{"PUT", "If-Match", `"v1"`, `"v1"`, 0},
+		{"PUT", "If-Match", `"v0", "v1"`, `"v1"`, 0},
+		{"PUT", "If-Match", `"v0"`, `"v1"`, 412},
+		{"PUT", "If-Match", `W/"v1"`, `"v1"`, 412},
+		{"PUT", "If-Match", `*`, `"v1"`, 0},
+		{"PUT", "If-Match", `*`, ``, 412},
+		{"PUT", "If-Match", `*`, `W/"v1"`, 412},
+		{"PUT", "If-Unmodified-Since", after, `"v1"`, 0},
+		{"PUT", "If-Unmodified-Since", before, `"v1"`, 412},
+		{"PUT", "If-Unmodified-Since", "bish", `"v1"`, 0},
+		{"PUT", "If-None-Match", `*`, `"v1"`, 412},
+		{"PUT", "If-None-Match", `*`, ``, 0},
+		{"GET", "If-None-Match", `W/"v1"`, `"v1"`, 304},
+		{"GET", "If-None-Match", `"v0"`, `"v1"`, 0},
+		{"GET", "If-Modified-Since", after, `"v1"`, 304},
+		{"GET", "If-Modified-Since", before, `"v1"`, 0},
+		{"PUT", "If-Modified-Since", after, `"v1"`, 0},
+	}
+	for _, test := range tests {
+		var status int
+		st := New(ConditionalRequests()).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+			status = CheckPreconditions(ctx, test.etag, modTime)
+		})
+		r, _ := http.NewRequest(test.method, "/", nil)
+		if test.header != "" {
+			r.Header.Set(test.header, test.value)
+		}
+		st.ServeHTTP(httptest.NewRecorder(), r)
+		if status != test.status {
+			t.Errorf("%s with %s: %s: got %d, want %d", test.method, test.header, test.value, status, test.status)
+		}
+	}
+}
+
+func TestRequirePreconditions(t *testing.T) {
+	st := New(ConditionalRequests(RequirePreconditions())).Then(bishHandler)
+
+	r, _ := http.NewRequest("DELETE", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 428, rec.Code)
+
+	r.Header.Set("If-Match", `"v1"`)
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 200, rec.Code)
+
+	r, _ = http.NewRequest("GET", "/", nil)
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 200, rec.Code)
+}
+
+func TestRequestPreconditionsWithoutMiddleware(t *testing.T) {
+	var p Preconditions
+	st := New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		p = RequestPreconditions(ctx)
+	})
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("If-None-Match", `"v1"`)
+	st.ServeHTTP(httptest.NewRecorder(), r)
+	assertEquals(t, `"v1"`, p.IfNoneMatch)
+	assertEquals(t, true, p.Conditional())
+	assertEquals(t, false, Preconditions{}.Conditional())
+}
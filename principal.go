@@ -0,0 +1,17 @@
+package stack
+
+const principalKey = "stack.principal"
+
+// SetPrincipal records the authenticated principal (a user, client or
+// token subject) for the current request. Authentication middleware call
+// it so that authorization middleware and handlers can find the principal
+// in one place, whichever scheme was used.
+func SetPrincipal(ctx *Context, principal interface{}) {
+	ctx.Put(principalKey, principal)
+}
+
+// Principal returns the authenticated principal for the current request,
+// or nil if the request has not been authenticated.
+func Principal(ctx *Context) interface{} {
+	return ctx.Get(principalKey)
+}
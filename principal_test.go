@@ -0,0 +1,11 @@
+package stack
+
+import "testing"
+
+func TestPrincipal(t *testing.T) {
+	ctx := NewContext()
+	assertEquals(t, nil, Principal(ctx))
+
+	SetPrincipal(ctx, "bish")
+	assertEquals(t, "bish", Principal(ctx))
+}
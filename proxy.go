@@ -0,0 +1,111 @@
+package stack
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+)
+
+const (
+	proxyTargetKey     = "stack.proxyTarget"
+	upstreamLatencyKey = "stack.upstreamLatency"
+)
+
+// ProxyOption configures the handler created by ThenProxy.
+type ProxyOption func(*httputil.ReverseProxy)
+
+// ProxyTransport sets the RoundTripper used to reach the upstream. The
+// default is http.DefaultTransport.
+func ProxyTransport(rt http.RoundTripper) ProxyOption {
+	return func(p *httputil.ReverseProxy) {
+		p.Transport = rt
+	}
+}
+
+// ProxyFlushInterval sets how often the response body is flushed to the
+// client while it is copied from the upstream. A negative value flushes
+// after every write. Streaming responses are always flushed immediately.
+func ProxyFlushInterval(d time.Duration) ProxyOption {
+	return func(p *httputil.ReverseProxy) {
+		p.FlushInterval = d
+	}
+}
+
+// ProxyModifyResponse sets a function which may change the upstream's
+// response before it is copied to the client. If fn returns an error the
+// client gets a 502 Bad Gateway.
+func ProxyModifyResponse(fn func(*http.Response) error) ProxyOption {
+	return func(p *httputil.ReverseProxy) {
+		p.ModifyResponse = fn
+	}
+}
+
+// ThenProxy finishes the chain with a reverse proxy, which sends each
+// request to the upstream URL returned by target. As target is passed the
+// Context, the upstream can depend on values set by earlier middleware,
+// such as the tenant or a canary bucket:
+//
+//	chain.ThenProxy(func(ctx *stack.Context, r *http.Request) (*url.URL, error) {
+//		return backends[ctx.Get("tenant").(string)], nil
+//	})
+//
+// The request path is appended to the target's path, and X-Forwarded-For,
+// X-Forwarded-Host and X-Forwarded-Proto headers are set. If target
+// returns an error it is passed to the chain's error handler, as are
+// failures to reach the upstream, with a 502 Bad Gateway status. The time
+// the upstream took to send its response headers is available through
+// UpstreamLatency, for logging and metrics middleware.
+func (c Chain) ThenProxy(target func(ctx *Context, r *http.Request) (*url.URL, error), opts ...ProxyOption) HandlerChain {
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			u, _ := FromRequest(pr.In).Get(proxyTargetKey).(*url.URL)
+			pr.SetURL(u)
+			pr.SetXForwarded()
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			Error(FromRequest(r), w, r, NewHTTPError(http.StatusBadGateway, err))
+		},
+	}
+	for _, opt := range opts {
+		opt(proxy)
+	}
+	transport := proxy.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	proxy.Transport = timedTransport{transport}
+
+	c.h = func(ctx *Context) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u, err := target(ctx, r)
+			if err != nil {
+				Error(ctx, w, r, err)
+				return
+			}
+			ctx.Put(proxyTargetKey, u)
+			proxy.ServeHTTP(w, withContext(r, ctx))
+		})
+	}
+	return newHandlerChain(c)
+}
+
+// timedTransport records how long the upstream takes to respond.
+type timedTransport struct {
+	rt http.RoundTripper
+}
+
+func (tt timedTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx := FromRequest(r)
+	start := Now(ctx)
+	res, err := tt.rt.RoundTrip(r)
+	ctx.Put(upstreamLatencyKey, Now(ctx).Sub(start))
+	return res, err
+}
+
+// UpstreamLatency returns the time the upstream took to send its response
+// headers to ThenProxy, or zero if the request wasn't proxied.
+func UpstreamLatency(ctx *Context) time.Duration {
+	d, _ := ctx.Get(upstreamLatencyKey).(time.Duration)
+	return d
+}
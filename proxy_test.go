@@ -0,0 +1,75 @@
+package stack
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestThenProxy(t *testing.T) {
+	upstream := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Upstream", name)
+			io.WriteString(w, r.URL.Path+" "+r.Header.Get("X-Forwarded-Host"))
+		}))
+	}
+	stable, canary := upstream("stable"), upstream("canary")
+	defer stable.Close()
+	defer canary.Close()
+
+	var latency bool
+	st := New(func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx.Put("canary", r.Header.Get("X-Canary") != "")
+			next.ServeHTTP(w, r)
+			latency = UpstreamLatency(ctx) > 0
+		})
+	}).ThenProxy(func(ctx *Context, r *http.Request) (*url.URL, error) {
+		if r.URL.Path == "/forbidden" {
+			return nil, NewHTTPError(http.StatusForbidden, errors.New("no upstream"))
+		}
+		if ctx.Get("canary").(bool) {
+			return url.Parse(canary.URL + "/api")
+		}
+		return url.Parse(stable.URL + "/api")
+	})
+
+	r := httptest.NewRequest("GET", "http://example.com/users", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "stable", rec.Header().Get("X-Upstream"))
+	assertEquals(t, "/api/users example.com", rec.Body.String())
+	assertEquals(t, true, latency)
+
+	r = httptest.NewRequest("GET", "/users", nil)
+	r.Header.Set("X-Canary", "1")
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, "canary", rec.Header().Get("X-Upstream"))
+
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, httptest.NewRequest("GET", "/forbidden", nil))
+	assertEquals(t, 403, rec.Code)
+}
+
+func TestThenProxyUnreachable(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	u, _ := url.Parse(ts.URL)
+	ts.Close()
+
+	var err error
+	st := New().OnError(func(ctx *Context, w http.ResponseWriter, r *http.Request, e error) {
+		err = e
+		w.WriteHeader(StatusCode(e))
+	}).ThenProxy(func(ctx *Context, r *http.Request) (*url.URL, error) {
+		return u, nil
+	})
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	assertEquals(t, 502, rec.Code)
+	assertEquals(t, 502, StatusCode(err))
+}
@@ -0,0 +1,74 @@
+package stack
+
+import (
+	"net/http"
+	"sync"
+)
+
+const pushedKey = "stack.pushed"
+
+// Push initiates HTTP/2 server pushes for the given resource paths. It
+// looks through any ResponseWriter wrappers in the chain (anything with an
+// Unwrap() http.ResponseWriter method) for an http.Pusher, and returns
+// http.ErrNotSupported if there isn't one, such as on HTTP/1.x connections.
+// Resources already pushed during the current request are skipped.
+func Push(ctx *Context, w http.ResponseWriter, resources ...string) error {
+	var pusher http.Pusher
+	for w != nil {
+		if p, ok := w.(http.Pusher); ok {
+			pusher = p
+			break
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = u.Unwrap()
+	}
+	if pusher == nil {
+		return http.ErrNotSupported
+	}
+
+	v, _ := ctx.GetOrCompute(pushedKey, func() (interface{}, error) {
+		return &pushedSet{m: make(map[string]bool)}, nil
+	})
+	pushed := v.(*pushedSet)
+	pushed.mu.Lock()
+	defer pushed.mu.Unlock()
+	for _, resource := range resources {
+		if pushed.m[resource] {
+			continue
+		}
+		if err := pusher.Push(resource, nil); err != nil {
+			return err
+		}
+		pushed.m[resource] = true
+	}
+	return nil
+}
+
+// pushedSet records the resources pushed for a request, which may be
+// pushed from more than one goroutine.
+type pushedSet struct {
+	mu sync.Mutex
+	m  map[string]bool
+}
+
+// EarlyHints returns middleware which sends a 103 Early Hints response
+// containing the given Link header values (such as
+// "</app.css>; rel=preload; as=style") before the rest of the chain runs,
+// so that clients can start fetching assets while the response is being
+// generated. The links are also included in the final response.
+func EarlyHints(links ...string) chainMiddleware {
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(links) > 0 && r.ProtoAtLeast(1, 1) && !IsWebSocketUpgrade(r) {
+				for _, link := range links {
+					w.Header().Add("Link", link)
+				}
+				w.WriteHeader(http.StatusEarlyHints)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
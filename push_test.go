@@ -0,0 +1,92 @@
+package stack
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"sync"
+	"testing"
+)
+
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+	pushed []string
+}
+
+func (pr *pushRecorder) Push(target string, opts *http.PushOptions) error {
+	pr.pushed = append(pr.pushed, target)
+	return nil
+}
+
+func TestPush(t *testing.T) {
+	var err error
+	st := New(Compress(), ETag()).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		err = Push(ctx, w, "/app.css", "/app.js")
+		Push(ctx, w, "/app.css")
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	rec := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	st.ServeHTTP(rec, r)
+	assertEquals(t, nil, err)
+	assertEquals(t, 2, len(rec.pushed))
+	assertEquals(t, "/app.js", rec.pushed[1])
+}
+
+func TestPushConcurrently(t *testing.T) {
+	st := New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				Push(ctx, w, "/app.css", "/app.js")
+			}()
+		}
+		wg.Wait()
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 2, len(rec.pushed))
+}
+
+func TestPushNotSupported(t *testing.T) {
+	var err error
+	st := New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		err = Push(ctx, w, "/app.css")
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	st.ServeHTTP(httptest.NewRecorder(), r)
+	assertEquals(t, http.ErrNotSupported, err)
+}
+
+func TestEarlyHints(t *testing.T) {
+	ts := httptest.NewServer(New(EarlyHints("</app.css>; rel=preload; as=style"), Compress()).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("bish"))
+	}))
+	defer ts.Close()
+
+	var hints []string
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			hints = append(hints, fmt.Sprintf("%d %s", code, header.Get("Link")))
+			return nil
+		},
+	}
+	r, _ := http.NewRequest("GET", ts.URL, nil)
+	r = r.WithContext(httptrace.WithClientTrace(r.Context(), trace))
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	assertEquals(t, 1, len(hints))
+	assertEquals(t, "103 </app.css>; rel=preload; as=style", hints[0])
+	assertEquals(t, 200, res.StatusCode)
+}
@@ -0,0 +1,213 @@
+package stack
+
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const fieldErrorsKey = "stack.fieldErrors"
+
+// FieldError describes a problem with a single input field.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// FieldErrors collects the problems found with a request's input, one per
+// field.
+type FieldErrors []FieldError
+
+func (fe FieldErrors) Error() string {
+	msgs := make([]string, len(fe))
+	for i, e := range fe {
+		msgs[i] = e.Field + ": " + e.Message
+	}
+	return "stack: invalid input: " + strings.Join(msgs, "; ")
+}
+
+// InvalidFields returns the field errors recorded for the current request
+// by BindQuery or Validate, or nil if there are none.
+func InvalidFields(ctx *Context) FieldErrors {
+	fe, _ := ctx.Get(fieldErrorsKey).(FieldErrors)
+	return fe
+}
+
+// BindQuery returns middleware which decodes the request's query
+// parameters into a T, which must be a struct, and stores it in the
+// Context for the handler to retrieve with Query. Fields are mapped with
+// query tags, and fields without one are ignored:
+//
+//	type listUsers struct {
+//		Page   int      `query:"page" default:"1"`
+//		Sort   string   `query:"sort,required"`
+//		Status []string `query:"status"`
+//	}
+//
+// Fields may be strings, booleans, numbers, time.Durations, time.Times (in
+// RFC 3339 format), types implementing encoding.TextUnmarshaler, or slices
+// of these, which take every value of a repeated parameter. Missing
+// parameters take the value of the field's default tag, if it has one.
+//
+// Every missing required parameter and value which can't be converted is
+// recorded, and the FieldErrors (also available through InvalidFields) are
+// passed to the chain's error handler with a 400 Bad Request status.
+func BindQuery[T any]() chainMiddleware {
+	t := configType[T]()
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("stack: BindQuery needs a struct type, not %s", t))
+	}
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var v T
+			if errs := decodeQuery(reflect.ValueOf(&v).Elem(), r.URL.Query()); len(errs) > 0 {
+				ctx.Put(fieldErrorsKey, errs)
+				Error(ctx, w, r, NewHTTPError(http.StatusBadRequest, errs))
+				return
+			}
+			ctx.Put(queryKey[T](), v)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Query returns the query parameters bound by BindQuery as a T. It panics
+// if the chain has no BindQuery middleware for T.
+func Query[T any](ctx *Context) T {
+	v, ok := ctx.Get(queryKey[T]()).(T)
+	if !ok {
+		panic(fmt.Sprintf("stack: no %s query bound for the request", configType[T]()))
+	}
+	return v
+}
+
+func queryKey[T any]() string {
+	t := configType[T]()
+	return "stack.query." + t.PkgPath() + "." + t.String()
+}
+
+// decodeQuery sets the tagged fields of the struct v, including those of
+// embedded structs, from params.
+func decodeQuery(v reflect.Value, params map[string][]string) FieldErrors {
+	var errs FieldErrors
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("query")
+		if !ok {
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				errs = append(errs, decodeQuery(v.Field(i), params)...)
+			}
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		vals := params[name]
+		if len(vals) == 0 {
+			if def, ok := f.Tag.Lookup("default"); ok {
+				vals = []string{def}
+			} else if opts == "required" {
+				errs = append(errs, FieldError{name, "is required"})
+				continue
+			} else {
+				continue
+			}
+		}
+		if err := setField(v.Field(i), vals); err != nil {
+			errs = append(errs, FieldError{name, err.Error()})
+		}
+	}
+	return errs
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// setField sets fv from vals, using every value for a slice and the first
+// for anything else.
+func setField(fv reflect.Value, vals []string) error {
+	if fv.Kind() == reflect.Slice && !fv.Type().Implements(textUnmarshalerType) && !reflect.PointerTo(fv.Type()).Implements(textUnmarshalerType) {
+		s := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err := setValue(s.Index(i), val); err != nil {
+				return err
+			}
+		}
+		fv.Set(s)
+		return nil
+	}
+	return setValue(fv, vals[0])
+}
+
+func setValue(fv reflect.Value, val string) error {
+	if fv.CanAddr() {
+		if tu, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			if err := tu.UnmarshalText([]byte(val)); err != nil {
+				return fmt.Errorf("invalid value %q", val)
+			}
+			return nil
+		}
+	}
+	switch fv.Type() {
+	case durationType:
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", val)
+		}
+		fv.SetInt(int64(d))
+		return nil
+	case timeType:
+		tm, err := time.Parse(time.RFC3339, val)
+		if err != nil {
+			return fmt.Errorf("invalid time %q", val)
+		}
+		fv.Set(reflect.ValueOf(tm))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", val)
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(val, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", val)
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(val, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", val)
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(val, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", val)
+		}
+		fv.SetFloat(n)
+	case reflect.Ptr:
+		p := reflect.New(fv.Type().Elem())
+		if err := setValue(p.Elem(), val); err != nil {
+			return err
+		}
+		fv.Set(p)
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
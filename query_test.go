@@ -0,0 +1,67 @@
+package stack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type queryPaging struct {
+	Page int `query:"page" default:"1"`
+}
+
+type listQuery struct {
+	queryPaging
+	Sort    string        `query:"sort,required"`
+	Status  []string      `query:"status"`
+	Since   time.Time     `query:"since"`
+	Timeout time.Duration `query:"timeout" default:"5s"`
+	Limit   *uint8        `query:"limit"`
+	Ignored string
+}
+
+func TestBindQuery(t *testing.T) {
+	var got listQuery
+	var ctx *Context
+	st := New(BindQuery[listQuery]()).OnError(func(c *Context, w http.ResponseWriter, r *http.Request, err error) {
+		ctx = c
+		w.WriteHeader(StatusCode(err))
+	}).Then(func(c *Context, w http.ResponseWriter, r *http.Request) {
+		got = Query[listQuery](c)
+	})
+
+	rec := serveFile(st, "/?sort=name&status=active&status=new&since=2006-01-02T15:04:05Z&limit=10&Ignored=x")
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, 1, got.Page)
+	assertEquals(t, "name", got.Sort)
+	assertEquals(t, 2, len(got.Status))
+	assertEquals(t, "new", got.Status[1])
+	assertEquals(t, time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC), got.Since)
+	assertEquals(t, 5*time.Second, got.Timeout)
+	assertEquals(t, uint8(10), *got.Limit)
+	assertEquals(t, "", got.Ignored)
+
+	rec = serveFile(st, "/?page=bish&limit=300")
+	assertEquals(t, 400, rec.Code)
+	assertEquals(t, FieldErrors{
+		{"page", `invalid integer "bish"`},
+		{"sort", "is required"},
+		{"limit", `invalid unsigned integer "300"`},
+	}.Error(), InvalidFields(ctx).Error())
+}
+
+func TestBindQueryNotStruct(t *testing.T) {
+	defer func() {
+		assertEquals(t, "stack: BindQuery needs a struct type, not string", recover())
+	}()
+	BindQuery[string]()
+}
+
+func TestFieldErrors(t *testing.T) {
+	fe := FieldErrors{{"page", "is required"}, {"sort", "is invalid"}}
+	assertEquals(t, "stack: invalid input: page: is required; sort: is invalid", fe.Error())
+	r, _ := http.NewRequest("GET", "/", nil)
+	st := New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {})
+	assertEquals(t, true, InvalidFields(st.ServeWithContext(httptest.NewRecorder(), r)) == nil)
+}
@@ -0,0 +1,33 @@
+package stack
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Redirect redirects the client to target with the given 3xx status code.
+// Unlike http.Redirect, it sends an absolute URL, resolving a relative
+// target against the request's URL and using the scheme and host seen by
+// the client, as reported by a trusted proxy if the chain uses
+// TrustProxies. Targets with a scheme are sent unchanged.
+func Redirect(ctx *Context, w http.ResponseWriter, r *http.Request, status int, target string) {
+	u, err := url.Parse(target)
+	if err == nil && u.Scheme == "" {
+		base := &url.URL{Scheme: requestScheme(r), Host: r.Host, Path: r.URL.Path}
+		target = base.ResolveReference(u).String()
+	}
+	http.Redirect(w, r, target, status)
+}
+
+// RedirectToRoute redirects the client with a 303 See Other to the named
+// route, with parameters given as for URL. If the URL can't be built the
+// error is passed to the chain's error handler and returned.
+func RedirectToRoute(ctx *Context, w http.ResponseWriter, r *http.Request, name string, pairs ...interface{}) error {
+	u, err := URL(ctx, name, pairs...)
+	if err != nil {
+		Error(ctx, w, r, err)
+		return err
+	}
+	Redirect(ctx, w, r, http.StatusSeeOther, u)
+	return nil
+}
@@ -0,0 +1,54 @@
+package stack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func redirectTo(hc http.Handler, path string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "http://example.com"+path, nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	hc.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestRedirect(t *testing.T) {
+	st := func(target string) HandlerChain {
+		return New(TrustProxies(XForwardedHeaders, "10.0.0.0/8")).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+			Redirect(ctx, w, r, http.StatusFound, target)
+		})
+	}
+	rec := redirectTo(st("/bish"), "/a/b", nil)
+	assertEquals(t, 302, rec.Code)
+	assertEquals(t, "http://example.com/bish", rec.Header().Get("Location"))
+
+	rec = redirectTo(st("../bash?x=1"), "/a/b/c", map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "bish.example"})
+	assertEquals(t, "https://bish.example/a/bash?x=1", rec.Header().Get("Location"))
+
+	rec = redirectTo(st("https://elsewhere.example/x"), "/", nil)
+	assertEquals(t, "https://elsewhere.example/x", rec.Header().Get("Location"))
+}
+
+func TestRedirectToRoute(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/users/{id}", New().Then(bishHandler)).Name("user.show")
+	rt.Post("/users", New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		RedirectToRoute(ctx, w, r, "user.show", "id", 42)
+	}))
+	rt.Post("/broken", New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		RedirectToRoute(ctx, w, r, "bish")
+	}))
+	st := New(TrustProxies(ForwardedHeader, "10.0.0.0/8")).ThenHandler(rt)
+
+	rec := redirectTo(st, "/users", map[string]string{"Forwarded": "proto=https;host=bish.example"})
+	assertEquals(t, 303, rec.Code)
+	assertEquals(t, "https://bish.example/users/42", rec.Header().Get("Location"))
+
+	rec = redirectTo(st, "/broken", nil)
+	assertEquals(t, 500, rec.Code)
+}
@@ -0,0 +1,229 @@
+package stack
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Refresher renews a client's credentials. It returns the principal the
+// request should be treated as authenticated for (nil to leave the
+// Context unchanged) and a cookie carrying a renewed credential (nil if
+// nothing needs to be sent to the client).
+type Refresher interface {
+	Refresh(ctx *Context, r *http.Request) (principal interface{}, cookie *http.Cookie, err error)
+}
+
+// RefresherFunc adapts an ordinary function into a Refresher.
+type RefresherFunc func(ctx *Context, r *http.Request) (interface{}, *http.Cookie, error)
+
+// Refresh calls fn(ctx, r).
+func (fn RefresherFunc) Refresh(ctx *Context, r *http.Request) (interface{}, *http.Cookie, error) {
+	return fn(ctx, r)
+}
+
+// RefreshCredentials returns middleware which runs each refresher in
+// turn. Principals they return are recorded with SetPrincipal straight
+// away, so later middleware and handlers see a valid identity, and the
+// cookies they return are set just before the response headers are
+// written, so the chain must call RecordResponses. Errors are passed to
+// the chain's error handler.
+func RefreshCredentials(refreshers ...Refresher) chainMiddleware {
+	return Requires(func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, rf := range refreshers {
+				principal, cookie, err := rf.Refresh(ctx, r)
+				if err != nil {
+					Error(ctx, w, r, err)
+					return
+				}
+				if principal != nil {
+					SetPrincipal(ctx, principal)
+				}
+				if cookie != nil {
+					BeforeWrite(ctx, func(w http.ResponseWriter) {
+						http.SetCookie(w, cookie)
+					})
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, NeedsResponses)
+}
+
+// RememberMe is a Refresher implementing persistent "remember me" logins
+// using a cookie signed with the chain's CookieCodec. When a request has
+// no principal but carries a valid remember-me cookie, the principal
+// stored in the cookie is used, and the cookie is reissued with a fresh
+// expiry once it is older than RotateAfter. The cookie it replaces is
+// revoked, so that a stolen cookie stops working once its owner's has
+// been rotated.
+type RememberMe struct {
+	// Revocations records the cookies which have been rotated. It is
+	// required: with several instances of a program, use one shared
+	// between them rather than a MemRevocations each.
+	Revocations Revocations
+	// Cookie is the cookie name. The default is "remember".
+	Cookie string
+	// Lifetime is how long a remember-me cookie is valid for. The default
+	// is 30 days.
+	Lifetime time.Duration
+	// RotateAfter is the age after which the cookie is reissued. The
+	// default is 24 hours.
+	RotateAfter time.Duration
+}
+
+type rememberValue struct {
+	ID        string `json:"n"`
+	Principal string `json:"p"`
+	Issued    int64  `json:"i"`
+}
+
+// Revocations records remember-me cookies which mustn't be accepted any
+// more, by the IDs RememberMe gives them.
+type Revocations interface {
+	// Revoke records id as revoked. The cookie would have expired by
+	// itself at expiry, after which id can be forgotten.
+	Revoke(id string, expiry time.Time) error
+	// Revoked reports whether id has been revoked.
+	Revoked(id string) (bool, error)
+}
+
+// MemRevocations is a Revocations held in memory, for programs which run
+// as a single instance. Create one with NewMemRevocations.
+type MemRevocations struct {
+	mu  sync.Mutex
+	ids map[string]time.Time
+}
+
+// NewMemRevocations returns an empty MemRevocations.
+func NewMemRevocations() *MemRevocations {
+	return &MemRevocations{ids: make(map[string]time.Time)}
+}
+
+// Revoke implements Revocations. It also forgets IDs which have expired.
+func (m *MemRevocations) Revoke(id string, expiry time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for other, exp := range m.ids {
+		if now.After(exp) {
+			delete(m.ids, other)
+		}
+	}
+	m.ids[id] = expiry
+	return nil
+}
+
+// Revoked implements Revocations.
+func (m *MemRevocations) Revoked(id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.ids[id]
+	return ok, nil
+}
+
+var errNoRevocations = errors.New("stack: RememberMe has no Revocations")
+
+func (rm RememberMe) name() string {
+	if rm.Cookie == "" {
+		return "remember"
+	}
+	return rm.Cookie
+}
+
+func (rm RememberMe) lifetime() time.Duration {
+	if rm.Lifetime == 0 {
+		return 30 * 24 * time.Hour
+	}
+	return rm.Lifetime
+}
+
+func (rm RememberMe) rotateAfter() time.Duration {
+	if rm.RotateAfter == 0 {
+		return 24 * time.Hour
+	}
+	return rm.RotateAfter
+}
+
+// Refresh implements Refresher. Invalid, expired or revoked cookies are
+// ignored.
+func (rm RememberMe) Refresh(ctx *Context, r *http.Request) (interface{}, *http.Cookie, error) {
+	if Principal(ctx) != nil {
+		return nil, nil, nil
+	}
+	if rm.Revocations == nil {
+		return nil, nil, errNoRevocations
+	}
+	raw, err := ReadSignedCookie(ctx, r, rm.name())
+	if err == ErrNoCookieCodec {
+		return nil, nil, err
+	}
+	if err != nil {
+		return nil, nil, nil
+	}
+	var v rememberValue
+	if json.Unmarshal([]byte(raw), &v) != nil || v.ID == "" || v.Principal == "" {
+		return nil, nil, nil
+	}
+	revoked, err := rm.Revocations.Revoked(v.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if revoked {
+		return nil, nil, nil
+	}
+	issued := time.Unix(v.Issued, 0)
+	if Now(ctx).Sub(issued) < rm.rotateAfter() {
+		return v.Principal, nil, nil
+	}
+	cookie, err := rm.cookie(ctx, v.Principal)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := rm.Revocations.Revoke(v.ID, issued.Add(rm.lifetime())); err != nil {
+		return nil, nil, err
+	}
+	return v.Principal, cookie, nil
+}
+
+// Remember sets a remember-me cookie for principal. It is typically
+// called by a login handler.
+func (rm RememberMe) Remember(ctx *Context, w http.ResponseWriter, principal string) error {
+	cookie, err := rm.cookie(ctx, principal)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, cookie)
+	return nil
+}
+
+// Forget expires the remember-me cookie. It is typically called by a
+// logout handler.
+func (rm RememberMe) Forget(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: rm.name(), Path: "/", MaxAge: -1, HttpOnly: true})
+}
+
+func (rm RememberMe) cookie(ctx *Context, principal string) (*http.Cookie, error) {
+	if ctx.cookieCodec == nil {
+		return nil, ErrNoCookieCodec
+	}
+	now := Now(ctx)
+	b, _ := json.Marshal(rememberValue{ID: NewID(ctx), Principal: principal, Issued: now.Unix()})
+	expiry := now.Add(rm.lifetime())
+	value, err := ctx.cookieCodec.Encode(rm.name(), string(b), expiry)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Cookie{
+		Name:     rm.name(),
+		Value:    value,
+		Path:     "/",
+		Expires:  expiry.UTC(),
+		MaxAge:   int(rm.lifetime().Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}, nil
+}
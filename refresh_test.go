@@ -0,0 +1,92 @@
+package stack
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRefreshCredentials(t *testing.T) {
+	refresher := RefresherFunc(func(ctx *Context, r *http.Request) (interface{}, *http.Cookie, error) {
+		if r.Header.Get("Authorization") == "" {
+			return nil, nil, nil
+		}
+		return "flip", &http.Cookie{Name: "token", Value: "renewed"}, nil
+	})
+	st := New(RefreshCredentials(refresher)).RecordResponses().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "principal=%v", Principal(ctx))
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer nearly-expired")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, "principal=flip", rec.Body.String())
+	assertEquals(t, "token=renewed", rec.Header().Get("Set-Cookie"))
+
+	r, _ = http.NewRequest("GET", "/", nil)
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, "principal=<nil>", rec.Body.String())
+	assertEquals(t, "", rec.Header().Get("Set-Cookie"))
+}
+
+func TestRememberMe(t *testing.T) {
+	cc, _ := NewCookieCodec([][]byte{testSigningKey}, nil)
+	revoked := NewMemRevocations()
+	rm := RememberMe{Revocations: revoked, RotateAfter: time.Hour}
+	login := New().UseCookieCodec(cc).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		rm.Remember(ctx, w, "flip")
+	})
+	st := New(RefreshCredentials(rm)).RecordResponses().UseCookieCodec(cc).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "principal=%v", Principal(ctx))
+	})
+
+	r, _ := http.NewRequest("POST", "/login", nil)
+	rec := httptest.NewRecorder()
+	login.ServeHTTP(rec, r)
+	cookie := (&http.Response{Header: rec.Header()}).Cookies()[0]
+	assertEquals(t, "remember", cookie.Name)
+
+	r, _ = http.NewRequest("GET", "/", nil)
+	r.AddCookie(cookie)
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, "principal=flip", rec.Body.String())
+	assertEquals(t, "", rec.Header().Get("Set-Cookie"))
+
+	// Cookies older than RotateAfter are reissued.
+	old := RememberMe{Revocations: revoked, RotateAfter: time.Nanosecond}
+	rotating := New(RefreshCredentials(old)).RecordResponses().UseCookieCodec(cc).Then(bishHandler)
+	rec = httptest.NewRecorder()
+	rotating.ServeHTTP(rec, r)
+	rotated := (&http.Response{Header: rec.Header()}).Cookies()
+	assertEquals(t, 1, len(rotated))
+	assertEquals(t, "remember", rotated[0].Name)
+
+	// The cookie it replaced can't be used again, but the new one can.
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, "principal=<nil>", rec.Body.String())
+	r, _ = http.NewRequest("GET", "/", nil)
+	r.AddCookie(rotated[0])
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, "principal=flip", rec.Body.String())
+
+	r, _ = http.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "remember", Value: "forged"})
+	rec = httptest.NewRecorder()
+	New(RefreshCredentials(rm)).RecordResponses().UseCookieCodec(cc).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "principal=%v", Principal(ctx))
+	}).ServeHTTP(rec, r)
+	assertEquals(t, "principal=<nil>", rec.Body.String())
+}
+
+func TestRememberMeWithoutRevocations(t *testing.T) {
+	cc, _ := NewCookieCodec([][]byte{testSigningKey}, nil)
+	st := New(RefreshCredentials(RememberMe{})).RecordResponses().UseCookieCodec(cc).Then(bishHandler)
+	assertEquals(t, 500, recordGet(st).Code)
+}
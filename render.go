@@ -0,0 +1,56 @@
+package stack
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// ErrNoRenderer is returned by Render when the chain has no Renderer.
+var ErrNoRenderer = errors.New("stack: no renderer configured for chain")
+
+// Renderer renders named templates. The render subpackage provides one
+// based on html/template.
+type Renderer interface {
+	Render(ctx *Context, w io.Writer, name string, data interface{}) error
+}
+
+// UseRenderer sets the Renderer used by Render for requests handled by
+// the chain.
+func (c Chain) UseRenderer(r Renderer) Chain {
+	c.renderer = r
+	return c
+}
+
+// Render renders the named template with data as an HTML response, using
+// the chain's Renderer. The output is buffered, so that if rendering fails
+// nothing has been written and the error is passed to the chain's error
+// handler instead. Render returns any error from rendering or writing the
+// response.
+func Render(ctx *Context, w http.ResponseWriter, name string, data interface{}) error {
+	return render(ctx, w, http.StatusOK, name, data)
+}
+
+func render(ctx *Context, w http.ResponseWriter, status int, name string, data interface{}) error {
+	if ctx.renderer == nil {
+		Error(ctx, w, ctx.request, ErrNoRenderer)
+		return ErrNoRenderer
+	}
+	buf := responseBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer responseBuffers.Put(buf)
+
+	if err := ctx.renderer.Render(ctx, buf, name, data); err != nil {
+		Error(ctx, w, ctx.request, err)
+		return err
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,208 @@
+// Package render provides a stack.Renderer for html/template templates,
+// with layouts, partials and reloading for development:
+//
+//	templates, err := render.New(os.DirFS("templates"), render.Layout("layouts/base.html"))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	chain := stack.New(stack.FlashMessages(), loadUser).
+//		UseCookieCodec(codec).RecordResponses().UseRenderer(templates)
+//
+//	func showUser(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+//		stack.Render(ctx, w, "users/show.html", user)
+//	}
+//
+// Templates are executed with a map holding the data passed to Render as
+// "Data", along with values from the Context which most pages need:
+//
+//	Flashes    the flash messages for the request (see stack.Flashes)
+//	User       the current user (see stack.CurrentUser)
+//	Principal  the authenticated principal (see stack.Principal)
+//	Locale     the request's locale (see stack.Locale)
+//	Asset      a function returning the URL of a fingerprinted asset (see
+//	           stack.AssetURL), used as {{call .Asset "app.css"}}
+//
+// Further values, such as a CSRF token, can be added with Global. If the
+// data passed to Render is a map[string]interface{}, its entries are
+// merged in as well, taking precedence over the values above.
+package render
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"sync"
+
+	"github.com/alexedwards/stack"
+)
+
+// Option configures a Set.
+type Option func(*Set)
+
+// Layout sets the template file which pages are rendered within. The
+// layout includes the page's content with a block, such as
+// {{block "content" .}}{{end}}, which pages fill with
+// {{define "content"}}...{{end}}.
+func Layout(name string) Option {
+	return func(s *Set) {
+		s.layout = name
+	}
+}
+
+// Partials sets the glob pattern, relative to the Set's file system,
+// matching templates which are available to every page. The default is
+// "partials/*.html".
+func Partials(pattern string) Option {
+	return func(s *Set) {
+		s.partials = pattern
+	}
+}
+
+// Funcs adds functions to the templates' function maps.
+func Funcs(funcs template.FuncMap) Option {
+	return func(s *Set) {
+		for name, fn := range funcs {
+			s.funcs[name] = fn
+		}
+	}
+}
+
+// Global adds a value, computed from the Context for each render, to the
+// data templates are executed with:
+//
+//	render.Global("CSRFToken", func(ctx *stack.Context) interface{} {
+//		return csrf.Token(ctx)
+//	})
+func Global(name string, fn func(ctx *stack.Context) interface{}) Option {
+	return func(s *Set) {
+		s.globals[name] = fn
+	}
+}
+
+// Reload makes the Set parse templates afresh for every render, so that
+// changes to the files show up without a restart. It is intended for
+// development.
+func Reload(reload bool) Option {
+	return func(s *Set) {
+		s.reload = reload
+	}
+}
+
+// Set is a set of templates, loaded from a file system. It implements
+// stack.Renderer, and is safe for concurrent use.
+type Set struct {
+	fsys     fs.FS
+	layout   string
+	partials string
+	funcs    template.FuncMap
+	globals  map[string]func(*stack.Context) interface{}
+	reload   bool
+
+	mu    sync.Mutex
+	pages map[string]*template.Template
+}
+
+// New returns a Set loading templates from fsys. It checks that the layout
+// and partials can be parsed, so that mistakes are caught at startup;
+// pages are parsed when they are first rendered.
+func New(fsys fs.FS, opts ...Option) (*Set, error) {
+	s := &Set{
+		fsys:     fsys,
+		partials: "partials/*.html",
+		funcs:    make(template.FuncMap),
+		globals:  make(map[string]func(*stack.Context) interface{}),
+		pages:    make(map[string]*template.Template),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if _, err := s.base(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// base parses the layout and partials.
+func (s *Set) base() (*template.Template, error) {
+	t := template.New("").Funcs(s.funcs)
+	partials, err := fs.Glob(s.fsys, s.partials)
+	if err != nil {
+		return nil, fmt.Errorf("render: %w", err)
+	}
+	files := partials
+	if s.layout != "" {
+		files = append(files, s.layout)
+	}
+	if len(files) > 0 {
+		if t, err = t.ParseFS(s.fsys, files...); err != nil {
+			return nil, fmt.Errorf("render: %w", err)
+		}
+	}
+	return t, nil
+}
+
+// page returns the template for the named page, parsing it if it hasn't
+// been already (or every time, when reloading).
+func (s *Set) page(name string) (*template.Template, error) {
+	if !s.reload {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if t, ok := s.pages[name]; ok {
+			return t, nil
+		}
+	}
+	t, err := s.base()
+	if err != nil {
+		return nil, err
+	}
+	if t, err = t.ParseFS(s.fsys, name); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("render: no template %q: %w", name, err)
+		}
+		return nil, fmt.Errorf("render: %w", err)
+	}
+	if !s.reload {
+		s.pages[name] = t
+	}
+	return t, nil
+}
+
+// Render executes the named page, within the layout if there is one.
+func (s *Set) Render(ctx *stack.Context, w io.Writer, name string, data interface{}) error {
+	t, err := s.page(name)
+	if err != nil {
+		return err
+	}
+	entry := path.Base(name)
+	if s.layout != "" {
+		entry = path.Base(s.layout)
+	}
+	return t.ExecuteTemplate(w, entry, s.data(ctx, data))
+}
+
+func (s *Set) data(ctx *stack.Context, data interface{}) map[string]interface{} {
+	m := map[string]interface{}{
+		"Data":      data,
+		"Flashes":   stack.Flashes(ctx),
+		"User":      stack.CurrentUser(ctx),
+		"Principal": stack.Principal(ctx),
+		"Locale":    stack.Locale(ctx),
+		"Asset": func(name string) string {
+			return stack.AssetURL(ctx, name)
+		},
+	}
+	for name, fn := range s.globals {
+		m[name] = fn(ctx)
+	}
+	if dm, ok := data.(map[string]interface{}); ok {
+		for k, v := range dm {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+var _ stack.Renderer = (*Set)(nil)
@@ -0,0 +1,126 @@
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/alexedwards/stack"
+)
+
+func assertEquals(t *testing.T, e interface{}, o interface{}) {
+	if e != o {
+		t.Errorf("\n...expected = %v\n...obtained = %v", e, o)
+	}
+}
+
+var files = fstest.MapFS{
+	"layouts/base.html":  {Data: []byte(`<title>{{block "title" .}}Bish{{end}}</title>{{template "nav" .}}{{block "content" .}}{{end}}`)},
+	"partials/nav.html":  {Data: []byte(`{{define "nav"}}<nav>{{.Principal}} {{.Token}}</nav>{{end}}`)},
+	"users/show.html":    {Data: []byte(`{{define "title"}}{{.Data.Name}}{{end}}{{define "content"}}<p>{{shout .Data.Name}}</p>{{end}}`)},
+	"users/list.html":    {Data: []byte(`{{define "content"}}{{range .Users}}<li>{{.}}</li>{{end}}{{end}}`)},
+	"plain.html":         {Data: []byte(`<p>{{.Data}}</p>`)},
+	"asset.html":         {Data: []byte(`<link href="{{call .Asset "app.css"}}">`)},
+	"broken/parse.html":  {Data: []byte(`{{define "content"}}{{.Data}`)},
+	"broken/layout.html": {Data: []byte(`{{block "content" .}}`)},
+}
+
+func render(hc stack.HandlerChain) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	hc.ServeHTTP(rec, r)
+	return rec
+}
+
+func setPrincipal(ctx *stack.Context, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stack.SetPrincipal(ctx, "bish")
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestRender(t *testing.T) {
+	set, err := New(files,
+		Layout("layouts/base.html"),
+		Funcs(map[string]interface{}{"shout": strings.ToUpper}),
+		Global("Token", func(ctx *stack.Context) interface{} { return "t0k3n" }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := stack.New(setPrincipal).UseRenderer(set)
+
+	rec := render(chain.Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		stack.Render(ctx, w, "users/show.html", struct{ Name string }{"flip"})
+	}))
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assertEquals(t, "<title>flip</title><nav>bish t0k3n</nav><p>FLIP</p>", rec.Body.String())
+
+	rec = render(chain.Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		stack.Render(ctx, w, "users/list.html", map[string]interface{}{"Users": []string{"a", "b"}, "Token": "mine"})
+	}))
+	assertEquals(t, "<title>Bish</title><nav>bish mine</nav><li>a</li><li>b</li>", rec.Body.String())
+
+	rec = render(chain.Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		stack.Render(ctx, w, "broken/parse.html", nil)
+	}))
+	assertEquals(t, 500, rec.Code)
+
+	rec = render(chain.Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		stack.Render(ctx, w, "missing.html", nil)
+	}))
+	assertEquals(t, 500, rec.Code)
+}
+
+func TestRenderWithoutLayout(t *testing.T) {
+	set, err := New(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := render(stack.New().UseRenderer(set).Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		stack.Render(ctx, w, "plain.html", "<bash>")
+	}))
+	assertEquals(t, "<p>&lt;bash&gt;</p>", rec.Body.String())
+}
+
+func TestRenderAsset(t *testing.T) {
+	set, err := New(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assets, err := stack.NewAssets(http.FS(fstest.MapFS{"app.css": {Data: []byte("body{}")}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := render(stack.New().UseRenderer(set).UseAssets(assets).Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		stack.Render(ctx, w, "asset.html", nil)
+	}))
+	assertEquals(t, `<link href="`+assets.URL("app.css")+`">`, rec.Body.String())
+}
+
+func TestNewChecksLayout(t *testing.T) {
+	_, err := New(files, Layout("broken/layout.html"))
+	assertEquals(t, true, err != nil)
+	_, err = New(files, Layout("missing.html"))
+	assertEquals(t, true, err != nil)
+}
+
+func TestReload(t *testing.T) {
+	fsys := fstest.MapFS{"page.html": {Data: []byte("bish")}}
+	cached, _ := New(fsys)
+	reloading, _ := New(fsys, Reload(true))
+	page := func(set *Set) string {
+		return render(stack.New().UseRenderer(set).Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+			stack.Render(ctx, w, "page.html", nil)
+		})).Body.String()
+	}
+	assertEquals(t, "bish", page(cached))
+	assertEquals(t, "bish", page(reloading))
+
+	fsys["page.html"] = &fstest.MapFile{Data: []byte("bash")}
+	assertEquals(t, "bish", page(cached))
+	assertEquals(t, "bash", page(reloading))
+}
@@ -0,0 +1,43 @@
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+)
+
+type greetRenderer struct{}
+
+func (greetRenderer) Render(ctx *Context, w io.Writer, name string, data interface{}) error {
+	if name != "greet" {
+		return errors.New("no such template")
+	}
+	_, err := fmt.Fprintf(w, "<p>Hello %v, bish=%v</p>", data, ctx.Get("bish"))
+	return err
+}
+
+func TestRender(t *testing.T) {
+	chain := New().UseRenderer(greetRenderer{})
+	rec := recordGet(Inject(chain.Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		Render(ctx, w, "greet", "flip")
+	}), "bish", "bash"))
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assertEquals(t, "<p>Hello flip, bish=bash</p>", rec.Body.String())
+
+	rec = recordGet(chain.Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		Render(ctx, w, "bash", nil)
+	}))
+	assertEquals(t, 500, rec.Code)
+}
+
+func TestRenderWithoutRenderer(t *testing.T) {
+	var err error
+	rec := recordGet(New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		err = Render(ctx, w, "greet", nil)
+	}))
+	assertEquals(t, 500, rec.Code)
+	assertEquals(t, ErrNoRenderer, err)
+}
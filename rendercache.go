@@ -0,0 +1,162 @@
+package stack
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RenderCache caches the output of rendered templates, already gzipped,
+// so that hot server-rendered pages skip both rendering and compression on
+// repeat hits.
+type RenderCache struct {
+	ttl     time.Duration
+	vary    []string
+	mu      sync.Mutex
+	entries map[string]renderEntry
+}
+
+type renderEntry struct {
+	plain   []byte
+	gzipped []byte
+	expires time.Time
+}
+
+// maxRenderEntries bounds the memory used by cached pages.
+const maxRenderEntries = 1000
+
+// gzipOnly is used to check whether a client accepts gzip.
+var gzipOnly = &compressConfig{encoders: []namedEncoder{{name: "gzip"}}}
+
+// NewRenderCache returns a RenderCache which keeps pages for ttl. Pages are
+// cached by template name, the key passed to Render, Locale and CacheKey
+// (when CanonicalQuery is in the chain), and by the values of the given
+// Context keys, so vary must name every other key which changes the page
+// for the same template, data, locale and URL.
+func NewRenderCache(ttl time.Duration, vary ...string) *RenderCache {
+	return &RenderCache{ttl: ttl, vary: vary, entries: make(map[string]renderEntry)}
+}
+
+// Render is like the package's Render function, but serves the page from
+// the cache if it can. key identifies data, so that pages rendered from
+// different data are cached separately:
+//
+//	rc.Render(ctx, w, "products/show.html", "product:"+id, product)
+//
+// On a miss the template is rendered with the chain's Renderer and stored,
+// both as it is and gzipped. Clients which accept gzip are sent the
+// gzipped copy with a Content-Encoding header, which the Compress and
+// Transform middleware leave alone.
+//
+// Requests with flash messages, a principal, a current user or values in
+// their session are never cached, since the page may show them.
+func (rc *RenderCache) Render(ctx *Context, w http.ResponseWriter, name, key string, data interface{}) error {
+	if len(Flashes(ctx)) > 0 || Principal(ctx) != nil || CurrentUser(ctx) != nil || hasSession(ctx) {
+		return Render(ctx, w, name, data)
+	}
+	if ctx.renderer == nil {
+		Error(ctx, w, ctx.request, ErrNoRenderer)
+		return ErrNoRenderer
+	}
+
+	key = rc.key(ctx, name, key)
+	now := Now(ctx)
+	rc.mu.Lock()
+	e, ok := rc.entries[key]
+	rc.mu.Unlock()
+	if !ok || !now.Before(e.expires) {
+		var err error
+		if e, err = rc.render(ctx, name, data); err != nil {
+			Error(ctx, w, ctx.request, err)
+			return err
+		}
+		e.expires = now.Add(rc.ttl)
+		rc.store(key, e, now)
+	}
+
+	h := w.Header()
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", "text/html; charset=utf-8")
+	}
+	addVary(h, "Accept-Encoding")
+	body := e.plain
+	if ctx.request != nil && gzipOnly.negotiate(ctx.request.Header.Get("Accept-Encoding")) != nil {
+		h.Set("Content-Encoding", "gzip")
+		body = e.gzipped
+	}
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(body)
+	return err
+}
+
+// hasSession reports whether the request has a session with values in it.
+// Empty sessions, which the session middleware gives every new visitor,
+// don't count.
+func hasSession(ctx *Context) bool {
+	s := Session(ctx)
+	if s == nil {
+		return false
+	}
+	if k, ok := s.(interface{ Keys() []string }); ok {
+		return len(k.Keys()) > 0
+	}
+	return true
+}
+
+func (rc *RenderCache) key(ctx *Context, name, key string) string {
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte(0)
+	b.WriteString(key)
+	b.WriteByte(0)
+	b.WriteString(Locale(ctx))
+	b.WriteByte(0)
+	b.WriteString(CacheKey(ctx))
+	for _, k := range rc.vary {
+		fmt.Fprintf(&b, "\x00%v", ctx.Get(k))
+	}
+	return b.String()
+}
+
+func (rc *RenderCache) render(ctx *Context, name string, data interface{}) (renderEntry, error) {
+	var plain, gzipped bytes.Buffer
+	if err := ctx.renderer.Render(ctx, &plain, name, data); err != nil {
+		return renderEntry{}, err
+	}
+	// Pages are compressed once and served many times, so it's worth
+	// spending longer on them than the Compress middleware would.
+	gw, _ := gzip.NewWriterLevel(&gzipped, gzip.BestCompression)
+	gw.Write(plain.Bytes())
+	if err := gw.Close(); err != nil {
+		return renderEntry{}, err
+	}
+	return renderEntry{plain: plain.Bytes(), gzipped: gzipped.Bytes()}, nil
+}
+
+// store adds an entry, first making room if the cache is full.
+func (rc *RenderCache) store(key string, e renderEntry, now time.Time) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if len(rc.entries) >= maxRenderEntries {
+		rc.evict(now)
+	}
+	rc.entries[key] = e
+}
+
+// evict removes expired entries, or everything if none have expired.
+func (rc *RenderCache) evict(now time.Time) {
+	for k, e := range rc.entries {
+		if !now.Before(e.expires) {
+			delete(rc.entries, k)
+		}
+	}
+	if len(rc.entries) >= maxRenderEntries {
+		rc.entries = make(map[string]renderEntry)
+	}
+}
@@ -0,0 +1,177 @@
+package stack
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type countingRenderer struct {
+	greetRenderer
+	n int
+}
+
+func (cr *countingRenderer) Render(ctx *Context, w io.Writer, name string, data interface{}) error {
+	cr.n++
+	return cr.greetRenderer.Render(ctx, w, name, data)
+}
+
+type settableClock struct {
+	t time.Time
+}
+
+func (c *settableClock) Now() time.Time {
+	return c.t
+}
+
+func TestRenderCache(t *testing.T) {
+	cr := &countingRenderer{}
+	clock := &settableClock{t: time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)}
+	rc := NewRenderCache(time.Minute, "bish")
+	bish := "bash"
+	st := New(func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx.Put("bish", bish)
+			next.ServeHTTP(w, r)
+		})
+	}, Compress()).UseRenderer(cr).UseClock(clock).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		rc.Render(ctx, w, "greet", "flip", "flip")
+	})
+	get := func(gzipped bool) string {
+		r, _ := http.NewRequest("GET", "/", nil)
+		if gzipped {
+			r.Header.Set("Accept-Encoding", "gzip")
+		}
+		rec := httptest.NewRecorder()
+		st.ServeHTTP(rec, r)
+		if !gzipped {
+			assertEquals(t, "", rec.Header().Get("Content-Encoding"))
+			return rec.Body.String()
+		}
+		assertEquals(t, "gzip", rec.Header().Get("Content-Encoding"))
+		gr, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(gr)
+		return string(body)
+	}
+
+	assertEquals(t, "<p>Hello flip, bish=bash</p>", get(true))
+	assertEquals(t, "<p>Hello flip, bish=bash</p>", get(false))
+	assertEquals(t, 1, cr.n)
+
+	bish = "bosh"
+	assertEquals(t, "<p>Hello flip, bish=bosh</p>", get(true))
+	assertEquals(t, 2, cr.n)
+
+	clock.t = clock.t.Add(time.Minute)
+	get(true)
+	assertEquals(t, 3, cr.n)
+}
+
+func TestRenderCacheErrors(t *testing.T) {
+	rc := NewRenderCache(time.Minute)
+	st := New().UseRenderer(greetRenderer{}).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		rc.Render(ctx, w, "bash", "", nil)
+	})
+	assertEquals(t, 500, recordGet(st).Code)
+	assertEquals(t, 0, len(rc.entries))
+}
+
+func TestRenderCacheSeparatesDataAndUsers(t *testing.T) {
+	cr := &countingRenderer{}
+	rc := NewRenderCache(time.Minute)
+	var principal interface{}
+	st := New(func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if principal != nil {
+				SetPrincipal(ctx, principal)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}).UseRenderer(cr).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("name")
+		name := key
+		if p := Principal(ctx); p != nil {
+			name = p.(string)
+		}
+		rc.Render(ctx, w, "greet", key, name)
+	})
+	get := func(query string) string {
+		r, _ := http.NewRequest("GET", "/?"+query, nil)
+		rec := httptest.NewRecorder()
+		st.ServeHTTP(rec, r)
+		return rec.Body.String()
+	}
+
+	assertEquals(t, "<p>Hello flip, bish=<nil></p>", get("name=flip"))
+	assertEquals(t, "<p>Hello flop, bish=<nil></p>", get("name=flop"))
+	assertEquals(t, "<p>Hello flip, bish=<nil></p>", get("name=flip"))
+	assertEquals(t, 2, cr.n)
+	assertEquals(t, 2, len(rc.entries))
+
+	principal = "alice"
+	assertEquals(t, "<p>Hello alice, bish=<nil></p>", get(""))
+	principal = "bob"
+	assertEquals(t, "<p>Hello bob, bish=<nil></p>", get(""))
+	assertEquals(t, 4, cr.n)
+	assertEquals(t, 2, len(rc.entries))
+}
+
+func TestRenderCacheUsesCacheKey(t *testing.T) {
+	cr := &countingRenderer{}
+	rc := NewRenderCache(time.Minute)
+	st := New(CanonicalQuery()).UseRenderer(cr).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		rc.Render(ctx, w, "greet", "", r.URL.Query().Get("name"))
+	})
+	get := func(target string) string {
+		rec := httptest.NewRecorder()
+		st.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec.Body.String()
+	}
+
+	assertEquals(t, "<p>Hello flip, bish=<nil></p>", get("/?name=flip"))
+	assertEquals(t, "<p>Hello flop, bish=<nil></p>", get("/?name=flop"))
+	assertEquals(t, "<p>Hello flip, bish=<nil></p>", get("/?utm_source=x&name=flip"))
+	assertEquals(t, 2, cr.n)
+}
+
+func TestRenderCacheSkipsSessions(t *testing.T) {
+	cr := &countingRenderer{}
+	rc := NewRenderCache(time.Minute)
+	var values map[string]interface{}
+	st := New(func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if values != nil {
+				s := ContextValues(NewContext())
+				for k, v := range values {
+					s.Put(k, v)
+				}
+				ctx.Put(sessionKey, s)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}).UseRenderer(cr).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		rc.Render(ctx, w, "greet", "", "flip")
+	})
+
+	values = map[string]interface{}{"cart": 3}
+	recordGet(st)
+	recordGet(st)
+	assertEquals(t, 2, cr.n)
+	assertEquals(t, 0, len(rc.entries))
+}
+
+func TestRenderCacheLimit(t *testing.T) {
+	rc := NewRenderCache(time.Minute)
+	now := time.Now()
+	for i := 0; i < maxRenderEntries+1; i++ {
+		rc.store(strconv.Itoa(i), renderEntry{expires: now.Add(time.Minute)}, now)
+	}
+	assertEquals(t, 1, len(rc.entries))
+}
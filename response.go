@@ -0,0 +1,171 @@
+package stack
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ResponseRecorder wraps the ResponseWriter for a request, recording the
+// status code, the number of body bytes written and when the response was
+// written. It is installed by chains which call RecordResponses, and
+// retrieved with Response, so that logging, metrics and similar middleware
+// don't each need a wrapper of their own.
+type ResponseRecorder struct {
+	http.ResponseWriter
+	status    int
+	size      int64
+	start     time.Time
+	firstByte time.Time
+	lastWrite time.Time
+	hijacked  bool
+	empty     bool
+	// pending is the status code being written while the BeforeWrite
+	// callbacks run.
+	pending int
+	now     func() time.Time
+	before  []func(http.ResponseWriter)
+	after   []func(ResponseInfo)
+}
+
+// RecordResponses makes the chain install a ResponseRecorder around the
+// ResponseWriter for each request, before any middleware run. It is
+// required by the response hooks, BeforeWrite and OnFinish.
+func (c Chain) RecordResponses() Chain {
+	c.record = true
+	return c
+}
+
+// Response returns the ResponseRecorder for the current request, or nil if
+// the chain doesn't record responses.
+func Response(ctx *Context) *ResponseRecorder {
+	return ctx.response
+}
+
+func newResponseRecorder(w http.ResponseWriter, now func() time.Time) *ResponseRecorder {
+	return &ResponseRecorder{ResponseWriter: w, start: now(), now: now}
+}
+
+// Status returns the status code sent to the client, or zero if the
+// response hasn't been started.
+func (rr *ResponseRecorder) Status() int {
+	return rr.status
+}
+
+// SetPendingStatus changes the status code about to be sent, and can only
+// be used by BeforeWrite callbacks. It lets a callback which finds at the
+// last moment that the request failed, such as one committing a
+// transaction, send an error status instead. It has no effect at other
+// times.
+func (rr *ResponseRecorder) SetPendingStatus(code int) {
+	if rr.pending != 0 {
+		rr.pending = code
+	}
+}
+
+// Size returns the number of body bytes written so far.
+func (rr *ResponseRecorder) Size() int64 {
+	return rr.size
+}
+
+// Written reports whether the response has been started.
+func (rr *ResponseRecorder) Written() bool {
+	return rr.status != 0
+}
+
+// Start returns the time at which the chain started handling the request.
+func (rr *ResponseRecorder) Start() time.Time {
+	return rr.start
+}
+
+// FirstByte returns the time at which the response headers were written,
+// or the zero Time if they haven't been.
+func (rr *ResponseRecorder) FirstByte() time.Time {
+	return rr.firstByte
+}
+
+// LastWrite returns the time of the most recent write to the body, or the
+// zero Time if nothing has been written.
+func (rr *ResponseRecorder) LastWrite() time.Time {
+	return rr.lastWrite
+}
+
+func (rr *ResponseRecorder) WriteHeader(code int) {
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		rr.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if rr.status == 0 {
+		rr.pending = code
+		rr.runBefore()
+		code, rr.pending = rr.pending, 0
+		rr.status = code
+		rr.firstByte = rr.now()
+	}
+	rr.ResponseWriter.WriteHeader(code)
+}
+
+func (rr *ResponseRecorder) Write(p []byte) (int, error) {
+	if rr.status == 0 {
+		rr.WriteHeader(http.StatusOK)
+	}
+	n, err := rr.ResponseWriter.Write(p)
+	rr.size += int64(n)
+	rr.lastWrite = rr.now()
+	return n, err
+}
+
+func (rr *ResponseRecorder) ReadFrom(src io.Reader) (int64, error) {
+	if rr.status == 0 {
+		rr.WriteHeader(http.StatusOK)
+	}
+	var n int64
+	var err error
+	if rf, ok := rr.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(src)
+	} else {
+		n, err = io.Copy(writerOnly{rr.ResponseWriter}, src)
+	}
+	rr.size += n
+	rr.lastWrite = rr.now()
+	return n, err
+}
+
+func (rr *ResponseRecorder) Flush() {
+	if rr.status == 0 {
+		rr.WriteHeader(http.StatusOK)
+	}
+	if f, ok := rr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Empty reports whether the handler sent a body-less response on purpose,
+// with NoContent or Status.
+func (rr *ResponseRecorder) Empty() bool {
+	return rr.empty
+}
+
+// Hijacked reports whether the connection has been hijacked, such as for
+// a WebSocket upgrade.
+func (rr *ResponseRecorder) Hijacked() bool {
+	return rr.hijacked
+}
+
+func (rr *ResponseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := hijack(rr.ResponseWriter)
+	if err == nil {
+		rr.hijacked = true
+		// The handler writes its own response on a hijacked connection, so
+		// there's no point at which the hooks could run.
+		rr.before = nil
+	}
+	return conn, brw, err
+}
+
+// Unwrap returns the underlying ResponseWriter.
+func (rr *ResponseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
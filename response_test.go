@@ -0,0 +1,81 @@
+package stack
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func responseLogger(log *string) chainMiddleware {
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			rr := Response(ctx)
+			*log = fmt.Sprintf("status=%d size=%d written=%v", rr.Status(), rr.Size(), rr.Written())
+			if rr.Written() && (rr.FirstByte().Before(rr.Start()) || rr.LastWrite().Before(rr.FirstByte())) {
+				*log += " bad timestamps"
+			}
+		})
+	}
+}
+
+func recordResponse(fn func(ctx *Context, w http.ResponseWriter, r *http.Request)) (*httptest.ResponseRecorder, string) {
+	var log string
+	st := New(responseLogger(&log)).RecordResponses().Then(fn)
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	return rec, log
+}
+
+func TestResponseRecorder(t *testing.T) {
+	rec, log := recordResponse(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.WriteHeader(http.StatusTeapot)
+		fmt.Fprint(w, "bish")
+		w.(http.Flusher).Flush()
+	})
+	assertEquals(t, 201, rec.Code)
+	assertEquals(t, true, rec.Flushed)
+	assertEquals(t, "status=201 size=4 written=true", log)
+
+	rec, log = recordResponse(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, strings.NewReader("bish bash bosh"))
+	})
+	assertEquals(t, "bish bash bosh", rec.Body.String())
+	assertEquals(t, "status=200 size=14 written=true", log)
+
+	_, log = recordResponse(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusEarlyHints)
+	})
+	assertEquals(t, "status=0 size=0 written=false", log)
+}
+
+func TestResponseWithoutRecording(t *testing.T) {
+	st := New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, Response(ctx) == nil)
+	})
+	assertEquals(t, "true", serveAndRequest(st))
+}
+
+func TestResponseRecorderHijack(t *testing.T) {
+	hijacked := make(chan bool, 1)
+	st := New(func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			hijacked <- Response(ctx).Hijacked()
+		})
+	}).RecordResponses().ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	})
+	ts := httptest.NewServer(st)
+	defer ts.Close()
+	http.Get(ts.URL)
+	assertEquals(t, true, <-hijacked)
+}
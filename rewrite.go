@@ -0,0 +1,215 @@
+package stack
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const rewriteRuleKey = "stack.rewriteRule"
+
+// RewriteRule maps request paths matching a pattern to a new path. Rules
+// are created with RewritePrefix, RewritePattern or RewriteRegexp, and
+// rewrite the request's URL unless Redirect is used.
+type RewriteRule struct {
+	desc     string
+	apply    func(p string) (string, bool)
+	redirect int
+}
+
+// RewritePrefix matches paths beginning with the segments in prefix, and
+// replaces the prefix with replacement, keeping the rest of the path. An
+// empty replacement strips the prefix, so that with
+// RewritePrefix("/api/v1", "") a request for /api/v1/users is routed as
+// /users.
+func RewritePrefix(prefix, replacement string) RewriteRule {
+	prefix = strings.TrimSuffix(prefix, "/")
+	replacement = strings.TrimSuffix(replacement, "/")
+	return RewriteRule{
+		desc: prefix + " -> " + replacement,
+		apply: func(p string) (string, bool) {
+			if p != prefix && !strings.HasPrefix(p, prefix+"/") {
+				return "", false
+			}
+			p = replacement + p[len(prefix):]
+			if !strings.HasPrefix(p, "/") {
+				p = "/" + p
+			}
+			return p, true
+		},
+	}
+}
+
+// RewritePattern matches paths against a pattern written as for
+// Router.Handle, with {name} matching a single segment and a final
+// {name...} matching the rest of the path, and builds the new path by
+// substituting the matched values into replacement:
+//
+//	stack.RewritePattern("/blog/{year}/{slug}", "/posts/{slug}")
+//
+// It panics if replacement uses a parameter the pattern doesn't define.
+func RewritePattern(pattern, replacement string) RewriteRule {
+	if !strings.HasPrefix(pattern, "/") {
+		panic("stack: rewrite pattern must begin with '/': " + pattern)
+	}
+	var expr strings.Builder
+	expr.WriteString("^")
+	names := make(map[string]bool)
+	segs := strings.Split(pattern[1:], "/")
+	for i, seg := range segs {
+		expr.WriteString("/")
+		switch {
+		case len(seg) > 5 && seg[0] == '{' && strings.HasSuffix(seg, "...}"):
+			if i != len(segs)-1 {
+				panic("stack: catch-all parameter must be the last segment in rewrite pattern: " + pattern)
+			}
+			name := seg[1 : len(seg)-4]
+			names[name] = true
+			fmt.Fprintf(&expr, "(?P<%s>.*)", name)
+		case len(seg) > 2 && seg[0] == '{' && seg[len(seg)-1] == '}':
+			name := seg[1 : len(seg)-1]
+			names[name] = true
+			fmt.Fprintf(&expr, "(?P<%s>[^/]+)", name)
+		default:
+			expr.WriteString(regexp.QuoteMeta(seg))
+		}
+	}
+	expr.WriteString("$")
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		panic("stack: invalid rewrite pattern " + pattern + ": " + err.Error())
+	}
+
+	var template strings.Builder
+	rest := replacement
+	for {
+		i := strings.IndexByte(rest, '{')
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(rest[i+1:], '}')
+		if j < 0 {
+			break
+		}
+		name := rest[i+1 : i+1+j]
+		if !names[name] {
+			panic("stack: rewrite replacement uses unknown parameter " + name + ": " + replacement)
+		}
+		template.WriteString(strings.ReplaceAll(rest[:i], "$", "$$"))
+		template.WriteString("${" + name + "}")
+		rest = rest[i+j+2:]
+	}
+	template.WriteString(strings.ReplaceAll(rest, "$", "$$"))
+
+	return regexpRule(pattern+" -> "+replacement, re, template.String())
+}
+
+// RewriteRegexp matches paths against the regular expression expr, and
+// builds the new path by expanding replacement as for
+// regexp.Regexp.Expand, so that $1 or ${name} refer to submatches:
+//
+//	stack.RewriteRegexp(`^/item\.php/(\d+)$`, "/items/$1")
+//
+// The expression isn't anchored unless it says so. RewriteRegexp panics
+// if expr doesn't compile.
+func RewriteRegexp(expr, replacement string) RewriteRule {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		panic("stack: invalid rewrite expression " + expr + ": " + err.Error())
+	}
+	return regexpRule(expr+" -> "+replacement, re, replacement)
+}
+
+func regexpRule(desc string, re *regexp.Regexp, template string) RewriteRule {
+	return RewriteRule{
+		desc: desc,
+		apply: func(p string) (string, bool) {
+			m := re.FindStringSubmatchIndex(p)
+			if m == nil {
+				return "", false
+			}
+			return string(re.ExpandString(nil, template, p, m)), true
+		},
+	}
+}
+
+// Redirect returns a copy of the rule which redirects the client to the
+// new path with the given status code (such as 301 Moved Permanently),
+// rather than rewriting the request. The request's query string is kept.
+// It panics if status isn't a 3xx code.
+func (rr RewriteRule) Redirect(status int) RewriteRule {
+	if status < 300 || status > 399 {
+		panic(fmt.Sprintf("stack: invalid redirect status %d for rewrite rule %s", status, rr.desc))
+	}
+	rr.redirect = status
+	return rr
+}
+
+// String describes the rule, as recorded by the Rewrite middleware.
+func (rr RewriteRule) String() string {
+	return rr.desc
+}
+
+// Rewrite returns middleware which applies the first of rules that matches
+// the request path, before the rest of the chain (and so any Router) sees
+// the request. Rules which rewrite the request pass the rest of the chain
+// a copy with the new URL, merging any query string in the new path with
+// the request's own; rules made with Redirect send the client to the new
+// URL instead. The rule which was applied can be retrieved with
+// AppliedRewrite, which is useful when debugging rule sets.
+func Rewrite(rules ...RewriteRule) chainMiddleware {
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, rule := range rules {
+				target, ok := rule.apply(r.URL.Path)
+				if !ok {
+					continue
+				}
+				ctx.Put(rewriteRuleKey, rule.desc)
+				p, query, _ := strings.Cut(target, "?")
+				// A path beginning "//" would be taken as a host by
+				// Redirect (and by browsers), so collapse the leading
+				// slashes; browsers treat backslashes the same way.
+				p = "/" + strings.TrimLeft(p, "/\\")
+				query = mergeQuery(query, r.URL.RawQuery)
+				if rule.redirect != 0 {
+					u := url.URL{Path: p, RawQuery: query}
+					Redirect(ctx, w, r, rule.redirect, u.String())
+					return
+				}
+				// Rewrite a copy, so that earlier middleware (such as
+				// loggers) still see the URL the client asked for.
+				r2 := new(http.Request)
+				*r2 = *r
+				r2.URL = new(url.URL)
+				*r2.URL = *r.URL
+				r2.URL.Path = p
+				r2.URL.RawPath = ""
+				r2.URL.RawQuery = query
+				r = r2
+				break
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// AppliedRewrite returns a description of the rule the Rewrite middleware
+// applied to the request, in the form "pattern -> replacement". It
+// returns an empty string if no rule matched.
+func AppliedRewrite(ctx *Context) string {
+	s, _ := ctx.Get(rewriteRuleKey).(string)
+	return s
+}
+
+func mergeQuery(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	}
+	return a + "&" + b
+}
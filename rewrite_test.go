@@ -0,0 +1,104 @@
+package stack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	st := New(Rewrite(
+		RewritePrefix("/api/v1/", ""),
+		RewritePrefix("/legacy", "/current"),
+		RewritePattern("/blog/{year}/{slug}", "/posts/{slug}?year={year}"),
+		RewritePattern("/docs/{path...}", "/manual/{path}").Redirect(301),
+		RewriteRegexp(`^/item\.php/(\d+)$`, "/items/$1"),
+	)).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RequestURI() + " " + AppliedRewrite(ctx)))
+	})
+
+	tests := []struct {
+		path     string
+		code     int
+		body     string
+		location string
+	}{
+		{"/bish", 200, "/bish ", ""},
+		{"/api/v1", 200, "/ /api/v1 -> ", ""},
+		{"/api/v1/users?page=2", 200, "/users?page=2 /api/v1 -> ", ""},
+		{"/api/v1beta/users", 200, "/api/v1beta/users ", ""},
+		{"/legacy/bish", 200, "/current/bish /legacy -> /current", ""},
+		{"/blog/2020/bash?ref=x", 200, "/posts/bash?year=2020&ref=x /blog/{year}/{slug} -> /posts/{slug}?year={year}", ""},
+		{"/blog/2020/bash/bosh", 200, "/blog/2020/bash/bosh ", ""},
+		{"/item.php/42", 200, "/items/42 " + `^/item\.php/(\d+)$ -> /items/$1`, ""},
+		{"/docs/a/b?v=1", 301, "", "http://example.com/manual/a/b?v=1"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", "http://example.com"+test.path, nil)
+		rec := httptest.NewRecorder()
+		st.ServeHTTP(rec, r)
+		assertEquals(t, test.code, rec.Code)
+		assertEquals(t, test.location, rec.Header().Get("Location"))
+		if test.code == 200 {
+			assertEquals(t, test.body, rec.Body.String())
+		}
+	}
+}
+
+func TestRewriteBeforeRouting(t *testing.T) {
+	rt := NewRouter()
+	rt.Handle("GET", "/users/{id}", New().ThenHandlerFunc(pathHandler))
+	st := New(Rewrite(RewritePattern("/members/{id}", "/users/{id}"))).ThenHandler(rt)
+
+	r, _ := http.NewRequest("GET", "http://example.com/members/7", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "/users/7", rec.Body.String())
+}
+
+func TestRewriteRedirectStaysOnHost(t *testing.T) {
+	st := New(Rewrite(
+		RewritePrefix("/old", "").Redirect(301),
+		RewriteRegexp(`^/back(.*)$`, "$1").Redirect(301),
+	)).Then(bishHandler)
+
+	for path, location := range map[string]string{
+		"/old//evil.com/x":   "http://example.com/evil.com/x",
+		"/back/%5Cevil.com/": "http://example.com/evil.com/",
+	} {
+		r, _ := http.NewRequest("GET", "http://example.com"+path, nil)
+		rec := httptest.NewRecorder()
+		st.ServeHTTP(rec, r)
+		assertEquals(t, 301, rec.Code)
+		assertEquals(t, location, rec.Header().Get("Location"))
+	}
+}
+
+func TestRewriteCopiesRequest(t *testing.T) {
+	var inner string
+	outer := func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			w.Write([]byte(" " + r.URL.RequestURI()))
+		})
+	}
+	st := New(outer, Rewrite(RewritePrefix("/bish", "/bash"))).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inner = r.URL.Path
+		w.Write([]byte(r.URL.RequestURI()))
+	})
+
+	r, _ := http.NewRequest("GET", "http://example.com/bish/x?y=1", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, "/bash/x", inner)
+	assertEquals(t, "/bash/x?y=1 /bish/x?y=1", rec.Body.String())
+	assertEquals(t, "/bish/x", r.URL.Path)
+}
+
+func TestRewritePatternUnknownParameter(t *testing.T) {
+	defer func() {
+		assertEquals(t, true, recover() != nil)
+	}()
+	RewritePattern("/bish/{id}", "/bash/{name}")
+}
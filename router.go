@@ -0,0 +1,516 @@
+package stack
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	paramsKey = "stack.params"
+	routesKey = "stack.routes"
+)
+
+// Router is a request multiplexer which dispatches to HandlerChains by
+// method and path pattern. Patterns are made up of literal segments and
+// {name} segments, which match any single path segment and make its value
+// available through Param:
+//
+//	rt := stack.NewRouter()
+//	rt.Get("/users/{id}", stack.New(auth).Then(showUser))
+//
+// A final {name...} segment matches the rest of the path, including any
+// slashes. Literal segments take priority over parameters, and parameters
+// over catch-alls, so "/users/new" can be registered alongside
+// "/users/{id}". HEAD requests are served by the GET
+// chain for a path unless a HEAD route is registered, with the body
+// discarded and its length sent as the Content-Length.
+type Router struct {
+	*routes
+	base []namedMiddleware
+}
+
+type namedMiddleware struct {
+	name string
+	mw   chainMiddleware
+}
+
+type routes struct {
+	root             *node
+	names            map[string]string
+	endpoints        []*Endpoint
+	mounts           []mount
+	notFound         HandlerChain
+	methodNotAllowed HandlerChain
+	options          HandlerChain
+	spa              http.Handler
+}
+
+type node struct {
+	static    map[string]*node
+	param     *node
+	paramName string
+	catchAll  *node
+	chains    map[string]HandlerChain
+}
+
+// NewRouter returns a new, empty Router.
+func NewRouter() *Router {
+	return &Router{routes: &routes{
+		root:             &node{},
+		names:            make(map[string]string),
+		notFound:         errorChain(http.StatusNotFound),
+		methodNotAllowed: errorChain(http.StatusMethodNotAllowed),
+		options: New().ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}),
+	}}
+}
+
+func errorChain(status int) HandlerChain {
+	return New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		Error(ctx, w, r, NewHTTPError(status, nil))
+	})
+}
+
+// Endpoint is a route registered with a Router. Its methods adjust the
+// route's chain, which is recomposed from the Router's base middleware, the
+// route's own middleware and the registered HandlerChain each time one of
+// them is called.
+type Endpoint struct {
+	rt      *Router
+	n       *node
+	method  string
+	pattern string
+	name    string
+	hc      HandlerChain
+	base    []namedMiddleware
+	extra   []chainMiddleware
+	skip    map[string]bool
+}
+
+// Name names the route so that URLs for it can be generated with URL. It
+// panics if the name is already in use by the Router.
+func (ep *Endpoint) Name(name string) *Endpoint {
+	if _, exists := ep.rt.names[name]; exists {
+		panic("stack: route name already in use: " + name)
+	}
+	ep.rt.names[name] = ep.pattern
+	ep.name = name
+	return ep
+}
+
+// Use adds mws to the route, running them after the Router's base
+// middleware and before the registered chain's own middleware.
+func (ep *Endpoint) Use(mws ...chainMiddleware) *Endpoint {
+	ep.extra = appendMiddleware(ep.extra, mws)
+	ep.compose()
+	return ep
+}
+
+// Skip removes the named middleware (added with Router.Use) from the
+// route's chain. It panics if the Router has no middleware with one of the
+// names, as that usually means a typo.
+func (ep *Endpoint) Skip(names ...string) *Endpoint {
+	for _, name := range names {
+		found := false
+		for _, nm := range ep.base {
+			found = found || nm.name == name
+		}
+		if !found {
+			panic("stack: no middleware named " + name)
+		}
+		ep.skip[name] = true
+	}
+	ep.compose()
+	return ep
+}
+
+func (ep *Endpoint) compose() {
+	mws := make([]chainMiddleware, 0, len(ep.base)+len(ep.extra))
+	for _, nm := range ep.base {
+		if nm.name == "" || !ep.skip[nm.name] {
+			mws = append(mws, nm.mw)
+		}
+	}
+	ep.n.chains[ep.method] = prependMiddleware(ep.hc, append(mws, ep.extra...))
+}
+
+// Handle registers hc for requests with the given method and path
+// pattern. The Router's base middleware run before the chain's own
+// middleware. It panics if the pattern is already registered
+// for the method.
+func (rt *Router) Handle(method, pattern string, hc HandlerChain) *Endpoint {
+	if !strings.HasPrefix(pattern, "/") {
+		panic("stack: route pattern must begin with '/': " + pattern)
+	}
+	n := rt.root
+	segs := strings.Split(pattern[1:], "/")
+	for i, seg := range segs {
+		if len(seg) > 5 && seg[0] == '{' && strings.HasSuffix(seg, "...}") {
+			if i != len(segs)-1 {
+				panic("stack: catch-all parameter must be the last segment in route pattern: " + pattern)
+			}
+			name := seg[1 : len(seg)-4]
+			if n.catchAll == nil {
+				n.catchAll = &node{paramName: name}
+			} else if n.catchAll.paramName != name {
+				panic("stack: conflicting parameter names in route pattern: " + pattern)
+			}
+			n = n.catchAll
+			continue
+		}
+		if len(seg) > 2 && seg[0] == '{' && seg[len(seg)-1] == '}' {
+			name := seg[1 : len(seg)-1]
+			if n.param == nil {
+				n.param = &node{paramName: name}
+			} else if n.param.paramName != name {
+				panic("stack: conflicting parameter names in route pattern: " + pattern)
+			}
+			n = n.param
+			continue
+		}
+		if n.static == nil {
+			n.static = make(map[string]*node)
+		}
+		child, ok := n.static[seg]
+		if !ok {
+			child = &node{}
+			n.static[seg] = child
+		}
+		n = child
+	}
+	if n.chains == nil {
+		n.chains = make(map[string]HandlerChain)
+	}
+	method = strings.ToUpper(method)
+	if _, exists := n.chains[method]; exists {
+		panic("stack: route already registered: " + method + " " + pattern)
+	}
+	ep := &Endpoint{
+		rt:      rt,
+		n:       n,
+		method:  method,
+		pattern: pattern,
+		hc:      hc,
+		base:    rt.base,
+		skip:    make(map[string]bool),
+	}
+	ep.compose()
+	rt.endpoints = append(rt.endpoints, ep)
+	return ep
+}
+
+// RouteInfo describes a route registered with a Router.
+type RouteInfo struct {
+	Method  string
+	Pattern string
+	// Name is the name given to the route with Endpoint.Name, if any.
+	Name string
+	// Middleware lists the names of the Router's named base middleware
+	// which run for the route, in order.
+	Middleware []string
+}
+
+// Routes returns the routes registered with the Router (and any Router
+// created from it with Group), sorted by pattern and then method.
+func (rt *Router) Routes() []RouteInfo {
+	infos := make([]RouteInfo, len(rt.endpoints))
+	for i, ep := range rt.endpoints {
+		var names []string
+		for _, nm := range ep.base {
+			if nm.name != "" && !ep.skip[nm.name] {
+				names = append(names, nm.name)
+			}
+		}
+		infos[i] = RouteInfo{Method: ep.method, Pattern: ep.pattern, Name: ep.name, Middleware: names}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Pattern != infos[j].Pattern {
+			return infos[i].Pattern < infos[j].Pattern
+		}
+		return infos[i].Method < infos[j].Method
+	})
+	return infos
+}
+
+// Get registers hc for GET requests matching pattern.
+func (rt *Router) Get(pattern string, hc HandlerChain) *Endpoint {
+	return rt.Handle("GET", pattern, hc)
+}
+
+// Post registers hc for POST requests matching pattern.
+func (rt *Router) Post(pattern string, hc HandlerChain) *Endpoint {
+	return rt.Handle("POST", pattern, hc)
+}
+
+// Put registers hc for PUT requests matching pattern.
+func (rt *Router) Put(pattern string, hc HandlerChain) *Endpoint {
+	return rt.Handle("PUT", pattern, hc)
+}
+
+// Patch registers hc for PATCH requests matching pattern.
+func (rt *Router) Patch(pattern string, hc HandlerChain) *Endpoint {
+	return rt.Handle("PATCH", pattern, hc)
+}
+
+// Delete registers hc for DELETE requests matching pattern.
+func (rt *Router) Delete(pattern string, hc HandlerChain) *Endpoint {
+	return rt.Handle("DELETE", pattern, hc)
+}
+
+// Use adds a named middleware to the Router's base chain, which runs
+// before the chain of every route registered afterwards. Routes can opt
+// out of it by name with Endpoint.Skip:
+//
+//	rt.Use("auth", requireLogin)
+//	rt.Get("/login", stack.New().Then(showLogin)).Skip("auth")
+func (rt *Router) Use(name string, mw chainMiddleware) {
+	rt.base = append(rt.base[:len(rt.base):len(rt.base)], namedMiddleware{name, Named(name, mw)})
+}
+
+// Group returns a Router which registers routes in the same table as rt,
+// with a base chain made up of rt's base chain followed by mws.
+func (rt *Router) Group(mws ...chainMiddleware) *Router {
+	base := make([]namedMiddleware, len(rt.base), len(rt.base)+len(mws))
+	copy(base, rt.base)
+	for _, mw := range mws {
+		base = append(base, namedMiddleware{mw: mw})
+	}
+	return &Router{routes: rt.routes, base: base}
+}
+
+func (rt *Router) baseMiddleware() []chainMiddleware {
+	mws := make([]chainMiddleware, len(rt.base))
+	for i, nm := range rt.base {
+		mws[i] = nm.mw
+	}
+	return mws
+}
+
+// NotFound sets the chain used when no route or mount matches the request
+// path, making it a catch-all for the Router. By default a 404 is passed to
+// the chain's error handler.
+func (rt *Router) NotFound(hc HandlerChain) {
+	rt.notFound = hc
+}
+
+// MethodNotAllowed sets the chain used when a route matches the request
+// path but not its method. The Allow header is set before the chain runs.
+// By default a 405 is passed to the chain's error handler.
+func (rt *Router) MethodNotAllowed(hc HandlerChain) {
+	rt.methodNotAllowed = hc
+}
+
+// SPA sets the chain used instead of the NotFound chain for unmatched GET
+// and HEAD requests which explicitly accept text/html, such as page loads
+// by a single-page application that does its own client-side routing. The
+// chain usually serves the application's index.html:
+//
+//	rt.SPA(stack.New().ThenFiles(http.Dir("dist"), stack.FileSPA("/index.html")))
+//
+// Other unmatched requests, like API calls made with fetch, still reach the
+// NotFound chain.
+func (rt *Router) SPA(hc HandlerChain) {
+	rt.spa = hc
+}
+
+// acceptsHTML reports whether the request's Accept header lists text/html
+// (rather than only matching it with a wildcard).
+func acceptsHTML(r *http.Request) bool {
+	for _, qv := range parseQualityList(r.Header.Get("Accept")) {
+		if qv.value == "text/html" && qv.quality > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// AutoOptions sets the chain used to answer OPTIONS requests for paths
+// which have no OPTIONS route of their own. The Allow header is set before
+// the chain runs. By default a 204 No Content is sent.
+func (rt *Router) AutoOptions(hc HandlerChain) {
+	rt.options = hc
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/") {
+		rt.notFound.ServeHTTP(w, r)
+		return
+	}
+	params := make(map[string]string)
+	segs := strings.Split(r.URL.Path[1:], "/")
+	// Prefer a route which handles the method, so that a literal segment
+	// registered for other methods doesn't shadow a parameter. Failing
+	// that, any route for the path gives a 405 with the right Allow
+	// header.
+	n := rt.root.match(segs, params, func(n *node) bool {
+		_, ok := n.chains[r.Method]
+		if !ok && r.Method == "HEAD" {
+			_, ok = n.chains["GET"]
+		}
+		return ok
+	})
+	if n == nil {
+		n = rt.root.match(segs, params, func(n *node) bool { return n.chains != nil })
+	}
+	if n == nil {
+		if h := rt.matchMount(r.URL.Path); h != nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if rt.spa != nil && (r.Method == "GET" || r.Method == "HEAD") && acceptsHTML(r) {
+			rt.spa.ServeHTTP(w, r)
+			return
+		}
+		rt.notFound.ServeHTTP(w, r)
+		return
+	}
+	hc, ok := n.chains[r.Method]
+	if !ok && r.Method == "HEAD" {
+		if hc, ok = n.chains["GET"]; ok {
+			hw := &headWriter{ResponseWriter: w}
+			hc.serve(PreserveInterfaces(hw), r, rt.initContext(r, params))
+			hw.finish()
+			return
+		}
+	}
+	if !ok {
+		w.Header().Set("Allow", n.allow())
+		if r.Method == "OPTIONS" {
+			rt.options.ServeHTTP(w, r)
+			return
+		}
+		rt.methodNotAllowed.ServeHTTP(w, r)
+		return
+	}
+	hc.serve(w, r, rt.initContext(r, params))
+}
+
+// initContext returns a function which stores the path parameters and the
+// information needed by URL in a route's Context.
+func (rt *Router) initContext(r *http.Request, params map[string]string) func(*Context) {
+	return func(ctx *Context) {
+		ctx.Put(paramsKey, params)
+		ctx.Put(routesKey, rt.routes)
+		if prefix := enclosingPrefix(r); prefix != "" {
+			ctx.Put(mountPrefixKey, prefix)
+		}
+	}
+}
+
+// allow returns the value of the Allow header for the node's routes.
+func (n *node) allow() string {
+	methods := make([]string, 0, len(n.chains)+1)
+	for method := range n.chains {
+		methods = append(methods, method)
+	}
+	if _, ok := n.chains["OPTIONS"]; !ok {
+		methods = append(methods, "OPTIONS")
+	}
+	_, get := n.chains["GET"]
+	if _, head := n.chains["HEAD"]; get && !head {
+		methods = append(methods, "HEAD")
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+// match finds the node for segs which is accepted by ok, preferring
+// literal segments over parameters and backtracking when a branch leads
+// nowhere.
+func (n *node) match(segs []string, params map[string]string, ok func(*node) bool) *node {
+	if len(segs) == 0 {
+		if !ok(n) {
+			return nil
+		}
+		return n
+	}
+	seg, rest := segs[0], segs[1:]
+	if child, found := n.static[seg]; found {
+		if m := child.match(rest, params, ok); m != nil {
+			return m
+		}
+	}
+	if n.param != nil && seg != "" {
+		if m := n.param.match(rest, params, ok); m != nil {
+			params[n.param.paramName] = seg
+			return m
+		}
+	}
+	if n.catchAll != nil && ok(n.catchAll) {
+		params[n.catchAll.paramName] = strings.Join(segs, "/")
+		return n.catchAll
+	}
+	return nil
+}
+
+// Param returns the value of the named path parameter for the current
+// request, or an empty string if there isn't one. Parameters are available
+// for chains served by a Router, registered on an http.ServeMux with Route
+// or passed to InjectParams.
+func Param(ctx *Context, name string) string {
+	params, _ := ctx.Get(paramsKey).(map[string]string)
+	return params[name]
+}
+
+// SetParams replaces the path parameters for the current request. Like
+// InjectParams, it is intended for adapters, in this case ones which run
+// as middleware within the chain.
+func SetParams(ctx *Context, params map[string]string) {
+	ctx.Put(paramsKey, params)
+}
+
+// InjectParams returns a copy of hc with params available through Param.
+// It is intended for adapters which serve chains from third-party
+// routers.
+func InjectParams(hc HandlerChain, params map[string]string) HandlerChain {
+	return Inject(hc, paramsKey, params)
+}
+
+var wildcardPattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)(?:\.\.\.)?\}`)
+
+// Route adapts hc for registration on an http.ServeMux, copying the
+// values of any wildcards in pattern into the Context so they can be read
+// with Param:
+//
+//	mux := http.NewServeMux()
+//	mux.Handle(stack.Route("GET /users/{id}", stack.New(auth).Then(showUser)))
+//
+// The pattern is returned unchanged, and uses the http.ServeMux syntax.
+func Route(pattern string, hc HandlerChain) (string, http.Handler) {
+	var names []string
+	for _, m := range wildcardPattern.FindAllStringSubmatch(pattern, -1) {
+		names = append(names, m[1])
+	}
+	return pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := make(map[string]string, len(names))
+		for _, name := range names {
+			params[name] = r.PathValue(name)
+		}
+		hc.serve(w, r, func(ctx *Context) {
+			ctx.Put(paramsKey, params)
+		})
+	})
+}
+
+// prependMiddleware returns a copy of hc which runs mws before its own
+// middleware. Like Then, it panics if the middleware require capabilities
+// which hc's chain doesn't provide.
+func prependMiddleware(hc HandlerChain, mws []chainMiddleware) HandlerChain {
+	if len(mws) == 0 {
+		return hc
+	}
+	hc.mws = appendMiddleware(mws, hc.mws)
+	hc.checkCapabilities()
+	return hc
+}
+
+// appendMiddleware returns a new slice containing a followed by b, so that
+// neither can be mutated through the result.
+func appendMiddleware(a, b []chainMiddleware) []chainMiddleware {
+	mws := make([]chainMiddleware, 0, len(a)+len(b))
+	mws = append(mws, a...)
+	return append(mws, b...)
+}
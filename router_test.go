@@ -0,0 +1,313 @@
+// The package has no go.mod declaring go >= 1.22, so opt in to the
+// ServeMux pattern syntax explicitly for TestRoute.
+
+//go:debug httpmuxgo121=0
+package stack
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func routeTo(rt http.Handler, method, path string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest(method, path, nil)
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, r)
+	return rec
+}
+
+func paramHandler(names ...string) func(*Context, http.ResponseWriter, *http.Request) {
+	return func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		for _, name := range names {
+			fmt.Fprintf(w, "[%s=%s]", name, Param(ctx, name))
+		}
+	}
+}
+
+func TestRouter(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/", New().Then(bishHandler))
+	rt.Get("/users/new", New().ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "new user")
+	}))
+	rt.Get("/users/{id}", New(flipMiddleware).Then(paramHandler("id")))
+	rt.Post("/users/{id}/posts/{post}", New().Then(paramHandler("id", "post")))
+	rt.Get("/users/{id}/", New().Then(paramHandler("id")))
+
+	assertEquals(t, "bishHandler [bish=<nil>]", routeTo(rt, "GET", "/").Body.String())
+	assertEquals(t, "new user", routeTo(rt, "GET", "/users/new").Body.String())
+	assertEquals(t, "flipMiddleware>[id=42]", routeTo(rt, "GET", "/users/42").Body.String())
+	assertEquals(t, "[id=42][post=7]", routeTo(rt, "POST", "/users/42/posts/7").Body.String())
+	assertEquals(t, "[id=42]", routeTo(rt, "GET", "/users/42/").Body.String())
+
+	assertEquals(t, 404, routeTo(rt, "GET", "/users").Code)
+	assertEquals(t, 404, routeTo(rt, "GET", "/users//posts/7").Code)
+	assertEquals(t, 404, routeTo(rt, "GET", "/bish").Code)
+	assertEquals(t, 405, routeTo(rt, "DELETE", "/users/42").Code)
+}
+
+func TestRouterBacktracking(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/files/new/edit", New().Then(paramHandler()))
+	rt.Get("/files/{name}/raw", New().Then(paramHandler("name")))
+
+	// The literal "new" segment matches first but leads nowhere, so the
+	// parameter branch should be tried instead.
+	assertEquals(t, "[name=new]", routeTo(rt, "GET", "/files/new/raw").Body.String())
+}
+
+func TestRouterBacktrackingByMethod(t *testing.T) {
+	rt := NewRouter()
+	rt.Post("/users/new", New().Then(bishHandler))
+	rt.Get("/users/{id}", New().Then(paramHandler("id")))
+
+	assertEquals(t, "[id=new]", routeTo(rt, "GET", "/users/new").Body.String())
+	assertEquals(t, "bishHandler [bish=<nil>]", routeTo(rt, "POST", "/users/new").Body.String())
+	rec := routeTo(rt, "PUT", "/users/new")
+	assertEquals(t, 405, rec.Code)
+	assertEquals(t, "OPTIONS, POST", rec.Header().Get("Allow"))
+}
+
+func TestRouterGroup(t *testing.T) {
+	rt := NewRouter()
+	api := rt.Group(bishMiddleware)
+	api.Get("/api/{id}", New(flipMiddleware).Then(bishHandler))
+	admin := api.Group(flipMiddleware)
+	admin.Get("/admin", New().Then(bishHandler))
+	rt.Get("/public", New().Then(bishHandler))
+
+	assertEquals(t, "bishMiddleware>flipMiddleware>bishHandler [bish=bash]", routeTo(rt, "GET", "/api/1").Body.String())
+	assertEquals(t, "bishMiddleware>flipMiddleware>bishHandler [bish=bash]", routeTo(rt, "GET", "/admin").Body.String())
+	assertEquals(t, "bishHandler [bish=<nil>]", routeTo(rt, "GET", "/public").Body.String())
+}
+
+func TestRouterNotFound(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/bish", New().Then(bishHandler))
+	rt.NotFound(New().ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+		fmt.Fprint(w, "no such page")
+	}))
+	rt.MethodNotAllowed(New().ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(405)
+		fmt.Fprint(w, "wrong method")
+	}))
+
+	res := routeTo(rt, "GET", "/bash")
+	assertEquals(t, 404, res.Code)
+	assertEquals(t, "no such page", res.Body.String())
+	res = routeTo(rt, "PUT", "/bish")
+	assertEquals(t, 405, res.Code)
+	assertEquals(t, "wrong method", res.Body.String())
+}
+
+func TestRouterParamsAreIsolated(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/{a}", Inject(New().Then(paramHandler("a")), "bish", "bash"))
+
+	assertEquals(t, "[a=flip]", routeTo(rt, "GET", "/flip").Body.String())
+	assertEquals(t, "[a=flop]", routeTo(rt, "GET", "/flop").Body.String())
+}
+
+func TestRouterPanics(t *testing.T) {
+	for _, pattern := range []string{"bish", "/{id}/b"} {
+		rt := NewRouter()
+		rt.Get("/{name}/a", New().Then(bishHandler))
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic registering %q", pattern)
+				}
+			}()
+			rt.Get(pattern, New().Then(bishHandler))
+		}()
+	}
+	rt := NewRouter()
+	rt.Get("/bish", New().Then(bishHandler))
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic registering duplicate route")
+		}
+	}()
+	rt.Get("/bish", New().Then(bishHandler))
+}
+
+func TestRoute(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle(Route("GET /users/{id}/files/{path...}", New(flipMiddleware).Then(paramHandler("id", "path"))))
+	mux.Handle(Route("/{$}", New().Then(paramHandler("id"))))
+
+	assertEquals(t, "flipMiddleware>[id=42][path=a/b.txt]", routeTo(mux, "GET", "/users/42/files/a/b.txt").Body.String())
+	assertEquals(t, "[id=]", routeTo(mux, "GET", "/").Body.String())
+	assertEquals(t, 405, routeTo(mux, "POST", "/users/42/files/a").Code)
+}
+
+func TestRouterAllow(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/users/{id}", New().Then(bishHandler))
+	rt.Delete("/users/{id}", New().Then(bishHandler))
+	rt.Get("/bish", New().Then(bishHandler))
+	rt.Handle("OPTIONS", "/bish", New().ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "custom options")
+	}))
+
+	res := routeTo(rt, "PUT", "/users/42")
+	assertEquals(t, 405, res.Code)
+	assertEquals(t, "DELETE, GET, HEAD, OPTIONS", res.Header().Get("Allow"))
+
+	res = routeTo(rt, "OPTIONS", "/users/42")
+	assertEquals(t, 204, res.Code)
+	assertEquals(t, "DELETE, GET, HEAD, OPTIONS", res.Header().Get("Allow"))
+
+	res = routeTo(rt, "OPTIONS", "/bish")
+	assertEquals(t, "custom options", res.Body.String())
+	res = routeTo(rt, "POST", "/bish")
+	assertEquals(t, "GET, HEAD, OPTIONS", res.Header().Get("Allow"))
+
+	assertEquals(t, 404, routeTo(rt, "OPTIONS", "/bash").Code)
+}
+
+func TestRouterAutoOptions(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/bish", New().Then(bishHandler))
+	rt.AutoOptions(New().ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Methods", w.Header().Get("Allow"))
+		w.WriteHeader(200)
+	}))
+
+	res := routeTo(rt, "OPTIONS", "/bish")
+	assertEquals(t, 200, res.Code)
+	assertEquals(t, "GET, HEAD, OPTIONS", res.Header().Get("Access-Control-Allow-Methods"))
+}
+
+func TestRouterHead(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/users/{id}", New(flipMiddleware).Then(paramHandler("id")))
+	rt.Get("/stream", New().ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "bish")
+		w.(http.Flusher).Flush()
+		fmt.Fprint(w, "bash")
+	}))
+	rt.Get("/empty", New().ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(204)
+	}))
+	rt.Get("/bish", New().Then(bishHandler))
+	rt.Handle("HEAD", "/bish", New().ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Head", "custom")
+	}))
+
+	res := routeTo(rt, "HEAD", "/users/42")
+	assertEquals(t, 200, res.Code)
+	assertEquals(t, "", res.Body.String())
+	assertEquals(t, "22", res.Header().Get("Content-Length"))
+
+	res = routeTo(rt, "HEAD", "/stream")
+	assertEquals(t, "", res.Body.String())
+	assertEquals(t, "", res.Header().Get("Content-Length"))
+	assertEquals(t, true, res.Flushed)
+
+	res = routeTo(rt, "HEAD", "/empty")
+	assertEquals(t, 204, res.Code)
+	assertEquals(t, "", res.Header().Get("Content-Length"))
+
+	res = routeTo(rt, "HEAD", "/bish")
+	assertEquals(t, "custom", res.Header().Get("X-Head"))
+	assertEquals(t, "GET, HEAD, OPTIONS", routeTo(rt, "PUT", "/bish").Header().Get("Allow"))
+}
+
+func TestRouterBaseChain(t *testing.T) {
+	rt := NewRouter()
+	rt.Use("bish", bishMiddleware)
+	rt.Use("flip", flipMiddleware)
+	rt.Get("/all", New().Then(bishHandler))
+	rt.Get("/skip", New().Then(bishHandler)).Skip("bish")
+	rt.Get("/extra", New(Adapt(wobbleMiddleware)).Then(bishHandler)).Skip("flip").Use(flipMiddleware, flipMiddleware)
+	api := rt.Group(Adapt(wobbleMiddleware))
+	api.Get("/api", New().Then(bishHandler)).Skip("bish", "flip")
+	rt.Use("late", flipMiddleware)
+	api.Get("/api/late", New().Then(bishHandler))
+
+	assertEquals(t, "bishMiddleware>flipMiddleware>bishHandler [bish=bash]", routeTo(rt, "GET", "/all").Body.String())
+	assertEquals(t, "flipMiddleware>bishHandler [bish=<nil>]", routeTo(rt, "GET", "/skip").Body.String())
+	assertEquals(t, "bishMiddleware>flipMiddleware>flipMiddleware>wobbleMiddleware>bishHandler [bish=bash]", routeTo(rt, "GET", "/extra").Body.String())
+	assertEquals(t, "wobbleMiddleware>bishHandler [bish=<nil>]", routeTo(rt, "GET", "/api").Body.String())
+	assertEquals(t, "bishMiddleware>flipMiddleware>wobbleMiddleware>bishHandler [bish=bash]", routeTo(rt, "GET", "/api/late").Body.String())
+}
+
+func TestRouterSkipUnknown(t *testing.T) {
+	rt := NewRouter()
+	rt.Use("bish", bishMiddleware)
+	ep := rt.Get("/", New().Then(bishHandler))
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic skipping unknown middleware")
+		}
+	}()
+	ep.Skip("bash")
+}
+
+func TestRouterCatchAll(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/files/{path...}", New().Then(paramHandler("path"))).Name("file")
+	rt.Get("/files/{id}/meta", New().Then(paramHandler("id")))
+	rt.Get("/files/readme", New().Then(paramHandler()))
+	rt.Get("/link", urlHandler("file", "path", "a b/c.txt"))
+
+	assertEquals(t, "[path=a/b/c.txt]", routeTo(rt, "GET", "/files/a/b/c.txt").Body.String())
+	assertEquals(t, "[path=]", routeTo(rt, "GET", "/files/").Body.String())
+	assertEquals(t, "[id=42]", routeTo(rt, "GET", "/files/42/meta").Body.String())
+	assertEquals(t, "", routeTo(rt, "GET", "/files/readme").Body.String())
+	assertEquals(t, "[path=readme/raw]", routeTo(rt, "GET", "/files/readme/raw").Body.String())
+	assertEquals(t, 404, routeTo(rt, "GET", "/files").Code)
+	assertEquals(t, "/files/a%20b/c.txt", routeTo(rt, "GET", "/link").Body.String())
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic registering catch-all before the last segment")
+		}
+	}()
+	rt.Get("/bish/{rest...}/bash", New().Then(bishHandler))
+}
+
+func TestRouterSPA(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/api/users", New().Then(bishHandler))
+	rt.SPA(New().ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "index.html")
+	}))
+	rt.NotFound(New().ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(404)
+		fmt.Fprint(w, `{"error":"not found"}`)
+	}))
+
+	request := func(method, path, accept string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, path, nil)
+		r.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, r)
+		return rec
+	}
+	assertEquals(t, "index.html", request("GET", "/users/42", "text/html,application/xhtml+xml,*/*;q=0.8").Body.String())
+	assertEquals(t, `{"error":"not found"}`, request("GET", "/api/bish", "*/*").Body.String())
+	assertEquals(t, `{"error":"not found"}`, request("GET", "/api/bish", "application/json").Body.String())
+	assertEquals(t, `{"error":"not found"}`, request("POST", "/users/42", "text/html").Body.String())
+	assertEquals(t, 405, request("POST", "/api/users", "text/html").Code)
+}
+
+func TestRouterRoutes(t *testing.T) {
+	rt := NewRouter()
+	rt.Use("auth", bishMiddleware)
+	rt.Use("log", flipMiddleware)
+	rt.Post("/users", New().Then(bishHandler))
+	rt.Get("/users/{id}", New().Then(bishHandler)).Name("user.show").Skip("log")
+	rt.Group(bishMiddleware).Get("/users", New().Then(bishHandler))
+
+	routes := rt.Routes()
+	assertEquals(t, 3, len(routes))
+	assertEquals(t, "GET /users  [auth log]", fmt.Sprintf("%s %s %s %v", routes[0].Method, routes[0].Pattern, routes[0].Name, routes[0].Middleware))
+	assertEquals(t, "POST /users  [auth log]", fmt.Sprintf("%s %s %s %v", routes[1].Method, routes[1].Pattern, routes[1].Name, routes[1].Middleware))
+	assertEquals(t, "GET /users/{id} user.show [auth]", fmt.Sprintf("%s %s %s %v", routes[2].Method, routes[2].Pattern, routes[2].Name, routes[2].Middleware))
+}
@@ -0,0 +1,113 @@
+package stack
+
+import (
+	"errors"
+	"net/http"
+)
+
+const (
+	requiredScopesKey = "stack.requiredScopes"
+	grantedScopesKey  = "stack.grantedScopes"
+)
+
+// ErrInsufficientScope is used, wrapped in an HTTPError with status 403,
+// when a request's credentials lack scopes required by the chain.
+var ErrInsufficientScope = errors.New("stack: insufficient scope")
+
+// RequireScopes returns a new copy of the chain which declares that its
+// requests need all of the given scopes, in addition to any already
+// required. Like Inject, it leaves the original chain unchanged, so
+// declarations can live next to route registration:
+//
+//	mux.Handle("/orders", stack.RequireScopes(ordersChain, "orders:write"))
+//
+// The requirement is checked after the chain's middleware have run, just
+// before its handler, against the scopes recorded with SetScopes by this
+// chain or an enclosing one (such as a chain wrapping a Router). Requests
+// lacking a scope are passed to the chain's error handler with
+// ErrInsufficientScope, and a 403 status, or 401 if there is no principal.
+// Authentication middleware which grant scopes (such as the jwt and
+// introspect packages) also check them, so they can send a challenge.
+func RequireScopes(hc HandlerChain, scopes ...string) HandlerChain {
+	existing, _ := hc.context.Get(requiredScopesKey).([]string)
+	required := make([]string, 0, len(existing)+len(scopes))
+	required = append(required, existing...)
+	required = append(required, scopes...)
+	return Inject(hc, requiredScopesKey, required)
+}
+
+// RequiredScopes returns the scopes declared with RequireScopes for the
+// chain handling the current request.
+func RequiredScopes(ctx *Context) []string {
+	scopes, _ := ctx.Get(requiredScopesKey).([]string)
+	return scopes
+}
+
+// SetScopes records the scopes granted to the current request's
+// credentials.
+func SetScopes(ctx *Context, scopes []string) {
+	ctx.Put(grantedScopesKey, scopes)
+}
+
+// Scopes returns the scopes granted to the current request's credentials.
+func Scopes(ctx *Context) []string {
+	scopes, _ := ctx.Get(grantedScopesKey).([]string)
+	return scopes
+}
+
+// MissingScopes returns the required scopes which haven't been granted,
+// by this chain or an enclosing one.
+func MissingScopes(ctx *Context) []string {
+	return missingScopes(ctx, Scopes(authenticatedContext(ctx)))
+}
+
+func missingScopes(ctx *Context, scopes []string) []string {
+	granted := make(map[string]bool)
+	for _, s := range scopes {
+		granted[s] = true
+	}
+	var missing []string
+	for _, s := range RequiredScopes(ctx) {
+		if !granted[s] {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
+
+// checkScopes wraps h so that requests lacking the scopes declared with
+// RequireScopes never reach it.
+func checkScopes(ctx *Context, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := authenticatedContext(ctx)
+		if len(missingScopes(ctx, Scopes(auth))) == 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+		status := http.StatusForbidden
+		if Principal(auth) == nil {
+			status = http.StatusUnauthorized
+		}
+		Error(ctx, w, r, NewHTTPError(status, ErrInsufficientScope))
+	})
+}
+
+// authenticatedContext returns the Context of the innermost chain, from
+// ctx outwards through the chains enclosing it, which has recorded scopes
+// or a principal. It returns ctx if none has.
+func authenticatedContext(ctx *Context) *Context {
+	for c := ctx; c != nil; {
+		if c.Exists(grantedScopesKey) || Principal(c) != nil {
+			return c
+		}
+		if c.request == nil {
+			break
+		}
+		outer := FromRequest(c.request)
+		if outer == c {
+			break
+		}
+		c = outer
+	}
+	return ctx
+}
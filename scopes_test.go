@@ -0,0 +1,62 @@
+package stack
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func grantScopes(principal interface{}, scopes ...string) chainMiddleware {
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			SetPrincipal(ctx, principal)
+			SetScopes(ctx, scopes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func scopesHandler(ctx *Context, w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "%v %v", RequiredScopes(ctx), MissingScopes(ctx))
+}
+
+func TestRequireScopes(t *testing.T) {
+	st := New(grantScopes("bish", "orders:read")).Then(scopesHandler)
+
+	rec := recordGet(RequireScopes(st, "orders:read"))
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "[orders:read] []", rec.Body.String())
+
+	rec = recordGet(RequireScopes(RequireScopes(st, "orders:read"), "orders:write"))
+	assertEquals(t, 403, rec.Code)
+
+	rec = recordGet(st)
+	assertEquals(t, "[] []", rec.Body.String())
+}
+
+func TestRequireScopesWithoutGrant(t *testing.T) {
+	// Middleware which authenticates without granting scopes, such as a
+	// session or basic auth, don't let the requirement be skipped.
+	rec := recordGet(RequireScopes(New(principalMiddleware("bish")).Then(scopesHandler), "bish"))
+	assertEquals(t, 403, rec.Code)
+
+	rec = recordGet(RequireScopes(New().Then(scopesHandler), "bish"))
+	assertEquals(t, 401, rec.Code)
+}
+
+func TestRequireScopesFromEnclosingChain(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/", RequireScopes(New().Then(scopesHandler), "orders:read"))
+	rt.Get("/write", RequireScopes(New().Then(scopesHandler), "orders:write"))
+	st := New(grantScopes("bish", "orders:read")).ThenHandler(rt)
+
+	rec := recordGet(st)
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "[orders:read] []", rec.Body.String())
+
+	r, _ := http.NewRequest("GET", "/write", nil)
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 403, rec.Code)
+}
@@ -0,0 +1,202 @@
+package stack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SendOption configures SendFile and Attachment.
+type SendOption func(*sendConfig)
+
+// SendName sets the file name offered to the client in the
+// Content-Disposition header, and used to pick the Content-Type. It
+// defaults to the base name of the file being sent.
+func SendName(name string) SendOption {
+	return func(c *sendConfig) {
+		c.name = name
+	}
+}
+
+// SendRate limits the transfer to roughly bytesPerSecond. The default is
+// zero, meaning no limit.
+func SendRate(bytesPerSecond int64) SendOption {
+	return func(c *sendConfig) {
+		c.rate = bytesPerSecond
+	}
+}
+
+type sendConfig struct {
+	name        string
+	disposition string
+	rate        int64
+}
+
+// SendFile sends the file at path as the response, setting Content-Type
+// from its name, and ETag and Last-Modified from its modification time and
+// size. Range, If-Range and conditional requests are answered as by
+// http.ServeContent. The ETag middleware is skipped, since the response
+// already has a validator and buffering a large file to hash it is
+// wasteful.
+//
+// If the file can't be opened, or is a directory, the error is passed to
+// the chain's error handler (with a 404 or 403 status where appropriate)
+// and returned.
+func SendFile(ctx *Context, w http.ResponseWriter, r *http.Request, path string, opts ...SendOption) error {
+	cfg := &sendConfig{disposition: "inline"}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg.send(ctx, w, r, path)
+}
+
+// Attachment is like SendFile, but asks the browser to download the file
+// rather than display it. Names which aren't plain ASCII are encoded as
+// described in RFC 6266, with an ASCII fallback for older clients.
+func Attachment(ctx *Context, w http.ResponseWriter, r *http.Request, path string, opts ...SendOption) error {
+	cfg := &sendConfig{disposition: "attachment"}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg.send(ctx, w, r, path)
+}
+
+func (cfg *sendConfig) send(ctx *Context, w http.ResponseWriter, r *http.Request, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		err = fileError(err)
+		Error(ctx, w, r, err)
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err == nil && fi.IsDir() {
+		err = NewHTTPError(http.StatusNotFound, fmt.Errorf("stack: %s is a directory", path))
+	}
+	if err != nil {
+		Error(ctx, w, r, err)
+		return err
+	}
+
+	name := cfg.name
+	if name == "" {
+		name = filepath.Base(path)
+	}
+	SkipETag(ctx)
+	h := w.Header()
+	if h.Get("ETag") == "" {
+		h.Set("ETag", fileETag(fi))
+	}
+	if cfg.disposition == "attachment" || cfg.name != "" {
+		h.Set("Content-Disposition", contentDisposition(cfg.disposition, name))
+	}
+	if h.Get("Content-Type") == "" {
+		if ct := mime.TypeByExtension(filepath.Ext(name)); ct != "" {
+			h.Set("Content-Type", ct)
+		}
+	}
+
+	var content io.ReadSeeker = f
+	if cfg.rate > 0 {
+		content = &throttledReader{rs: f, rate: cfg.rate, ctx: r.Context()}
+	}
+	http.ServeContent(w, r, name, fi.ModTime(), content)
+	return nil
+}
+
+// fileETag returns a validator derived from a file's modification time and
+// size, which lets conditional and If-Range requests be answered without
+// reading the file.
+func fileETag(fi os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size())
+}
+
+// contentDisposition formats a Content-Disposition header. The filename
+// parameter holds an ASCII approximation of name, and if that isn't exact
+// the filename* parameter holds name itself, percent-encoded as UTF-8.
+func contentDisposition(disposition, name string) string {
+	var fallback strings.Builder
+	exact := true
+	for _, c := range name {
+		switch {
+		case c == '"' || c == '\\':
+			fallback.WriteByte('\\')
+			fallback.WriteRune(c)
+		case c < ' ' || c > '~':
+			fallback.WriteByte('_')
+			exact = false
+		default:
+			fallback.WriteRune(c)
+		}
+	}
+	v := fmt.Sprintf("%s; filename=\"%s\"", disposition, fallback.String())
+	if !exact {
+		v += "; filename*=UTF-8''" + encodeRFC5987(name)
+	}
+	return v
+}
+
+// encodeRFC5987 percent-encodes every byte of s which isn't an attr-char.
+func encodeRFC5987(s string) string {
+	const attrChars = "!#$&+-.^_`|~"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte(attrChars, c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// throttledReader limits the rate at which content is read, and so sent.
+// The clock starts at the first Read, so the seeks ServeContent makes to
+// find the size and the start of a range aren't counted.
+type throttledReader struct {
+	rs    io.ReadSeeker
+	rate  int64
+	ctx   context.Context
+	start time.Time
+	n     int64
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	if tr.start.IsZero() {
+		tr.start = time.Now()
+	}
+	// Read at most a tenth of a second's worth at a time, so that the
+	// transfer is smooth rather than bursty.
+	chunk := tr.rate / 10
+	if chunk < 1 {
+		chunk = 1
+	}
+	if int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+	n, err := tr.rs.Read(p)
+	tr.n += int64(n)
+
+	due := tr.start.Add(time.Duration(tr.n * int64(time.Second) / tr.rate))
+	if wait := time.Until(due); wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-tr.ctx.Done():
+			return n, tr.ctx.Err()
+		}
+	}
+	return n, err
+}
+
+func (tr *throttledReader) Seek(offset int64, whence int) (int64, error) {
+	return tr.rs.Seek(offset, whence)
+}
@@ -0,0 +1,93 @@
+package stack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func sendRequest(hc HandlerChain, header http.Header) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("GET", "/", nil)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	hc.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestSendFile(t *testing.T) {
+	st := New(ETag()).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		SendFile(ctx, w, r, "testdata/static/css/site.css")
+	})
+
+	rec := sendRequest(st, nil)
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "body{}\n", rec.Body.String())
+	assertEquals(t, "text/css; charset=utf-8", rec.Header().Get("Content-Type"))
+	assertEquals(t, "", rec.Header().Get("Content-Disposition"))
+	etag := rec.Header().Get("ETag")
+	assertEquals(t, true, etag != "")
+	assertEquals(t, true, rec.Header().Get("Last-Modified") != "")
+
+	rec = sendRequest(st, http.Header{"Range": {"bytes=0-3"}})
+	assertEquals(t, 206, rec.Code)
+	assertEquals(t, "body", rec.Body.String())
+
+	rec = sendRequest(st, http.Header{"If-None-Match": {etag}})
+	assertEquals(t, 304, rec.Code)
+}
+
+func TestSendFileMissing(t *testing.T) {
+	var err error
+	st := New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		err = SendFile(ctx, w, r, "testdata/static/missing.txt")
+	})
+	rec := sendRequest(st, nil)
+	assertEquals(t, 404, rec.Code)
+	assertEquals(t, 404, err.(*HTTPError).Status)
+
+	st = New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		err = SendFile(ctx, w, r, "testdata/static/css")
+	})
+	rec = sendRequest(st, nil)
+	assertEquals(t, 404, rec.Code)
+}
+
+func TestAttachment(t *testing.T) {
+	var name string
+	st := New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		opts := []SendOption{}
+		if name != "" {
+			opts = append(opts, SendName(name))
+		}
+		Attachment(ctx, w, r, "testdata/static/css/site.css", opts...)
+	})
+
+	tests := []struct {
+		name        string
+		disposition string
+	}{
+		{"", `attachment; filename="site.css"`},
+		{`bish "bash".css`, `attachment; filename="bish \"bash\".css"`},
+		{"résumé.txt", `attachment; filename="r_sum_.txt"; filename*=UTF-8''r%C3%A9sum%C3%A9.txt`},
+	}
+	for _, test := range tests {
+		name = test.name
+		rec := sendRequest(st, nil)
+		assertEquals(t, test.disposition, rec.Header().Get("Content-Disposition"))
+	}
+	assertEquals(t, "text/plain; charset=utf-8", sendRequest(st, nil).Header().Get("Content-Type"))
+}
+
+func TestSendFileRate(t *testing.T) {
+	st := New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		SendFile(ctx, w, r, "testdata/static/css/site.css", SendRate(40))
+	})
+	start := time.Now()
+	rec := sendRequest(st, nil)
+	assertEquals(t, "body{}\n", rec.Body.String())
+	// 7 bytes at 40 bytes per second takes at least 175ms.
+	assertEquals(t, true, time.Since(start) >= 150*time.Millisecond)
+}
@@ -0,0 +1,208 @@
+package stack
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// serverState tracks one server started with Serve, and is reached from
+// its requests through their context.Context.
+type serverState struct {
+	draining int32
+	inFlight int64
+}
+
+type serverStateKey struct{}
+
+func stateOf(r *http.Request) *serverState {
+	s, _ := r.Context().Value(serverStateKey{}).(*serverState)
+	return s
+}
+
+// Draining reports whether the server started with Serve which received r
+// is shutting down. Health checks should report the service as not ready
+// while it is, so that load balancers stop sending it traffic. It reports
+// false for requests which weren't received by Serve.
+func Draining(r *http.Request) bool {
+	s := stateOf(r)
+	return s != nil && atomic.LoadInt32(&s.draining) == 1
+}
+
+// InFlight returns the number of requests currently being handled by the
+// server started with Serve which received r, or zero for requests which
+// weren't received by Serve.
+func InFlight(r *http.Request) int64 {
+	if s := stateOf(r); s != nil {
+		return atomic.LoadInt64(&s.inFlight)
+	}
+	return 0
+}
+
+// ServeOption configures Serve.
+type ServeOption func(*serveConfig)
+
+// ServeDrainTimeout sets how long Serve waits for in-flight requests to
+// complete once shutdown has begun, before closing their connections. The
+// default is 30 seconds.
+func ServeDrainTimeout(d time.Duration) ServeOption {
+	return func(c *serveConfig) {
+		c.drainTimeout = d
+	}
+}
+
+// ServeShutdownDelay sets how long Serve keeps accepting requests after
+// receiving a signal, with Draining reporting true, before it begins to
+// shut down. This gives load balancers time to notice that the service is
+// no longer ready. The default is zero.
+func ServeShutdownDelay(d time.Duration) ServeOption {
+	return func(c *serveConfig) {
+		c.shutdownDelay = d
+	}
+}
+
+// ServeSignals sets the signals which start a graceful shutdown. The
+// default is SIGINT and SIGTERM.
+func ServeSignals(sigs ...os.Signal) ServeOption {
+	return func(c *serveConfig) {
+		c.signals = sigs
+	}
+}
+
+// ServeContext makes Serve shut down gracefully when ctx is done, as well
+// as on receiving a signal.
+func ServeContext(ctx context.Context) ServeOption {
+	return func(c *serveConfig) {
+		c.ctx = ctx
+	}
+}
+
+// ServeListener makes Serve accept connections from l rather than
+// listening on its address.
+func ServeListener(l net.Listener) ServeOption {
+	return func(c *serveConfig) {
+		c.listener = l
+	}
+}
+
+// ServeServer registers a function to configure the http.Server before it
+// starts, such as to set timeouts or a TLSConfig.
+func ServeServer(fn func(*http.Server)) ServeOption {
+	return func(c *serveConfig) {
+		c.server = append(c.server, fn)
+	}
+}
+
+type serveConfig struct {
+	drainTimeout  time.Duration
+	shutdownDelay time.Duration
+	signals       []os.Signal
+	ctx           context.Context
+	listener      net.Listener
+	server        []func(*http.Server)
+}
+
+// Serve serves hc on addr until it receives SIGINT or SIGTERM, and then
+// shuts down gracefully: Draining reports true, new connections are
+// refused and in-flight requests are given the drain timeout to complete.
+// It returns nil after a graceful shutdown, or the error which stopped the
+// server otherwise. Each call tracks its own state, so several servers can
+// run in one process.
+//
+// The chain's OnStart hooks are run before the server starts, and if one
+// fails Serve returns its error. The OnStop hooks are run once the server
+// has stopped, with a drain timeout of their own.
+//
+//	log.Fatal(stack.Serve(":8080", app, stack.ServeDrainTimeout(10*time.Second)))
+func Serve(addr string, hc HandlerChain, opts ...ServeOption) error {
+	cfg := &serveConfig{
+		drainTimeout: 30 * time.Second,
+		signals:      []os.Signal{os.Interrupt, syscall.SIGTERM},
+		ctx:          context.Background(),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	state := &serverState{}
+	srv := &http.Server{
+		Addr: addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt64(&state.inFlight, 1)
+			defer atomic.AddInt64(&state.inFlight, -1)
+			hc.ServeHTTP(w, r)
+		}),
+	}
+	for _, fn := range cfg.server {
+		fn(srv)
+	}
+	base := srv.BaseContext
+	srv.BaseContext = func(l net.Listener) context.Context {
+		ctx := context.Background()
+		if base != nil {
+			ctx = base(l)
+		}
+		return context.WithValue(ctx, serverStateKey{}, state)
+	}
+	ln := cfg.listener
+	if ln == nil {
+		var err error
+		if ln, err = net.Listen("tcp", addr); err != nil {
+			return err
+		}
+	}
+	if err := hc.Start(cfg.ctx); err != nil {
+		ln.Close()
+		return err
+	}
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, cfg.signals...)
+	defer signal.Stop(sigc)
+
+	errc := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			errc <- srv.ServeTLS(ln, "", "")
+			return
+		}
+		errc <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errc:
+		cfg.stop(hc)
+		return err
+	case <-sigc:
+	case <-cfg.ctx.Done():
+	}
+
+	atomic.StoreInt32(&state.draining, 1)
+	defer atomic.StoreInt32(&state.draining, 0)
+	if cfg.shutdownDelay > 0 {
+		time.Sleep(cfg.shutdownDelay)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.drainTimeout)
+	defer cancel()
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		srv.Close()
+	}
+	if stopErr := cfg.stop(hc); err == nil {
+		err = stopErr
+	}
+	return err
+}
+
+// stop runs hc's OnStop hooks with a context of their own, so that they
+// still get the full drain timeout when draining requests used it up.
+func (cfg *serveConfig) stop(hc HandlerChain) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.drainTimeout)
+	defer cancel()
+	return hc.Stop(ctx)
+}
@@ -0,0 +1,146 @@
+package stack
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func listen(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	for i := 0; i < 500; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timed out")
+}
+
+func TestServe(t *testing.T) {
+	entered := make(chan *http.Request)
+	release := make(chan struct{})
+	st := New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		entered <- r
+		<-release
+		w.Write([]byte("done"))
+	})
+	ln := listen(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve("", st, ServeListener(ln), ServeContext(ctx))
+	}()
+
+	body := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		b, _ := ioutil.ReadAll(res.Body)
+		body <- string(b)
+	}()
+	r := <-entered
+	assertEquals(t, int64(1), InFlight(r))
+	assertEquals(t, false, Draining(r))
+
+	cancel()
+	waitFor(t, func() bool { return Draining(r) })
+	close(release)
+	assertEquals(t, "done", <-body)
+	assertEquals(t, nil, <-served)
+	assertEquals(t, false, Draining(r))
+	assertEquals(t, int64(0), InFlight(r))
+}
+
+func TestServeDrainTimeout(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	var stopErr error
+	st := New().OnStop(func(ctx context.Context) error {
+		stopErr = ctx.Err()
+		return nil
+	}).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	})
+	ln := listen(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve("", st, ServeListener(ln), ServeContext(ctx), ServeDrainTimeout(20*time.Millisecond))
+	}()
+
+	go http.Get("http://" + ln.Addr().String())
+	<-entered
+	cancel()
+	assertEquals(t, context.DeadlineExceeded, <-served)
+	// The OnStop hooks get a drain timeout of their own.
+	assertEquals(t, nil, stopErr)
+}
+
+func TestServeSeparateServers(t *testing.T) {
+	entered := make(chan *http.Request)
+	release := make(chan struct{})
+	st := New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		entered <- r
+		<-release
+	})
+	serve := func() (*http.Request, context.CancelFunc, chan error) {
+		ln := listen(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		served := make(chan error, 1)
+		go func() {
+			served <- Serve("", st, ServeListener(ln), ServeContext(ctx))
+		}()
+		go http.Get("http://" + ln.Addr().String())
+		return <-entered, cancel, served
+	}
+	r1, cancel1, served1 := serve()
+	r2, cancel2, served2 := serve()
+	assertEquals(t, int64(1), InFlight(r1))
+	assertEquals(t, int64(1), InFlight(r2))
+
+	cancel1()
+	waitFor(t, func() bool { return Draining(r1) })
+	assertEquals(t, false, Draining(r2))
+	cancel2()
+	close(release)
+	assertEquals(t, nil, <-served1)
+	assertEquals(t, nil, <-served2)
+}
+
+func TestServeLifecycle(t *testing.T) {
+	var log []string
+	hook := func(event string, err error) func(context.Context) error {
+		return func(context.Context) error {
+			log = append(log, event)
+			return err
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	st := New().OnStart(hook("start", nil)).OnStop(hook("stop", nil)).Then(bishHandler)
+	assertEquals(t, nil, Serve("", st, ServeListener(listen(t)), ServeContext(ctx)))
+	assertEquals(t, "start stop", strings.Join(log, " "))
+
+	errStart := errors.New("start failed")
+	st = New().OnStart(hook("fail", errStart)).Then(bishHandler)
+	assertEquals(t, errStart, Serve("", st, ServeListener(listen(t))))
+}
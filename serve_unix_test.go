@@ -0,0 +1,28 @@
+//go:build unix
+
+package stack
+
+import (
+	"net/http"
+	"syscall"
+	"testing"
+)
+
+func TestServeSignal(t *testing.T) {
+	st := New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {})
+	ln := listen(t)
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve("", st, ServeListener(ln), ServeSignals(syscall.SIGUSR1))
+	}()
+
+	waitFor(t, func() bool {
+		res, err := http.Get("http://" + ln.Addr().String())
+		if err == nil {
+			res.Body.Close()
+		}
+		return err == nil
+	})
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	assertEquals(t, nil, <-served)
+}
@@ -0,0 +1,13 @@
+package stack
+
+// sessionKey is where the session package stores the request's session.
+const sessionKey = "stack.session"
+
+// Session returns the session for the current request, as loaded by the
+// middleware in the session package, or nil if it hasn't run. Use
+// session.FromContext for the rest of the session's methods, such as
+// RenewToken and Destroy.
+func Session(ctx *Context) Values {
+	s, _ := ctx.Get(sessionKey).(Values)
+	return s
+}
@@ -0,0 +1,298 @@
+// Package session provides cookie-based session management for stack
+// chains, with pluggable storage backends.
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alexedwards/stack"
+)
+
+// sessionKey must match the key stack.Session reads.
+const sessionKey = "stack.session"
+
+// Store persists session data. Each session is identified by a token,
+// which is the value of the session cookie.
+type Store interface {
+	// Load returns the values for token. It returns found == false if the
+	// session doesn't exist or has expired.
+	Load(token string) (values map[string]interface{}, found bool, err error)
+	// Save stores values until expiry and returns the token to send to the
+	// client, which may differ from the one passed in. An empty token
+	// means the store should create a new session.
+	Save(token string, values map[string]interface{}, expiry time.Time) (string, error)
+	// Delete removes the session identified by token.
+	Delete(token string) error
+}
+
+// Option configures the session middleware.
+type Option func(*config)
+
+// CookieName sets the name of the session cookie. The default is "session".
+func CookieName(name string) Option {
+	return func(c *config) {
+		c.cookie.Name = name
+	}
+}
+
+// Lifetime sets how long sessions last after they were last modified. The
+// default is 24 hours.
+func Lifetime(d time.Duration) Option {
+	return func(c *config) {
+		c.lifetime = d
+	}
+}
+
+// Secure sets the Secure attribute on the session cookie.
+func Secure(secure bool) Option {
+	return func(c *config) {
+		c.cookie.Secure = secure
+	}
+}
+
+// Domain sets the Domain attribute on the session cookie.
+func Domain(domain string) Option {
+	return func(c *config) {
+		c.cookie.Domain = domain
+	}
+}
+
+// Path sets the Path attribute on the session cookie. The default is "/".
+func Path(path string) Option {
+	return func(c *config) {
+		c.cookie.Path = path
+	}
+}
+
+// SameSite sets the SameSite attribute on the session cookie. The default
+// is http.SameSiteLaxMode.
+func SameSite(mode http.SameSite) Option {
+	return func(c *config) {
+		c.cookie.SameSite = mode
+	}
+}
+
+// ErrorFunc sets the function called when a session can't be loaded or
+// saved. The default logs the error with the standard logger.
+func ErrorFunc(fn func(error)) Option {
+	return func(c *config) {
+		c.errorFunc = fn
+	}
+}
+
+type config struct {
+	store     Store
+	cookie    http.Cookie
+	lifetime  time.Duration
+	errorFunc func(error)
+}
+
+// New returns middleware which makes a Session available to the rest of
+// the chain through FromContext. Session data is only loaded from the
+// store when it is first used, and is saved (and the cookie set) just
+// before the response headers are written, if it was modified. It relies
+// on the response hooks, so the chain must call RecordResponses.
+func New(store Store, opts ...Option) func(*stack.Context, http.Handler) http.Handler {
+	cfg := &config{
+		store:    store,
+		cookie:   http.Cookie{Name: "session", Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode},
+		lifetime: 24 * time.Hour,
+		errorFunc: func(err error) {
+			log.Printf("session: %v", err)
+		},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return stack.Requires(func(ctx *stack.Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s := &Session{cfg: cfg, now: func() time.Time { return stack.Now(ctx) }}
+			if c, err := r.Cookie(cfg.cookie.Name); err == nil {
+				s.token = c.Value
+			}
+			ctx.Put(sessionKey, s)
+
+			stack.BeforeWrite(ctx, s.save)
+			next.ServeHTTP(w, r)
+		})
+	}, stack.NeedsResponses)
+}
+
+// FromContext returns the session for the current request. It returns nil
+// if the session middleware has not run. stack.Session returns the same
+// session, for code which only needs its values.
+func FromContext(ctx *stack.Context) *Session {
+	s, _ := ctx.Get(sessionKey).(*Session)
+	return s
+}
+
+// Session holds the data for a single client session. It is safe for
+// concurrent use.
+type Session struct {
+	cfg   *config
+	mu    sync.Mutex
+	token string
+	// now is the chain's clock (see stack.Now), for session expiry.
+	now       func() time.Time
+	values    map[string]interface{}
+	loaded    bool
+	modified  bool
+	destroyed bool
+	oldToken  string
+}
+
+func (s *Session) load() {
+	if s.loaded {
+		return
+	}
+	s.loaded = true
+	s.values = make(map[string]interface{})
+	if s.token == "" {
+		return
+	}
+	values, found, err := s.cfg.store.Load(s.token)
+	if err != nil {
+		s.cfg.errorFunc(err)
+	}
+	if !found || err != nil {
+		s.token = ""
+		return
+	}
+	s.values = values
+}
+
+// Get returns the value for key, or nil if there isn't one.
+func (s *Session) Get(key string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	return s.values[key]
+}
+
+// Exists reports whether the session contains key.
+func (s *Session) Exists(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	_, ok := s.values[key]
+	return ok
+}
+
+// Put sets the value for key.
+func (s *Session) Put(key string, val interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	s.values[key] = val
+	s.modified = true
+	// Writing to a destroyed session starts a new one.
+	s.destroyed = false
+}
+
+// Delete removes key from the session.
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.modified = true
+	}
+}
+
+// Keys returns the sorted keys in the session.
+func (s *Session) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	keys := make([]string, 0, len(s.values))
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// RenewToken keeps the session data but moves it to a new token. It should
+// be called whenever the user's privilege level changes (such as on login)
+// to prevent session fixation attacks.
+func (s *Session) RenewToken() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	if s.token != "" && s.oldToken == "" {
+		s.oldToken = s.token
+	}
+	s.token = ""
+	s.modified = true
+}
+
+// Destroy deletes all session data and expires the session cookie. Putting
+// new values afterwards starts a fresh session with a new token.
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	s.values = make(map[string]interface{})
+	if s.token != "" && s.oldToken == "" {
+		s.oldToken = s.token
+	}
+	s.token = ""
+	s.destroyed = true
+	s.modified = false
+}
+
+// save writes the session to the store and sets the cookie, if anything
+// changed during the request.
+func (s *Session) save(w http.ResponseWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cookie := s.cfg.cookie
+
+	if s.oldToken != "" {
+		if err := s.cfg.store.Delete(s.oldToken); err != nil {
+			s.cfg.errorFunc(err)
+		}
+		s.oldToken = ""
+	}
+	if s.destroyed {
+		cookie.MaxAge = -1
+		cookie.Expires = time.Unix(1, 0)
+		http.SetCookie(w, &cookie)
+		return
+	}
+	if !s.modified {
+		return
+	}
+
+	expiry := s.now().Add(s.cfg.lifetime)
+	token, err := s.cfg.store.Save(s.token, s.values, expiry)
+	if err != nil {
+		s.cfg.errorFunc(err)
+		return
+	}
+	s.token = token
+	s.modified = false
+	cookie.Value = token
+	cookie.Expires = expiry.UTC()
+	cookie.MaxAge = int(s.cfg.lifetime.Seconds())
+	http.SetCookie(w, &cookie)
+	stack.AddVary(w.Header(), "Cookie")
+}
+
+// NewToken returns a random, URL-safe session token.
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,142 @@
+package session
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/stack"
+)
+
+func assertEquals(t *testing.T, e interface{}, o interface{}) {
+	if e != o {
+		t.Errorf("\n...expected = %v\n...obtained = %v", e, o)
+	}
+}
+
+func counterHandler(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+	s := FromContext(ctx)
+	switch r.URL.Path {
+	case "/destroy":
+		s.Destroy()
+	case "/renew":
+		s.RenewToken()
+	case "/read":
+	default:
+		n, _ := s.Get("count").(int)
+		s.Put("count", n+1)
+	}
+	fmt.Fprintf(w, "count=%v", s.Get("count"))
+}
+
+func request(hc stack.HandlerChain, path string, cookie *http.Cookie) (*httptest.ResponseRecorder, *http.Cookie) {
+	r, _ := http.NewRequest("GET", path, nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	hc.ServeHTTP(rec, r)
+	cookies := (&http.Response{Header: rec.Header()}).Cookies()
+	if len(cookies) == 0 {
+		return rec, cookie
+	}
+	return rec, cookies[0]
+}
+
+func testStore(t *testing.T, store Store) {
+	st := stack.New(New(store)).RecordResponses().Then(counterHandler)
+
+	rec, cookie := request(st, "/", nil)
+	assertEquals(t, "count=1", rec.Body.String())
+	assertEquals(t, "session", cookie.Name)
+	assertEquals(t, true, cookie.HttpOnly)
+
+	rec, cookie = request(st, "/", cookie)
+	assertEquals(t, "count=2", rec.Body.String())
+
+	rec, _ = request(st, "/read", cookie)
+	assertEquals(t, "count=2", rec.Body.String())
+	assertEquals(t, "", rec.Header().Get("Set-Cookie"))
+
+	rec, renewed := request(st, "/renew", cookie)
+	assertEquals(t, "count=2", rec.Body.String())
+	assertEquals(t, false, renewed.Value == cookie.Value)
+	rec, _ = request(st, "/read", renewed)
+	assertEquals(t, "count=2", rec.Body.String())
+
+	rec, destroyed := request(st, "/destroy", renewed)
+	assertEquals(t, "count=<nil>", rec.Body.String())
+	assertEquals(t, -1, destroyed.MaxAge)
+}
+
+func TestMemStore(t *testing.T) {
+	store := NewMemStore()
+	testStore(t, store)
+
+	// The old token should no longer work after renewal.
+	st := stack.New(New(store)).RecordResponses().Then(counterHandler)
+	_, cookie := request(st, "/", nil)
+	request(st, "/renew", cookie)
+	rec, _ := request(st, "/read", cookie)
+	assertEquals(t, "count=<nil>", rec.Body.String())
+}
+
+func TestCookieStore(t *testing.T) {
+	codec, err := stack.NewCookieCodec([][]byte{[]byte("0123456789abcdef0123456789abcdef")}, [][]byte{[]byte("fedcba9876543210")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewCookieStore(codec)
+	testStore(t, store)
+
+	st := stack.New(New(store, ErrorFunc(func(error) {}))).RecordResponses().Then(counterHandler)
+	_, cookie := request(st, "/", nil)
+	cookie.Value = strings.Replace(cookie.Value, ".", "x.", 1)
+	rec, _ := request(st, "/read", cookie)
+	assertEquals(t, "count=<nil>", rec.Body.String())
+}
+
+func TestSaveBeforeWrite(t *testing.T) {
+	st := stack.New(New(NewMemStore())).RecordResponses().Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		FromContext(ctx).Put("bish", "bash")
+		w.WriteHeader(201)
+		// Changes made after the headers are written can't be saved.
+		FromContext(ctx).Put("flip", "flop")
+	})
+
+	rec, cookie := request(st, "/", nil)
+	assertEquals(t, 201, rec.Code)
+	assertEquals(t, "session", cookie.Name)
+}
+
+func TestSaveWithoutBody(t *testing.T) {
+	st := stack.New(New(NewMemStore())).RecordResponses().Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		FromContext(ctx).Put("bish", "bash")
+	})
+
+	rec, cookie := request(st, "/", nil)
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "session", cookie.Name)
+	assertEquals(t, 1, len(rec.Header()["Set-Cookie"]))
+	assertEquals(t, "Cookie", rec.Header().Get("Vary"))
+}
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+func TestSessionUsesChainClock(t *testing.T) {
+	now := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	var values stack.Values
+	st := stack.New(New(NewMemStore(), Lifetime(time.Hour))).RecordResponses().UseClock(fixedClock(now)).Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		values = stack.Session(ctx)
+		values.Put("bish", "bash")
+	})
+
+	_, cookie := request(st, "/", nil)
+	assertEquals(t, now.Add(time.Hour), cookie.Expires)
+	assertEquals(t, true, values != nil)
+}
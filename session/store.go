@@ -0,0 +1,138 @@
+package session
+
+import (
+	"bytes"
+	"encoding/gob"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexedwards/stack"
+)
+
+// MemStore is an in-memory Store. It is suitable for development and for
+// single-process deployments where losing sessions on restart is
+// acceptable.
+type MemStore struct {
+	mu       sync.Mutex
+	sessions map[string]memEntry
+	swept    time.Time
+}
+
+type memEntry struct {
+	values map[string]interface{}
+	expiry time.Time
+}
+
+// NewMemStore returns an empty MemStore.
+func NewMemStore() *MemStore {
+	return &MemStore{sessions: make(map[string]memEntry)}
+}
+
+// Load implements Store.
+func (m *MemStore) Load(token string) (map[string]interface{}, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.sessions[token]
+	if !ok || time.Now().After(e.expiry) {
+		return nil, false, nil
+	}
+	return copyValues(e.values), true, nil
+}
+
+// Save implements Store.
+func (m *MemStore) Save(token string, values map[string]interface{}, expiry time.Time) (string, error) {
+	if token == "" {
+		var err error
+		if token, err = NewToken(); err != nil {
+			return "", err
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep()
+	m.sessions[token] = memEntry{values: copyValues(values), expiry: expiry}
+	return token, nil
+}
+
+// Delete implements Store.
+func (m *MemStore) Delete(token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, token)
+	return nil
+}
+
+// sweep removes expired sessions, at most once a minute.
+func (m *MemStore) sweep() {
+	now := time.Now()
+	if now.Sub(m.swept) < time.Minute {
+		return
+	}
+	m.swept = now
+	for token, e := range m.sessions {
+		if now.After(e.expiry) {
+			delete(m.sessions, token)
+		}
+	}
+}
+
+func copyValues(values map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		c[k] = v
+	}
+	return c
+}
+
+// CookieStore keeps session data in the session cookie itself, protected
+// by a stack.CookieCodec. The codec always signs the data, so it can't be
+// tampered with, but only encrypts it if the codec was created with
+// encryption keys. The data must fit in a cookie (about 4KB), and custom
+// value types must be registered with gob.Register.
+type CookieStore struct {
+	codec *stack.CookieCodec
+}
+
+// NewCookieStore returns a CookieStore which protects session data with
+// codec.
+func NewCookieStore(codec *stack.CookieCodec) *CookieStore {
+	return &CookieStore{codec: codec}
+}
+
+// cookiePurpose is bound into the signature of session cookies, so that
+// values signed by the same codec for other cookies can't be used as
+// session data.
+const cookiePurpose = "session"
+
+// Load implements Store. Cookies which fail verification are reported
+// as an error; expired cookies are treated as not found.
+func (c *CookieStore) Load(token string) (map[string]interface{}, bool, error) {
+	data, err := c.codec.Decode(cookiePurpose, token)
+	if err == stack.ErrCookieExpired {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var values map[string]interface{}
+	if err := gob.NewDecoder(strings.NewReader(data)).Decode(&values); err != nil {
+		return nil, false, err
+	}
+	return values, true, nil
+}
+
+// Save implements Store. The returned token is the encoded session data.
+func (c *CookieStore) Save(token string, values map[string]interface{}, expiry time.Time) (string, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(values); err != nil {
+		return "", err
+	}
+	return c.codec.Encode(cookiePurpose, buf.String(), expiry)
+}
+
+// Delete implements Store. There is nothing to delete server-side; the
+// middleware expires the cookie.
+func (c *CookieStore) Delete(token string) error {
+	return nil
+}
@@ -0,0 +1,21 @@
+package stack
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	var got Values
+	recordGet(New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		got = Session(ctx)
+	}))
+	assertEquals(t, nil, got)
+
+	values := ContextValues(NewContext())
+	recordGet(New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		ctx.Put(sessionKey, values)
+		Session(ctx).Put("bish", "bash")
+	}))
+	assertEquals(t, "bash", values.Get("bish"))
+}
@@ -0,0 +1,132 @@
+package stack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSEEvent is a single server-sent event. Data may contain newlines, which
+// are sent as multiple data lines; newlines in Event and ID are removed.
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  string
+	// Retry, if non-zero, tells the client how long to wait before
+	// reconnecting.
+	Retry time.Duration
+}
+
+// SSEConn sends server-sent events to a client. It is created by ThenSSE.
+type SSEConn struct {
+	w       http.ResponseWriter
+	r       *http.Request
+	rc      *http.ResponseController
+	started bool
+}
+
+// ThenSSE finishes the chain with a handler for a server-sent events
+// stream. fn is called with an SSEConn, and the stream ends when fn
+// returns. The response headers are sent with the first event (or
+// comment), so if fn returns an error before sending anything it is passed
+// to the chain's error handler as usual.
+//
+// The ETag, Compress and Transform middleware all pass event streams
+// straight through, so each event reaches the client as soon as it is
+// sent.
+func (c Chain) ThenSSE(fn func(ctx *Context, s *SSEConn) error) HandlerChain {
+	c.h = func(ctx *Context) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			SkipETag(ctx)
+			s := &SSEConn{w: w, r: r, rc: http.NewResponseController(w)}
+			err := fn(ctx, s)
+			if err == nil || s.started {
+				return
+			}
+			if errors.Is(err, context.Canceled) && r.Context().Err() != nil {
+				return
+			}
+			Error(ctx, w, r, err)
+		})
+	}
+	return newHandlerChain(c)
+}
+
+// Done returns a channel which is closed when the client disconnects.
+func (s *SSEConn) Done() <-chan struct{} {
+	return s.r.Context().Done()
+}
+
+// LastEventID returns the ID of the last event received by a reconnecting
+// client, from the Last-Event-ID request header.
+func (s *SSEConn) LastEventID() string {
+	return s.r.Header.Get("Last-Event-ID")
+}
+
+// Send writes ev to the client and flushes it. It returns the request
+// context's error once the client has disconnected.
+func (s *SSEConn) Send(ev SSEEvent) error {
+	var b strings.Builder
+	if ev.ID != "" {
+		b.WriteString("id: " + stripNewlines(ev.ID) + "\n")
+	}
+	if ev.Event != "" {
+		b.WriteString("event: " + stripNewlines(ev.Event) + "\n")
+	}
+	if ev.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(int64(ev.Retry/time.Millisecond), 10) + "\n")
+	}
+	// A lone \r ends a line too, so it mustn't be let through, or data
+	// could add fields of its own.
+	data := strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(ev.Data)
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+// SendData sends an event with only a data field.
+func (s *SSEConn) SendData(data string) error {
+	return s.Send(SSEEvent{Data: data})
+}
+
+// Comment sends a comment line, which clients ignore. It is useful as a
+// heartbeat to keep idle connections open through proxies, and to send the
+// response headers before the first event is ready.
+func (s *SSEConn) Comment(text string) error {
+	return s.write(": " + stripNewlines(text) + "\n\n")
+}
+
+func (s *SSEConn) write(msg string) error {
+	if err := s.r.Context().Err(); err != nil {
+		return err
+	}
+	if !s.started {
+		s.started = true
+		h := s.w.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		h.Del("Content-Length")
+		// Stop nginx from buffering the stream.
+		h.Set("X-Accel-Buffering", "no")
+		s.w.WriteHeader(http.StatusOK)
+	}
+	if _, err := fmt.Fprint(s.w, msg); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func stripNewlines(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+// isEventStream reports whether contentType is text/event-stream.
+func isEventStream(contentType string) bool {
+	return mediaTypeIn(contentType, []string{"text/event-stream"})
+}
@@ -0,0 +1,118 @@
+package stack
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestThenSSE(t *testing.T) {
+	st := fullChain().ThenSSE(func(ctx *Context, s *SSEConn) error {
+		if err := s.Send(SSEEvent{ID: "1", Event: "greeting", Data: "bish\nbash", Retry: time.Second}); err != nil {
+			return err
+		}
+		return s.SendData("bosh")
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "text/event-stream", rec.Header().Get("Content-Type"))
+	assertEquals(t, "no-cache", rec.Header().Get("Cache-Control"))
+	assertEquals(t, "", rec.Header().Get("Content-Encoding"))
+	assertEquals(t, "", rec.Header().Get("ETag"))
+	assertEquals(t, true, rec.Flushed)
+	assertEquals(t, "id: 1\nevent: greeting\nretry: 1000\ndata: bish\ndata: bash\n\ndata: bosh\n\n", rec.Body.String())
+}
+
+func TestThenSSELineBreaks(t *testing.T) {
+	st := New().ThenSSE(func(ctx *Context, s *SSEConn) error {
+		if err := s.SendData("hi\revent: admin\rid: 999"); err != nil {
+			return err
+		}
+		return s.Send(SSEEvent{ID: "1\rdata: x", Event: "bish\nid: 2", Data: "a\r\nb\nc"})
+	})
+
+	rec := recordGet(st)
+	assertEquals(t, "data: hi\ndata: event: admin\ndata: id: 999\n\nid: 1data: x\nevent: bishid: 2\ndata: a\ndata: b\ndata: c\n\n", rec.Body.String())
+}
+
+func TestThenSSEError(t *testing.T) {
+	st := New().ThenSSE(func(ctx *Context, s *SSEConn) error {
+		return NewHTTPError(http.StatusForbidden, nil)
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+
+	assertEquals(t, 403, rec.Code)
+}
+
+func TestThenSSEStreams(t *testing.T) {
+	next := make(chan struct{})
+	done := make(chan error, 1)
+	st := fullChain().ThenSSE(func(ctx *Context, s *SSEConn) error {
+		s.Comment("hello")
+		for i := 0; ; i++ {
+			select {
+			case <-next:
+			case <-s.Done():
+				done <- s.SendData("too late")
+				return nil
+			}
+			if err := s.Send(SSEEvent{ID: s.LastEventID() + "x"}); err != nil {
+				return err
+			}
+		}
+	})
+	ts := httptest.NewServer(st)
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Last-Event-ID", "7")
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(res.Body)
+	line, _ := br.ReadString('\n')
+	assertEquals(t, ": hello\n", line)
+	br.ReadString('\n')
+	next <- struct{}{}
+	line, _ = br.ReadString('\n')
+	assertEquals(t, "id: 7x\n", line)
+
+	res.Body.Close()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected an error after disconnect")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler didn't see the disconnect")
+	}
+}
+
+func TestThenSSECanceled(t *testing.T) {
+	st := New().ThenSSE(func(ctx *Context, s *SSEConn) error {
+		return s.SendData("bish")
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	cctx, cancel := context.WithCancel(r.Context())
+	cancel()
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r.WithContext(cctx))
+
+	// Nothing is sent to a client which has gone away, not even an error.
+	assertEquals(t, false, rec.Flushed)
+	assertEquals(t, "", rec.Body.String())
+}
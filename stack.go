@@ -1,13 +1,37 @@
 package stack
 
-import "net/http"
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
 
 type chainHandler func(*Context) http.Handler
 type chainMiddleware func(*Context, http.Handler) http.Handler
 
 type Chain struct {
-	mws []chainMiddleware
-	h   chainHandler
+	mws      []chainMiddleware
+	h        chainHandler
+	errh     ErrorHandlerFunc
+	cc       *CookieCodec
+	record   bool
+	clock    Clock
+	newID    func() string
+	reporter func(*Context, error)
+	waitGo   time.Duration
+	toggles  map[string]bool
+	renderer Renderer
+	assets   *Assets
+	// views maps payload types to templates for Negotiate.
+	views map[reflect.Type]string
+	// onStart and onStop hold the lifecycle hooks.
+	onStart []func(context.Context) error
+	onStop  []func(context.Context) error
+	// ctxHeaders maps Context keys to response header names.
+	ctxHeaders map[string]string
 }
 
 func New(mws ...chainMiddleware) Chain {
@@ -22,11 +46,34 @@ func (c Chain) Append(mws ...chainMiddleware) Chain {
 	return c
 }
 
+// OnError sets the function used to handle errors raised by middleware
+// in the chain (such as a 406 from ContentNegotiation). By default the
+// status text for the error's status code is sent to the client.
+func (c Chain) OnError(fn ErrorHandlerFunc) Chain {
+	c.errh = fn
+	return c
+}
+
+// UseCookieCodec sets the codec used by SetSignedCookie and
+// ReadSignedCookie for requests handled by the chain.
+func (c Chain) UseCookieCodec(cc *CookieCodec) Chain {
+	c.cc = cc
+	return c
+}
+
 func (c Chain) Then(chf func(ctx *Context, w http.ResponseWriter, r *http.Request)) HandlerChain {
 	c.h = adaptContextHandlerFunc(chf)
 	return newHandlerChain(c)
 }
 
+// ThenValues is like Then, for handlers which only use the Context to get
+// and put values.
+func (c Chain) ThenValues(fn func(v Values, w http.ResponseWriter, r *http.Request)) HandlerChain {
+	return c.Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		fn(ContextValues(ctx), w, r)
+	})
+}
+
 func (c Chain) ThenHandler(h http.Handler) HandlerChain {
 	c.h = adaptHandler(h)
 	return newHandlerChain(c)
@@ -39,41 +86,236 @@ func (c Chain) ThenHandlerFunc(fn func(http.ResponseWriter, *http.Request)) Hand
 
 type HandlerChain struct {
 	context *Context
+	// funcs compute per-request values added with InjectFunc.
+	funcs []injectedFunc
+	// injected lists the keys added with Inject and its variants.
+	injected []string
+	// reads records which injected keys have been read, for Lint, once
+	// turned on with TrackReads.
+	reads map[string]*int32
 	Chain
 }
 
+type injectedFunc struct {
+	key string
+	fn  func(r *http.Request) interface{}
+}
+
 func newHandlerChain(c Chain) HandlerChain {
+	c.checkCapabilities()
 	return HandlerChain{context: NewContext(), Chain: c}
 }
 
 func (hc HandlerChain) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hc.serve(w, r, nil)
+}
+
+// ServeWithContext is like ServeHTTP, but returns the Context used for the
+// request so that tests can inspect it once the chain has run.
+func (hc HandlerChain) ServeWithContext(w http.ResponseWriter, r *http.Request) *Context {
+	var ctx *Context
+	hc.serve(w, r, func(c *Context) { ctx = c })
+	return ctx
+}
+
+// settings returns a Context holding the chain's settings, for the
+// Contexts of its requests to inherit.
+func (hc HandlerChain) settings() *Context {
+	return &Context{
+		errorHandler: hc.errh,
+		cookieCodec:  hc.cc,
+		clock:        hc.clock,
+		newID:        hc.newID,
+		reporter:     hc.reporter,
+		toggles:      hc.toggles,
+		reads:        hc.reads,
+		renderer:     hc.renderer,
+		assets:       hc.assets,
+		views:        hc.views,
+	}
+}
+
+// serve runs the chain, calling init (if it isn't nil) to add
+// request-specific values to the Context before any middleware run.
+func (hc HandlerChain) serve(w http.ResponseWriter, r *http.Request, init func(*Context)) {
 	// Always take a copy of context (i.e. pointing to a brand new memory location)
 	ctx := hc.context.copy()
+	ctx.inherit(hc.settings())
+	ctx.request = r
+	defer func() {
+		ctx.runDeferred(recover())
+	}()
+	if hc.record {
+		ctx.response = newResponseRecorder(w, func() time.Time { return Now(ctx) })
+		w = PreserveInterfaces(ctx.response)
+		defer ctx.response.finish(ctx)
+		if len(hc.ctxHeaders) > 0 {
+			BeforeWrite(ctx, func(w http.ResponseWriter) {
+				setContextHeaders(ctx, w.Header(), hc.ctxHeaders)
+			})
+		}
+	}
+	if hc.waitGo > 0 {
+		ctx.goroutines = &sync.WaitGroup{}
+		defer waitGoroutines(ctx.goroutines, hc.waitGo)
+	}
+	for _, f := range hc.funcs {
+		ctx.Put(f.key, f.fn(r))
+	}
+	if init != nil {
+		init(ctx)
+	}
 
 	final := hc.h(ctx)
+	if len(RequiredScopes(ctx)) > 0 {
+		final = checkScopes(ctx, final)
+	}
 	for i := len(hc.mws) - 1; i >= 0; i-- {
 		final = hc.mws[i](ctx, final)
 	}
 	final.ServeHTTP(w, r)
+	if hc.record && !ctx.response.Written() && !ctx.response.hijacked {
+		// Otherwise net/http sends the implicit 200 itself once the chain
+		// returns, bypassing the BeforeWrite callbacks.
+		ctx.response.WriteHeader(http.StatusOK)
+	}
 }
 
 func Inject(hc HandlerChain, key string, val interface{}) HandlerChain {
-	hc.context = hc.context.copy().Put(key, val)
+	ctx := hc.context.copy()
+	ctx.Put(key, val)
+	hc.context = ctx
+	hc.track(key)
 	return hc
 }
 
+// InjectFunc is like Inject, but calls fn for each request to compute the
+// value, before any middleware run. It saves writing a middleware for
+// values which depend on the request, such as a feature bucket derived
+// from a cookie.
+func InjectFunc(hc HandlerChain, key string, fn func(r *http.Request) interface{}) HandlerChain {
+	funcs := make([]injectedFunc, len(hc.funcs), len(hc.funcs)+1)
+	copy(funcs, hc.funcs)
+	hc.funcs = append(funcs, injectedFunc{key, fn})
+	hc.track(key)
+	return hc
+}
+
+// InjectMap is like Inject, but adds every value in vals, copying the
+// chain's Context only once.
+func InjectMap(hc HandlerChain, vals map[string]interface{}) HandlerChain {
+	ctx := hc.context.copy()
+	keys := make([]string, 0, len(vals))
+	for key, val := range vals {
+		ctx.Put(key, val)
+		keys = append(keys, key)
+	}
+	hc.context = ctx
+	hc.track(keys...)
+	return hc
+}
+
+// InjectPairs is like InjectMap, but takes alternating keys and values:
+//
+//	hc = stack.InjectPairs(hc, "template", "users/show", "cacheFor", time.Minute)
+//
+// It panics if a key isn't a string or the last key has no value.
+func InjectPairs(hc HandlerChain, kvs ...interface{}) HandlerChain {
+	if len(kvs)%2 != 0 {
+		panic("stack: InjectPairs called with an odd number of arguments")
+	}
+	ctx := hc.context.copy()
+	keys := make([]string, 0, len(kvs)/2)
+	for i := 0; i < len(kvs); i += 2 {
+		key, ok := kvs[i].(string)
+		if !ok {
+			panic(fmt.Sprintf("stack: InjectPairs key %v is not a string", kvs[i]))
+		}
+		ctx.Put(key, kvs[i+1])
+		keys = append(keys, key)
+	}
+	hc.context = ctx
+	hc.track(keys...)
+	return hc
+}
+
+// Std returns the chain as a single middleware with the signature
+// func(http.Handler) http.Handler, for use with routers such as chi which
+// accept that form (e.g. chi.Router.Use). Each request gets its own
+// Context, just as when the chain is closed with ThenHandler.
+func (c Chain) Std() func(http.Handler) http.Handler {
+	return c.Wrap
+}
+
+// Wrap returns h wrapped in the chain's middleware. It is the same as
+// ThenHandler, but returns a plain http.Handler so that the method value
+// c.Wrap can be passed wherever another framework expects a
+// func(http.Handler) http.Handler.
+func (c Chain) Wrap(h http.Handler) http.Handler {
+	return c.ThenHandler(h)
+}
+
 // Adapt third party middleware with the signature
-// func(http.Handler) http.Handler into chainMiddleware
+// func(http.Handler) http.Handler into chainMiddleware. The Context is
+// available to the middleware through FromRequest.
 func Adapt(fn func(http.Handler) http.Handler) chainMiddleware {
 	return func(ctx *Context, h http.Handler) http.Handler {
-		return fn(h)
+		wrapped := fn(h)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			wrapped.ServeHTTP(w, withContext(r, ctx))
+		})
+	}
+}
+
+// AdaptValues adapts middleware which only use the Context to get and put
+// values into chainMiddleware.
+func AdaptValues(fn func(v Values, next http.Handler) http.Handler) chainMiddleware {
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return fn(ContextValues(ctx), next)
+	}
+}
+
+// MustAdapt is like Adapt, but takes the results of a middleware
+// constructor which can fail, and panics if err is not nil. It is intended
+// for assembling chains at startup:
+//
+//	stack.New(stack.MustAdapt(cors.New(opts)), tokenMiddleware)
+func MustAdapt(fn func(http.Handler) http.Handler, err error) chainMiddleware {
+	if err != nil {
+		panic("stack: middleware constructor failed: " + err.Error())
+	}
+	return Adapt(fn)
+}
+
+// TryAppend adapts fn and appends it to the chain, unless err (the error
+// from fn's constructor) is not nil, in which case the chain is returned
+// unchanged along with err.
+func (c Chain) TryAppend(fn func(http.Handler) http.Handler, err error) (Chain, error) {
+	if err != nil {
+		return c, err
+	}
+	return c.Append(Adapt(fn)), nil
+}
+
+// AdaptNegroni adapts middleware with the negroni signature
+// func(http.ResponseWriter, *http.Request, http.HandlerFunc) into
+// chainMiddleware. As with Adapt, the Context is available to the
+// middleware through FromRequest.
+func AdaptNegroni(fn func(http.ResponseWriter, *http.Request, http.HandlerFunc)) chainMiddleware {
+	return func(ctx *Context, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fn(w, withContext(r, ctx), h.ServeHTTP)
+		})
 	}
 }
 
-// Adapt http.Handler into a chainHandler
+// Adapt http.Handler into a chainHandler. The Context is available to the
+// handler through FromRequest.
 func adaptHandler(h http.Handler) chainHandler {
 	return func(ctx *Context) http.Handler {
-		return h
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, withContext(r, ctx))
+		})
 	}
 }
 
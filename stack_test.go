@@ -124,3 +124,153 @@ func TestInject(t *testing.T) {
 	res = serveAndRequest(st)
 	assertEquals(t, "flipMiddleware>flipHandler [bish=<nil>,flip=<nil>]", res)
 }
+
+func TestInjectFunc(t *testing.T) {
+	st := New(flipMiddleware).Then(flipHandler)
+	st = InjectFunc(st, "bish", func(r *http.Request) interface{} { return r.URL.Path })
+	st2 := InjectFunc(st, "flip", func(r *http.Request) interface{} { return r.Method })
+
+	r, _ := http.NewRequest("GET", "/boop", nil)
+	rec := httptest.NewRecorder()
+	st2.ServeHTTP(rec, r)
+	assertEquals(t, "flipMiddleware>flipHandler [bish=/boop,flip=GET]", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, "flipMiddleware>flipHandler [bish=/boop,flip=<nil>]", rec.Body.String())
+}
+
+func TestInjectMap(t *testing.T) {
+	st := New(flipMiddleware).Then(flipHandler)
+	st2 := InjectMap(st, map[string]interface{}{"bish": "boop", "flip": "flop"})
+
+	res := serveAndRequest(st2)
+	assertEquals(t, "flipMiddleware>flipHandler [bish=boop,flip=flop]", res)
+
+	res = serveAndRequest(st)
+	assertEquals(t, "flipMiddleware>flipHandler [bish=<nil>,flip=<nil>]", res)
+}
+
+func TestInjectPairs(t *testing.T) {
+	st := InjectPairs(New(flipMiddleware).Then(flipHandler), "bish", "boop", "flip", 42)
+	res := serveAndRequest(st)
+	assertEquals(t, "flipMiddleware>flipHandler [bish=boop,flip=42]", res)
+
+	defer func() {
+		assertEquals(t, "stack: InjectPairs key 1 is not a string", recover())
+	}()
+	InjectPairs(st, 1, "bish")
+}
+
+func TestServeWithContext(t *testing.T) {
+	st := Inject(New(bishMiddleware).Then(bishHandler), "flip", "flop")
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	ctx := st.ServeWithContext(httptest.NewRecorder(), r)
+	assertEquals(t, "bash", ctx.Get("bish"))
+	assertEquals(t, "flop", ctx.Get("flip"))
+}
+
+func TestStd(t *testing.T) {
+	final := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "final [bish=%v]", FromRequest(r).Get("bish"))
+	})
+	mw := New(bishMiddleware, flipMiddleware).Std()
+	res := serveAndRequest(wobbleMiddleware(mw(final)))
+	assertEquals(t, "wobbleMiddleware>bishMiddleware>flipMiddleware>final [bish=bash]", res)
+
+	// Each request must get its own Context.
+	count := 0
+	counter := func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			count++
+			assertEquals(t, false, ctx.Exists("bish"))
+			ctx.Put("bish", "bash")
+			next.ServeHTTP(w, r)
+		})
+	}
+	h := New(counter).Std()(final)
+	serveAndRequest(h)
+	serveAndRequest(h)
+	assertEquals(t, 2, count)
+}
+
+func TestAdaptFromRequest(t *testing.T) {
+	reader := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "reader [bish=%v]>", FromRequest(r).Get("bish"))
+			next.ServeHTTP(w, r)
+		})
+	}
+	st := New(bishMiddleware, Adapt(reader)).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromRequest(r).Put("flip", "flop")
+		fmt.Fprint(w, "handler")
+	})
+	res := serveAndRequest(st)
+	assertEquals(t, "bishMiddleware>reader [bish=bash]>handler", res)
+}
+
+func TestAdaptNegroni(t *testing.T) {
+	negroniMiddleware := func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		fmt.Fprintf(w, "negroniMiddleware [bish=%v]>", FromRequest(r).Get("bish"))
+		next(w, r)
+	}
+	halting := func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		fmt.Fprint(w, "halted")
+	}
+
+	res := serveAndRequest(New(bishMiddleware, AdaptNegroni(negroniMiddleware), flipMiddleware).Then(bishHandler))
+	assertEquals(t, "bishMiddleware>negroniMiddleware [bish=bash]>flipMiddleware>bishHandler [bish=bash]", res)
+
+	res = serveAndRequest(New(AdaptNegroni(halting)).Then(bishHandler))
+	assertEquals(t, "halted", res)
+}
+
+func fallibleMiddleware(fail bool) (func(http.Handler) http.Handler, error) {
+	if fail {
+		return nil, errTest
+	}
+	return wobbleMiddleware, nil
+}
+
+func TestValuesAdapters(t *testing.T) {
+	mw := AdaptValues(func(v Values, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			v.Put("bish", "bash")
+			next.ServeHTTP(w, r)
+		})
+	})
+	st := New(mw).ThenValues(func(v Values, w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "bish=%v", v.Get("bish"))
+	})
+
+	assertEquals(t, "bish=bash", serveAndRequest(st))
+}
+
+func TestMustAdapt(t *testing.T) {
+	res := serveAndRequest(New(flipMiddleware, MustAdapt(fallibleMiddleware(false))).Then(bishHandler))
+	assertEquals(t, "flipMiddleware>wobbleMiddleware>bishHandler [bish=<nil>]", res)
+
+	defer func() {
+		assertEquals(t, "stack: middleware constructor failed: test error", recover())
+	}()
+	MustAdapt(fallibleMiddleware(true))
+}
+
+func TestTryAppend(t *testing.T) {
+	c, err := New(flipMiddleware).TryAppend(fallibleMiddleware(false))
+	assertEquals(t, nil, err)
+	res := serveAndRequest(c.Then(bishHandler))
+	assertEquals(t, "flipMiddleware>wobbleMiddleware>bishHandler [bish=<nil>]", res)
+
+	c, err = c.TryAppend(fallibleMiddleware(true))
+	assertEquals(t, errTest, err)
+	res = serveAndRequest(c.Then(bishHandler))
+	assertEquals(t, "flipMiddleware>wobbleMiddleware>bishHandler [bish=<nil>]", res)
+}
+
+func TestWrap(t *testing.T) {
+	var mw func(http.Handler) http.Handler = New(bishMiddleware).Wrap
+	res := serveAndRequest(mw(New(flipMiddleware).Then(bishHandler)))
+	assertEquals(t, "bishMiddleware>flipMiddleware>bishHandler [bish=<nil>]", res)
+}
@@ -0,0 +1,74 @@
+package stacktest
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/stack"
+)
+
+// AssertContains fails the test unless ctx holds want (compared with
+// reflect.DeepEqual) under key.
+func AssertContains(t testing.TB, ctx *stack.Context, key string, want interface{}) {
+	t.Helper()
+	if !ctx.Exists(key) {
+		t.Errorf("context key %q is missing (want %#v); keys are %s", key, want, formatKeys(ctx.Keys()))
+		return
+	}
+	if got := ctx.Get(key); !reflect.DeepEqual(got, want) {
+		t.Errorf("context key %q:\n...expected = %#v\n...obtained = %#v", key, want, got)
+	}
+}
+
+// AssertMissing fails the test if ctx holds a value under key.
+func AssertMissing(t testing.TB, ctx *stack.Context, key string) {
+	t.Helper()
+	if ctx.Exists(key) {
+		t.Errorf("context key %q should be missing, but is %#v", key, ctx.Get(key))
+	}
+}
+
+// AssertKeys fails the test unless ctx holds exactly the given keys, in
+// any order, listing any which are missing or unexpected.
+func AssertKeys(t testing.TB, ctx *stack.Context, keys ...string) {
+	t.Helper()
+	want := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		want[k] = true
+	}
+	var unexpected []string
+	for _, k := range ctx.Keys() {
+		if !want[k] {
+			unexpected = append(unexpected, k)
+		}
+		delete(want, k)
+	}
+	var missing []string
+	for k := range want {
+		missing = append(missing, k)
+	}
+	sort.Strings(missing)
+
+	if len(missing) == 0 && len(unexpected) == 0 {
+		return
+	}
+	var msg strings.Builder
+	msg.WriteString("context keys differ:")
+	if len(missing) > 0 {
+		msg.WriteString("\n...missing    = " + formatKeys(missing))
+	}
+	if len(unexpected) > 0 {
+		msg.WriteString("\n...unexpected = " + formatKeys(unexpected))
+	}
+	t.Error(msg.String())
+}
+
+func formatKeys(keys []string) string {
+	quoted := make([]string, len(keys))
+	for i, k := range keys {
+		quoted[i] = `"` + k + `"`
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,60 @@
+package stacktest
+
+import (
+	"fmt"
+	"testing"
+)
+
+// fakeT records the failures reported through it.
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (ft *fakeT) Helper() {}
+
+func (ft *fakeT) Errorf(format string, args ...interface{}) {
+	ft.errors = append(ft.errors, fmt.Sprintf(format, args...))
+}
+
+func (ft *fakeT) Error(args ...interface{}) {
+	ft.errors = append(ft.errors, fmt.Sprint(args...))
+}
+
+func TestAssertContains(t *testing.T) {
+	ctx := NewContext(map[string]interface{}{"bish": []string{"bash"}})
+
+	ft := &fakeT{}
+	AssertContains(ft, ctx, "bish", []string{"bash"})
+	assertEquals(t, 0, len(ft.errors))
+
+	AssertContains(ft, ctx, "bish", []string{"bosh"})
+	AssertContains(ft, ctx, "flip", "flop")
+	assertEquals(t, 2, len(ft.errors))
+	assertEquals(t, "context key \"bish\":\n...expected = []string{\"bosh\"}\n...obtained = []string{\"bash\"}", ft.errors[0])
+	assertEquals(t, `context key "flip" is missing (want "flop"); keys are ["bish"]`, ft.errors[1])
+}
+
+func TestAssertMissing(t *testing.T) {
+	ctx := NewContext(map[string]interface{}{"bish": "bash"})
+
+	ft := &fakeT{}
+	AssertMissing(ft, ctx, "flip")
+	assertEquals(t, 0, len(ft.errors))
+
+	AssertMissing(ft, ctx, "bish")
+	assertEquals(t, 1, len(ft.errors))
+	assertEquals(t, `context key "bish" should be missing, but is "bash"`, ft.errors[0])
+}
+
+func TestAssertKeys(t *testing.T) {
+	ctx := NewContext(map[string]interface{}{"bish": 1, "flip": 2})
+
+	ft := &fakeT{}
+	AssertKeys(ft, ctx, "flip", "bish")
+	assertEquals(t, 0, len(ft.errors))
+
+	AssertKeys(ft, ctx, "bish", "wobble")
+	assertEquals(t, 1, len(ft.errors))
+	assertEquals(t, "context keys differ:\n...missing    = [\"wobble\"]\n...unexpected = [\"flip\"]", ft.errors[0])
+}
@@ -0,0 +1,50 @@
+package stacktest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/alexedwards/stack"
+)
+
+// Benchmark drives hc with requests from newRequest, in memory, for b.N
+// iterations, reporting allocations along with the time per request. The
+// response is discarded. Requests are created inside the timed loop, as
+// stopping the timer for each one costs more than most chains, so the time
+// and allocations reported include those of newRequest; keep it cheap, or
+// benchmark it alone to see how much it adds:
+//
+//	func BenchmarkAPI(b *testing.B) {
+//		stacktest.Benchmark(b, hc, func() *http.Request {
+//			return httptest.NewRequest("GET", "/users/1", nil)
+//		})
+//	}
+func Benchmark(b *testing.B, hc stack.HandlerChain, newRequest func() *http.Request) {
+	b.Helper()
+	w := &discardWriter{header: make(http.Header)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := newRequest()
+		for k := range w.header {
+			delete(w.header, k)
+		}
+		hc.ServeHTTP(w, r)
+	}
+}
+
+// discardWriter is a ResponseWriter which throws the response away,
+// so that benchmarks measure the chain rather than the recorder.
+type discardWriter struct {
+	header http.Header
+}
+
+func (dw *discardWriter) Header() http.Header {
+	return dw.header
+}
+
+func (dw *discardWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (dw *discardWriter) WriteHeader(code int) {}
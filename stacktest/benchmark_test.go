@@ -0,0 +1,29 @@
+package stacktest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alexedwards/stack"
+)
+
+func TestBenchmark(t *testing.T) {
+	var served int
+	hc := stack.New(bishMiddleware).Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		served++
+		greetHandler(ctx, w, r)
+	})
+
+	res := testing.Benchmark(func(b *testing.B) {
+		Benchmark(b, hc, func() *http.Request {
+			return httptest.NewRequest("GET", "/", nil)
+		})
+	})
+	if res.N == 0 || served < res.N {
+		t.Fatalf("chain served %d requests in %d iterations", served, res.N)
+	}
+	if res.AllocsPerOp() == 0 {
+		t.Fatal("allocations weren't reported")
+	}
+}
@@ -0,0 +1,43 @@
+package stacktest
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock is a stack.Clock which only moves when told to, for use with
+// Chain.UseClock.
+type Clock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+// NewClock returns a Clock set to t.
+func NewClock(t time.Time) *Clock {
+	return &Clock{t: t}
+}
+
+// Now returns the Clock's current time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+// Advance moves the Clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// SequentialIDs returns an ID generator, for use with
+// Chain.UseIDGenerator, which returns prefix followed by 1, 2, 3 and so on.
+func SequentialIDs(prefix string) func() string {
+	var n int64
+	return func() string {
+		return prefix + strconv.FormatInt(atomic.AddInt64(&n, 1), 10)
+	}
+}
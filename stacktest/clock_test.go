@@ -0,0 +1,39 @@
+package stacktest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/stack"
+)
+
+func TestClock(t *testing.T) {
+	start := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	clock := NewClock(start)
+	var info stack.ResponseInfo
+	hc := stack.New(func(ctx *stack.Context, next http.Handler) http.Handler {
+		stack.OnFinish(ctx, func(i stack.ResponseInfo) { info = i })
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clock.Advance(time.Minute)
+			next.ServeHTTP(w, r)
+		})
+	}).UseClock(clock).RecordResponses().Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(stack.Now(ctx).Format(time.Kitchen)))
+	})
+
+	res := Record(hc, httptest.NewRequest("GET", "/", nil))
+	assertEquals(t, "3:05PM", res.Recorder.Body.String())
+	assertEquals(t, time.Minute, info.Duration)
+	assertEquals(t, start.Add(time.Minute), clock.Now())
+}
+
+func TestSequentialIDs(t *testing.T) {
+	hc := stack.New().UseIDGenerator(SequentialIDs("req-")).Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(stack.NewID(ctx)))
+	})
+
+	assertEquals(t, "req-1", Record(hc, httptest.NewRequest("GET", "/", nil)).Recorder.Body.String())
+	assertEquals(t, "req-2", Record(hc, httptest.NewRequest("GET", "/", nil)).Recorder.Body.String())
+}
@@ -0,0 +1,84 @@
+package stacktest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexedwards/stack"
+)
+
+// updateEnv names the environment variable which, when set to 1, makes
+// Golden write golden files rather than compare against them. It is an
+// environment variable rather than a flag so that importing stacktest
+// doesn't claim a flag name the test binary may already use.
+const updateEnv = "STACKTEST_UPDATE"
+
+// GoldenOption configures Golden.
+type GoldenOption func(*goldenConfig)
+
+// GoldenIgnore adds to the headers whose values change from run to run,
+// and so are replaced with a placeholder before comparison. Date and
+// X-Request-Id are always ignored.
+func GoldenIgnore(headers ...string) GoldenOption {
+	return func(c *goldenConfig) {
+		c.ignore = append(c.ignore, headers...)
+	}
+}
+
+type goldenConfig struct {
+	ignore []string
+}
+
+// Golden sends r through hc and compares the status, headers and body of
+// the response with the golden file at path, failing the test if they
+// differ. Running the tests with STACKTEST_UPDATE=1 in the environment
+// writes the response to the file instead, creating any missing
+// directories.
+func Golden(t testing.TB, hc stack.HandlerChain, r *http.Request, path string, opts ...GoldenOption) {
+	t.Helper()
+	cfg := &goldenConfig{ignore: []string{"Date", "X-Request-Id"}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	got := Record(hc, r).Recorder.Result()
+	defer got.Body.Close()
+	body, err := ioutil.ReadAll(got.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := got.Header.Clone()
+	for _, name := range cfg.ignore {
+		if h.Get(name) != "" {
+			h.Set(name, "<ignored>")
+		}
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "HTTP %d\n", got.StatusCode)
+	h.Write(&buf)
+	out := bytes.ReplaceAll(buf.Bytes(), []byte("\r\n"), []byte("\n"))
+	out = append(out, '\n')
+	out = append(out, body...)
+
+	if os.Getenv(updateEnv) == "1" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, out, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run the tests with %s=1 to create it)", err, updateEnv)
+	}
+	if !bytes.Equal(want, out) {
+		t.Errorf("response doesn't match %s:\n...expected =\n%s\n...obtained =\n%s", path, want, out)
+	}
+}
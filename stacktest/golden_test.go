@@ -0,0 +1,48 @@
+package stacktest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexedwards/stack"
+)
+
+func goldenChain() stack.HandlerChain {
+	return stack.New(bishMiddleware).Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Date", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.Header().Set("X-Trace", r.URL.Query().Get("trace"))
+		w.WriteHeader(http.StatusAccepted)
+		greetHandler(ctx, w, r)
+	})
+}
+
+func TestGolden(t *testing.T) {
+	Golden(t, goldenChain(), httptest.NewRequest("GET", "/?trace=1", nil), "testdata/greeting.golden", GoldenIgnore("X-Trace"))
+}
+
+func TestGoldenMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "greeting.golden")
+	if err := ioutil.WriteFile(path, []byte("HTTP 200\n\nhello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ft := &fakeT{}
+	Golden(ft, goldenChain(), httptest.NewRequest("GET", "/", nil), path)
+	assertEquals(t, 1, len(ft.errors))
+}
+
+func TestGoldenUpdate(t *testing.T) {
+	t.Setenv(updateEnv, "1")
+	path := filepath.Join(t.TempDir(), "new", "greeting.golden")
+	Golden(t, goldenChain(), httptest.NewRequest("GET", "/", nil), path)
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, true, len(b) > 0)
+}
@@ -0,0 +1,105 @@
+package stacktest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alexedwards/stack"
+)
+
+// HammerOption configures Hammer.
+type HammerOption func(*hammerConfig)
+
+// HammerRequests sets the function which creates the request for each
+// iteration, numbered from zero. By default every request is a GET for
+// "/".
+func HammerRequests(fn func(i int) *http.Request) HammerOption {
+	return func(c *hammerConfig) {
+		c.newRequest = fn
+	}
+}
+
+type hammerConfig struct {
+	newRequest func(i int) *http.Request
+}
+
+// HammerResult summarises the requests sent by Hammer.
+type HammerResult struct {
+	// Statuses counts the responses by status code.
+	Statuses map[int]int
+	// Errors describes each request which got a 5xx response or panicked.
+	Errors []error
+	// Panics holds the values of any panics, which are recovered so that
+	// the remaining requests still run.
+	Panics []interface{}
+}
+
+// Err returns an error listing the failed requests, or nil if there
+// weren't any.
+func (hr *HammerResult) Err() error {
+	if len(hr.Errors) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(hr.Errors))
+	for i, err := range hr.Errors {
+		msgs[i] = err.Error()
+	}
+	sort.Strings(msgs)
+	return fmt.Errorf("%d of the requests failed:\n%s", len(msgs), strings.Join(msgs, "\n"))
+}
+
+// Hammer sends n requests through hc, running up to concurrency of them at
+// once, and reports how they went. It is meant to be run with the race
+// detector enabled (go test -race), so that unsynchronised access to
+// shared state in middleware shows up:
+//
+//	if err := stacktest.Hammer(hc, 1000, 50).Err(); err != nil {
+//		t.Fatal(err)
+//	}
+func Hammer(hc stack.HandlerChain, n int, concurrency int, opts ...HammerOption) *HammerResult {
+	cfg := &hammerConfig{
+		newRequest: func(i int) *http.Request { return httptest.NewRequest("GET", "/", nil) },
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	res := &HammerResult{Statuses: make(map[int]int)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				if p := recover(); p != nil {
+					mu.Lock()
+					res.Panics = append(res.Panics, p)
+					res.Errors = append(res.Errors, fmt.Errorf("request %d: panic: %v", i, p))
+					mu.Unlock()
+				}
+				<-sem
+				wg.Done()
+			}()
+			rec := httptest.NewRecorder()
+			hc.ServeHTTP(rec, cfg.newRequest(i))
+
+			mu.Lock()
+			defer mu.Unlock()
+			res.Statuses[rec.Code]++
+			if rec.Code >= 500 {
+				res.Errors = append(res.Errors, fmt.Errorf("request %d: status %d", i, rec.Code))
+			}
+		}(i)
+	}
+	wg.Wait()
+	return res
+}
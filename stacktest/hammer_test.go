@@ -0,0 +1,41 @@
+package stacktest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alexedwards/stack"
+)
+
+func TestHammer(t *testing.T) {
+	var served int64
+	hc := stack.Inject(stack.New(bishMiddleware).Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&served, 1)
+		switch r.URL.Path {
+		case "/3":
+			panic("bish")
+		case "/5":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		greetHandler(ctx, w, r)
+	}), "greeting", "hello")
+
+	res := Hammer(hc, 20, 4, HammerRequests(func(i int) *http.Request {
+		return httptest.NewRequest("GET", fmt.Sprintf("/%d", i), nil)
+	}))
+	assertEquals(t, int64(20), atomic.LoadInt64(&served))
+	assertEquals(t, 18, res.Statuses[200])
+	assertEquals(t, 1, res.Statuses[503])
+	assertEquals(t, 1, len(res.Panics))
+	assertEquals(t, "bish", res.Panics[0])
+	err := res.Err()
+	if err == nil || !strings.Contains(err.Error(), "request 3: panic: bish\nrequest 5: status 503") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertEquals(t, nil, Hammer(hc, 10, 10).Err())
+}
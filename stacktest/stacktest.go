@@ -0,0 +1,108 @@
+// Package stacktest provides helpers for testing chains, middleware and
+// handlers in memory, without starting a server:
+//
+//	res := stacktest.Record(hc, httptest.NewRequest("GET", "/", nil))
+//	if res.Recorder.Code != http.StatusOK { ... }
+//	if res.Context.Get("user") == nil { ... }
+package stacktest
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/alexedwards/stack"
+)
+
+// Result is the outcome of a request recorded with Record.
+type Result struct {
+	// Recorder holds the response written by the chain.
+	Recorder *httptest.ResponseRecorder
+	// Context is a copy of the request's Context, taken after the chain
+	// returned.
+	Context *stack.Context
+}
+
+// Record sends r through hc and returns the response along with the final
+// state of the request's Context.
+func Record(hc stack.HandlerChain, r *http.Request) *Result {
+	rec := httptest.NewRecorder()
+	ctx := hc.ServeWithContext(rec, r)
+	return &Result{Recorder: rec, Context: snapshot(ctx)}
+}
+
+// NewContext returns a Context holding values, for calling handlers and
+// middleware directly.
+func NewContext(values map[string]interface{}) *stack.Context {
+	ctx := stack.NewContext()
+	for k, v := range values {
+		ctx.Put(k, v)
+	}
+	return ctx
+}
+
+// snapshot copies the values in ctx, so that later changes (such as from
+// goroutines started by the handler) don't affect the copy.
+func snapshot(ctx *stack.Context) *stack.Context {
+	nc := stack.NewContext()
+	for _, k := range ctx.Keys() {
+		nc.Put(k, ctx.Get(k))
+	}
+	return nc
+}
+
+// MiddlewareResult is the outcome of running a middleware with
+// RunMiddleware.
+type MiddlewareResult struct {
+	// Called reports whether the middleware called the next handler.
+	Called bool
+	// Request is the request passed to the next handler, or nil if it
+	// wasn't called.
+	Request *http.Request
+	// NextContext is a copy of the Context taken when the next handler was
+	// called, or nil if it wasn't.
+	NextContext *stack.Context
+	// Context is a copy of the Context taken after the middleware returned.
+	Context *stack.Context
+	// Recorder holds the response written by the middleware.
+	Recorder *httptest.ResponseRecorder
+}
+
+// RunMiddleware runs a single middleware for r, in a chain of its own,
+// with a next handler which records how it was called and writes nothing.
+func RunMiddleware(mw func(*stack.Context, http.Handler) http.Handler, r *http.Request) *MiddlewareResult {
+	res := &MiddlewareResult{Recorder: httptest.NewRecorder()}
+	hc := stack.New(mw).Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		res.Called = true
+		res.Request = r
+		res.NextContext = snapshot(ctx)
+	})
+	res.Context = snapshot(hc.ServeWithContext(res.Recorder, r))
+	return res
+}
+
+// MapValues is a stack.Values backed by a plain map, for testing code
+// which accepts a Values. It isn't safe for concurrent use.
+type MapValues map[string]interface{}
+
+var _ stack.Values = MapValues(nil)
+
+// Get implements stack.Values.
+func (m MapValues) Get(key string) interface{} {
+	return m[key]
+}
+
+// Put implements stack.Values.
+func (m MapValues) Put(key string, val interface{}) {
+	m[key] = val
+}
+
+// Delete implements stack.Values.
+func (m MapValues) Delete(key string) {
+	delete(m, key)
+}
+
+// Exists implements stack.Values.
+func (m MapValues) Exists(key string) bool {
+	_, ok := m[key]
+	return ok
+}
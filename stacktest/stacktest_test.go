@@ -0,0 +1,92 @@
+package stacktest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alexedwards/stack"
+)
+
+func assertEquals(t *testing.T, e interface{}, o interface{}) {
+	if e != o {
+		t.Errorf("\n...expected = %v\n...obtained = %v", e, o)
+	}
+}
+
+func bishMiddleware(ctx *stack.Context, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx.Put("bish", "bash")
+		next.ServeHTTP(w, r)
+	})
+}
+
+func greetHandler(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "%v %v", ctx.Get("greeting"), ctx.Get("bish"))
+}
+
+func TestRecord(t *testing.T) {
+	hc := stack.Inject(stack.New(bishMiddleware).Then(greetHandler), "greeting", "hello")
+
+	res := Record(hc, httptest.NewRequest("GET", "/", nil))
+	assertEquals(t, 200, res.Recorder.Code)
+	assertEquals(t, "hello bash", res.Recorder.Body.String())
+	assertEquals(t, "bash", res.Context.Get("bish"))
+	assertEquals(t, "hello", res.Context.Get("greeting"))
+}
+
+func TestNewContext(t *testing.T) {
+	ctx := NewContext(map[string]interface{}{"greeting": "hi", "bish": "bosh"})
+
+	rec := httptest.NewRecorder()
+	greetHandler(ctx, rec, httptest.NewRequest("GET", "/", nil))
+	assertEquals(t, "hi bosh", rec.Body.String())
+}
+
+func TestRunMiddleware(t *testing.T) {
+	tagMiddleware := func(ctx *stack.Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx.Put("bish", "bash")
+			r.Header.Set("X-Tag", "flip")
+			next.ServeHTTP(w, r)
+			ctx.Put("bish", "bosh")
+		})
+	}
+
+	res := RunMiddleware(tagMiddleware, httptest.NewRequest("GET", "/", nil))
+	assertEquals(t, true, res.Called)
+	assertEquals(t, "flip", res.Request.Header.Get("X-Tag"))
+	assertEquals(t, "bash", res.NextContext.Get("bish"))
+	assertEquals(t, "bosh", res.Context.Get("bish"))
+}
+
+func TestRunMiddlewareNotCalled(t *testing.T) {
+	denyMiddleware := func(ctx *stack.Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			stack.Error(ctx, w, r, stack.NewHTTPError(http.StatusForbidden, nil))
+		})
+	}
+
+	res := RunMiddleware(denyMiddleware, httptest.NewRequest("GET", "/", nil))
+	assertEquals(t, false, res.Called)
+	assertEquals(t, (*http.Request)(nil), res.Request)
+	assertEquals(t, 403, res.Recorder.Code)
+}
+
+func TestMapValues(t *testing.T) {
+	greet := func(v stack.Values, w http.ResponseWriter, r *http.Request) {
+		if !v.Exists("greeting") {
+			v.Put("greeting", "hello")
+		}
+		v.Delete("bish")
+		fmt.Fprint(w, v.Get("greeting"))
+	}
+
+	v := MapValues{"bish": "bash"}
+	rec := httptest.NewRecorder()
+	greet(v, rec, httptest.NewRequest("GET", "/", nil))
+	assertEquals(t, "hello", rec.Body.String())
+	assertEquals(t, "hello", v["greeting"])
+	assertEquals(t, false, v.Exists("bish"))
+}
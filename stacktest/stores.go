@@ -0,0 +1,143 @@
+package stacktest
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alexedwards/stack/session"
+)
+
+var _ session.Store = (*SessionStore)(nil)
+
+// SessionStore is an in-memory session.Store which records how it is
+// used and can be made to fail, for testing chains which use sessions.
+// New sessions get the tokens "session-1", "session-2" and so on.
+type SessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]sessionEntry
+	created  int
+	hits     int
+	misses   int
+	saves    int
+	deletes  int
+	err      error
+}
+
+type sessionEntry struct {
+	values map[string]interface{}
+	expiry time.Time
+}
+
+// NewSessionStore returns an empty SessionStore.
+func NewSessionStore() *SessionStore {
+	return &SessionStore{sessions: make(map[string]sessionEntry)}
+}
+
+// Load implements session.Store.
+func (s *SessionStore) Load(token string) (map[string]interface{}, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, false, s.err
+	}
+	e, ok := s.sessions[token]
+	if !ok || time.Now().After(e.expiry) {
+		s.misses++
+		return nil, false, nil
+	}
+	s.hits++
+	return copyMap(e.values), true, nil
+}
+
+// Save implements session.Store.
+func (s *SessionStore) Save(token string, values map[string]interface{}, expiry time.Time) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return "", s.err
+	}
+	if token == "" {
+		s.created++
+		token = "session-" + strconv.Itoa(s.created)
+	}
+	s.saves++
+	s.sessions[token] = sessionEntry{values: copyMap(values), expiry: expiry}
+	return token, nil
+}
+
+// Delete implements session.Store.
+func (s *SessionStore) Delete(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.deletes++
+	delete(s.sessions, token)
+	return nil
+}
+
+// Entries returns a copy of the values of every stored session, keyed by
+// token, including expired sessions.
+func (s *SessionStore) Entries() map[string]map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make(map[string]map[string]interface{}, len(s.sessions))
+	for token, e := range s.sessions {
+		entries[token] = copyMap(e.values)
+	}
+	return entries
+}
+
+// Expiry returns the expiry time of the session with token, or the zero
+// Time if there isn't one.
+func (s *SessionStore) Expiry(token string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[token].expiry
+}
+
+// Hits returns the number of calls to Load which found a session.
+func (s *SessionStore) Hits() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits
+}
+
+// Misses returns the number of calls to Load which didn't find a session.
+func (s *SessionStore) Misses() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.misses
+}
+
+// Saves returns the number of successful calls to Save.
+func (s *SessionStore) Saves() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saves
+}
+
+// Deletes returns the number of successful calls to Delete.
+func (s *SessionStore) Deletes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deletes
+}
+
+// Fail makes every call to the store return err, simulating an outage,
+// until Fail is called again with nil.
+func (s *SessionStore) Fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
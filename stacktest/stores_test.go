@@ -0,0 +1,43 @@
+package stacktest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alexedwards/stack"
+	"github.com/alexedwards/stack/session"
+)
+
+func TestSessionStore(t *testing.T) {
+	store := NewSessionStore()
+	var storeErr error
+	hc := stack.New(session.New(store, session.ErrorFunc(func(err error) { storeErr = err }))).RecordResponses().Then(func(ctx *stack.Context, w http.ResponseWriter, r *http.Request) {
+		sess := session.FromContext(ctx)
+		n, _ := sess.Get("visits").(int)
+		sess.Put("visits", n+1)
+	})
+
+	res := Record(hc, httptest.NewRequest("GET", "/", nil))
+	assertEquals(t, 1, store.Saves())
+	assertEquals(t, 1, store.Entries()["session-1"]["visits"])
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Cookie", res.Recorder.Header().Get("Set-Cookie"))
+	Record(hc, r)
+	assertEquals(t, 1, store.Hits())
+	assertEquals(t, 2, store.Entries()["session-1"]["visits"])
+	assertEquals(t, false, store.Expiry("session-1").IsZero())
+
+	errDown := errors.New("store down")
+	store.Fail(errDown)
+	Record(hc, r)
+	assertEquals(t, errDown, storeErr)
+	assertEquals(t, 2, store.Saves())
+
+	store.Fail(nil)
+	assertEquals(t, nil, store.Delete("session-1"))
+	assertEquals(t, 0, len(store.Entries()))
+	assertEquals(t, 1, store.Deletes())
+}
@@ -0,0 +1,66 @@
+package stacktest
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/alexedwards/stack"
+)
+
+// Tracer is an http.Handler which runs a chain and records the order in
+// which its named middleware (see stack.Named) are entered and exited.
+type Tracer struct {
+	hc       stack.HandlerChain
+	mu       sync.Mutex
+	requests [][]string
+}
+
+// Trace returns a Tracer for hc. Each request served by the Tracer gets its
+// own trace, a list of events such as "enter auth" and "exit auth":
+//
+//	tr := stacktest.Trace(hc)
+//	tr.ServeHTTP(httptest.NewRecorder(), req)
+//	// tr.Last() is [enter log, enter auth, exit auth, exit log]
+func Trace(hc stack.HandlerChain) *Tracer {
+	return &Tracer{hc: hc}
+}
+
+func (tr *Tracer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var mu sync.Mutex
+	var events []string
+	hc := stack.WithTrace(tr.hc, func(ctx *stack.Context, name string, exit bool) {
+		event := "enter " + name
+		if exit {
+			event = "exit " + name
+		}
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	})
+	hc.ServeHTTP(w, r)
+
+	mu.Lock()
+	defer mu.Unlock()
+	tr.mu.Lock()
+	tr.requests = append(tr.requests, events)
+	tr.mu.Unlock()
+}
+
+// Requests returns the traces of every request served so far, in the order
+// the requests finished.
+func (tr *Tracer) Requests() [][]string {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return append([][]string(nil), tr.requests...)
+}
+
+// Last returns the trace of the most recently finished request, or nil if
+// there hasn't been one.
+func (tr *Tracer) Last() []string {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if len(tr.requests) == 0 {
+		return nil
+	}
+	return tr.requests[len(tr.requests)-1]
+}
@@ -0,0 +1,35 @@
+package stacktest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/stack"
+)
+
+func TestTrace(t *testing.T) {
+	deny := func(ctx *stack.Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/private" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	hc := stack.New(
+		stack.Named("log", bishMiddleware),
+		stack.Named("authz", deny),
+		stack.Named("bish", bishMiddleware),
+	).Then(greetHandler)
+
+	tr := Trace(hc)
+	assertEquals(t, 0, len(tr.Last()))
+	tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assertEquals(t, "enter log, enter authz, enter bish, exit bish, exit authz, exit log", strings.Join(tr.Last(), ", "))
+	tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/private", nil))
+	assertEquals(t, "enter log, enter authz, exit authz, exit log", strings.Join(tr.Last(), ", "))
+	assertEquals(t, 2, len(tr.Requests()))
+}
@@ -0,0 +1,45 @@
+package stack
+
+import "net/http"
+
+// NoContent sends a 204 No Content response. It marks the response as
+// intentionally empty on the chain's ResponseRecorder, if there is one, so
+// that logging and metrics middleware can tell it apart from a handler
+// which forgot to write a body (see ResponseInfo.Empty).
+func NoContent(w http.ResponseWriter) {
+	if rr := findRecorder(w); rr != nil {
+		rr.empty = true
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Status sends a response with the given status code and no body, such as
+// a 202 Accepted. Like NoContent it marks the response as intentionally
+// empty, and it also opts the request out of the ETag middleware, as there
+// is nothing to hash.
+func Status(ctx *Context, w http.ResponseWriter, code int) {
+	SkipETag(ctx)
+	if ctx.response != nil {
+		ctx.response.empty = true
+	}
+	w.WriteHeader(code)
+}
+
+// findRecorder unwraps w until it reaches a ResponseRecorder, returning nil
+// if there isn't one.
+func findRecorder(w http.ResponseWriter) *ResponseRecorder {
+	for {
+		if rr, ok := w.(*ResponseRecorder); ok {
+			return rr
+		}
+		if p, ok := w.(interface{ wrapped() WrappedWriter }); ok {
+			w = p.wrapped()
+			continue
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = u.Unwrap()
+	}
+}
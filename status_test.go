@@ -0,0 +1,61 @@
+package stack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNoContent(t *testing.T) {
+	var info ResponseInfo
+	st := New(func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			OnFinish(ctx, func(i ResponseInfo) { info = i })
+			next.ServeHTTP(w, r)
+		})
+	}, ETag()).RecordResponses().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		NoContent(w)
+	})
+	r, _ := http.NewRequest("DELETE", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 204, rec.Code)
+	assertEquals(t, 204, info.Status)
+	assertEquals(t, true, info.Empty)
+
+	// Without a recorder it still sends the status.
+	st = New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		NoContent(w)
+	})
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 204, rec.Code)
+}
+
+func TestStatus(t *testing.T) {
+	var info ResponseInfo
+	var ctx *Context
+	st := New(func(c *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			OnFinish(c, func(i ResponseInfo) { info = i })
+			next.ServeHTTP(w, r)
+		})
+	}, ETag()).RecordResponses().Then(func(c *Context, w http.ResponseWriter, r *http.Request) {
+		ctx = c
+		Status(c, w, http.StatusOK)
+	})
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "", rec.Header().Get("ETag"))
+	assertEquals(t, true, info.Empty)
+	assertEquals(t, true, Response(ctx).Empty())
+
+	st = New().RecordResponses().Then(func(c *Context, w http.ResponseWriter, r *http.Request) {
+		ctx = c
+		w.WriteHeader(http.StatusOK)
+	})
+	st.ServeHTTP(httptest.NewRecorder(), r)
+	assertEquals(t, false, Response(ctx).Empty())
+}
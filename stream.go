@@ -0,0 +1,104 @@
+package stack
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const streamingKey = "stack.streaming"
+
+// streamFlushInterval is how often Stream flushes data written since the
+// last flush.
+const streamFlushInterval = 100 * time.Millisecond
+
+// Stream sends a response body written by fn as it is produced, such as a
+// large export or the output of a long-running job. It stops the ETag and
+// Transform middleware from buffering the response, and flushes whatever
+// fn has written every 100ms, and when fn returns.
+//
+// Once the client disconnects, writes fail with the request context's
+// error, so fn should return when a write fails. If fn returns an error
+// before writing anything, it is passed to the chain's error handler as
+// usual. After that the response can't be changed, so the error is
+// recorded for OnFinish callbacks (in ResponseInfo.Err) instead, and the
+// response ends where fn stopped. Stream returns fn's error.
+func Stream(ctx *Context, w http.ResponseWriter, fn func(w io.Writer) error) error {
+	SkipETag(ctx)
+	ctx.Put(streamingKey, true)
+
+	reqCtx := context.Background()
+	if ctx.request != nil {
+		reqCtx = ctx.request.Context()
+	}
+	sw := &streamWriter{w: w, rc: http.NewResponseController(w), ctx: reqCtx}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(streamFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sw.flush()
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	err := fn(sw)
+	close(stop)
+	wg.Wait()
+	sw.flush()
+
+	if err == nil {
+		return nil
+	}
+	sw.mu.Lock()
+	started := sw.started
+	sw.mu.Unlock()
+	if !started {
+		Error(ctx, w, ctx.request, err)
+		return err
+	}
+	ctx.err = err
+	return err
+}
+
+// streamWriter serializes writes and flushes, which happen on different
+// goroutines.
+type streamWriter struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	ctx     context.Context
+	started bool
+	dirty   bool
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if err := sw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.started = true
+	sw.dirty = true
+	return sw.w.Write(p)
+}
+
+func (sw *streamWriter) flush() {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if !sw.dirty {
+		return
+	}
+	sw.dirty = false
+	// Writers which can't flush have nothing buffered to flush.
+	sw.rc.Flush()
+}
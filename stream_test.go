@@ -0,0 +1,98 @@
+package stack
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	lines := make(chan string, 1)
+	st := New(ETag(), Transform(BufferTransformer(func(ctx *Context, h http.Header, body []byte) ([]byte, error) {
+		return []byte("transformed"), nil
+	}))).Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		Stream(ctx, w, func(w io.Writer) error {
+			for line := range lines {
+				fmt.Fprintln(w, line)
+			}
+			return nil
+		})
+	})
+	ts := httptest.NewServer(st)
+	defer ts.Close()
+
+	// Each line must arrive before the next is written.
+	lines <- "bish"
+	res, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	br := bufio.NewReader(res.Body)
+	for _, tc := range []struct{ want, next string }{{"bish\n", "bash"}, {"bash\n", "bosh"}} {
+		got, err := br.ReadString('\n')
+		assertEquals(t, nil, err)
+		assertEquals(t, tc.want, got)
+		lines <- tc.next
+	}
+	close(lines)
+	rest, _ := io.ReadAll(br)
+	assertEquals(t, "bosh\n", string(rest))
+	assertEquals(t, "", res.Header.Get("ETag"))
+}
+
+func TestStreamErrors(t *testing.T) {
+	var info ResponseInfo
+	fail := errors.New("export failed")
+	st := func(write bool) HandlerChain {
+		return New(func(ctx *Context, next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				OnFinish(ctx, func(i ResponseInfo) { info = i })
+				next.ServeHTTP(w, r)
+			})
+		}).RecordResponses().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+			Stream(ctx, w, func(w io.Writer) error {
+				if write {
+					io.WriteString(w, "partial")
+				}
+				return fail
+			})
+		})
+	}
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st(false).ServeHTTP(rec, r)
+	assertEquals(t, 500, rec.Code)
+	assertEquals(t, fail, info.Err)
+
+	rec = httptest.NewRecorder()
+	st(true).ServeHTTP(rec, r)
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, "partial", rec.Body.String())
+	assertEquals(t, fail, info.Err)
+}
+
+func TestStreamClientGone(t *testing.T) {
+	var writeErr error
+	st := New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		Stream(ctx, w, func(w io.Writer) error {
+			_, writeErr = io.WriteString(w, "bish")
+			return writeErr
+		})
+	})
+	reqCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r, _ := http.NewRequestWithContext(reqCtx, "GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, context.Canceled, writeErr)
+	assertEquals(t, false, strings.Contains(rec.Body.String(), "bish"))
+}
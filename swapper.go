@@ -0,0 +1,38 @@
+package stack
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Swapper is an http.Handler serving a HandlerChain which can be replaced
+// while the server is running, such as to apply new configuration to the
+// middleware. Requests in progress when the chain is swapped finish on the
+// chain they started on.
+type Swapper struct {
+	v atomic.Value
+}
+
+// NewSwapper returns a Swapper serving hc.
+func NewSwapper(hc HandlerChain) *Swapper {
+	s := &Swapper{}
+	s.v.Store(hc)
+	return s
+}
+
+// Swap replaces the chain used for new requests with hc and returns the
+// previous one. It doesn't call either chain's lifecycle hooks; callers
+// should Start hc before swapping it in, and Stop the old chain once they
+// are happy for it to finish its requests.
+func (s *Swapper) Swap(hc HandlerChain) HandlerChain {
+	return s.v.Swap(hc).(HandlerChain)
+}
+
+// Current returns the chain used for new requests.
+func (s *Swapper) Current() HandlerChain {
+	return s.v.Load().(HandlerChain)
+}
+
+func (s *Swapper) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Current().ServeHTTP(w, r)
+}
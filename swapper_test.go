@@ -0,0 +1,37 @@
+package stack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestSwapper(t *testing.T) {
+	bish := New(bishMiddleware).Then(bishHandler)
+	flip := New(flipMiddleware).Then(flipHandler)
+	s := NewSwapper(bish)
+
+	assertEquals(t, "bishMiddleware>bishHandler [bish=bash]", serveAndRequest(s))
+	old := s.Swap(flip)
+	assertEquals(t, "bishMiddleware>bishHandler [bish=bash]", serveAndRequest(old))
+	assertEquals(t, "flipMiddleware>flipHandler [bish=<nil>,flip=<nil>]", serveAndRequest(s))
+}
+
+func TestSwapperConcurrent(t *testing.T) {
+	s := NewSwapper(New(bishMiddleware).Then(bishHandler))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			r, _ := http.NewRequest("GET", "/", nil)
+			s.ServeHTTP(httptest.NewRecorder(), r)
+		}()
+		go func() {
+			defer wg.Done()
+			s.Swap(New(flipMiddleware).Then(flipHandler))
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,29 @@
+package stack
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+const tempDirKey = "stack.tempDir"
+
+// TempDir returns a temporary directory for the current request, creating
+// it on first use. The directory and everything in it are removed when the
+// chain has finished with the request (see Context.Defer), even if the
+// handler panics.
+func TempDir(ctx *Context) (string, error) {
+	dir, err := ctx.GetOrCompute(tempDirKey, func() (interface{}, error) {
+		dir, err := ioutil.TempDir("", "stack-")
+		if err != nil {
+			return nil, err
+		}
+		// If another goroutine creates a directory at the same time this
+		// one is discarded, but it's still removed.
+		ctx.Defer(func() { os.RemoveAll(dir) })
+		return dir, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return dir.(string), nil
+}
@@ -0,0 +1,39 @@
+package stack
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTempDir(t *testing.T) {
+	var dir string
+	st := New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		var err error
+		dir, err = TempDir(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, _ := TempDir(ctx)
+		assertEquals(t, dir, again)
+		if err := ioutil.WriteFile(filepath.Join(dir, "upload"), []byte("bish"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		panic("bash")
+	})
+
+	func() {
+		defer func() { recover() }()
+		r, _ := http.NewRequest("GET", "/", nil)
+		st.ServeHTTP(httptest.NewRecorder(), r)
+	}()
+	if dir == "" {
+		t.Fatal("no directory created")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("%s wasn't removed: %v", dir, err)
+	}
+}
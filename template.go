@@ -0,0 +1,135 @@
+package stack
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ChainTemplate describes the shape of a family of chains: fixed
+// middleware, plus named slots which each service or route fills with
+// middleware of its own (or leaves empty) when it builds a Chain:
+//
+//	base := stack.NewChainTemplate(recoverer, logger).
+//		RequiredSlot("auth").
+//		Slot("ratelimit").
+//		Append(csrf)
+//
+//	api := base.Fill("auth", tokenAuth).Fill("ratelimit", limiter).MustChain()
+//	admin := base.Fill("auth", sessionAuth, requireAdmin).MustChain()
+//
+// Like Chain, a ChainTemplate is never modified by its methods, which
+// return a new ChainTemplate instead.
+type ChainTemplate struct {
+	parts []templatePart
+	fills map[string][]chainMiddleware
+}
+
+// templatePart is either a middleware or a slot.
+type templatePart struct {
+	mw       chainMiddleware
+	slot     string
+	required bool
+}
+
+// NewChainTemplate returns a ChainTemplate starting with mws.
+func NewChainTemplate(mws ...chainMiddleware) ChainTemplate {
+	return ChainTemplate{}.Append(mws...)
+}
+
+// Append adds mws to the end of the template.
+func (t ChainTemplate) Append(mws ...chainMiddleware) ChainTemplate {
+	parts := make([]templatePart, len(t.parts), len(t.parts)+len(mws))
+	copy(parts, t.parts)
+	for _, mw := range mws {
+		parts = append(parts, templatePart{mw: mw})
+	}
+	t.parts = parts
+	return t
+}
+
+// Slot adds an optional slot called name to the end of the template. It
+// panics if the template already has a slot with that name.
+func (t ChainTemplate) Slot(name string) ChainTemplate {
+	return t.addSlot(name, false)
+}
+
+// RequiredSlot is like Slot, but Chain returns an error unless the slot
+// has been filled.
+func (t ChainTemplate) RequiredSlot(name string) ChainTemplate {
+	return t.addSlot(name, true)
+}
+
+func (t ChainTemplate) addSlot(name string, required bool) ChainTemplate {
+	if t.hasSlot(name) {
+		panic("stack: duplicate template slot " + name)
+	}
+	parts := make([]templatePart, len(t.parts), len(t.parts)+1)
+	copy(parts, t.parts)
+	t.parts = append(parts, templatePart{slot: name, required: required})
+	return t
+}
+
+func (t ChainTemplate) hasSlot(name string) bool {
+	for _, p := range t.parts {
+		if p.mw == nil && p.slot == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Fill puts mws in the named slot, replacing anything it was filled with
+// before. Filling a slot with no middleware counts as filling it, so that
+// a required slot can be left empty deliberately. The middleware are
+// given the slot's name with Named, so they can be traced and toggled.
+func (t ChainTemplate) Fill(name string, mws ...chainMiddleware) ChainTemplate {
+	fills := make(map[string][]chainMiddleware, len(t.fills)+1)
+	for slot, fill := range t.fills {
+		fills[slot] = fill
+	}
+	fills[name] = mws
+	t.fills = fills
+	return t
+}
+
+// Chain builds a Chain from the template and its filled slots. It returns
+// an error if a required slot hasn't been filled, or if a slot which the
+// template doesn't have has been.
+func (t ChainTemplate) Chain() (Chain, error) {
+	var unknown []string
+	for name := range t.fills {
+		if !t.hasSlot(name) {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return Chain{}, fmt.Errorf("stack: template has no slot %q", unknown[0])
+	}
+
+	var mws []chainMiddleware
+	for _, p := range t.parts {
+		if p.mw != nil {
+			mws = append(mws, p.mw)
+			continue
+		}
+		fill, ok := t.fills[p.slot]
+		if !ok && p.required {
+			return Chain{}, fmt.Errorf("stack: required template slot %q not filled", p.slot)
+		}
+		for _, mw := range fill {
+			mws = append(mws, Named(p.slot, mw))
+		}
+	}
+	return New(mws...), nil
+}
+
+// MustChain is like Chain, but panics if the template can't be built. It
+// is intended for assembling chains at startup.
+func (t ChainTemplate) MustChain() Chain {
+	c, err := t.Chain()
+	if err != nil {
+		panic(err.Error())
+	}
+	return c
+}
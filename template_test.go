@@ -0,0 +1,32 @@
+package stack
+
+import "testing"
+
+func TestChainTemplate(t *testing.T) {
+	base := NewChainTemplate(flipMiddleware).RequiredSlot("auth").Slot("extra").Append(flipMiddleware)
+
+	c := base.Fill("auth", bishMiddleware).MustChain()
+	assertEquals(t, "flipMiddleware>bishMiddleware>flipMiddleware>bishHandler [bish=bash]", serveAndRequest(c.Then(bishHandler)))
+
+	c = base.Fill("auth").Fill("extra", bishMiddleware, bishMiddleware).MustChain()
+	assertEquals(t, "flipMiddleware>bishMiddleware>bishMiddleware>flipMiddleware>bishHandler [bish=bash]", serveAndRequest(c.Then(bishHandler)))
+
+	// Slot middleware are named after the slot.
+	c = base.Fill("auth", bishMiddleware).MustChain().Toggle(map[string]bool{"auth": false})
+	assertEquals(t, "flipMiddleware>flipMiddleware>bishHandler [bish=<nil>]", serveAndRequest(c.Then(bishHandler)))
+}
+
+func TestChainTemplateErrors(t *testing.T) {
+	base := NewChainTemplate().RequiredSlot("auth").Slot("extra")
+
+	_, err := base.Chain()
+	assertEquals(t, `stack: required template slot "auth" not filled`, err.Error())
+
+	_, err = base.Fill("auth").Fill("bish", bishMiddleware).Chain()
+	assertEquals(t, `stack: template has no slot "bish"`, err.Error())
+
+	defer func() {
+		assertEquals(t, "stack: duplicate template slot extra", recover())
+	}()
+	base.Slot("extra")
+}
@@ -0,0 +1,70 @@
+package stack
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Optional is like Named, but the middleware is turned off unless it is
+// turned on with Chain.Toggle. It suits middleware which should only run
+// in some environments, such as one dumping requests for debugging:
+//
+//	chain := stack.New(stack.Optional("dump", dumpRequests), auth).
+//		Toggle(stack.EnvToggles("MW_"))
+//
+// Here the dump middleware only runs if the environment sets MW_DUMP=true.
+func Optional(name string, mw chainMiddleware) chainMiddleware {
+	return named(name, mw, false)
+}
+
+// Toggle turns the middleware given names with Named or Optional on or off,
+// according to flags, which maps names to whether the middleware should
+// run. Middleware without an entry in flags keep their default. Names are
+// matched ignoring case and treating any character other than a letter or
+// digit as an underscore, so that "request-dump" is turned on by an entry
+// for "REQUEST_DUMP" from EnvToggles. Calling Toggle again adds to the
+// flags, replacing any entries with the same names.
+func (c Chain) Toggle(flags map[string]bool) Chain {
+	toggles := make(map[string]bool, len(c.toggles)+len(flags))
+	for key, on := range c.toggles {
+		toggles[key] = on
+	}
+	for name, on := range flags {
+		toggles[toggleKey(name)] = on
+	}
+	c.toggles = toggles
+	return c
+}
+
+// EnvToggles returns flags for Chain.Toggle from the environment variables
+// whose names start with prefix, with the prefix removed. Each variable's
+// value must be accepted by strconv.ParseBool (such as "true", "false",
+// "1" or "0"); variables with other values are ignored.
+func EnvToggles(prefix string) map[string]bool {
+	flags := make(map[string]bool)
+	for _, kv := range os.Environ() {
+		name, val, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, prefix) || name == prefix {
+			continue
+		}
+		on, err := strconv.ParseBool(val)
+		if err != nil {
+			continue
+		}
+		flags[strings.TrimPrefix(name, prefix)] = on
+	}
+	return flags
+}
+
+func toggleKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
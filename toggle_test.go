@@ -0,0 +1,30 @@
+package stack
+
+import "testing"
+
+func TestToggle(t *testing.T) {
+	c := New(Named("bish", bishMiddleware), Optional("flip-flop", flipMiddleware))
+	assertEquals(t, "bishMiddleware>bishHandler [bish=bash]", serveAndRequest(c.Then(bishHandler)))
+
+	c2 := c.Toggle(map[string]bool{"FLIP_FLOP": true})
+	assertEquals(t, "bishMiddleware>flipMiddleware>bishHandler [bish=bash]", serveAndRequest(c2.Then(bishHandler)))
+
+	c3 := c2.Toggle(map[string]bool{"bish": false})
+	assertEquals(t, "flipMiddleware>bishHandler [bish=<nil>]", serveAndRequest(c3.Then(bishHandler)))
+
+	// Toggle doesn't change the chain it is called on.
+	assertEquals(t, "bishMiddleware>flipMiddleware>bishHandler [bish=bash]", serveAndRequest(c2.Then(bishHandler)))
+}
+
+func TestEnvToggles(t *testing.T) {
+	t.Setenv("STACKTEST_FLIP_FLOP", "1")
+	t.Setenv("STACKTEST_BISH", "off")
+	t.Setenv("STACKTEST_BASH", "false")
+	flags := EnvToggles("STACKTEST_")
+	assertEquals(t, 2, len(flags))
+	assertEquals(t, true, flags["FLIP_FLOP"])
+	assertEquals(t, false, flags["BASH"])
+
+	c := New(Optional("flip-flop", flipMiddleware)).Toggle(flags)
+	assertEquals(t, "flipMiddleware>bishHandler [bish=<nil>]", serveAndRequest(c.Then(bishHandler)))
+}
@@ -0,0 +1,70 @@
+package stack
+
+import "net/http"
+
+const traceKey = "stack.trace"
+
+// TraceFunc is called as each named middleware is entered and exited.
+type TraceFunc func(ctx *Context, name string, exit bool)
+
+// Named gives mw a name, which is reported to the TraceFunc installed with
+// WithTrace (if any) when the middleware is entered and exited, and can be
+// used to turn it off with Chain.Toggle. Middleware added to a Router with
+// Router.Use are named automatically.
+func Named(name string, mw chainMiddleware) chainMiddleware {
+	return named(name, mw, true)
+}
+
+func named(name string, mw chainMiddleware, on bool) chainMiddleware {
+	key := toggleKey(name)
+	enabled := func(toggles map[string]bool) bool {
+		enabled, ok := toggles[key]
+		return ok && enabled || !ok && on
+	}
+	wrapped := func(ctx *Context, next http.Handler) http.Handler {
+		if !enabled(ctx.toggles) {
+			return next
+		}
+		h := mw(ctx, next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			trace := traceFunc(ctx, r)
+			if trace == nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+			trace(ctx, name, false)
+			defer trace(ctx, name, true)
+			h.ServeHTTP(w, r)
+		})
+	}
+	if d, ok := lookupDeclaration(mw); ok {
+		declare(wrapped, func(toggles map[string]bool) ([]Capability, []Role) {
+			if !enabled(toggles) {
+				return nil, nil
+			}
+			return d(toggles)
+		})
+	}
+	return wrapped
+}
+
+// WithTrace returns a copy of hc which calls fn as each named middleware
+// in the chain is entered and exited. It is intended for tests; see the
+// stacktest package.
+func WithTrace(hc HandlerChain, fn TraceFunc) HandlerChain {
+	return Inject(hc, traceKey, fn)
+}
+
+// traceFunc returns the TraceFunc for ctx. Failing that it looks in the
+// Context of an enclosing chain (such as the one a Router was added to
+// with ThenHandler), as each route has a Context of its own.
+func traceFunc(ctx *Context, r *http.Request) TraceFunc {
+	if fn, ok := ctx.Get(traceKey).(TraceFunc); ok {
+		return fn
+	}
+	if outer := FromRequest(r); outer != nil && outer != ctx {
+		fn, _ := outer.Get(traceKey).(TraceFunc)
+		return fn
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package stack
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNamed(t *testing.T) {
+	var events []string
+	trace := func(ctx *Context, name string, exit bool) {
+		events = append(events, fmt.Sprintf("%s:%v", name, exit))
+	}
+	st := New(Named("bish", bishMiddleware), flipMiddleware, Named("flip", flipMiddleware)).Then(bishHandler)
+
+	assertEquals(t, "bishMiddleware>flipMiddleware>flipMiddleware>bishHandler [bish=bash]", serveAndRequest(st))
+	assertEquals(t, 0, len(events))
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	WithTrace(st, trace).ServeHTTP(httptest.NewRecorder(), r)
+	assertEquals(t, "bish:false flip:false flip:true bish:true", strings.Join(events, " "))
+}
+
+func TestRouterUseIsNamed(t *testing.T) {
+	var events []string
+	trace := func(ctx *Context, name string, exit bool) {
+		if !exit {
+			events = append(events, name)
+		}
+	}
+	rt := NewRouter()
+	rt.Use("bish", bishMiddleware)
+	rt.Get("/", New().Then(bishHandler))
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	WithTrace(New().ThenHandler(rt), trace).ServeHTTP(httptest.NewRecorder(), r)
+	assertEquals(t, "bish", strings.Join(events, " "))
+}
@@ -0,0 +1,219 @@
+package stack
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Transformer rewrites response bodies for the Transform middleware.
+//
+// Transform is called once the status and headers of a response are known.
+// It should return nil to leave the response alone, or a WriteCloser which
+// receives the original body and writes the transformed body to dst. The
+// WriteCloser is closed when the handler returns, and may implement
+// http.Flusher to support streaming responses. Until something is written
+// to dst the header can still be changed, so transformers which buffer the
+// whole body can set Content-Length before writing it.
+type Transformer interface {
+	Transform(ctx *Context, h http.Header, status int, dst io.Writer) io.WriteCloser
+}
+
+// TransformerFunc adapts a function into a Transformer.
+type TransformerFunc func(ctx *Context, h http.Header, status int, dst io.Writer) io.WriteCloser
+
+// Transform calls fn.
+func (fn TransformerFunc) Transform(ctx *Context, h http.Header, status int, dst io.Writer) io.WriteCloser {
+	return fn(ctx, h, status, dst)
+}
+
+// BufferTransformer returns a Transformer which buffers whole responses
+// with one of the given media types (such as "text/html", or "text/" for
+// all text types) and passes them to fn. If fn returns an error the
+// original body is sent unchanged.
+func BufferTransformer(fn func(ctx *Context, h http.Header, body []byte) ([]byte, error), types ...string) Transformer {
+	return TransformerFunc(func(ctx *Context, h http.Header, status int, dst io.Writer) io.WriteCloser {
+		if !mediaTypeIn(h.Get("Content-Type"), types) {
+			return nil
+		}
+		return &bufferTransform{ctx: ctx, h: h, dst: dst, fn: fn}
+	})
+}
+
+type bufferTransform struct {
+	ctx *Context
+	h   http.Header
+	dst io.Writer
+	fn  func(*Context, http.Header, []byte) ([]byte, error)
+	buf bytes.Buffer
+}
+
+func (bt *bufferTransform) Write(p []byte) (int, error) {
+	return bt.buf.Write(p)
+}
+
+func (bt *bufferTransform) Close() error {
+	body, err := bt.fn(bt.ctx, bt.h, bt.buf.Bytes())
+	if err != nil {
+		body = bt.buf.Bytes()
+	}
+	bt.h.Set("Content-Length", strconv.Itoa(len(body)))
+	_, err = bt.dst.Write(body)
+	return err
+}
+
+// Transform returns middleware which passes successful response bodies
+// through t. Responses to HEAD requests, partial content and responses
+// which already have a Content-Encoding are never transformed, so Transform
+// should come after Compress in a chain. Nor are event streams, or
+// responses written with Stream.
+//
+// When a response is transformed any Content-Length and Accept-Ranges
+// headers set by the handler are removed, and a strong ETag is made weak,
+// as they describe the original body. Transformers which match on
+// Content-Type rely on the handler setting it explicitly.
+func Transform(t Transformer) chainMiddleware {
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "HEAD" || IsWebSocketUpgrade(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			tw := &transformWriter{ResponseWriter: w, ctx: ctx, t: t}
+			defer tw.close()
+			next.ServeHTTP(PreserveInterfaces(tw), r)
+		})
+	}
+}
+
+type transformWriter struct {
+	http.ResponseWriter
+	ctx         *Context
+	t           Transformer
+	status      int
+	wc          io.WriteCloser
+	wroteHeader bool
+	hijacked    bool
+}
+
+func (tw *transformWriter) WriteHeader(code int) {
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		tw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if tw.status != 0 {
+		return
+	}
+	tw.status = code
+	h := tw.Header()
+	if code >= 200 && code < 300 && code != http.StatusNoContent && code != http.StatusPartialContent &&
+		h.Get("Content-Encoding") == "" && !isEventStream(h.Get("Content-Type")) && !tw.ctx.Exists(streamingKey) {
+		tw.wc = tw.t.Transform(tw.ctx, h, code, transformDst{tw})
+	}
+	if tw.wc == nil {
+		tw.writeHeader()
+		return
+	}
+	h.Del("Content-Length")
+	h.Del("Accept-Ranges")
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+}
+
+func (tw *transformWriter) writeHeader() {
+	if !tw.wroteHeader {
+		tw.wroteHeader = true
+		tw.ResponseWriter.WriteHeader(tw.status)
+	}
+}
+
+func (tw *transformWriter) Write(p []byte) (int, error) {
+	if tw.status == 0 {
+		tw.WriteHeader(http.StatusOK)
+	}
+	if tw.wc != nil {
+		return tw.wc.Write(p)
+	}
+	return tw.ResponseWriter.Write(p)
+}
+
+func (tw *transformWriter) ReadFrom(src io.Reader) (int64, error) {
+	if tw.status == 0 {
+		tw.WriteHeader(http.StatusOK)
+	}
+	if tw.wc != nil {
+		return io.Copy(tw.wc, src)
+	}
+	if rf, ok := tw.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+	return io.Copy(writerOnly{tw.ResponseWriter}, src)
+}
+
+// Flush flushes the transformer, if it supports flushing, and then the
+// response. Transformers which buffer the whole body can't be flushed, so
+// for them Flush has no effect.
+func (tw *transformWriter) Flush() {
+	if tw.status == 0 {
+		tw.WriteHeader(http.StatusOK)
+	}
+	if tw.wc != nil {
+		f, ok := tw.wc.(http.Flusher)
+		if !ok {
+			return
+		}
+		f.Flush()
+	}
+	tw.writeHeader()
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (tw *transformWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	tw.hijacked = true
+	return hijack(tw.ResponseWriter)
+}
+
+// Unwrap returns the underlying ResponseWriter.
+func (tw *transformWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+func (tw *transformWriter) close() {
+	if tw.hijacked || tw.wc == nil {
+		return
+	}
+	tw.wc.Close()
+	tw.writeHeader()
+}
+
+// transformDst writes the transformed body, sending the header first.
+type transformDst struct {
+	tw *transformWriter
+}
+
+func (d transformDst) Write(p []byte) (int, error) {
+	d.tw.writeHeader()
+	return d.tw.ResponseWriter.Write(p)
+}
+
+// mediaTypeIn reports whether the media type of contentType is one of
+// types, where an entry ending in "/" matches any subtype.
+func mediaTypeIn(contentType string, types []string) bool {
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	for _, t := range types {
+		if contentType == t || strings.HasSuffix(t, "/") && strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package stack
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func upperTransformer() Transformer {
+	return BufferTransformer(func(ctx *Context, h http.Header, body []byte) ([]byte, error) {
+		return bytes.ToUpper(body), nil
+	}, "text/")
+}
+
+// shoutWriter upper-cases the body as it's written.
+type shoutWriter struct {
+	dst io.Writer
+}
+
+func (sw shoutWriter) Write(p []byte) (int, error) {
+	return sw.dst.Write(bytes.ToUpper(p))
+}
+
+func (sw shoutWriter) Close() error { return nil }
+
+func (sw shoutWriter) Flush() {}
+
+func TestTransform(t *testing.T) {
+	st := New(Transform(upperTransformer())).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Length", "4")
+		w.Header().Set("ETag", `"abc"`)
+		w.Write([]byte("bish"))
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+
+	assertEquals(t, "BISH", rec.Body.String())
+	assertEquals(t, "4", rec.Header().Get("Content-Length"))
+	assertEquals(t, `W/"abc"`, rec.Header().Get("ETag"))
+}
+
+func TestTransformSkipsOtherTypes(t *testing.T) {
+	st := New(Transform(upperTransformer())).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("ETag", `"abc"`)
+		w.Write([]byte("bish"))
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+
+	assertEquals(t, "bish", rec.Body.String())
+	assertEquals(t, `"abc"`, rec.Header().Get("ETag"))
+}
+
+func TestTransformSkipsEncoded(t *testing.T) {
+	st := New(Transform(upperTransformer())).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Encoding", "identity-ish")
+		w.Write([]byte("bish"))
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+
+	assertEquals(t, "bish", rec.Body.String())
+}
+
+func TestTransformSkipsErrors(t *testing.T) {
+	st := New(Transform(upperTransformer())).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "missing", http.StatusNotFound)
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+
+	assertEquals(t, 404, rec.Code)
+	assertEquals(t, "missing\n", rec.Body.String())
+}
+
+func TestTransformFailureSendsOriginal(t *testing.T) {
+	tr := BufferTransformer(func(ctx *Context, h http.Header, body []byte) ([]byte, error) {
+		return nil, io.ErrUnexpectedEOF
+	}, "text/plain")
+	st := New(Transform(tr)).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("bish"))
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+
+	assertEquals(t, "bish", rec.Body.String())
+	assertEquals(t, "4", rec.Header().Get("Content-Length"))
+}
+
+func TestTransformStreaming(t *testing.T) {
+	tr := TransformerFunc(func(ctx *Context, h http.Header, status int, dst io.Writer) io.WriteCloser {
+		return shoutWriter{dst}
+	})
+	st := New(Transform(tr)).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("bish "))
+		w.(http.Flusher).Flush()
+		w.Write([]byte("bash"))
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+
+	assertEquals(t, true, rec.Flushed)
+	assertEquals(t, "BISH BASH", rec.Body.String())
+	assertEquals(t, "", rec.Header().Get("Content-Length"))
+}
+
+func TestTransformBeforeCompress(t *testing.T) {
+	body := strings.Repeat("bish bash bosh ", 10)
+	st := New(Compress(CompressMinSize(1)), Transform(upperTransformer())).ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.Copy(w, strings.NewReader(body))
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+
+	assertEquals(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assertEquals(t, "", rec.Header().Get("Content-Length"))
+	gr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(gr)
+	assertEquals(t, strings.ToUpper(body), string(b))
+}
@@ -0,0 +1,153 @@
+package stack
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"sort"
+)
+
+const uploadsKey = "stack.uploads"
+
+// Upload describes a file accepted by the CheckUploads middleware.
+type Upload struct {
+	Field    string
+	Filename string
+	Size     int64
+	// ContentType is sniffed from the file's contents, not taken from the
+	// client, which may claim anything.
+	ContentType string
+	header      *multipart.FileHeader
+}
+
+// Open opens the uploaded file for reading.
+func (u Upload) Open() (multipart.File, error) {
+	return u.header.Open()
+}
+
+// UploadOption configures the CheckUploads middleware.
+type UploadOption func(*uploadConfig)
+
+// UploadField allows up to maxFiles files of up to maxSize bytes each in
+// the named form field. If any types are given (such as "image/png", or
+// "image/" for any image) the files' sniffed content types must match one
+// of them.
+func UploadField(name string, maxFiles int, maxSize int64, types ...string) UploadOption {
+	return func(c *uploadConfig) {
+		c.fields[name] = uploadRule{maxFiles, maxSize, types}
+	}
+}
+
+type uploadRule struct {
+	maxFiles int
+	maxSize  int64
+	types    []string
+}
+
+type uploadConfig struct {
+	fields map[string]uploadRule
+}
+
+// CheckUploads returns middleware which checks the files uploaded in a
+// multipart form parsed by ParseForm, which must come before it, against
+// the rules given with UploadField. Accepted files are available to the
+// handler through Uploads.
+//
+// Files in fields without a rule, and too many files in a field, are
+// rejected with a 400 Bad Request status, files which are too large with
+// 413 Request Entity Too Large, and files of the wrong type with 415
+// Unsupported Media Type. Every problem found is recorded as FieldErrors
+// (see InvalidFields) before the first is passed to the chain's error
+// handler.
+func CheckUploads(opts ...UploadOption) chainMiddleware {
+	cfg := &uploadConfig{fields: make(map[string]uploadRule)}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			files, _ := ctx.Get(formFilesKey).(map[string][]*multipart.FileHeader)
+			uploads, errs, status := cfg.check(files)
+			if len(errs) > 0 {
+				ctx.Put(fieldErrorsKey, errs)
+				Error(ctx, w, r, NewHTTPError(status, errs))
+				return
+			}
+			ctx.Put(uploadsKey, uploads)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (cfg *uploadConfig) check(files map[string][]*multipart.FileHeader) (map[string][]Upload, FieldErrors, int) {
+	var errs FieldErrors
+	status := 0
+	fail := func(code int, field, msg string) {
+		errs = append(errs, FieldError{field, msg})
+		if status == 0 {
+			status = code
+		}
+	}
+
+	// Check fields in a stable order, so the same request always gets the
+	// same errors.
+	fields := make([]string, 0, len(files))
+	for field := range files {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	uploads := make(map[string][]Upload, len(files))
+	for _, field := range fields {
+		rule, ok := cfg.fields[field]
+		if !ok {
+			fail(http.StatusBadRequest, field, "does not accept files")
+			continue
+		}
+		if len(files[field]) > rule.maxFiles {
+			fail(http.StatusBadRequest, field, fmt.Sprintf("accepts at most %d files", rule.maxFiles))
+			continue
+		}
+		for _, fh := range files[field] {
+			if fh.Size > rule.maxSize {
+				fail(http.StatusRequestEntityTooLarge, field, fmt.Sprintf("%s is larger than %d bytes", fh.Filename, rule.maxSize))
+				continue
+			}
+			ct, err := sniff(fh)
+			if err != nil {
+				fail(http.StatusBadRequest, field, fmt.Sprintf("%s can't be read", fh.Filename))
+				continue
+			}
+			if len(rule.types) > 0 && !mediaTypeIn(ct, rule.types) {
+				fail(http.StatusUnsupportedMediaType, field, fmt.Sprintf("%s is not an accepted type", fh.Filename))
+				continue
+			}
+			uploads[field] = append(uploads[field], Upload{field, fh.Filename, fh.Size, ct, fh})
+		}
+	}
+	return uploads, errs, status
+}
+
+// sniff detects the content type of an uploaded file from its first 512
+// bytes.
+func sniff(fh *multipart.FileHeader) (string, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// Uploads returns the files accepted by CheckUploads in the named field.
+func Uploads(ctx *Context, field string) []Upload {
+	uploads, _ := ctx.Get(uploadsKey).(map[string][]Upload)
+	return uploads[field]
+}
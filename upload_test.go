@@ -0,0 +1,75 @@
+package stack
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var pngHeader = "\x89PNG\r\n\x1a\n"
+
+type uploadFile struct {
+	field, name, content string
+}
+
+func postFiles(hc HandlerChain, files ...uploadFile) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, f := range files {
+		fw, _ := mw.CreateFormFile(f.field, f.name)
+		io.WriteString(fw, f.content)
+	}
+	mw.Close()
+	r, _ := http.NewRequest("POST", "/", &buf)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	hc.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestCheckUploads(t *testing.T) {
+	var uploads []Upload
+	var ctx *Context
+	st := New(ParseForm(), CheckUploads(
+		UploadField("avatar", 1, 64, "image/png", "image/gif"),
+		UploadField("docs", 2, 1024),
+	)).OnError(func(c *Context, w http.ResponseWriter, r *http.Request, err error) {
+		ctx = c
+		w.WriteHeader(StatusCode(err))
+	}).Then(func(c *Context, w http.ResponseWriter, r *http.Request) {
+		uploads = Uploads(c, "avatar")
+	})
+
+	// The client's claimed content type is ignored.
+	rec := postFiles(st, uploadFile{"avatar", "me.png", pngHeader + "bish"}, uploadFile{"docs", "a.txt", "bash"})
+	assertEquals(t, 200, rec.Code)
+	assertEquals(t, 1, len(uploads))
+	assertEquals(t, "me.png", uploads[0].Filename)
+	assertEquals(t, "image/png", uploads[0].ContentType)
+	f, err := uploads[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(f)
+	f.Close()
+	assertEquals(t, pngHeader+"bish", string(b))
+
+	tests := []struct {
+		files  []uploadFile
+		status int
+		errs   string
+	}{
+		{[]uploadFile{{"avatar", "me.png", "<html>bish</html>"}}, 415, "stack: invalid input: avatar: me.png is not an accepted type"},
+		{[]uploadFile{{"avatar", "me.png", pngHeader + string(make([]byte, 64))}}, 413, "stack: invalid input: avatar: me.png is larger than 64 bytes"},
+		{[]uploadFile{{"avatar", "a.png", pngHeader}, {"avatar", "b.png", pngHeader}}, 400, "stack: invalid input: avatar: accepts at most 1 files"},
+		{[]uploadFile{{"other", "a.txt", "bish"}, {"avatar", "me.gif", "bash"}}, 415, "stack: invalid input: avatar: me.gif is not an accepted type; other: does not accept files"},
+	}
+	for _, test := range tests {
+		rec := postFiles(st, test.files...)
+		assertEquals(t, test.status, rec.Code)
+		assertEquals(t, test.errs, InvalidFields(ctx).Error())
+	}
+}
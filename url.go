@@ -0,0 +1,76 @@
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrUnknownRoute is returned by URL when no route has the given name.
+var ErrUnknownRoute = errors.New("stack: unknown route name")
+
+// URL returns the path for the named route of the Router serving the
+// current request, with its parameters filled in from pairs of names and
+// values:
+//
+//	u, err := stack.URL(ctx, "user.show", "id", 42)
+//
+// Values are formatted with fmt.Sprint and escaped. If the Router is
+// mounted under a prefix, the prefix is included in the result.
+func URL(ctx *Context, name string, pairs ...interface{}) (string, error) {
+	rts, _ := ctx.Get(routesKey).(*routes)
+	if rts == nil {
+		return "", ErrUnknownRoute
+	}
+	pattern, ok := rts.names[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownRoute, name)
+	}
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("stack: odd number of parameters for route %q", name)
+	}
+	params := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return "", fmt.Errorf("stack: parameter name %v for route %q is not a string", pairs[i], name)
+		}
+		params[key] = fmt.Sprint(pairs[i+1])
+	}
+
+	segs := strings.Split(pattern, "/")
+	for i, seg := range segs {
+		if len(seg) > 2 && seg[0] == '{' && seg[len(seg)-1] == '}' {
+			key := seg[1 : len(seg)-1]
+			if rest := strings.TrimSuffix(key, "..."); rest != key {
+				// A catch-all may contain slashes, so escape each of its
+				// segments separately.
+				parts := strings.Split(params[rest], "/")
+				for j, part := range parts {
+					parts[j] = url.PathEscape(part)
+				}
+				segs[i] = strings.Join(parts, "/")
+				continue
+			}
+			val, ok := params[key]
+			if !ok || val == "" {
+				return "", fmt.Errorf("stack: missing parameter %q for route %q", key, name)
+			}
+			segs[i] = url.PathEscape(val)
+		}
+	}
+	return MountPrefix(ctx) + strings.Join(segs, "/"), nil
+}
+
+// AbsoluteURL is like URL, but returns an absolute URL using the scheme and
+// host of the request r, as reported by a trusted proxy if the chain uses
+// TrustProxies.
+func AbsoluteURL(ctx *Context, r *http.Request, name string, pairs ...interface{}) (string, error) {
+	path, err := URL(ctx, name, pairs...)
+	if err != nil {
+		return "", err
+	}
+	return requestScheme(r) + "://" + r.Host + path, nil
+}
@@ -0,0 +1,73 @@
+package stack
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func urlHandler(name string, pairs ...interface{}) HandlerChain {
+	return New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		u, err := URL(ctx, name, pairs...)
+		if err != nil {
+			fmt.Fprint(w, err)
+			return
+		}
+		fmt.Fprint(w, u)
+	})
+}
+
+func TestURL(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/users/{id}", New().Then(bishHandler)).Name("user.show")
+	rt.Get("/users/{id}/posts/{post}/", New().Then(bishHandler)).Name("post.show")
+	rt.Get("/a", urlHandler("user.show", "id", 42))
+	rt.Get("/b", urlHandler("post.show", "id", "a b", "post", "x/y"))
+	rt.Get("/c", urlHandler("user.show"))
+	rt.Get("/d", urlHandler("bish"))
+	rt.Get("/e", urlHandler("user.show", "id"))
+
+	assertEquals(t, "/users/42", routeTo(rt, "GET", "/a").Body.String())
+	assertEquals(t, "/users/a%20b/posts/x%2Fy/", routeTo(rt, "GET", "/b").Body.String())
+	assertEquals(t, `stack: missing parameter "id" for route "user.show"`, routeTo(rt, "GET", "/c").Body.String())
+	assertEquals(t, `stack: unknown route name: "bish"`, routeTo(rt, "GET", "/d").Body.String())
+	assertEquals(t, `stack: odd number of parameters for route "user.show"`, routeTo(rt, "GET", "/e").Body.String())
+}
+
+func TestURLMounted(t *testing.T) {
+	inner := NewRouter()
+	inner.Get("/users/{id}", New().Then(bishHandler)).Name("user.show")
+	inner.Get("/", urlHandler("user.show", "id", 1))
+	outer := NewRouter()
+	outer.Mount("/api", New().ThenHandler(inner))
+
+	r := httptest.NewRequest("GET", "/api/", nil)
+	rec := httptest.NewRecorder()
+	outer.ServeHTTP(rec, r)
+	assertEquals(t, "/api/users/1", rec.Body.String())
+}
+
+func TestAbsoluteURL(t *testing.T) {
+	rt := NewRouter()
+	rt.Get("/users/{id}", New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		u, _ := AbsoluteURL(ctx, r, "user.show", "id", Param(ctx, "id"))
+		fmt.Fprint(w, u)
+	})).Name("user.show")
+
+	r := httptest.NewRequest("GET", "http://example.com/users/7", nil)
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, r)
+	assertEquals(t, "http://example.com/users/7", rec.Body.String())
+
+	r.TLS = &tls.ConnectionState{}
+	rec = httptest.NewRecorder()
+	rt.ServeHTTP(rec, r)
+	assertEquals(t, "https://example.com/users/7", rec.Body.String())
+}
+
+func TestURLWithoutRouter(t *testing.T) {
+	_, err := URL(NewContext(), "bish")
+	assertEquals(t, ErrUnknownRoute, err)
+}
@@ -0,0 +1,62 @@
+package stack
+
+import (
+	"errors"
+	"net/http"
+)
+
+const userKey = "stack.user"
+
+// ErrUserNotFound is passed to the chain's error handler, wrapped in an
+// HTTPError with status 401, when a UserLoader can't find a user for the
+// authenticated principal.
+var ErrUserNotFound = errors.New("stack: no user for principal")
+
+// UserLoader resolves an authenticated principal (such as a JWT subject,
+// a username from basic auth or an ID stored in a session) into a user
+// object. It should return a nil user and nil error if no user exists.
+type UserLoader interface {
+	LoadUser(ctx *Context, principal interface{}) (user interface{}, err error)
+}
+
+// UserLoaderFunc adapts an ordinary function into a UserLoader.
+type UserLoaderFunc func(ctx *Context, principal interface{}) (interface{}, error)
+
+// LoadUser calls fn(ctx, principal).
+func (fn UserLoaderFunc) LoadUser(ctx *Context, principal interface{}) (interface{}, error) {
+	return fn(ctx, principal)
+}
+
+// LoadUser returns middleware which loads the user for the authenticated
+// principal, making it available through CurrentUser. It must come after
+// the authentication middleware in the chain. Unauthenticated requests are
+// passed through untouched, so access control is left to Authorize.
+func LoadUser(loader UserLoader) chainMiddleware {
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := Principal(ctx)
+			if principal == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			_, err := ctx.GetOrCompute(userKey, func() (interface{}, error) {
+				user, err := loader.LoadUser(ctx, principal)
+				if err == nil && user == nil {
+					err = NewHTTPError(http.StatusUnauthorized, ErrUserNotFound)
+				}
+				return user, err
+			})
+			if err != nil {
+				Error(ctx, w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CurrentUser returns the user loaded for the current request by the
+// LoadUser middleware, or nil if there isn't one.
+func CurrentUser(ctx *Context) interface{} {
+	return ctx.Get(userKey)
+}
@@ -0,0 +1,51 @@
+package stack
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testUser struct {
+	Name string
+}
+
+func TestLoadUser(t *testing.T) {
+	calls := 0
+	loader := UserLoaderFunc(func(ctx *Context, principal interface{}) (interface{}, error) {
+		calls++
+		switch principal {
+		case "flip":
+			return &testUser{Name: "Flip"}, nil
+		case "error":
+			return nil, errTest
+		}
+		return nil, nil
+	})
+	userHandler := func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		if u, ok := CurrentUser(ctx).(*testUser); ok {
+			fmt.Fprint(w, u.Name)
+		}
+	}
+
+	tests := []struct {
+		principal interface{}
+		code      int
+		body      string
+	}{
+		{"flip", 200, "Flip"},
+		{nil, 200, ""},
+		{"flop", 401, "Unauthorized\n"},
+		{"error", 500, "Internal Server Error\n"},
+	}
+	for _, test := range tests {
+		st := New(principalMiddleware(test.principal), LoadUser(loader), LoadUser(loader)).Then(userHandler)
+		r, _ := http.NewRequest("GET", "/", nil)
+		rec := httptest.NewRecorder()
+		st.ServeHTTP(rec, r)
+		assertEquals(t, test.code, rec.Code)
+		assertEquals(t, test.body, rec.Body.String())
+	}
+	assertEquals(t, 3, calls)
+}
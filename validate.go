@@ -0,0 +1,264 @@
+package stack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// InputValidator checks a request's bound input, returning the problems
+// it finds with each field.
+type InputValidator interface {
+	ValidateInput(v interface{}) FieldErrors
+}
+
+// InputValidatorFunc adapts a function into an InputValidator.
+type InputValidatorFunc func(v interface{}) FieldErrors
+
+// ValidateInput calls fn.
+func (fn InputValidatorFunc) ValidateInput(v interface{}) FieldErrors {
+	return fn(v)
+}
+
+// Validate returns middleware which checks the T bound earlier in the
+// chain by BindJSON (or, failing that, BindQuery) with each of validators,
+// or with TagValidator if none are given. If T, or a pointer to it,
+// implements Validator then its Validate method is called too, and may
+// return FieldErrors to report problems with particular fields.
+//
+// If any problems are found the FieldErrors are stored in the Context
+// (see InvalidFields) and passed to the chain's error handler with a 422
+// Unprocessable Entity status. ValidationErrorHandler renders them.
+func Validate[T any](validators ...InputValidator) chainMiddleware {
+	if len(validators) == 0 {
+		validators = []InputValidator{TagValidator()}
+	}
+	return func(ctx *Context, next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			val := ctx.Get(bodyKey[T]())
+			if val == nil {
+				val = ctx.Get(queryKey[T]())
+			}
+			v, ok := val.(T)
+			if !ok {
+				panic(fmt.Sprintf("stack: Validate needs a %s bound earlier in the chain", configType[T]()))
+			}
+
+			var errs FieldErrors
+			for _, iv := range validators {
+				errs = append(errs, iv.ValidateInput(v)...)
+			}
+			sv, ok := any(&v).(Validator)
+			if !ok {
+				sv, ok = any(v).(Validator)
+			}
+			if ok {
+				if err := sv.Validate(); err != nil {
+					if fe, isFE := err.(FieldErrors); isFE {
+						errs = append(errs, fe...)
+					} else {
+						errs = append(errs, FieldError{Message: err.Error()})
+					}
+				}
+			}
+			if len(errs) > 0 {
+				ctx.Put(fieldErrorsKey, errs)
+				Error(ctx, w, r, NewHTTPError(http.StatusUnprocessableEntity, errs))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// TagValidator returns an InputValidator which checks struct fields
+// against the rules in their validate tags, separated by commas:
+//
+//	type signup struct {
+//		Email string `json:"email" validate:"required,email"`
+//		Name  string `json:"name" validate:"min=2,max=50"`
+//		Plan  string `json:"plan" validate:"oneof=free pro"`
+//	}
+//
+// The rules are required (not the zero value), min=N and max=N (the length
+// of strings, slices and maps, or the value of numbers), email and
+// oneof=a b c. Rules other than required aren't applied to zero values, so
+// that optional fields can be left out. Fields are named in errors by their
+// json or query tag, or else their Go name. Embedded structs are checked
+// too.
+func TagValidator() InputValidator {
+	return InputValidatorFunc(func(v interface{}) FieldErrors {
+		rv := reflect.Indirect(reflect.ValueOf(v))
+		if rv.Kind() != reflect.Struct {
+			return nil
+		}
+		return validateStruct(rv)
+	})
+}
+
+func validateStruct(v reflect.Value) FieldErrors {
+	var errs FieldErrors
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			errs = append(errs, validateStruct(v.Field(i))...)
+			continue
+		}
+		rules, ok := f.Tag.Lookup("validate")
+		if !ok || !f.IsExported() {
+			continue
+		}
+		for _, rule := range strings.Split(rules, ",") {
+			if msg := checkRule(v.Field(i), rule); msg != "" {
+				errs = append(errs, FieldError{fieldName(f), msg})
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// fieldName returns the name by which clients know a struct field.
+func fieldName(f reflect.StructField) string {
+	for _, key := range []string{"json", "query"} {
+		if name, _, _ := strings.Cut(f.Tag.Get(key), ","); name != "" && name != "-" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// checkRule returns a message describing how fv breaks rule, or "" if it
+// doesn't.
+func checkRule(fv reflect.Value, rule string) string {
+	name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+	if fv.IsZero() {
+		if name == "required" {
+			return "is required"
+		}
+		return ""
+	}
+	fv = reflect.Indirect(fv)
+	switch name {
+	case "required":
+	case "min", "max":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			panic(fmt.Sprintf("stack: invalid validate rule %q", rule))
+		}
+		n, isLength := measure(fv)
+		switch {
+		case name == "min" && n < limit && isLength:
+			return fmt.Sprintf("must be at least %s characters long", arg)
+		case name == "min" && n < limit:
+			return fmt.Sprintf("must be at least %s", arg)
+		case name == "max" && n > limit && isLength:
+			return fmt.Sprintf("must be at most %s characters long", arg)
+		case name == "max" && n > limit:
+			return fmt.Sprintf("must be at most %s", arg)
+		}
+	case "email":
+		addr, err := mail.ParseAddress(fv.String())
+		if err != nil || addr.Address != fv.String() {
+			return "must be an email address"
+		}
+	case "oneof":
+		s := fmt.Sprint(fv.Interface())
+		for _, option := range strings.Fields(arg) {
+			if s == option {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(strings.Fields(arg), ", ")
+	default:
+		panic(fmt.Sprintf("stack: unknown validate rule %q", rule))
+	}
+	return ""
+}
+
+// measure returns the value of a number, or the length of anything else,
+// reporting which it was.
+func measure(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false
+	}
+	panic(fmt.Sprintf("stack: min and max can't be applied to %s", v.Type()))
+}
+
+// ValidationErrorHandler returns an ErrorHandlerFunc which renders the
+// FieldErrors recorded for a request by BindQuery or Validate. Clients
+// which prefer HTML are shown form, rendered with the chain's Renderer,
+// with data holding the FieldErrors as Errors, and the submitted values
+// (see Form) as Form. Other clients, and all clients if form is "" or
+// can't be rendered, get an RFC 7807 problem document:
+//
+//	{"title":"Unprocessable Entity","status":422,"errors":[{"field":"email","message":"is required"}]}
+//
+// Other errors are passed to next, or handled as by the default error
+// handler if next is nil.
+func ValidationErrorHandler(form string, next ErrorHandlerFunc) ErrorHandlerFunc {
+	if next == nil {
+		next = defaultErrorHandler
+	}
+	return func(ctx *Context, w http.ResponseWriter, r *http.Request, err error) {
+		errs := InvalidFields(ctx)
+		if errs == nil {
+			next(ctx, w, r, err)
+			return
+		}
+		status := StatusCode(err)
+		addVary(w.Header(), "Accept")
+		if form != "" && ctx.renderer != nil {
+			if ct, _ := negotiateMediaType(r.Header.Get("Accept"), []string{"application/problem+json", "application/json", "text/html"}); ct == "text/html" {
+				// Render directly rather than with render, which would call
+				// this handler again if the template failed.
+				var buf bytes.Buffer
+				data := map[string]interface{}{"Errors": errs, "Form": Form(ctx)}
+				if ctx.renderer.Render(ctx, &buf, form, data) == nil {
+					w.Header().Set("Content-Type", "text/html; charset=utf-8")
+					w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+					w.WriteHeader(status)
+					w.Write(buf.Bytes())
+					return
+				}
+			}
+		}
+
+		problem := problemBody{Title: http.StatusText(status), Status: status}
+		for _, fe := range errs {
+			problem.Errors = append(problem.Errors, problemField{fe.Field, fe.Message})
+		}
+		body, _ := json.Marshal(problem)
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(status)
+		w.Write(body)
+	}
+}
+
+type problemBody struct {
+	Title  string         `json:"title"`
+	Status int            `json:"status"`
+	Errors []problemField `json:"errors"`
+}
+
+type problemField struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,104 @@
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type signupInput struct {
+	Email string   `json:"email" validate:"required,email"`
+	Name  string   `json:"name" validate:"min=2,max=5"`
+	Plan  string   `json:"plan" validate:"oneof=free pro"`
+	Seats int      `json:"seats" validate:"max=10"`
+	Tags  []string `validate:"max=2"`
+}
+
+type checkedInput struct {
+	Name string `json:"name"`
+}
+
+func (ci checkedInput) Validate() error {
+	if ci.Name == "bash" {
+		return FieldErrors{{"name", "is taken"}}
+	}
+	if ci.Name == "bosh" {
+		return errors.New("not allowed")
+	}
+	return nil
+}
+
+func TestTagValidator(t *testing.T) {
+	tests := []struct {
+		input signupInput
+		errs  string
+	}{
+		{signupInput{Email: "bish@example.com"}, "[]"},
+		{signupInput{}, "[{email is required}]"},
+		{signupInput{Email: "bish", Name: "b", Plan: "gold"}, "[{email must be an email address} {name must be at least 2 characters long} {plan must be one of free, pro}]"},
+		{signupInput{Email: "bish@example.com", Name: "bishbash", Seats: 11, Tags: []string{"a", "b", "c"}}, "[{name must be at most 5 characters long} {seats must be at most 10} {Tags must be at most 2 characters long}]"},
+	}
+	for _, test := range tests {
+		errs := TagValidator().ValidateInput(test.input)
+		assertEquals(t, test.errs, fmt.Sprint([]FieldError(errs)))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	st := New(BindJSON[checkedInput](), Validate[checkedInput]()).
+		OnError(ValidationErrorHandler("", nil)).
+		Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {})
+
+	assertEquals(t, 200, postJSON(st, "", `{"name": "bish"}`).Code)
+
+	rec := postJSON(st, "", `{"name": "bash"}`)
+	assertEquals(t, 422, rec.Code)
+	assertEquals(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	assertEquals(t, `{"title":"Unprocessable Entity","status":422,"errors":[{"field":"name","message":"is taken"}]}`, rec.Body.String())
+
+	rec = postJSON(st, "", `{"name": "bosh"}`)
+	assertEquals(t, `{"title":"Unprocessable Entity","status":422,"errors":[{"message":"not allowed"}]}`, rec.Body.String())
+
+	// Other errors go to the next handler.
+	rec = postJSON(st, "text/plain", `bish`)
+	assertEquals(t, 415, rec.Code)
+	assertEquals(t, "Unsupported Media Type\n", rec.Body.String())
+}
+
+type formRenderer struct{}
+
+func (formRenderer) Render(ctx *Context, w io.Writer, name string, data interface{}) error {
+	if name != "signup" {
+		return errors.New("no such template")
+	}
+	d := data.(map[string]interface{})
+	_, err := fmt.Fprintf(w, "%v %v", d["Errors"], d["Form"])
+	return err
+}
+
+func TestValidationErrorHandlerHTML(t *testing.T) {
+	type query struct {
+		Plan string `query:"plan" validate:"oneof=free pro"`
+	}
+	st := New(BindQuery[query](), Validate[query]()).
+		UseRenderer(formRenderer{}).
+		OnError(ValidationErrorHandler("signup", nil)).
+		Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {})
+
+	r, _ := http.NewRequest("GET", "/?plan=gold", nil)
+	r.Header.Set("Accept", "text/html,*/*;q=0.8")
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, 422, rec.Code)
+	assertEquals(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assertEquals(t, "stack: invalid input: plan: must be one of free, pro map[]", rec.Body.String())
+
+	r.Header.Set("Accept", "application/json")
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, true, strings.HasPrefix(rec.Body.String(), `{"title":`))
+}
@@ -0,0 +1,84 @@
+package stack
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const hostMatchKey = "stack.hostMatch"
+
+// HostMatch records the virtual host which matched the current request.
+type HostMatch struct {
+	// Pattern is the key in the map passed to Vhost.
+	Pattern string
+	// Host is the request's host name, lowercased and without a port.
+	Host string
+	// Subdomain is the part of Host matched by a wildcard pattern's "*"
+	// (such as "acme" for "acme.example.com" and "*.example.com"). It is
+	// empty for exact matches.
+	Subdomain string
+}
+
+// Vhost returns a handler which dispatches requests to chains by their
+// Host header. Keys are host names ("example.com") or wildcard patterns
+// ("*.example.com"), which match any number of subdomain labels. Exact
+// matches take priority, followed by the wildcard with the longest suffix,
+// and the key "*" matches any host. Ports are ignored.
+//
+// Requests for unknown hosts get a 404 Not Found. The match is stored in
+// the chain's Context, where it can be retrieved with MatchedHost.
+func Vhost(hosts map[string]HandlerChain) http.Handler {
+	exact := make(map[string]HandlerChain)
+	var wildcards []string
+	for pattern, hc := range hosts {
+		pattern = strings.ToLower(pattern)
+		exact[pattern] = hc
+		if strings.HasPrefix(pattern, "*.") {
+			wildcards = append(wildcards, pattern)
+		}
+	}
+	sort.Slice(wildcards, func(i, j int) bool {
+		return len(wildcards[i]) > len(wildcards[j])
+	})
+	notFound := errorChain(http.StatusNotFound)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := normalizeHost(r.Host)
+		m := HostMatch{Pattern: host, Host: host}
+		hc, ok := exact[host]
+		if !ok {
+			for _, pattern := range wildcards {
+				if sub := strings.TrimSuffix(host, pattern[1:]); sub != host && sub != "" {
+					m.Pattern, m.Subdomain, hc, ok = pattern, sub, exact[pattern], true
+					break
+				}
+			}
+		}
+		if !ok {
+			if hc, ok = exact["*"]; !ok {
+				notFound.ServeHTTP(w, r)
+				return
+			}
+			m.Pattern = "*"
+		}
+		hc.serve(w, r, func(ctx *Context) {
+			ctx.Put(hostMatchKey, m)
+		})
+	})
+}
+
+// MatchedHost returns the virtual host matched by Vhost for the current
+// request, or the zero HostMatch if the chain wasn't reached through Vhost.
+func MatchedHost(ctx *Context) HostMatch {
+	m, _ := ctx.Get(hostMatchKey).(HostMatch)
+	return m
+}
+
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
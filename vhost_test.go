@@ -0,0 +1,50 @@
+package stack
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func hostHandler(name string) HandlerChain {
+	return New().Then(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		m := MatchedHost(ctx)
+		fmt.Fprintf(w, "%s [pattern=%s][host=%s][sub=%s]", name, m.Pattern, m.Host, m.Subdomain)
+	})
+}
+
+func requestHost(h http.Handler, host string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Host = host
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestVhost(t *testing.T) {
+	h := Vhost(map[string]HandlerChain{
+		"example.com":        hostHandler("site"),
+		"api.example.com":    hostHandler("api"),
+		"*.example.com":      hostHandler("tenant"),
+		"*.eu.example.com":   hostHandler("eu"),
+		"*.bish.example.org": hostHandler("bish"),
+	})
+
+	assertEquals(t, "site [pattern=example.com][host=example.com][sub=]", requestHost(h, "Example.COM:8080").Body.String())
+	assertEquals(t, "api [pattern=api.example.com][host=api.example.com][sub=]", requestHost(h, "api.example.com").Body.String())
+	assertEquals(t, "tenant [pattern=*.example.com][host=acme.example.com][sub=acme]", requestHost(h, "acme.example.com.").Body.String())
+	assertEquals(t, "tenant [pattern=*.example.com][host=a.b.example.com][sub=a.b]", requestHost(h, "a.b.example.com").Body.String())
+	assertEquals(t, "eu [pattern=*.eu.example.com][host=acme.eu.example.com][sub=acme]", requestHost(h, "acme.eu.example.com").Body.String())
+	assertEquals(t, 404, requestHost(h, "bish.example.org").Code)
+	assertEquals(t, 404, requestHost(h, "example.net").Code)
+}
+
+func TestVhostDefault(t *testing.T) {
+	h := Vhost(map[string]HandlerChain{
+		"example.com": hostHandler("site"),
+		"*":           hostHandler("default"),
+	})
+
+	assertEquals(t, "default [pattern=*][host=example.net][sub=]", requestHost(h, "example.net").Body.String())
+}
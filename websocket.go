@@ -0,0 +1,37 @@
+package stack
+
+import "net/http"
+
+// IsWebSocketUpgrade reports whether r asks to upgrade the connection to
+// the WebSocket protocol.
+func IsWebSocketUpgrade(r *http.Request) bool {
+	return r.Method == "GET" &&
+		headerHasToken(r.Header, "Connection", "upgrade") &&
+		headerHasToken(r.Header, "Upgrade", "websocket")
+}
+
+// ThenWebSocket finishes the chain with a handler for WebSocket
+// connections. fn should upgrade the connection using a WebSocket package
+// (such as gorilla/websocket's Upgrader, which hijacks w) and can go on
+// using ctx for as long as the connection is open. Requests which aren't
+// WebSocket upgrades are passed to the chain's error handler with a 426
+// Upgrade Required status.
+//
+// The ETag, Compress and Transform middleware don't wrap the ResponseWriter
+// for upgrade requests, and a chain which records responses notes that the
+// connection was hijacked (see ResponseRecorder.Hijacked) and skips its
+// BeforeWrite hooks, so the upgrade response reaches the client exactly as
+// fn writes it.
+func (c Chain) ThenWebSocket(fn func(ctx *Context, w http.ResponseWriter, r *http.Request)) HandlerChain {
+	c.h = func(ctx *Context) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !IsWebSocketUpgrade(r) {
+				w.Header().Set("Upgrade", "websocket")
+				Error(ctx, w, r, NewHTTPError(http.StatusUpgradeRequired, nil))
+				return
+			}
+			fn(ctx, w, withContext(r, ctx))
+		})
+	}
+	return newHandlerChain(c)
+}
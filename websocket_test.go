@@ -0,0 +1,78 @@
+package stack
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsWebSocketUpgrade(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/", nil)
+	assertEquals(t, false, IsWebSocketUpgrade(r))
+	r.Header.Set("Connection", "keep-alive, Upgrade")
+	r.Header.Set("Upgrade", "WebSocket")
+	assertEquals(t, true, IsWebSocketUpgrade(r))
+	r.Method = "POST"
+	assertEquals(t, false, IsWebSocketUpgrade(r))
+}
+
+func TestThenWebSocket(t *testing.T) {
+	finished := make(chan ResponseInfo, 1)
+	onFinish := func(ctx *Context, next http.Handler) http.Handler {
+		OnFinish(ctx, func(info ResponseInfo) { finished <- info })
+		return next
+	}
+	hc := fullChain().Append(onFinish).ThenWebSocket(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		go func() {
+			defer conn.Close()
+			brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+			brw.Flush()
+			line, _ := brw.ReadString('\n')
+			fmt.Fprintf(brw, "%s %s", ctx.Get("greeting"), line)
+			brw.Flush()
+		}()
+	})
+	ts := httptest.NewServer(Inject(hc, "greeting", "hello"))
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: example.com\r\nAccept-Encoding: gzip\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 101, res.StatusCode)
+	assertEquals(t, "", res.Header.Get("Vary"))
+	fmt.Fprint(conn, "bish\n")
+	line, _ := br.ReadString('\n')
+	assertEquals(t, "hello bish\n", line)
+
+	info := <-finished
+	assertEquals(t, true, info.Hijacked)
+}
+
+func TestThenWebSocketRequiresUpgrade(t *testing.T) {
+	hc := New().ThenWebSocket(func(ctx *Context, w http.ResponseWriter, r *http.Request) {
+		t.Error("handler called")
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	hc.ServeHTTP(rec, r)
+
+	assertEquals(t, 426, rec.Code)
+	assertEquals(t, "websocket", rec.Header().Get("Upgrade"))
+}
@@ -0,0 +1,198 @@
+package stack
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// WrappedWriter is implemented by ResponseWriter wrappers, such as those
+// used by the middleware in this package. Wrappers should flush and read
+// from their underlying ResponseWriter where it supports it, and fall back
+// to Write otherwise.
+type WrappedWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	io.ReaderFrom
+	Unwrap() http.ResponseWriter
+}
+
+// All of the wrappers in this package implement Unwrap, so that
+// http.ResponseController can reach the server's ResponseWriter to set
+// read and write deadlines.
+var (
+	_ WrappedWriter = (*compressWriter)(nil)
+	_ WrappedWriter = (*etagWriter)(nil)
+	_ WrappedWriter = (*headWriter)(nil)
+	_ WrappedWriter = (*ResponseRecorder)(nil)
+	_ WrappedWriter = (*transformWriter)(nil)
+)
+
+// PreserveInterfaces returns w extended with http.Hijacker and http.Pusher
+// exactly when the ResponseWriter it wraps implements them, so that type
+// assertions made by handlers later in the chain (for WebSocket upgrades,
+// for example) succeed just as they would without the wrapper. If w has a
+// Hijack method of its own it is used, so that the wrapper can stop
+// writing to the response once the connection has been taken over.
+//
+// Middleware should pass the result, rather than w itself, to the next
+// handler.
+func PreserveInterfaces(w WrappedWriter) http.ResponseWriter {
+	under := w.Unwrap()
+	_, canHijack := under.(http.Hijacker)
+	p, canPush := under.(http.Pusher)
+	h, ok := w.(http.Hijacker)
+	if !ok {
+		h, _ = under.(http.Hijacker)
+	}
+	pw := preservedWriter{w}
+	switch {
+	case canHijack && canPush:
+		return struct {
+			preservedWriter
+			http.Hijacker
+			http.Pusher
+		}{pw, h, p}
+	case canHijack:
+		return struct {
+			preservedWriter
+			http.Hijacker
+		}{pw, h}
+	case canPush:
+		return struct {
+			preservedWriter
+			http.Pusher
+		}{pw, p}
+	}
+	// Hide any Hijack method on w itself.
+	return pw
+}
+
+// preservedWriter hides the methods of a wrapper which aren't part of
+// WrappedWriter, while letting this package get at the wrapper itself.
+type preservedWriter struct {
+	WrappedWriter
+}
+
+func (pw preservedWriter) wrapped() WrappedWriter {
+	return pw.WrappedWriter
+}
+
+// hijack takes over the connection underlying w.
+func hijack(w http.ResponseWriter) (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w).Hijack()
+}
+
+// writerOnly hides any methods other than Write, so that io.Copy to a
+// ResponseWriter wrapper doesn't recurse into the wrapper's own ReadFrom.
+type writerOnly struct {
+	io.Writer
+}
+
+// copyUntil copies from src to w in chunks until done reports true or src
+// is exhausted. It is used by wrappers which buffer the start of a
+// response and can only pass ReadFrom through once they have decided how
+// the rest of the body will be written.
+func copyUntil(w io.Writer, src io.Reader, done func() bool) (int64, error) {
+	var n int64
+	buf := make([]byte, 32*1024)
+	for !done() {
+		nr, err := src.Read(buf)
+		if nr > 0 {
+			nw, werr := w.Write(buf[:nr])
+			n += int64(nw)
+			if werr != nil {
+				return n, werr
+			}
+		}
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// headWriter discards the body written by a GET handler serving a HEAD
+// request, counting its length so that an accurate Content-Length can be
+// sent once the handler returns.
+type headWriter struct {
+	http.ResponseWriter
+	status  int
+	n       int64
+	flushed bool
+}
+
+func (hw *headWriter) WriteHeader(code int) {
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		hw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if hw.status == 0 {
+		hw.status = code
+	}
+}
+
+func (hw *headWriter) Write(p []byte) (int, error) {
+	if hw.status == 0 {
+		hw.WriteHeader(http.StatusOK)
+	}
+	hw.n += int64(len(p))
+	return len(p), nil
+}
+
+func (hw *headWriter) ReadFrom(src io.Reader) (int64, error) {
+	if hw.status == 0 {
+		hw.WriteHeader(http.StatusOK)
+	}
+	n, err := io.Copy(io.Discard, src)
+	hw.n += n
+	return n, err
+}
+
+// Flush sends the headers without a Content-Length, as the length of the
+// rest of the body isn't known yet.
+func (hw *headWriter) Flush() {
+	if !hw.flushed {
+		hw.flushed = true
+		if hw.status == 0 {
+			hw.status = http.StatusOK
+		}
+		hw.ResponseWriter.WriteHeader(hw.status)
+	}
+	if f, ok := hw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter.
+func (hw *headWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
+
+func (hw *headWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hw.flushed = true
+	return hijack(hw.ResponseWriter)
+}
+
+func (hw *headWriter) finish() {
+	if hw.flushed {
+		return
+	}
+	if hw.status == 0 {
+		hw.status = http.StatusOK
+	}
+	h := hw.Header()
+	if h.Get("Content-Length") == "" && h.Get("Transfer-Encoding") == "" && bodyAllowed(hw.status) {
+		h.Set("Content-Length", strconv.FormatInt(hw.n, 10))
+	}
+	hw.ResponseWriter.WriteHeader(hw.status)
+}
+
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
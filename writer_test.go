@@ -0,0 +1,160 @@
+package stack
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// readerFromRecorder records whether io.Copy reached the underlying
+// ResponseWriter's ReadFrom method.
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+}
+
+func (rr *readerFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	rr.readFrom = true
+	return io.Copy(writerOnly{rr.ResponseRecorder}, src)
+}
+
+func TestReadFromPassthrough(t *testing.T) {
+	st := New(ETag(), Compress(CompressMinSize(1))).ThenFiles(http.Dir("testdata/static"))
+
+	r, _ := http.NewRequest("GET", "/logo.png", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	r.Header.Set("Range", "bytes=8-13")
+	rec := &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+	st.ServeHTTP(rec, r)
+
+	assertEquals(t, 206, rec.Code)
+	assertEquals(t, "binary", rec.Body.String())
+	assertEquals(t, true, rec.readFrom)
+}
+
+// fullChain returns a chain using every middleware in the package which
+// wraps the ResponseWriter.
+func fullChain() Chain {
+	codec, _ := NewCookieCodec([][]byte{testSigningKey}, nil)
+	return New(Compress(CompressMinSize(1)), ETag(), FlashMessages()).UseCookieCodec(codec).RecordResponses()
+}
+
+func TestPreserveInterfaces(t *testing.T) {
+	st := fullChain().ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, hijacker := w.(http.Hijacker)
+		_, pusher := w.(http.Pusher)
+		_, flusher := w.(http.Flusher)
+		_, readerFrom := w.(io.ReaderFrom)
+		fmt.Fprintf(w, "hijacker=%v pusher=%v flusher=%v readerFrom=%v", hijacker, pusher, flusher, readerFrom)
+	})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	st.ServeHTTP(rec, r)
+	assertEquals(t, "hijacker=false pusher=false flusher=true readerFrom=true", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	st.ServeHTTP(&pushRecorder{ResponseRecorder: rec}, r)
+	assertEquals(t, "hijacker=false pusher=true flusher=true readerFrom=true", rec.Body.String())
+
+	assertEquals(t, "hijacker=true pusher=false flusher=true readerFrom=true", serveAndRequest(st))
+}
+
+func TestWebSocketUpgradeThroughChain(t *testing.T) {
+	st := fullChain().ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		brw.Flush()
+		line, _ := brw.ReadString('\n')
+		brw.WriteString("echo: " + line)
+		brw.Flush()
+	})
+	ts := httptest.NewServer(st)
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: example.com\r\nAccept-Encoding: gzip\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 101, res.StatusCode)
+	fmt.Fprint(conn, "bish\n")
+	line, _ := br.ReadString('\n')
+	assertEquals(t, "echo: bish\n", line)
+}
+
+func TestServerSentEventsThroughChain(t *testing.T) {
+	next := make(chan struct{})
+	st := fullChain().ThenHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 2; i++ {
+			fmt.Fprintf(w, "data: %d\n\n", i)
+			w.(http.Flusher).Flush()
+			<-next
+		}
+	})
+	ts := httptest.NewServer(st)
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	// Event streams are sent uncompressed.
+	assertEquals(t, "", res.Header.Get("Content-Encoding"))
+	br := bufio.NewReader(res.Body)
+	for i := 0; i < 2; i++ {
+		// Each event must arrive before the handler is allowed to continue.
+		line, _ := br.ReadString('\n')
+		assertEquals(t, fmt.Sprintf("data: %d\n", i), line)
+		br.ReadString('\n')
+		next <- struct{}{}
+	}
+}
+
+func TestResponseControllerThroughChain(t *testing.T) {
+	errs := make(chan error, 3)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		errs <- rc.SetWriteDeadline(time.Now().Add(time.Minute))
+		errs <- rc.SetReadDeadline(time.Now().Add(time.Minute))
+		fmt.Fprint(w, "bish")
+		errs <- rc.Flush()
+	}
+	rt := NewRouter()
+	rt.Get("/", fullChain().ThenHandlerFunc(handler))
+	ts := httptest.NewServer(rt)
+	defer ts.Close()
+
+	for _, method := range []string{"GET", "HEAD"} {
+		req, _ := http.NewRequest(method, ts.URL, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		for i := 0; i < 3; i++ {
+			assertEquals(t, nil, <-errs)
+		}
+	}
+}